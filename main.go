@@ -8,10 +8,12 @@ import (
 
 	"github.com/unpoller/unpoller/pkg/poller"
 	// Load input plugins!
+	_ "github.com/unpoller/unpoller/pkg/inputreplay"
 	_ "github.com/unpoller/unpoller/pkg/inputunas"
 	_ "github.com/unpoller/unpoller/pkg/inputunifi"
 	// Load output plugins!
 	_ "github.com/unpoller/unpoller/pkg/datadogunifi"
+	_ "github.com/unpoller/unpoller/pkg/execunifi"
 	_ "github.com/unpoller/unpoller/pkg/influxunifi"
 	_ "github.com/unpoller/unpoller/pkg/lokiunifi"
 	_ "github.com/unpoller/unpoller/pkg/otelunifi"
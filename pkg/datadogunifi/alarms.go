@@ -3,7 +3,6 @@ package datadogunifi
 import (
 	"fmt"
 	"strconv"
-	"time"
 
 	"github.com/unpoller/unifi/v5"
 )
@@ -15,7 +14,7 @@ const (
 
 // batchAlarms generates alarm events and logs for Datadog.
 func (u *DatadogUnifi) batchAlarms(r report, event *unifi.Alarm) { // nolint:dupl
-	if time.Since(event.Datetime) > u.Interval.Duration+time.Second {
+	if u.tooOld(event.Datetime) {
 		return // The event is older than our interval, ignore it.
 	}
 
@@ -69,7 +68,7 @@ func (u *DatadogUnifi) batchAlarms(r report, event *unifi.Alarm) { // nolint:dup
 
 // batchAnomaly generates Anomalies from UniFi for Datadog.
 func (u *DatadogUnifi) batchAnomaly(r report, event *unifi.Anomaly) {
-	if time.Since(event.Datetime) > u.Interval.Duration+time.Second {
+	if u.tooOld(event.Datetime) {
 		return // The event is older than our interval, ignore it.
 	}
 
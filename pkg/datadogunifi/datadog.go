@@ -3,6 +3,8 @@
 package datadogunifi
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -84,12 +86,21 @@ type DatadogUnifi struct {
 	Statsd    statsd.ClientInterface
 	LastCheck time.Time
 	*Datadog
+	reload chan *Config // configs from Reload, applied by the poll loop.
+	once   bool         // writing for --once; the poller picked the events to write.
 }
 
-var _ poller.OutputPlugin = &DatadogUnifi{}
+var (
+	_ poller.OutputPlugin        = &DatadogUnifi{}
+	_ poller.ContextOutputPlugin = &DatadogUnifi{}
+	_ poller.Reloader            = &DatadogUnifi{}
+	_ poller.KindExporter        = &DatadogUnifi{}
+	_ poller.OnceOutput          = &DatadogUnifi{}
+	_ poller.InputFilter         = &DatadogUnifi{}
+)
 
 func init() { // nolint: gochecknoinits
-	u := &DatadogUnifi{Datadog: &Datadog{}, LastCheck: time.Now()}
+	u := &DatadogUnifi{Datadog: &Datadog{}, LastCheck: time.Now(), reload: make(chan *Config, 1)}
 
 	poller.NewOutput(&poller.Output{
 		Name:         "datadog",
@@ -189,6 +200,12 @@ func (u *DatadogUnifi) DebugOutput() (bool, error) {
 
 // Run runs a ticker to poll the unifi server and update Datadog.
 func (u *DatadogUnifi) Run(c poller.Collect) error {
+	return u.RunContext(context.Background(), c)
+}
+
+// RunContext is Run, but it returns once the context is canceled, after flushing
+// and closing the statsd client.
+func (u *DatadogUnifi) RunContext(ctx context.Context, c poller.Collect) error {
 	u.Collector = c
 	if !u.Enabled() {
 		u.LogDebugf("DataDog config missing (or disabled), DataDog output disabled!")
@@ -208,50 +225,239 @@ func (u *DatadogUnifi) Run(c poller.Collect) error {
 		return err
 	}
 
-	u.PollController()
+	u.PollController(ctx)
+
+	u.Logf("Flushing and closing Datadog statsd client")
+
+	if err := u.Statsd.Flush(); err != nil {
+		u.LogErrorf("Flushing statsd client: %v", err)
+	}
+
+	if err := u.Statsd.Close(); err != nil {
+		return fmt.Errorf("closing statsd client: %w", err)
+	}
 
 	return nil
 }
 
-// PollController runs forever, polling UniFi and pushing to Datadog
+// InputName returns the input this output writes. Satisfies poller.InputFilter.
+func (u *DatadogUnifi) InputName() string {
+	return "unifi"
+}
+
+// WriteOnce sends one snapshot to the agent, then flushes and closes the client,
+// for --once. Satisfies poller.OnceOutput.
+func (u *DatadogUnifi) WriteOnce(_ context.Context, c poller.Collect, snap *poller.Snapshot) error {
+	u.Collector, u.once = c, true
+	u.setConfigDefaults()
+
+	var err error
+
+	u.Statsd, err = statsd.New(u.Address, u.options...)
+	if err != nil {
+		return fmt.Errorf("configuring datadog agent reporting: %w", err)
+	}
+
+	report, _ := u.ReportMetrics(snap.Metrics, snap.Events)
+	u.LogDatadogReport(report) // sends its counts before the client is flushed.
+
+	errs := append([]error{}, report.Errors...)
+
+	if err := u.Statsd.Flush(); err != nil {
+		errs = append(errs, fmt.Errorf("flushing statsd client: %w", err))
+	}
+
+	if err := u.Statsd.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("closing statsd client: %w", err))
+	}
+
+	err = errors.Join(errs...)
+	poller.RecordWrite("datadog", report.Elapsed, report.points(), err)
+
+	return err
+}
+
+// PollController polls UniFi and pushes to Datadog until the context is canceled.
 // This is started by Run() or RunBoth() after everything is validated.
-func (u *DatadogUnifi) PollController() {
+func (u *DatadogUnifi) PollController(ctx context.Context) {
 	interval := u.Interval.Round(time.Second)
-	ticker := time.NewTicker(interval)
 	u.Logf("Everything checks out! Poller started, interval=%+v", interval)
 
-	for u.LastCheck = range ticker.C {
-		u.Collect(interval)
+	if s, ok := u.Collector.(poller.Scheduler); ok {
+		sub := &poller.Subscription{Name: "datadog", Input: u.InputName(), Interval: interval, Metrics: true, Events: true, Ack: true}
+		snaps := s.Subscribe(sub)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case config := <-u.reload:
+				if !u.applyConfig(config) {
+					return
+				}
+
+				if r, ok := s.(poller.Rescheduler); ok {
+					r.Reschedule(sub, u.Interval.Duration)
+				}
+			case snap, ok := <-snaps:
+				if !ok {
+					return
+				}
+
+				u.LastCheck = snap.Start
+				u.CollectSnapshot(snap)
+			}
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case config := <-u.reload:
+			if !u.applyConfig(config) {
+				return
+			}
+
+			interval = u.Interval.Duration
+			ticker.Reset(interval)
+		case u.LastCheck = <-ticker.C:
+			u.Collect(interval)
+		}
+	}
+}
+
+// Reload hands a new config to the poll loop, which applies it between polls.
+// Satisfies poller.Reloader.
+func (u *DatadogUnifi) Reload(config any) error {
+	c, ok := config.(*Datadog)
+	if !ok {
+		return fmt.Errorf("%w: %T", poller.ErrReloadConfigType, config)
+	}
+
+	if c.Config == nil {
+		c.Config = &Config{}
+	}
+
+	// Replace a config the poll loop has not picked up yet.
+	select {
+	case <-u.reload:
+	default:
 	}
+
+	u.reload <- c.Config
+
+	return nil
 }
 
+// applyConfig swaps in a reloaded config and a statsd client built from it, then
+// flushes and closes the old client. It runs on the poll loop, between polls.
+// Returns false if the reloaded config disables the output.
+func (u *DatadogUnifi) applyConfig(config *Config) bool {
+	if config.Enable == nil || !*config.Enable {
+		u.Logf("Datadog output disabled by a config reload")
+
+		return false
+	}
+
+	prev, client := u.Datadog, u.Statsd
+	u.Datadog = &Datadog{Config: config}
+	u.setConfigDefaults()
+
+	var err error
+
+	u.Statsd, err = statsd.New(u.Address, u.options...)
+	if err != nil {
+		u.LogErrorf("Reloading Datadog config, keeping the current one: %v", err)
+		u.Datadog, u.Statsd = prev, client
+
+		return true
+	}
+
+	if err := client.Flush(); err != nil {
+		u.LogErrorf("Flushing statsd client: %v", err)
+	}
+
+	if err := client.Close(); err != nil {
+		u.LogErrorf("Closing statsd client: %v", err)
+	}
+
+	u.Logf("Datadog config reloaded, interval: %v, address: %s", u.Interval.Duration, u.Address)
+
+	return true
+}
+
+// Collect fetches metrics and events from the input it reads and reports them to Datadog.
+// Only used when the collector does not provide a shared poll scheduler.
 func (u *DatadogUnifi) Collect(interval time.Duration) {
-	metrics, err := u.Collector.Metrics(&poller.Filter{Name: "unifi"})
+	metrics, err := u.Collector.Metrics(&poller.Filter{Name: u.InputName()})
 	if err != nil {
 		u.LogErrorf("metric fetch for Datadog failed: %v", err)
 
 		return
 	}
 
-	events, err := u.Collector.Events(&poller.Filter{Name: "unifi", Dur: interval})
+	events, err := u.Collector.Events(&poller.Filter{Name: u.InputName(), Dur: interval})
 	if err != nil {
 		u.LogErrorf("event fetch for Datadog failed", err)
 
 		return
 	}
 
+	u.report(metrics, events)
+}
+
+// CollectSnapshot reports a snapshot from the shared poll scheduler to Datadog,
+// and tells the scheduler whether its events were reported.
+func (u *DatadogUnifi) CollectSnapshot(snap *poller.Snapshot) {
+	// The events are reported when the metrics failed, and the other way around. Done
+	// only hears about the events, so failed metrics do not hold back event cursors.
+	metrics, events := snap.Metrics, snap.Events
+
+	if snap.MetricsErr != nil {
+		u.LogErrorf("metric fetch for Datadog failed: %v", snap.MetricsErr)
+		metrics = &poller.Metrics{TS: snap.Start}
+	}
+
+	if snap.EventsErr != nil {
+		u.LogErrorf("event fetch for Datadog failed: %v", snap.EventsErr)
+		events = &poller.Events{}
+	}
+
+	if snap.MetricsErr != nil && snap.EventsErr != nil {
+		snap.Done(snap.EventsErr)
+
+		return
+	}
+
+	err := u.report(metrics, events)
+	if snap.EventsErr != nil {
+		err = snap.EventsErr
+	}
+
+	snap.Done(err)
+}
+
+func (u *DatadogUnifi) report(metrics *poller.Metrics, events *poller.Events) error {
 	report, err := u.ReportMetrics(metrics, events)
+	poller.RecordWrite("datadog", report.Elapsed, report.points(), err)
+
 	if err != nil {
 		// Is the agent down?
 		u.LogErrorf("unable to report metrics and events", err)
 
 		_ = report.reportCount("unifi.collect.errors", 1, []string{})
 
-		return
+		return err
 	}
 
 	_ = report.reportCount("unifi.collect.success", 1, []string{})
 	u.LogDatadogReport(report)
+
+	return nil
 }
 
 // ReportMetrics batches all device and client data into datadog data points.
@@ -281,34 +487,10 @@ func (u *DatadogUnifi) ReportMetrics(m *poller.Metrics, e *poller.Events) (*Repo
 func (u *DatadogUnifi) loopPoints(r report) {
 	m := r.metrics()
 
-	for _, s := range m.RogueAPs {
-		u.switchExport(r, s)
-	}
-
-	for _, s := range m.Sites {
-		u.switchExport(r, s)
-	}
-
 	for _, s := range m.SitesDPI {
 		u.reportSiteDPI(r, s.(*unifi.DPITable))
 	}
 
-	for _, s := range m.Clients {
-		u.switchExport(r, s)
-	}
-
-	for _, s := range m.Devices {
-		u.switchExport(r, s)
-	}
-
-	for _, st := range m.SpeedTests {
-		u.switchExport(r, st)
-	}
-
-	for _, s := range r.events().Logs {
-		u.switchExport(r, s)
-	}
-
 	appTotal := make(totalsDPImap)
 	catTotal := make(totalsDPImap)
 
@@ -318,210 +500,73 @@ func (u *DatadogUnifi) loopPoints(r report) {
 
 	reportClientDPItotals(r, appTotal, catTotal)
 
-	for _, w := range m.WANConfigs {
-		u.switchExport(r, w)
-	}
-
-	for _, p := range m.FirewallPolicies {
-		u.switchExport(r, p)
-	}
-
-	for _, t := range m.Topologies {
-		u.switchExport(r, t)
-	}
-
-	for _, a := range m.PortAnomalies {
-		u.switchExport(r, a)
-	}
-
-	for _, v := range m.VPNMeshes {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.PortForwards {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.SSLCertificates {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.UPSDevices {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.UNASDevices {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.WANStatuses {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.IntegrationDevStats {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.WifiBroadcasts {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.FirewallZones {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.ACLRules {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.VPNServers {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.SiteToSiteTunnels {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.LAGs {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.MCLAGDomains {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.SwitchStacks {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.DNSPolicies {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.RADIUSProfiles {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.TrafficMatchingLists {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.HotspotVouchers {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.DPIApplications {
-		u.switchExport(r, v)
-	}
-
-	for _, v := range m.DPICategories {
-		u.switchExport(r, v)
+	for _, v := range u.handlers().Export(r, append(m.Collections(), r.events().Logs)...) {
+		if u.Collector != nil && u.Collector.Poller().LogUnknownTypes {
+			u.LogDebugf("unknown export type: %T", v)
+		}
 	}
 
-	for _, v := range m.PendingDevices {
-		u.switchExport(r, v)
-	}
+	u.batchTelemetry(r, poller.GetTelemetry())
+}
 
-	for _, v := range m.Countries {
-		u.switchExport(r, v)
-	}
+// handlers returns the report method for each type this output exports.
+// DPI tables are reported in loopPoints, as sites and clients are reported differently.
+func (u *DatadogUnifi) handlers() *poller.Handlers[report] {
+	h := &poller.Handlers[report]{}
+	poller.Handle(h, u.batchRogueAP)
+	poller.Handle(h, u.batchUAP)
+	poller.Handle(h, u.batchUSW)
+	poller.Handle(h, u.batchPDU)
+	poller.Handle(h, u.batchUSG)
+	poller.Handle(h, u.batchUXG)
+	poller.Handle(h, u.batchUDM)
+	poller.Handle(h, u.batchUBB)
+	poller.Handle(h, u.batchUCI)
+	poller.Handle(h, u.batchUDB)
+	poller.Handle(h, u.reportSite)
+	poller.Handle(h, u.batchClient)
+	poller.Handle(h, u.batchEvent)
+	poller.Handle(h, u.batchClientEvent)
+	poller.Handle(h, u.batchDeviceEvent)
+	poller.Handle(h, u.batchIDs)
+	poller.Handle(h, u.batchAlarms)
+	poller.Handle(h, u.batchAnomaly)
+	poller.Handle(h, u.batchSpeedTest)
+	poller.Handle(h, u.batchWAN)
+	poller.Handle(h, u.batchFirewallPolicy)
+	poller.Handle(h, u.batchTopology)
+	poller.Handle(h, u.batchPortAnomaly)
+	poller.Handle(h, u.batchMagicSiteToSiteVPN)
+	poller.Handle(h, u.batchPortForward)
+	poller.Handle(h, u.batchSSLCertificate)
+	poller.Handle(h, u.batchUPSDevice)
+	poller.Handle(h, u.batchUNASDevice)
+	poller.Handle(h, u.batchWANStatus)
+	poller.Handle(h, u.batchIntegrationDevStats)
+	poller.Handle(h, u.batchWifiBroadcast)
+	poller.Handle(h, u.batchFirewallZone)
+	poller.Handle(h, u.batchACLRule)
+	poller.Handle(h, u.batchVPNServer)
+	poller.Handle(h, u.batchSiteToSiteTunnel)
+	poller.Handle(h, u.batchLAG)
+	poller.Handle(h, u.batchMCLAGDomain)
+	poller.Handle(h, u.batchSwitchStack)
+	poller.Handle(h, u.batchDNSPolicy)
+	poller.Handle(h, u.batchRADIUSProfile)
+	poller.Handle(h, u.batchTrafficMatchingList)
+	poller.Handle(h, u.batchHotspotVoucher)
+	poller.Handle(h, u.batchClientInventory)
+	poller.Handle(h, u.batchDPIApplication)
+	poller.Handle(h, u.batchDPICategory)
+	poller.Handle(h, u.batchPendingDevice)
+	poller.Handle(h, u.batchCountry)
+
+	return h
 }
 
-func (u *DatadogUnifi) switchExport(r report, v any) { //nolint:cyclop
-	switch v := v.(type) {
-	case *unifi.RogueAP:
-		u.batchRogueAP(r, v)
-	case *unifi.UAP:
-		u.batchUAP(r, v)
-	case *unifi.USW:
-		u.batchUSW(r, v)
-	case *unifi.PDU:
-		u.batchPDU(r, v)
-	case *unifi.USG:
-		u.batchUSG(r, v)
-	case *unifi.UXG:
-		u.batchUXG(r, v)
-	case *unifi.UDM:
-		u.batchUDM(r, v)
-	case *unifi.UBB:
-		u.batchUBB(r, v)
-	case *unifi.UCI:
-		u.batchUCI(r, v)
-	case *unifi.UDB:
-		u.batchUDB(r, v)
-	case *unifi.Site:
-		u.reportSite(r, v)
-	case *unifi.Client:
-		u.batchClient(r, v)
-	case *unifi.Event:
-		u.batchEvent(r, v)
-	case *unifi.IDS:
-		u.batchIDs(r, v)
-	case *unifi.Alarm:
-		u.batchAlarms(r, v)
-	case *unifi.Anomaly:
-		u.batchAnomaly(r, v)
-	case *unifi.SpeedTestResult:
-		u.batchSpeedTest(r, v)
-	case *unifi.WANEnrichedConfiguration:
-		u.batchWAN(r, v)
-	case *unifi.FirewallPolicy:
-		u.batchFirewallPolicy(r, v)
-	case *unifi.Topology:
-		u.batchTopology(r, v)
-	case *unifi.PortAnomaly:
-		u.batchPortAnomaly(r, v)
-	case *unifi.MagicSiteToSiteVPN:
-		u.batchMagicSiteToSiteVPN(r, v)
-	case *unifi.PortForward:
-		u.batchPortForward(r, v)
-	case *unifi.SSLCertificate:
-		u.batchSSLCertificate(r, v)
-	case *unifi.UPSDeviceSelector:
-		u.batchUPSDevice(r, v)
-	case *unifi.UNASDevice:
-		u.batchUNASDevice(r, v)
-	case *unifi.WANStatus:
-		u.batchWANStatus(r, v)
-	case *unifi.IntegrationDeviceStats:
-		u.batchIntegrationDevStats(r, v)
-	case *unifi.WifiBroadcast:
-		u.batchWifiBroadcast(r, v)
-	case *unifi.FirewallZone:
-		u.batchFirewallZone(r, v)
-	case *unifi.ACLRule:
-		u.batchACLRule(r, v)
-	case *unifi.VPNServer:
-		u.batchVPNServer(r, v)
-	case *unifi.SiteToSiteTunnel:
-		u.batchSiteToSiteTunnel(r, v)
-	case *unifi.LAG:
-		u.batchLAG(r, v)
-	case *unifi.MCLAGDomain:
-		u.batchMCLAGDomain(r, v)
-	case *unifi.SwitchStack:
-		u.batchSwitchStack(r, v)
-	case *unifi.DNSPolicy:
-		u.batchDNSPolicy(r, v)
-	case *unifi.RADIUSProfile:
-		u.batchRADIUSProfile(r, v)
-	case *unifi.TrafficMatchingList:
-		u.batchTrafficMatchingList(r, v)
-	case *unifi.HotspotVoucher:
-		u.batchHotspotVoucher(r, v)
-	case *unifi.DPIApplication:
-		u.batchDPIApplication(r, v)
-	case *unifi.DPICategory:
-		u.batchDPICategory(r, v)
-	case *unifi.PendingDevice:
-		u.batchPendingDevice(r, v)
-	case *unifi.Country:
-		u.batchCountry(r, v)
-	default:
-		if u.Collector != nil && u.Collector.Poller().LogUnknownTypes {
-			u.LogDebugf("unknown export type: %T", v)
-		}
-	}
+// ExportedKinds satisfies poller.KindExporter.
+func (u *DatadogUnifi) ExportedKinds() []poller.MetricKind {
+	return append(u.handlers().Kinds(), poller.KindOf(&unifi.DPITable{}))
 }
 
 // LogDatadogReport writes a log message after exporting to Datadog.
@@ -6,17 +6,20 @@ import (
 	"time"
 
 	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
 )
 
 // These constants are used as names for printed/logged counters.
 const (
-	eventT = item("Event")
-	idsT   = item("IDs")
+	eventT       = item("Event")
+	idsT         = item("IDs")
+	clientEventT = item("ClientEvent")
+	deviceEventT = item("DeviceEvent")
 )
 
 // batchIDs generates intrusion detection datapoints for Datadog.
 func (u *DatadogUnifi) batchIDs(r report, i *unifi.IDS) { // nolint:dupl
-	if time.Since(i.Datetime) > u.Interval.Duration+time.Second {
+	if u.tooOld(i.Datetime) {
 		return // The event is older than our interval, ignore it.
 	}
 
@@ -70,7 +73,7 @@ func (u *DatadogUnifi) batchIDs(r report, i *unifi.IDS) { // nolint:dupl
 
 // batchEvents generates events from UniFi for Datadog.
 func (u *DatadogUnifi) batchEvent(r report, i *unifi.Event) { // nolint: funlen
-	if time.Since(i.Datetime) > u.Interval.Duration+time.Second {
+	if u.tooOld(i.Datetime) {
 		return // The event is older than our interval, ignore it.
 	}
 
@@ -141,3 +144,71 @@ func (u *DatadogUnifi) batchEvent(r report, i *unifi.Event) { // nolint: funlen
 	_ = r.reportEvent(title, i.Datetime, i.Msg, tags)
 	r.reportInfoLog(fmt.Sprintf("[%d] %s: %s - %s", i.Datetime.Unix(), title, i.Msg, tagMapToSimpleStrings(tagMap)))
 }
+
+// tooOld reports whether an event is older than the interval, so it was sent on an
+// earlier poll. With event cursors on, the input returns each event once, so none are.
+// Neither are any with --once, which collects events from its own window.
+func (u *DatadogUnifi) tooOld(t time.Time) bool {
+	return !u.once && !poller.EventCursors() && time.Since(t) > u.Interval.Duration+time.Second
+}
+
+// batchClientEvent generates client session events for Datadog. The input returns
+// each of these once, so unlike controller events they are never too old.
+func (u *DatadogUnifi) batchClientEvent(r report, e *poller.ClientEvent) {
+	tagMap := map[string]string{
+		"type":         e.Type,
+		"site_name":    e.SiteName,
+		"source":       e.SourceName,
+		"mac":          e.Mac,
+		"name":         e.Name,
+		"hostname":     e.Hostname,
+		"ip":           e.IP,
+		"from_ip":      e.FromIP,
+		"is_wired":     strconv.FormatBool(e.Wired),
+		"ssid":         e.SSID,
+		"ap_name":      e.ApName,
+		"from_ap_name": e.FromApName,
+		"band":         e.Band,
+		"from_band":    e.FromBand,
+	}
+
+	if e.Type == poller.ClientDisconnected {
+		tagMap["duration"] = strconv.FormatInt(e.Duration, 10)
+	}
+
+	r.addCount(clientEventT)
+
+	tagMap = cleanTags(tagMap)
+	tags := tagMapToTags(tagMap)
+	title := fmt.Sprintf("Unifi Client Event at %s from %s", e.SiteName, e.SourceName)
+	_ = r.reportEvent(title, e.Time, e.Msg(), tags)
+	r.reportInfoLog(fmt.Sprintf("[%d] %s: %s - %s", e.Time.Unix(), title, e.Msg(), tagMapToSimpleStrings(tagMap)))
+}
+
+// batchDeviceEvent generates device lifecycle events for Datadog. The input returns
+// each of these once, so unlike controller events they are never too old.
+func (u *DatadogUnifi) batchDeviceEvent(r report, e *poller.DeviceEvent) {
+	tagMap := map[string]string{
+		"type":         e.Type,
+		"site_name":    e.SiteName,
+		"source":       e.SourceName,
+		"mac":          e.Mac,
+		"name":         e.Name,
+		"model":        e.Model,
+		"device_type":  e.DeviceType,
+		"version":      e.Version,
+		"from_version": e.FromVersion,
+		"state":        e.State,
+		"from_state":   e.FromState,
+		"uplink":       e.Uplink,
+		"from_uplink":  e.FromUplink,
+	}
+
+	r.addCount(deviceEventT)
+
+	tagMap = cleanTags(tagMap)
+	tags := tagMapToTags(tagMap)
+	title := fmt.Sprintf("Unifi Device Event at %s from %s", e.SiteName, e.SourceName)
+	_ = r.reportEvent(title, e.Time, e.Msg(), tags)
+	r.reportInfoLog(fmt.Sprintf("[%d] %s: %s - %s", e.Time.Unix(), title, e.Msg(), tagMapToSimpleStrings(tagMap)))
+}
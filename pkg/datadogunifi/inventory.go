@@ -0,0 +1,18 @@
+package datadogunifi
+
+import (
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// batchClientInventory generates the known and new connected client counts
+// per site, from the input's client inventory, for Datadog.
+func (u *DatadogUnifi) batchClientInventory(r report, s *poller.ClientInventory) {
+	metricName := metricNamespace("client_inventory")
+	tags := []string{
+		tag("site_name", s.SiteName),
+		tag("source", s.SourceName),
+	}
+
+	_ = r.reportGauge(metricName("known"), float64(s.Known), tags)
+	_ = r.reportGauge(metricName("new"), float64(s.New), tags)
+}
@@ -1,6 +1,9 @@
 package datadogunifi
 
 import (
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -91,20 +94,78 @@ func (r *Report) error(err error) {
 	}
 }
 
+// point counts one datapoint sent to the agent.
+func (r *Report) point() {
+	r.Counts.Lock()
+	defer r.Counts.Unlock()
+
+	r.Total++
+}
+
+// points returns how many datapoints were sent.
+func (r *Report) points() int {
+	return r.Total
+}
+
+// withLabels appends the static labels the poller's relabel rules added for the
+// controller and site in tags. Tags already present are not duplicated.
+func (r *Report) withLabels(tags []string) []string {
+	var source, site string
+
+	for _, t := range tags {
+		if v, ok := strings.CutPrefix(t, "source:"); ok {
+			source = v
+		} else if v, ok := strings.CutPrefix(t, "site_name:"); ok {
+			site = v
+		}
+	}
+
+	var labels map[string]string
+	if r.Metrics != nil {
+		labels = r.Metrics.Labels.For(source, site)
+	}
+
+	if labels == nil && r.Events != nil {
+		labels = r.Events.Labels.For(source, site)
+	}
+
+	if len(labels) == 0 {
+		return tags
+	}
+
+	out := slices.Clip(tags)
+
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		if !slices.ContainsFunc(tags, func(t string) bool { return strings.HasPrefix(t, k+":") }) {
+			out = append(out, tag(k, labels[k]))
+		}
+	}
+
+	return out
+}
+
 func (r *Report) reportGauge(name string, value float64, tags []string) error {
-	return r.client.Gauge(name, value, tags, 1.0)
+	r.point()
+
+	return r.client.Gauge(name, value, r.withLabels(tags), 1.0)
 }
 
 func (r *Report) reportCount(name string, value int64, tags []string) error {
-	return r.client.Count(name, value, tags, 1.0)
+	r.point()
+
+	return r.client.Count(name, value, r.withLabels(tags), 1.0)
 }
 
 func (r *Report) reportDistribution(name string, value float64, tags []string) error {
-	return r.client.Distribution(name, value, tags, 1.0)
+	r.point()
+
+	return r.client.Distribution(name, value, r.withLabels(tags), 1.0)
 }
 
 func (r *Report) reportTiming(name string, value time.Duration, tags []string) error {
-	return r.client.Timing(name, value, tags, 1.0)
+	r.point()
+
+	return r.client.Timing(name, value, r.withLabels(tags), 1.0)
 }
 
 func (r *Report) reportEvent(title string, date time.Time, message string, tags []string) error {
@@ -116,7 +177,7 @@ func (r *Report) reportEvent(title string, date time.Time, message string, tags
 		Title:     title,
 		Text:      message,
 		Timestamp: date,
-		Tags:      tags,
+		Tags:      r.withLabels(tags),
 	})
 }
 
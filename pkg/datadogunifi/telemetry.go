@@ -0,0 +1,36 @@
+package datadogunifi
+
+import (
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// batchTelemetry generates datapoints for the poller's own telemetry: input polls,
+// requests to controllers, and output writes. The counts and totals are running totals.
+func (u *DatadogUnifi) batchTelemetry(r report, t *poller.Telemetry) {
+	for _, p := range t.Polls {
+		tags := map[string]string{"input": p.Input, "kind": p.Kind}
+		reportGaugeForFloat64Map(r, metricNamespace("poller.poll"), telemetryData(&p.CallStats), tags)
+	}
+
+	for _, q := range t.Requests {
+		tags := map[string]string{"input": q.Input, "controller": q.Controller, "endpoint": q.Endpoint}
+		reportGaugeForFloat64Map(r, metricNamespace("poller.request"), telemetryData(&q.CallStats), tags)
+	}
+
+	for _, w := range t.Writes {
+		data := telemetryData(&w.CallStats)
+		data["points"] = float64(w.Points)
+
+		reportGaugeForFloat64Map(r, metricNamespace("poller.write"), data, map[string]string{"output": w.Output})
+	}
+}
+
+func telemetryData(s *poller.CallStats) map[string]float64 {
+	return map[string]float64{
+		"count":         float64(s.Count),
+		"errors":        float64(s.Errors),
+		"total_seconds": s.Total.Seconds(),
+		"last_seconds":  s.Last.Seconds(),
+		"max_seconds":   s.Max.Seconds(),
+	}
+}
@@ -0,0 +1,61 @@
+package datadogunifi
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+var (
+	ErrIntervalLow   = fmt.Errorf("interval is below the minimum of %v, and is raised to it", minimumInterval)
+	ErrNegativeValue = errors.New("must not be negative")
+)
+
+var (
+	_ poller.ConfigChecker = &DatadogUnifi{}
+	_ poller.ConfigPrinter = &DatadogUnifi{}
+)
+
+// CheckConfig returns a problem for each configured value that cannot work, or that
+// setConfigDefaults would quietly change. Satisfies poller.ConfigChecker.
+func (u *DatadogUnifi) CheckConfig() []error {
+	if !u.Enabled() {
+		return nil
+	}
+
+	var errs []error
+
+	if u.Interval.Duration < 0 || (u.Interval.Duration > 0 && u.Interval.Duration < minimumInterval) {
+		errs = append(errs, fmt.Errorf("%w: %v", ErrIntervalLow, u.Interval))
+	}
+
+	for _, opt := range []struct {
+		name  string
+		value *int
+	}{
+		{"max_bytes_per_payload", u.MaxBytesPerPayload},
+		{"max_messages_per_payload", u.MaxMessagesPerPayload},
+		{"buffer_pool_size", u.BufferPoolSize},
+		{"sender_queue_size", u.SenderQueueSize},
+		{"channel_mode_buffer_size", u.ChannelModeBufferSize},
+	} {
+		if opt.value != nil && *opt.value < 0 {
+			errs = append(errs, fmt.Errorf("%s %w: %d", opt.name, ErrNegativeValue, *opt.value))
+		}
+	}
+
+	return errs
+}
+
+// EffectiveConfig returns the config with defaults applied. There are no secrets in it.
+// Satisfies poller.ConfigPrinter.
+func (u *DatadogUnifi) EffectiveConfig() any {
+	if u.Config == nil {
+		return Config{}
+	}
+
+	u.setConfigDefaults()
+
+	return *u.Config
+}
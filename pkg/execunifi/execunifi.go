@@ -0,0 +1,233 @@
+// Package execunifi runs output and input plugins as separate programs. Each configured
+// output is started by the poller and sent every poll's metrics and events as JSON on its
+// stdin; it answers with logs, health and write results on its stdout. Each input is asked
+// for metrics and events on every poll, and answers with them. See protocol.go.
+//
+// Unlike Go plugins (.so files), these need not be built with the poller's toolchain or
+// source, may be written in any language, and work on every OS the poller runs on.
+package execunifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golift.io/cnfg"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/webserver"
+)
+
+// PluginName is the name of this plugin.
+const PluginName = "exec"
+
+const (
+	defaultInterval     = 30 * time.Second
+	minimumInterval     = 10 * time.Second
+	defaultRestartDelay = 10 * time.Second
+)
+
+// Config defines the external plugins to run.
+type Config struct {
+	Enable  bool      `json:"enable" toml:"enable" xml:"enable,attr" yaml:"enable"`
+	Plugins []*Plugin `json:"plugin" toml:"plugin" xml:"plugin"      yaml:"plugin"`
+	Inputs  []*Plugin `json:"input"  toml:"input"  xml:"input"       yaml:"input"`
+}
+
+// Plugin is one external plugin: a program the poller starts and sends data to, or,
+// for an input, asks for data.
+type Plugin struct {
+	// Name identifies the plugin in logs, telemetry and the web interface.
+	// Defaults to the command's file name.
+	Name string `json:"name" toml:"name" xml:"name,attr" yaml:"name"`
+	// Command is the program to run, and Args its arguments. No shell is used.
+	Command string   `json:"command" toml:"command" xml:"command" yaml:"command"`
+	Args    []string `json:"args"    toml:"args"    xml:"arg"     yaml:"args"`
+	// Env is the program's environment, with PATH, HOME, TZ and LANG from the poller's.
+	// Values may be secret references, like env://NAME or file:///path.
+	Env map[string]string `json:"env" toml:"env" xml:"env" yaml:"env"`
+	// Config is sent to the program in its hello message.
+	Config map[string]string `json:"config" toml:"config" xml:"config" yaml:"config"`
+	// Interval is how often an output is sent a snapshot, and how long an input has
+	// to answer a request for metrics or events.
+	Interval cnfg.Duration `json:"interval" toml:"interval" xml:"interval" yaml:"interval"`
+	// RestartDelay is how long to wait before starting the program again after it exits.
+	RestartDelay cnfg.Duration `json:"restart_delay" toml:"restart_delay" xml:"restart_delay" yaml:"restart_delay"`
+	// Disable skips this plugin.
+	Disable bool `json:"disable" toml:"disable" xml:"disable,attr" yaml:"disable"`
+}
+
+// Exec wraps the config for nested TOML/JSON/YAML config file support.
+type Exec struct {
+	*Config `json:"exec" toml:"exec" xml:"exec" yaml:"exec"`
+}
+
+// ExecOutput is the working struct for this plugin.
+type ExecOutput struct {
+	Collector poller.Collect
+	*Exec
+}
+
+var (
+	_ poller.OutputPlugin        = &ExecOutput{}
+	_ poller.ContextOutputPlugin = &ExecOutput{}
+	_ poller.KindExporter        = &ExecOutput{}
+	_ poller.OnceOutput          = &ExecOutput{}
+)
+
+func init() { //nolint:gochecknoinits
+	u := &ExecOutput{Exec: &Exec{Config: &Config{}}}
+
+	poller.NewOutput(&poller.Output{
+		Name:         PluginName,
+		Config:       u.Exec,
+		OutputPlugin: u,
+	})
+
+	in := &ExecInput{Exec: &Exec{Config: &Config{}}}
+
+	poller.NewInput(&poller.InputPlugin{
+		Name:   PluginName,
+		Config: in.Exec,
+		Input:  in,
+	})
+}
+
+// Enabled returns true when the plugin is enabled and has plugins to run.
+func (u *ExecOutput) Enabled() bool {
+	if u == nil || u.Config == nil {
+		return false
+	}
+
+	return u.Enable && len(u.Plugins) > 0
+}
+
+// DebugOutput checks that every plugin's program can be found.
+func (u *ExecOutput) DebugOutput() (bool, error) {
+	if !u.Enabled() {
+		return true, nil
+	}
+
+	u.setConfigDefaults()
+
+	for _, p := range u.Plugins {
+		if p.Disable {
+			continue
+		}
+
+		if _, err := exec.LookPath(p.Command); err != nil {
+			return false, fmt.Errorf("exec plugin %s: %w", p.Name, err)
+		}
+	}
+
+	return true, nil
+}
+
+// ExportedKinds returns every registered kind: plugins are sent all of the metrics.
+// Satisfies poller.KindExporter.
+func (u *ExecOutput) ExportedKinds() []poller.MetricKind {
+	return poller.MetricKinds()
+}
+
+// Run is the main loop called by the poller core.
+func (u *ExecOutput) Run(c poller.Collect) error {
+	return u.RunContext(context.Background(), c)
+}
+
+// RunContext starts every plugin and keeps them running until the context is canceled.
+// Then each plugin is asked to shut down, and given the shutdown_timeout to exit.
+func (u *ExecOutput) RunContext(ctx context.Context, c poller.Collect) error {
+	u.Collector = c
+
+	if !u.Enabled() {
+		u.LogDebugf("Exec plugins not configured (or disabled), exec output disabled!")
+
+		return nil
+	}
+
+	u.setConfigDefaults()
+	webserver.UpdateOutput(&webserver.Output{Name: PluginName, Config: u.redacted()})
+
+	var wg sync.WaitGroup
+
+	for _, p := range u.Plugins {
+		if p.Disable {
+			u.Logf("Exec plugin %s disabled", p.Name)
+
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(p *plugin) {
+			defer wg.Done()
+			p.run(ctx)
+		}(newPlugin(u, p))
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// WriteOnce starts every plugin, sends each the snapshot and stops it once it replied
+// that the snapshot was written, for --once. Satisfies poller.OnceOutput.
+func (u *ExecOutput) WriteOnce(ctx context.Context, c poller.Collect, snap *poller.Snapshot) error {
+	u.Collector = c
+	u.setConfigDefaults()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	for _, p := range u.Plugins {
+		if p.Disable {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(p *plugin) {
+			defer wg.Done()
+
+			if err := p.once(ctx, snap); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("exec plugin %s: %w", p.Name, err))
+				mu.Unlock()
+			}
+		}(newPlugin(u, p))
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// setConfigDefaults fills in the name, interval and restart delay of each plugin.
+func (c *Config) setConfigDefaults() {
+	for _, p := range append(slices.Clip(c.Plugins), c.Inputs...) {
+		if p.Name == "" {
+			p.Name = strings.TrimSuffix(filepath.Base(p.Command), filepath.Ext(p.Command))
+		}
+
+		if p.Interval.Duration == 0 {
+			p.Interval.Duration = defaultInterval
+		} else if p.Interval.Duration < minimumInterval {
+			p.Interval.Duration = minimumInterval
+		}
+
+		p.Interval.Duration = p.Interval.Round(time.Second)
+
+		if p.RestartDelay.Duration <= 0 {
+			p.RestartDelay.Duration = defaultRestartDelay
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package execunifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/webserver"
+)
+
+// ErrNoRawMetrics is returned for --dumpjson: input plugins answer with metrics, not
+// with the raw output of an API.
+var ErrNoRawMetrics = errors.New("exec inputs have no raw metrics")
+
+// ExecInput asks input plugins for metrics and events on every poll.
+type ExecInput struct {
+	*Exec
+	Logger poller.Logger
+
+	plugins []*plugin
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+var (
+	_ poller.Input        = &ExecInput{}
+	_ poller.ContextInput = &ExecInput{}
+	_ poller.Closer       = &ExecInput{}
+)
+
+// answer is one input plugin's answer to a request.
+type answer struct {
+	plugin *plugin
+	reply  *reply
+}
+
+// enabled returns true when the plugin is enabled and has inputs to run.
+func (u *ExecInput) enabled() bool {
+	return u.Config != nil && u.Enable && len(u.Inputs) > 0
+}
+
+// Initialize starts every input plugin. They run until Close.
+// Satisfies poller.Input interface.
+func (u *ExecInput) Initialize(l poller.Logger) error {
+	if u.Config == nil {
+		u.Config = &Config{}
+	}
+
+	if u.Logger = l; !u.enabled() {
+		return nil
+	}
+
+	u.setConfigDefaults()
+	webserver.UpdateInput(&webserver.Input{Name: PluginName, Config: u.redacted()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	u.cancel = cancel
+
+	for _, p := range u.Inputs {
+		if p.Disable {
+			u.Logf("Exec input %s disabled", p.Name)
+
+			continue
+		}
+
+		p := newInputPlugin(u, p)
+		u.plugins = append(u.plugins, p)
+		u.running.Add(1)
+
+		go func() {
+			defer u.running.Done()
+			p.run(ctx)
+		}()
+	}
+
+	return nil
+}
+
+// DebugInput checks that every input plugin's program can be found.
+// Satisfies poller.Input interface.
+func (u *ExecInput) DebugInput() (bool, error) {
+	if !u.enabled() {
+		return true, nil
+	}
+
+	u.setConfigDefaults()
+
+	for _, p := range u.Inputs {
+		if p.Disable {
+			continue
+		}
+
+		if _, err := exec.LookPath(p.Command); err != nil {
+			return false, fmt.Errorf("exec input %s: %w", p.Name, err)
+		}
+	}
+
+	return true, nil
+}
+
+// Metrics asks every input plugin for its metrics. Satisfies poller.Input interface.
+func (u *ExecInput) Metrics(filter *poller.Filter) (*poller.Metrics, error) {
+	return u.MetricsContext(context.Background(), filter)
+}
+
+// MetricsContext is Metrics with a context. The metrics of the plugins that answered
+// are returned with the errors of those that did not.
+func (u *ExecInput) MetricsContext(ctx context.Context, _ *poller.Filter) (*poller.Metrics, error) {
+	if !u.enabled() || len(u.plugins) == 0 {
+		return nil, nil
+	}
+
+	answers, err := u.askAll(ctx, func() *request { return &request{Type: msgMetrics} })
+	errs := []error{err}
+	metrics := &poller.Metrics{}
+
+	for _, a := range answers {
+		m, err := newMetrics(a.reply)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("exec input %s: %w", a.plugin.Name, err))
+		}
+
+		metrics = poller.AppendMetrics(metrics, m)
+	}
+
+	return metrics, errors.Join(errs...)
+}
+
+// Events asks every input plugin for the events since the filter's duration.
+// Satisfies poller.Input interface.
+func (u *ExecInput) Events(filter *poller.Filter) (*poller.Events, error) {
+	return u.EventsContext(context.Background(), filter)
+}
+
+// EventsContext is Events with a context, and returns partial results like MetricsContext.
+func (u *ExecInput) EventsContext(ctx context.Context, filter *poller.Filter) (*poller.Events, error) {
+	if !u.enabled() || len(u.plugins) == 0 {
+		return &poller.Events{}, nil
+	}
+
+	var since *time.Time
+
+	if filter != nil && filter.Dur > 0 {
+		t := time.Now().Add(-filter.Dur)
+		since = &t
+	}
+
+	answers, err := u.askAll(ctx, func() *request { return &request{Type: msgEvents, Since: since} })
+	errs := []error{err}
+	events := &poller.Events{}
+
+	for _, a := range answers {
+		e, err := newEvents(a.reply)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("exec input %s: %w", a.plugin.Name, err))
+		}
+
+		events.Logs = append(events.Logs, e.Logs...)
+	}
+
+	return events, errors.Join(errs...)
+}
+
+// RawMetrics is not supported: there is no raw API output. Satisfies poller.Input interface.
+func (u *ExecInput) RawMetrics(_ *poller.Filter) ([]byte, error) {
+	return nil, ErrNoRawMetrics
+}
+
+// askAll sends every input plugin a request at once, and returns the answers of those
+// that answered. An answer with an error is returned too, for what else it holds.
+func (u *ExecInput) askAll(ctx context.Context, newRequest func() *request) ([]*answer, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		answers []*answer
+		errs    []error
+	)
+
+	for _, p := range u.plugins {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			msg, err := p.ask(ctx, newRequest())
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("exec input %s: %w", p.Name, err))
+			}
+
+			if msg != nil {
+				answers = append(answers, &answer{plugin: p, reply: msg})
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return answers, errors.Join(errs...)
+}
+
+// Close stops every input plugin. Called once by the poller core on shutdown.
+func (u *ExecInput) Close(ctx context.Context) error {
+	if u.cancel == nil {
+		return nil
+	}
+
+	u.cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		u.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("stopping exec inputs: %w", ctx.Err())
+	}
+}
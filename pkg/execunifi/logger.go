@@ -0,0 +1,93 @@
+package execunifi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/webserver"
+)
+
+// Logf logs an informational message.
+func (u *ExecOutput) Logf(msg string, v ...any) {
+	logf(u.Collector, webserver.NewOutputEvent, PluginName, "info", msg, v...)
+}
+
+// LogErrorf logs an error message.
+func (u *ExecOutput) LogErrorf(msg string, v ...any) {
+	logf(u.Collector, webserver.NewOutputEvent, PluginName, "error", msg, v...)
+}
+
+// LogDebugf logs a debug message.
+func (u *ExecOutput) LogDebugf(msg string, v ...any) {
+	logf(u.Collector, webserver.NewOutputEvent, PluginName, "debug", msg, v...)
+}
+
+// Logf logs an informational message.
+func (u *ExecInput) Logf(msg string, v ...any) {
+	logf(u.Logger, webserver.NewInputEvent, PluginName, "info", msg, v...)
+}
+
+// LogErrorf logs an error message.
+func (u *ExecInput) LogErrorf(msg string, v ...any) {
+	logf(u.Logger, webserver.NewInputEvent, PluginName, "error", msg, v...)
+}
+
+// LogDebugf logs a debug message.
+func (u *ExecInput) LogDebugf(msg string, v ...any) {
+	logf(u.Logger, webserver.NewInputEvent, PluginName, "debug", msg, v...)
+}
+
+// Logf logs an informational message. On the web interface, messages are grouped by plugin.
+func (p *plugin) Logf(msg string, v ...any) {
+	logf(p.logger, p.newEvent(), p.Name, "info", msg, v...)
+}
+
+// LogErrorf logs an error message.
+func (p *plugin) LogErrorf(msg string, v ...any) {
+	logf(p.logger, p.newEvent(), p.Name, "error", msg, v...)
+}
+
+// LogDebugf logs a debug message.
+func (p *plugin) LogDebugf(msg string, v ...any) {
+	logf(p.logger, p.newEvent(), p.Name, "debug", msg, v...)
+}
+
+// newEvent returns the web interface's event log for the plugin's role.
+func (p *plugin) newEvent() func(name, id string, event *webserver.Event) {
+	if p.role == roleInput {
+		return webserver.NewInputEvent
+	}
+
+	return webserver.NewOutputEvent
+}
+
+// count adds to one of the plugin's counters on the web interface.
+func (p *plugin) count(label string, value int64) {
+	if p.role == roleInput {
+		webserver.UpdateInputCounter(PluginName, p.Name+" "+label, value)
+	} else {
+		webserver.UpdateOutputCounter(PluginName, p.Name+" "+label, value)
+	}
+}
+
+func logf(l poller.Logger, newEvent func(name, id string, event *webserver.Event), id, level, msg string, v ...any) {
+	newEvent(PluginName, id, &webserver.Event{
+		Ts:   time.Now(),
+		Msg:  fmt.Sprintf(msg, v...),
+		Tags: map[string]string{"type": level},
+	})
+
+	if l == nil {
+		return
+	}
+
+	switch level {
+	case "error":
+		l.LogErrorf(msg, v...)
+	case "debug":
+		l.LogDebugf(msg, v...)
+	default:
+		l.Logf(msg, v...)
+	}
+}
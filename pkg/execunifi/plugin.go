@@ -0,0 +1,594 @@
+package execunifi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// maxLine is the longest line a plugin may write to stdout or stderr.
+const maxLine = 1024 * 1024
+
+var (
+	errNotRunning = errors.New("plugin is not running")
+	errExited     = errors.New("plugin exited before replying")
+	errNoAnswer   = errors.New("plugin did not answer")
+)
+
+// plugin runs one external program. An output is sent snapshots while it runs, and an
+// input is asked for metrics and events.
+type plugin struct {
+	*Plugin
+	role    string         // roleOutput or roleInput.
+	collect poller.Collect // polls the inputs for an output without a poll scheduler. nil for inputs.
+	logger  poller.Logger
+	flush   func() (context.Context, context.CancelFunc) // bounds the wait for the program to exit.
+
+	writing sync.Mutex // held while a message is written to the program.
+	asking  sync.Mutex // held while an input is waiting for an answer.
+	mu      sync.Mutex // protects the fields below.
+	proc    *process
+	sent    []*sent     // the snapshots without a written reply, in the order they were sent.
+	asked   int64       // the id of the last request sent to an input.
+	answer  chan *reply // gets the answer to the request with the asked id. nil when none is waiting.
+	healthy bool
+	status  string
+	results chan error // gets the result of each written reply, for --once. May be nil.
+}
+
+// sent is a snapshot sent to the program, and when.
+type sent struct {
+	at   time.Time
+	snap *poller.Snapshot
+}
+
+// process is one run of a plugin's program.
+type process struct {
+	cmd   *exec.Cmd
+	stdin *os.File
+	enc   *json.Encoder
+	done  chan struct{} // closed once the program exited; err is set first.
+	err   error
+}
+
+func newPlugin(u *ExecOutput, p *Plugin) *plugin {
+	return &plugin{
+		Plugin:  p,
+		role:    roleOutput,
+		collect: u.Collector,
+		logger:  u.Collector,
+		flush:   func() (context.Context, context.CancelFunc) { return poller.FlushContext(u.Collector) },
+		healthy: true,
+	}
+}
+
+// newInputPlugin returns an input plugin. The poller's Close context bounds the wait
+// for all of them to stop; each is given the default shutdown timeout within it.
+func newInputPlugin(u *ExecInput, p *Plugin) *plugin {
+	return &plugin{
+		Plugin: p,
+		role:   roleInput,
+		logger: u.Logger,
+		flush: func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), poller.DefaultShutdownTimeout)
+		},
+		healthy: true,
+	}
+}
+
+// id names the plugin in telemetry and the poll scheduler.
+func (p *plugin) id() string {
+	return PluginName + ":" + p.Name
+}
+
+// run starts the program and, for an output, sends it every snapshot until the context
+// is canceled, starting it again whenever it exits.
+func (p *plugin) run(ctx context.Context) {
+	var snaps <-chan *poller.Snapshot // nil for an input: it is asked, not sent.
+	if p.role == roleOutput {
+		snaps = p.snapshots(ctx)
+	}
+
+	restart := time.NewTimer(0) // start right away.
+
+	defer restart.Stop()
+
+	var exited <-chan struct{}
+
+	p.Logf("Exec plugin %s starting, command: %s, interval: %v", p.Name, p.Command, p.Interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.stop()
+
+			return
+		case <-restart.C:
+			proc, err := p.start()
+			if err != nil {
+				p.LogErrorf("Starting exec plugin %s, retrying in %v: %v", p.Name, p.RestartDelay, err)
+				restart.Reset(p.RestartDelay.Duration)
+
+				continue
+			}
+
+			exited = proc.done
+		case <-exited:
+			exited = nil
+
+			p.mu.Lock()
+			err := p.proc.err
+			_ = p.proc.stdin.Close()
+			unwritten := p.sent
+			p.proc, p.sent = nil, nil
+			p.mu.Unlock()
+
+			for _, s := range unwritten {
+				s.snap.Done(errExited)
+			}
+
+			p.LogErrorf("Exec plugin %s exited, restarting in %v: %v", p.Name, p.RestartDelay, err)
+			p.count("restarts", 1)
+			restart.Reset(p.RestartDelay.Duration)
+		case snap, ok := <-snaps:
+			if !ok {
+				p.stop()
+
+				return
+			}
+
+			p.send(snap)
+		}
+	}
+}
+
+// once starts the program, sends it one snapshot, waits for its written reply and
+// stops it. ctx bounds the wait for the reply.
+func (p *plugin) once(ctx context.Context, snap *poller.Snapshot) error {
+	p.results = make(chan error, 1)
+
+	proc, err := p.start()
+	if err != nil {
+		return err
+	}
+
+	defer p.stop()
+
+	pending := &sent{at: time.Now(), snap: snap}
+
+	p.mu.Lock()
+	p.sent = append(p.sent, pending)
+	p.mu.Unlock()
+
+	if err := p.write(newSnapshot(snap)); err != nil {
+		poller.RecordWrite(p.id(), time.Since(pending.at), 0, err)
+
+		return err
+	}
+
+	select {
+	case err := <-p.results:
+		return err
+	case <-proc.done:
+		return fmt.Errorf("%w: %v", errExited, proc.err) //nolint:errorlint // proc.err may be nil.
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for a written reply: %w", ctx.Err())
+	}
+}
+
+// snapshots returns the plugin's snapshots: from the shared poll scheduler, or from
+// polling the inputs on the plugin's own ticker when there is none.
+func (p *plugin) snapshots(ctx context.Context) <-chan *poller.Snapshot {
+	c := p.collect
+	if s, ok := c.(poller.Scheduler); ok {
+		return s.Subscribe(&poller.Subscription{Name: p.id(), Interval: p.Interval.Duration, Metrics: true, Events: true, Ack: true})
+	}
+
+	snaps := make(chan *poller.Snapshot, 1)
+
+	go func() {
+		defer close(snaps)
+
+		ticker := time.NewTicker(p.Interval.Duration)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				snap := &poller.Snapshot{Start: now}
+				snap.Metrics, snap.MetricsErr = c.Metrics(&poller.Filter{})
+				snap.Events, snap.EventsErr = c.Events(&poller.Filter{Dur: p.Interval.Duration})
+
+				select {
+				case snaps <- snap:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return snaps
+}
+
+// passedEnv are the only variables of the poller's environment a program gets. The
+// rest may hold secrets, like UP_UNIFI_DEFAULT_PASS, that are not the program's.
+var passedEnv = []string{"PATH", "HOME", "TZ", "LANG"} // nolint: gochecknoglobals
+
+// environ returns the program's environment: passedEnv, and the plugin's Env with its
+// secrets resolved.
+func (p *plugin) environ() ([]string, error) {
+	env := []string{}
+
+	for _, name := range passedEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	for name, value := range p.Env {
+		secret, err := poller.ResolveSecret(value)
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", name, err)
+		}
+
+		env = append(env, name+"="+secret)
+	}
+
+	return env, nil
+}
+
+// start runs the program and sends it the hello message.
+func (p *plugin) start() (*process, error) {
+	env, err := p.environ()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(p.Command, p.Args...) //nolint:gosec // running it is the point.
+	cmd.Env = env
+
+	// Not cmd.StdinPipe: writes to this pipe can time out, so a stuck program cannot
+	// block the poller.
+	stdinR, stdin, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdin: %w", err)
+	}
+
+	cmd.Stdin = stdinR
+	defer stdinR.Close() // the program has its own copy once it started.
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdout: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stderr: %w", err)
+	}
+
+	if err = cmd.Start(); err != nil {
+		stdin.Close()
+
+		return nil, fmt.Errorf("running %s: %w", p.Command, err)
+	}
+
+	proc := &process{cmd: cmd, stdin: stdin, enc: json.NewEncoder(stdin), done: make(chan struct{})}
+
+	var readers sync.WaitGroup
+
+	readers.Add(2) //nolint:mnd // stdout and stderr.
+
+	go func() {
+		defer readers.Done()
+		p.readReplies(stdout)
+	}()
+
+	go func() {
+		defer readers.Done()
+		p.readStderr(stderr)
+	}()
+
+	go func() {
+		readers.Wait() // Wait closes the pipes, so the readers must be done first.
+		proc.err = cmd.Wait()
+		close(proc.done)
+	}()
+
+	p.mu.Lock()
+	p.proc = proc
+	p.mu.Unlock()
+
+	if err := p.write(&hello{Type: msgHello, Protocol: protocolVersion, Role: p.role, Name: p.Name, Config: p.Config}); err != nil {
+		p.LogErrorf("Exec plugin %s: sending hello: %v", p.Name, err)
+	}
+
+	p.LogDebugf("Exec plugin %s started, pid: %d", p.Name, cmd.Process.Pid)
+
+	return proc, nil
+}
+
+// write sends one message to the program. A program that does not read it within an
+// interval is killed: it is stuck, or the line it was sent is now cut short.
+func (p *plugin) write(msg any) error {
+	p.mu.Lock()
+	proc := p.proc
+	p.mu.Unlock()
+
+	if proc == nil {
+		return errNotRunning
+	}
+
+	p.writing.Lock()
+	defer p.writing.Unlock()
+
+	_ = proc.stdin.SetWriteDeadline(time.Now().Add(p.Interval.Duration)) // not every OS can.
+
+	if err := proc.enc.Encode(msg); err != nil {
+		_ = proc.cmd.Process.Kill()
+
+		return fmt.Errorf("writing to %s: %w", p.Command, err)
+	}
+
+	return nil
+}
+
+// send writes a snapshot to the program. Snapshots are dropped while it is not running,
+// and when the poll collected nothing. A poll that failed in part is sent with what it
+// collected and its errors. The snapshot is done when the program replies that it wrote it.
+func (p *plugin) send(snap *poller.Snapshot) {
+	if err := errors.Join(snap.MetricsErr, snap.EventsErr); err != nil {
+		if snap.Metrics == nil && snap.Events == nil {
+			p.LogErrorf("fetch for exec plugin %s failed: %v", p.Name, err)
+			snap.Done(err)
+
+			return
+		}
+
+		p.LogErrorf("fetch for exec plugin %s failed in part, sending what was collected: %v", p.Name, err)
+	}
+
+	// It is queued before it is written, so a reply that comes right away finds it.
+	pending := &sent{at: time.Now(), snap: snap}
+
+	p.mu.Lock()
+	p.sent = append(p.sent, pending)
+	p.mu.Unlock()
+
+	if err := p.write(newSnapshot(snap)); err != nil {
+		p.mu.Lock()
+		i := slices.Index(p.sent, pending)
+		if i >= 0 {
+			p.sent = slices.Delete(p.sent, i, i+1)
+		}
+		p.mu.Unlock()
+
+		poller.RecordWrite(p.id(), time.Since(pending.at), 0, err)
+		p.LogErrorf("Sending snapshot to exec plugin %s: %v", p.Name, err)
+
+		if i >= 0 { // else the exit of the program already failed it.
+			snap.Done(err)
+		}
+	}
+}
+
+// ask sends an input a request for metrics or events, and returns its answer. The
+// program has an interval to answer, and one request is asked at a time.
+func (p *plugin) ask(ctx context.Context, msg *request) (*reply, error) {
+	p.asking.Lock()
+	defer p.asking.Unlock()
+
+	answer := make(chan *reply, 1)
+
+	p.mu.Lock()
+	proc := p.proc
+	p.asked++
+	msg.ID, p.answer = p.asked, answer
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.answer = nil
+		p.mu.Unlock()
+	}()
+
+	if proc == nil {
+		return nil, errNotRunning
+	}
+
+	if err := p.write(msg); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(p.Interval.Duration)
+	defer timer.Stop()
+
+	select {
+	case msg := <-answer:
+		if msg.Error != "" {
+			return msg, errors.New(msg.Error) //nolint:err113 // it comes from the plugin.
+		}
+
+		return msg, nil
+	case <-proc.done:
+		return nil, fmt.Errorf("%w: %v", errExited, proc.err) //nolint:errorlint // proc.err may be nil.
+	case <-timer.C:
+		return nil, fmt.Errorf("%w within %v", errNoAnswer, p.Interval)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for an answer: %w", ctx.Err())
+	}
+}
+
+// answered passes an input's answer to the request waiting for it. Answers to a
+// request that is no longer waiting, because it timed out, are dropped.
+func (p *plugin) answered(msg *reply) {
+	p.mu.Lock()
+	answer, asked := p.answer, p.asked
+	p.mu.Unlock()
+
+	if answer == nil || msg.ID != asked {
+		p.LogErrorf("Exec plugin %s: dropped a late or unasked %s answer, id: %d", p.Name, msg.Type, msg.ID)
+
+		return
+	}
+
+	select {
+	case answer <- msg:
+	default: // it answered twice.
+	}
+}
+
+// stop asks the program to shut down, and kills it if it has not exited by the end
+// of the shutdown timeout.
+func (p *plugin) stop() {
+	p.mu.Lock()
+	proc := p.proc
+	p.mu.Unlock()
+
+	if proc == nil {
+		return
+	}
+
+	if err := p.write(&shutdown{Type: msgShutdown}); err != nil {
+		p.LogDebugf("Exec plugin %s: sending shutdown: %v", p.Name, err)
+	}
+
+	_ = proc.stdin.Close()
+
+	flushCtx, cancel := p.flush()
+	defer cancel()
+
+	select {
+	case <-proc.done:
+		p.Logf("Exec plugin %s stopped", p.Name)
+	case <-flushCtx.Done():
+		p.LogErrorf("Exec plugin %s did not exit in time, killing it", p.Name)
+		_ = proc.cmd.Process.Kill()
+		<-proc.done
+	}
+}
+
+// readReplies handles each message the program writes to stdout.
+func (p *plugin) readReplies(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, maxLine)
+
+	for scanner.Scan() {
+		var msg reply
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			p.LogErrorf("Exec plugin %s: invalid message: %v: %s", p.Name, err, scanner.Text())
+
+			continue
+		}
+
+		p.handle(&msg)
+	}
+
+	if err := scanner.Err(); err != nil {
+		p.LogErrorf("Exec plugin %s: reading stdout: %v", p.Name, err)
+	}
+}
+
+// readStderr logs each line the program writes to stderr as an error.
+func (p *plugin) readStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(nil, maxLine)
+
+	for scanner.Scan() {
+		p.LogErrorf("Exec plugin %s: %s", p.Name, scanner.Text())
+	}
+}
+
+// handle acts on one message from the program.
+func (p *plugin) handle(msg *reply) {
+	switch msg.Type {
+	case msgLog:
+		switch msg.Level {
+		case "error":
+			p.LogErrorf("%s: %s", p.Name, msg.Msg)
+		case "debug":
+			p.LogDebugf("%s: %s", p.Name, msg.Msg)
+		default:
+			p.Logf("%s: %s", p.Name, msg.Msg)
+		}
+	case msgHealth:
+		p.setHealth(msg.Healthy, msg.Msg)
+	case msgWritten:
+		p.written(msg)
+	case msgMetrics, msgEvents:
+		p.answered(msg)
+	default:
+		p.LogErrorf("Exec plugin %s: unknown message type %q", p.Name, msg.Type)
+	}
+}
+
+// written records the result of a snapshot in the poller's telemetry.
+func (p *plugin) written(msg *reply) {
+	var (
+		elapsed time.Duration
+		snap    *poller.Snapshot
+		err     error
+	)
+
+	p.mu.Lock()
+	if len(p.sent) > 0 {
+		elapsed, snap = time.Since(p.sent[0].at), p.sent[0].snap
+		p.sent = p.sent[1:]
+	}
+	p.mu.Unlock()
+
+	if msg.Error != "" {
+		err = errors.New(msg.Error) //nolint:err113 // it comes from the plugin.
+	}
+
+	snap.Done(err)
+
+	poller.RecordWrite(p.id(), elapsed, msg.Points, err)
+	p.count("points", int64(msg.Points))
+
+	if p.results != nil {
+		select {
+		case p.results <- err:
+		default: // only the first reply is waited for.
+		}
+	}
+
+	if err != nil {
+		p.LogErrorf("Exec plugin %s write failed: %v", p.Name, err)
+
+		return
+	}
+
+	p.Logf("Exec plugin %s wrote %d points in %v", p.Name, msg.Points, elapsed.Round(time.Millisecond))
+}
+
+// setHealth keeps the health the program reported, and logs when it changes.
+func (p *plugin) setHealth(healthy bool, status string) {
+	p.mu.Lock()
+	changed := p.healthy != healthy || p.status != status
+	p.healthy, p.status = healthy, status
+	p.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if healthy {
+		p.Logf("Exec plugin %s is healthy: %s", p.Name, status)
+	} else {
+		p.LogErrorf("Exec plugin %s is unhealthy: %s", p.Name, status)
+	}
+}
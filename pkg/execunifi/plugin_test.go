@@ -0,0 +1,221 @@
+//nolint:testpackage // white-box: drives one plugin process without the poll scheduler.
+package execunifi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+const testPluginEnv = "UP_EXEC_TEST_PLUGIN"
+
+// TestMain turns the test binary into the plugin under test when it is run by one.
+func TestMain(m *testing.M) {
+	if os.Getenv(testPluginEnv) != "" {
+		runTestPlugin()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// testItem is the kind the test input answers with.
+type testItem struct {
+	Name string `json:"name"`
+}
+
+func init() { //nolint:gochecknoinits
+	poller.RegisterKind[*testItem]("exec_test_item")
+}
+
+// runTestPlugin is an output that reports one point per client and per label scope,
+// and an input that answers with a client and an extra item, and with one event.
+func runTestPlugin() {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(nil, maxLine)
+
+	for scanner.Scan() {
+		var msg struct {
+			Type    string `json:"type"`
+			ID      int64  `json:"id"`
+			Role    string `json:"role"`
+			Name    string `json:"name"`
+			Config  map[string]string
+			Metrics struct {
+				Clients []any
+				Labels  []scopedLabels
+			} `json:"metrics"`
+		}
+
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			continue
+		}
+
+		switch msg.Type {
+		case msgHello:
+			fmt.Printf(`{"type":"health","healthy":true,"msg":"%s %s %s"}`+"\n", msg.Role, msg.Name, msg.Config["greeting"])
+		case msgSnapshot:
+			fmt.Printf(`{"type":"log","msg":"zone %s"}`+"\n", msg.Metrics.Labels[0].Labels["zone"])
+			fmt.Printf(`{"type":"written","points":%d}`+"\n", len(msg.Metrics.Clients)+len(msg.Metrics.Labels))
+		case msgMetrics:
+			fmt.Printf(`{"type":"metrics","id":%d,"collections":[`+
+				`{"kind":"exec_test_item","field":"Clients","items":[{"name":"client"}]},`+
+				`{"kind":"exec_test_item","items":[{"name":"extra"}]},`+
+				`{"kind":"not_registered","items":[{}]}]}`+"\n", msg.ID)
+		case msgEvents:
+			fmt.Printf(`{"type":"events","id":%d,"collections":[{"kind":"exec_test_item","items":[{"name":"event"}]}]}`+"\n", msg.ID)
+		case msgShutdown:
+			return
+		}
+	}
+}
+
+// writtenPoints returns the points a plugin wrote so far, from the poller's telemetry.
+func writtenPoints(id string) uint64 {
+	for _, w := range poller.GetTelemetry().Writes {
+		if w.Output == id {
+			return w.Points
+		}
+	}
+
+	return 0
+}
+
+func TestPluginProtocol(t *testing.T) {
+	c := poller.NewTestCollector(t)
+	c.SetPoller(&poller.Poller{})
+
+	u := &ExecOutput{Collector: c, Exec: &Exec{Config: &Config{Enable: true, Plugins: []*Plugin{{
+		Name:    "test",
+		Command: os.Args[0],
+		Env:     map[string]string{testPluginEnv: "1"},
+		Config:  map[string]string{"greeting": "hi"},
+	}}}}}
+	u.setConfigDefaults()
+
+	p := newPlugin(u, u.Plugins[0])
+	before := writtenPoints(p.id())
+	proc, err := p.start()
+	require.NoError(t, err)
+
+	p.send(&poller.Snapshot{Start: time.Now(), Metrics: &poller.Metrics{
+		Clients: []any{map[string]string{"mac": "00:00:00:00:00:01"}, map[string]string{"mac": "00:00:00:00:00:02"}},
+		Labels:  poller.Labels{{Source: "https://unifi", Site: "default"}: {"zone": "guest"}},
+	}})
+
+	require.Eventually(t, func() bool {
+		return writtenPoints(p.id())-before == 3
+	}, 10*time.Second, 10*time.Millisecond, "the plugin reports the points it wrote")
+
+	p.mu.Lock()
+	assert.Equal(t, "output test hi", p.status, "the hello message carries the role, name and config")
+	assert.Empty(t, p.sent, "the written reply is matched to its snapshot")
+	p.mu.Unlock()
+
+	before = writtenPoints(p.id())
+
+	p.send(&poller.Snapshot{
+		Start:     time.Now(),
+		Metrics:   &poller.Metrics{Labels: poller.Labels{{Source: "https://unifi", Site: "default"}: {"zone": "iot"}}},
+		EventsErr: assert.AnError,
+	})
+
+	require.Eventually(t, func() bool {
+		return writtenPoints(p.id())-before == 1
+	}, 10*time.Second, 10*time.Millisecond, "a poll that failed in part is sent with what it collected")
+
+	p.stop()
+
+	select {
+	case <-proc.done:
+		require.NoError(t, proc.err, "the plugin exits cleanly on shutdown")
+	default:
+		t.Fatal("stop returns once the plugin exited")
+	}
+}
+
+func TestWriteOnce(t *testing.T) {
+	c := poller.NewTestCollector(t)
+	c.SetPoller(&poller.Poller{})
+
+	u := &ExecOutput{Exec: &Exec{Config: &Config{Enable: true, Plugins: []*Plugin{{
+		Name:    "once",
+		Command: os.Args[0],
+		Env:     map[string]string{testPluginEnv: "1"},
+	}}}}}
+
+	before := writtenPoints(PluginName + ":once")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require.NoError(t, u.WriteOnce(ctx, c, &poller.Snapshot{Start: time.Now(), Metrics: &poller.Metrics{
+		Clients: []any{map[string]string{"mac": "00:00:00:00:00:01"}},
+		Labels:  poller.Labels{{Source: "https://unifi", Site: "default"}: {"zone": "iot"}},
+	}}), "the plugin is stopped after its written reply")
+
+	assert.EqualValues(t, 2, writtenPoints(PluginName+":once")-before)
+}
+
+func TestInputProtocol(t *testing.T) {
+	u := &ExecInput{Exec: &Exec{Config: &Config{Enable: true, Inputs: []*Plugin{{
+		Name:    "input",
+		Command: os.Args[0],
+		Env:     map[string]string{testPluginEnv: "1"},
+	}}}}}
+	require.NoError(t, u.Initialize(poller.NewTestCollector(t)))
+
+	defer func() { assert.NoError(t, u.Close(context.Background())) }()
+
+	require.Eventually(t, func() bool {
+		u.plugins[0].mu.Lock()
+		defer u.plugins[0].mu.Unlock()
+
+		return u.plugins[0].status == "input input "
+	}, 10*time.Second, 10*time.Millisecond, "the input is told its role")
+
+	m, err := u.Metrics(&poller.Filter{})
+	require.ErrorIs(t, err, poller.ErrUnknownKind, "items of an unknown kind are an error")
+	require.NotNil(t, m, "the rest of the answer is kept")
+	assert.Equal(t, []any{&testItem{Name: "client"}}, m.Clients)
+	assert.Equal(t, []any{&testItem{Name: "extra"}}, m.Extra["exec_test_item"])
+
+	e, err := u.Events(&poller.Filter{Dur: time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, []any{&testItem{Name: "event"}}, e.Logs)
+
+	_, err = u.RawMetrics(&poller.Filter{})
+	assert.ErrorIs(t, err, ErrNoRawMetrics)
+}
+
+// Not parallel: sets environment variables.
+func TestPluginEnvironment(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("UP_UNIFI_DEFAULT_PASS", "not for plugins")
+	t.Setenv("UP_EXEC_TEST_SHARED", "shared")
+
+	u := &ExecOutput{Exec: &Exec{Config: &Config{Plugins: []*Plugin{{
+		Name: "test",
+		Env:  map[string]string{"MYSQL_PASS": "plain", "SHARED": "env://UP_EXEC_TEST_SHARED"},
+	}}}}}
+
+	env, err := newPlugin(u, u.Plugins[0]).environ()
+	require.NoError(t, err)
+	assert.Contains(t, env, "PATH=/usr/bin")
+	assert.Contains(t, env, "MYSQL_PASS=plain")
+	assert.Contains(t, env, "SHARED=shared", "secret references are resolved")
+
+	for _, v := range env {
+		assert.NotContains(t, v, "UP_", "the poller's own variables are not passed on")
+	}
+}
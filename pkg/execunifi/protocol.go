@@ -0,0 +1,220 @@
+package execunifi
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+/* The protocol. The poller writes one JSON object per line to the plugin's stdin,
+   and the plugin writes one JSON object per line to its stdout. Every object has a
+   "type". Anything the plugin writes to stderr is logged as an error.
+
+   Poller to plugin:
+     hello     sent once, first: {"type":"hello","protocol":1,"role":"output|input","name":"...","config":{...}}
+     snapshot  to outputs, each poll: {"type":"snapshot","time":"...","metrics":{...},"events":{...},"errors":[...]}
+     metrics   to inputs, each poll: {"type":"metrics","id":1}
+     events    to inputs, each poll: {"type":"events","id":2,"since":"..."}
+     shutdown  sent before stdin is closed; the plugin should flush and exit.
+
+   Plugin to poller:
+     log       {"type":"log","level":"info|error|debug","msg":"..."}
+     health    {"type":"health","healthy":true,"msg":"..."}
+     written   from outputs, once per snapshot: {"type":"written","points":123,"error":""}
+     metrics   from inputs, the answer to a request with its id:
+               {"type":"metrics","id":1,"collections":[{"kind":"client","field":"Clients","items":[...]}],"error":""}
+     events    from inputs, like metrics.
+
+   Metrics and events are poller.Metrics and poller.Events, with their fields named as
+   in Go. Labels, keyed by a struct in Go, are a list of {source, site, labels}. A
+   snapshot's errors are those of a poll that failed in part. An input's items are
+   decoded into the type an input registered for their kind, and added to the field,
+   or to Extra without one. */
+
+// protocolVersion is sent in the hello message. It changes when a message changes in a
+// way that breaks existing plugins.
+const protocolVersion = 1
+
+// Message types.
+const (
+	msgHello    = "hello"
+	msgSnapshot = "snapshot"
+	msgShutdown = "shutdown"
+	msgLog      = "log"
+	msgHealth   = "health"
+	msgWritten  = "written"
+	msgMetrics  = "metrics"
+	msgEvents   = "events"
+)
+
+// Plugin roles, sent in the hello message.
+const (
+	roleOutput = "output"
+	roleInput  = "input"
+)
+
+// hello is the first message a plugin reads.
+type hello struct {
+	Type     string            `json:"type"`
+	Protocol int               `json:"protocol"`
+	Role     string            `json:"role"`
+	Name     string            `json:"name"`
+	Config   map[string]string `json:"config,omitempty"`
+}
+
+// snapshot carries the metrics and events of one poll.
+type snapshot struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Metrics *metrics  `json:"metrics,omitempty"`
+	Events  *events   `json:"events,omitempty"`
+	Errors  []string  `json:"errors,omitempty"`
+}
+
+// request asks an input for its metrics or events.
+type request struct {
+	Type  string     `json:"type"`
+	ID    int64      `json:"id"`
+	Since *time.Time `json:"since,omitempty"` // events only: the oldest to answer with.
+}
+
+// shutdown asks the plugin to flush and exit.
+type shutdown struct {
+	Type string `json:"type"`
+}
+
+// reply is any message from a plugin. Fields not used by its type are empty.
+type reply struct {
+	Type        string       `json:"type"`
+	ID          int64        `json:"id"`
+	Level       string       `json:"level"`
+	Msg         string       `json:"msg"`
+	Healthy     bool         `json:"healthy"`
+	Points      int          `json:"points"`
+	Error       string       `json:"error"`
+	Collections []collection `json:"collections"`
+}
+
+// collection is a list of items of one kind in an input's answer.
+type collection struct {
+	Kind  poller.MetricKind `json:"kind"`
+	Field string            `json:"field,omitempty"` // ie. Clients. Empty for Extra.
+	Items []json.RawMessage `json:"items"`
+}
+
+// metrics is poller.Metrics with labels that encoding/json can write.
+type metrics struct {
+	*poller.Metrics
+	Labels []scopedLabels `json:"Labels,omitempty"`
+}
+
+// events is poller.Events with labels that encoding/json can write.
+type events struct {
+	*poller.Events
+	Labels []scopedLabels `json:"Labels,omitempty"`
+}
+
+// scopedLabels are the static labels for one controller and site.
+type scopedLabels struct {
+	Source string            `json:"source"`
+	Site   string            `json:"site"`
+	Labels map[string]string `json:"labels"`
+}
+
+// newSnapshot returns the message for a poll. Metrics or events are left out when the
+// poll did not collect them.
+func newSnapshot(snap *poller.Snapshot) *snapshot {
+	msg := &snapshot{Type: msgSnapshot, Time: snap.Start}
+
+	if snap.Metrics != nil {
+		msg.Metrics = &metrics{Metrics: snap.Metrics, Labels: listLabels(snap.Metrics.Labels)}
+	}
+
+	if snap.Events != nil {
+		msg.Events = &events{Events: snap.Events, Labels: listLabels(snap.Events.Labels)}
+	}
+
+	for _, err := range []error{snap.MetricsErr, snap.EventsErr} {
+		if err != nil {
+			msg.Errors = append(msg.Errors, err.Error())
+		}
+	}
+
+	return msg
+}
+
+// decode returns the items in the collection as the types registered for its kind.
+func (c *collection) decode() ([]any, error) {
+	items := make([]any, 0, len(c.Items))
+
+	for i, raw := range c.Items {
+		item, err := poller.DecodeKind(c.Kind, raw)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i+1, err)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// newMetrics returns the metrics in an input's answer. Collections that cannot be
+// decoded are skipped, and returned as errors with the rest.
+func newMetrics(msg *reply) (*poller.Metrics, error) {
+	m := &poller.Metrics{TS: time.Now()}
+
+	var errs []error
+
+	for _, c := range msg.Collections {
+		items, err := c.decode()
+		if err == nil {
+			err = m.Add(c.Field, items...)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("collection %s: %w", c.Kind, err))
+		}
+	}
+
+	return m, errors.Join(errs...)
+}
+
+// newEvents returns the events in an input's answer, like newMetrics.
+func newEvents(msg *reply) (*poller.Events, error) {
+	e := &poller.Events{}
+
+	var errs []error
+
+	for _, c := range msg.Collections {
+		items, err := c.decode()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("collection %s: %w", c.Kind, err))
+
+			continue
+		}
+
+		e.Logs = append(e.Logs, items...)
+	}
+
+	return e, errors.Join(errs...)
+}
+
+func listLabels(labels poller.Labels) []scopedLabels {
+	list := make([]scopedLabels, 0, len(labels))
+
+	for scope, l := range labels {
+		list = append(list, scopedLabels{Source: scope.Source, Site: scope.Site, Labels: l})
+	}
+
+	slices.SortFunc(list, func(a, b scopedLabels) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Site, b.Site))
+	})
+
+	return list
+}
@@ -0,0 +1,111 @@
+package execunifi
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strconv"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+var (
+	ErrNoCommand     = errors.New("command must be set")
+	ErrDuplicateName = errors.New("plugin name is used more than once")
+	ErrIntervalLow   = fmt.Errorf("interval is below the minimum of %v, and is raised to it", minimumInterval)
+	ErrNegativeDelay = errors.New("restart_delay must not be negative")
+)
+
+var (
+	_ poller.ConfigChecker = &ExecOutput{}
+	_ poller.ConfigPrinter = &ExecOutput{}
+	_ poller.ConfigPrinter = &ExecInput{}
+)
+
+// CheckConfig returns a problem for each configured output and input that cannot work,
+// or that setConfigDefaults would quietly change. Satisfies poller.ConfigChecker.
+func (u *ExecOutput) CheckConfig() []error {
+	if u.Config == nil || !u.Enable {
+		return nil
+	}
+
+	var errs []error
+
+	names := make(map[string]bool)
+
+	for i, p := range append(slices.Clip(u.Plugins), u.Inputs...) {
+		name := p.Name
+		if name == "" {
+			name = "plugin " + strconv.Itoa(i+1)
+		}
+
+		if p.Command == "" {
+			errs = append(errs, fmt.Errorf("%s: %w", name, ErrNoCommand))
+		} else if _, err := exec.LookPath(p.Command); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+
+		if p.Name != "" && names[p.Name] {
+			errs = append(errs, fmt.Errorf("%s: %w", name, ErrDuplicateName))
+		}
+
+		names[p.Name] = true
+
+		if p.Interval.Duration < 0 || (p.Interval.Duration > 0 && p.Interval.Duration < minimumInterval) {
+			errs = append(errs, fmt.Errorf("%s: %w: %v", name, ErrIntervalLow, p.Interval))
+		}
+
+		if p.RestartDelay.Duration < 0 {
+			errs = append(errs, fmt.Errorf("%s: %w: %v", name, ErrNegativeDelay, p.RestartDelay))
+		}
+
+		for key, value := range p.Env {
+			if err := poller.CheckSecret(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: env %s: %w", name, key, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// EffectiveConfig returns the config with defaults applied and the environment redacted.
+// Satisfies poller.ConfigPrinter for the output and the input.
+func (e *Exec) EffectiveConfig() any {
+	if e.Config == nil {
+		return Config{}
+	}
+
+	e.setConfigDefaults()
+
+	return e.redacted()
+}
+
+// redacted returns a copy of the config that only shows whether each variable in the
+// plugins' environments is set. They often hold API keys and passwords.
+func (c *Config) redacted() Config {
+	fake := *c
+	fake.Plugins = redactPlugins(c.Plugins)
+	fake.Inputs = redactPlugins(c.Inputs)
+
+	return fake
+}
+
+func redactPlugins(plugins []*Plugin) []*Plugin {
+	fake := make([]*Plugin, len(plugins))
+
+	for i, p := range plugins {
+		plugin := *p
+		plugin.Env = maps.Clone(p.Env)
+
+		for key, value := range plugin.Env {
+			plugin.Env[key] = strconv.FormatBool(value != "")
+		}
+
+		fake[i] = &plugin
+	}
+
+	return fake
+}
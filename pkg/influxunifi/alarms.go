@@ -1,8 +1,6 @@
 package influxunifi
 
 import (
-	"time"
-
 	"github.com/unpoller/unifi/v5"
 )
 
@@ -13,7 +11,7 @@ const (
 
 // batchAlarms generates alarm datapoints for InfluxDB.
 func (u *InfluxUnifi) batchAlarms(r report, event *unifi.Alarm) { // nolint:dupl
-	if time.Since(event.Datetime) > u.Interval.Duration+time.Second {
+	if u.tooOld(event.Datetime) {
 		return // The event is older than our interval, ignore it.
 	}
 
@@ -68,7 +66,7 @@ func (u *InfluxUnifi) batchAlarms(r report, event *unifi.Alarm) { // nolint:dupl
 
 // batchAnomaly generates Anomalies from UniFi for InfluxDB.
 func (u *InfluxUnifi) batchAnomaly(r report, event *unifi.Anomaly) {
-	if time.Since(event.Datetime) > u.Interval.Duration+time.Second {
+	if u.tooOld(event.Datetime) {
 		return // The event is older than our interval, ignore it.
 	}
 
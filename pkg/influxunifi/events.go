@@ -1,20 +1,24 @@
 package influxunifi
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
 )
 
 // These constants are used as names for printed/logged counters.
 const (
-	eventT = item("Event")
-	idsT   = item("IDs")
+	eventT       = item("Event")
+	idsT         = item("IDs")
+	clientEventT = item("ClientEvent")
+	deviceEventT = item("DeviceEvent")
 )
 
 // batchIDs generates intrusion detection datapoints for InfluxDB.
 func (u *InfluxUnifi) batchIDs(r report, i *unifi.IDS) { // nolint:dupl
-	if time.Since(i.Datetime) > u.Interval.Duration+time.Second {
+	if u.tooOld(i.Datetime) {
 		return // The event is older than our interval, ignore it.
 	}
 
@@ -69,7 +73,7 @@ func (u *InfluxUnifi) batchIDs(r report, i *unifi.IDS) { // nolint:dupl
 
 // batchEvents generates events from UniFi for InfluxDB.
 func (u *InfluxUnifi) batchEvent(r report, i *unifi.Event) { // nolint: funlen
-	if time.Since(i.Datetime) > u.Interval.Duration+time.Second {
+	if u.tooOld(i.Datetime) {
 		return // The event is older than our interval, ignore it.
 	}
 
@@ -183,3 +187,77 @@ func cleanFields(fields map[string]any) map[string]any { //nolint:cyclop
 
 	return fields
 }
+
+// tooOld reports whether an event is older than the interval, so it was sent on an
+// earlier poll. With event cursors on, the input returns each event once, so none are.
+// Neither are any with --once, which collects events from its own window.
+func (u *InfluxUnifi) tooOld(t time.Time) bool {
+	return !u.once && !poller.EventCursors() && time.Since(t) > u.Interval.Duration+time.Second
+}
+
+// batchClientEvent generates client session event datapoints for InfluxDB. The input
+// returns each of these once, so unlike controller events they are never too old.
+func (u *InfluxUnifi) batchClientEvent(r report, e *poller.ClientEvent) {
+	fields := map[string]any{
+		"msg":          e.Msg(),
+		"mac":          e.Mac,
+		"hostname":     e.Hostname,
+		"ip":           e.IP,
+		"from_ip":      e.FromIP,
+		"from_ap_name": e.FromApName,
+		"from_band":    e.FromBand,
+	}
+
+	if e.Type == poller.ClientDisconnected {
+		fields["duration"] = e.Duration
+	}
+
+	r.addCount(clientEventT)
+	r.send(&metric{
+		TS:     e.Time,
+		Table:  "unifi_client_events",
+		Fields: cleanFields(fields),
+		Tags: cleanTags(map[string]string{
+			"type":      e.Type,
+			"site_name": e.SiteName,
+			"source":    e.SourceName,
+			"name":      e.Name,
+			"is_wired":  strconv.FormatBool(e.Wired),
+			"ssid":      e.SSID,
+			"ap_name":   e.ApName,
+			"band":      e.Band,
+		}),
+	})
+}
+
+// batchDeviceEvent generates device lifecycle event datapoints for InfluxDB. The input
+// returns each of these once, so unlike controller events they are never too old.
+func (u *InfluxUnifi) batchDeviceEvent(r report, e *poller.DeviceEvent) {
+	fields := map[string]any{
+		"msg":          e.Msg(),
+		"mac":          e.Mac,
+		"version":      e.Version,
+		"from_version": e.FromVersion,
+		"from_state":   e.FromState,
+		"uplink":       e.Uplink,
+		"from_uplink":  e.FromUplink,
+		"uptime":       e.Uptime,
+		"from_uptime":  e.FromUptime,
+	}
+
+	r.addCount(deviceEventT)
+	r.send(&metric{
+		TS:     e.Time,
+		Table:  "unifi_device_events",
+		Fields: cleanFields(fields),
+		Tags: cleanTags(map[string]string{
+			"type":        e.Type,
+			"site_name":   e.SiteName,
+			"source":      e.SourceName,
+			"name":        e.Name,
+			"model":       e.Model,
+			"device_type": e.DeviceType,
+			"state":       e.State,
+		}),
+	})
+}
@@ -5,11 +5,9 @@ package influxunifi
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/url"
-	"os"
-	"strconv"
-	"strings"
 	"time"
 
 	influx "github.com/influxdata/influxdb-client-go/v2"
@@ -79,9 +77,18 @@ type InfluxUnifi struct {
 	LastCheck      time.Time
 	IsVersion2     bool
 	*InfluxDB
+	reload chan *Config // configs from Reload, applied by the poll loop.
+	once   bool         // writing for --once; the poller picked the events to write.
 }
 
-var _ poller.OutputPlugin = &InfluxUnifi{}
+var (
+	_ poller.OutputPlugin        = &InfluxUnifi{}
+	_ poller.ContextOutputPlugin = &InfluxUnifi{}
+	_ poller.Reloader            = &InfluxUnifi{}
+	_ poller.KindExporter        = &InfluxUnifi{}
+	_ poller.OnceOutput          = &InfluxUnifi{}
+	_ poller.InputFilter         = &InfluxUnifi{}
+)
 
 type metric struct {
 	Table  string
@@ -91,7 +98,7 @@ type metric struct {
 }
 
 func init() { // nolint: gochecknoinits
-	u := &InfluxUnifi{InfluxDB: &InfluxDB{}, LastCheck: time.Now()}
+	u := &InfluxUnifi{InfluxDB: &InfluxDB{}, LastCheck: time.Now(), reload: make(chan *Config, 1)}
 
 	poller.NewOutput(&poller.Output{
 		Name:         PluginName,
@@ -100,11 +107,10 @@ func init() { // nolint: gochecknoinits
 	})
 }
 
-// PollController runs forever, polling UniFi and pushing to InfluxDB
+// PollController polls UniFi and pushes to InfluxDB until the context is canceled.
 // This is started by Run() or RunBoth() after everything checks out.
-func (u *InfluxUnifi) PollController() {
+func (u *InfluxUnifi) PollController(ctx context.Context) {
 	interval := u.Interval.Round(time.Second)
-	ticker := time.NewTicker(interval)
 	version := "1"
 
 	if u.IsVersion2 {
@@ -114,35 +120,189 @@ func (u *InfluxUnifi) PollController() {
 	u.Logf("Poller->InfluxDB started, version: %s, interval: %v, dp: %v, db: %s, url: %s, bucket: %s, org: %s",
 		version, interval, u.DeadPorts, u.DB, u.URL, u.Bucket, u.Org)
 
-	for u.LastCheck = range ticker.C {
-		u.Poll(interval)
+	if s, ok := u.Collector.(poller.Scheduler); ok {
+		sub := &poller.Subscription{
+			Name: PluginName, Input: u.InputName(), Interval: interval, Metrics: true, Events: true, Ack: true,
+		}
+		snaps := s.Subscribe(sub)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case config := <-u.reload:
+				if !u.applyConfig(config) {
+					return
+				}
+
+				if r, ok := s.(poller.Rescheduler); ok {
+					r.Reschedule(sub, u.Interval.Duration)
+				}
+			case snap, ok := <-snaps:
+				if !ok {
+					return
+				}
+
+				u.LastCheck = snap.Start
+				u.PollSnapshot(snap)
+			}
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case config := <-u.reload:
+			if !u.applyConfig(config) {
+				return
+			}
+
+			interval = u.Interval.Duration
+			ticker.Reset(interval)
+		case u.LastCheck = <-ticker.C:
+			u.Poll(interval)
+		}
+	}
+}
+
+// Reload validates a new config and hands it to the poll loop, which applies it
+// between polls. Satisfies poller.Reloader.
+func (u *InfluxUnifi) Reload(config any) error {
+	c, ok := config.(*InfluxDB)
+	if !ok {
+		return fmt.Errorf("%w: %T", poller.ErrReloadConfigType, config)
+	}
+
+	if c.Config == nil {
+		c.Config = &Config{Disable: true}
+	}
+
+	if _, err := url.Parse(c.URL); err != nil {
+		return fmt.Errorf("invalid influx URL: %w", err)
+	}
+
+	// Replace a config the poll loop has not picked up yet.
+	select {
+	case <-u.reload:
+	default:
+	}
+
+	u.reload <- c.Config
+
+	return nil
+}
+
+// applyConfig swaps in a reloaded config and a new client built from it, then closes
+// the old client. It runs on the poll loop, so nothing is writing to the old client.
+// Returns false if the reloaded config disables the output.
+func (u *InfluxUnifi) applyConfig(config *Config) bool {
+	if config.Disable {
+		u.Logf("InfluxDB output disabled by a config reload")
+
+		return false
+	}
+
+	prev := *u
+
+	u.InfluxDB = &InfluxDB{Config: config}
+	u.IsVersion2 = false
+	u.setConfigDefaults()
+
+	if err := u.newClient(); err != nil {
+		u.LogErrorf("Reloading InfluxDB config, keeping the current one: %v", err)
+		u.InfluxDB, u.IsVersion2 = prev.InfluxDB, prev.IsVersion2
+		u.InfluxV1Client, u.InfluxV2Client = prev.InfluxV1Client, prev.InfluxV2Client
+
+		return true
 	}
+
+	if err := prev.close(); err != nil {
+		u.LogErrorf("%v", err)
+	}
+
+	u.updateWeb()
+	u.Logf("InfluxDB config reloaded, interval: %v, dp: %v, db: %s, url: %s, bucket: %s, org: %s",
+		u.Interval.Duration, u.DeadPorts, u.DB, u.URL, u.Bucket, u.Org)
+
+	return true
 }
 
+// Poll fetches metrics and events from the input it writes and sends them to InfluxDB.
+// Only used when the collector does not provide a shared poll scheduler.
 func (u *InfluxUnifi) Poll(interval time.Duration) {
-	metrics, err := u.Collector.Metrics(&poller.Filter{Name: "unifi"})
+	metrics, err := u.Collector.Metrics(&poller.Filter{Name: u.InputName()})
 	if err != nil {
 		u.LogErrorf("metric fetch for InfluxDB failed: %v", err)
 
 		return
 	}
 
-	events, err := u.Collector.Events(&poller.Filter{Name: "unifi", Dur: interval})
+	events, err := u.Collector.Events(&poller.Filter{Name: u.InputName(), Dur: interval})
 	if err != nil {
 		u.LogErrorf("event fetch for InfluxDB failed: %v", err)
 
 		return
 	}
 
+	u.report(metrics, events)
+}
+
+// PollSnapshot writes a snapshot from the shared poll scheduler to InfluxDB,
+// and tells the scheduler whether its events were written.
+func (u *InfluxUnifi) PollSnapshot(snap *poller.Snapshot) {
+	// The events are written when the metrics failed, and the other way around. Done
+	// only hears about the events, so failed metrics do not hold back event cursors.
+	metrics, events := snap.Metrics, snap.Events
+
+	if snap.MetricsErr != nil {
+		u.LogErrorf("metric fetch for InfluxDB failed: %v", snap.MetricsErr)
+		metrics = &poller.Metrics{TS: snap.Start}
+	}
+
+	if snap.EventsErr != nil {
+		u.LogErrorf("event fetch for InfluxDB failed: %v", snap.EventsErr)
+		events = &poller.Events{}
+	}
+
+	if snap.MetricsErr != nil && snap.EventsErr != nil {
+		snap.Done(snap.EventsErr)
+
+		return
+	}
+
+	err := u.report(metrics, events)
+	if snap.EventsErr != nil {
+		err = snap.EventsErr
+	}
+
+	snap.Done(err)
+}
+
+func (u *InfluxUnifi) report(metrics *poller.Metrics, events *poller.Events) error {
+	start := time.Now()
 	report, err := u.ReportMetrics(metrics, events)
+
+	points := 0
+	if report != nil {
+		points = report.points()
+	}
+
+	poller.RecordWrite(PluginName, time.Since(start), points, err)
+
 	if err != nil {
-		// XXX: reset and re-auth? not sure..
 		u.LogErrorf("%v", err)
+		u.renewClient()
 
-		return
+		return err
 	}
 
 	u.Logf("UniFi Metrics Recorded. %v", report)
+
+	return nil
 }
 
 func (u *InfluxUnifi) Enabled() bool {
@@ -173,12 +333,11 @@ func (u *InfluxUnifi) DebugOutput() (bool, error) {
 		return false, fmt.Errorf("invalid influx URL: %v", err)
 	}
 
-	if u.IsVersion2 {
-		// we're a version 2
-		tlsConfig := &tls.Config{InsecureSkipVerify: !u.VerifySSL} // nolint: gosec
-		serverOptions := influx.DefaultOptions().SetTLSConfig(tlsConfig).SetBatchSize(u.BatchSize)
-		u.InfluxV2Client = influx.NewClientWithOptions(u.URL, u.AuthToken, serverOptions)
+	if err = u.newClient(); err != nil {
+		return false, err
+	}
 
+	if u.IsVersion2 {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
 		defer cancel()
 
@@ -191,16 +350,6 @@ func (u *InfluxUnifi) DebugOutput() (bool, error) {
 			return false, fmt.Errorf("unsuccessful ping to influxdb2")
 		}
 	} else {
-		u.InfluxV1Client, err = influxV1.NewHTTPClient(influxV1.HTTPConfig{
-			Addr:      u.URL,
-			Username:  u.User,
-			Password:  u.Pass,
-			TLSConfig: &tls.Config{InsecureSkipVerify: !u.VerifySSL}, // nolint: gosec
-		})
-		if err != nil {
-			return false, fmt.Errorf("making client: %w", err)
-		}
-
 		_, _, err = u.InfluxV1Client.Ping(time.Second * 2)
 		if err != nil {
 			return false, fmt.Errorf("unsuccessful ping to influxdb1")
@@ -212,6 +361,12 @@ func (u *InfluxUnifi) DebugOutput() (bool, error) {
 
 // Run runs a ticker to poll the unifi server and update influxdb.
 func (u *InfluxUnifi) Run(c poller.Collect) error {
+	return u.RunContext(context.Background(), c)
+}
+
+// RunContext is Run, but it returns once the context is canceled, after closing
+// the InfluxDB client. Closing the v2 client flushes its write buffer.
+func (u *InfluxUnifi) RunContext(ctx context.Context, c poller.Collect) error {
 	u.Collector = c
 
 	if !u.Enabled() {
@@ -233,28 +388,162 @@ func (u *InfluxUnifi) Run(c poller.Collect) error {
 		return err
 	}
 
+	if err = u.newClient(); err != nil {
+		return err
+	}
+
+	u.updateWeb()
+	u.PollController(ctx)
+
+	return u.close()
+}
+
+// InputName returns the input this output writes. Satisfies poller.InputFilter.
+func (u *InfluxUnifi) InputName() string {
+	return "unifi"
+}
+
+// WriteOnce writes one snapshot, flushes and closes the client, for --once.
+// Satisfies poller.OnceOutput.
+func (u *InfluxUnifi) WriteOnce(_ context.Context, c poller.Collect, snap *poller.Snapshot) error {
+	u.Collector, u.once = c, true
+	u.setConfigDefaults()
+
+	if _, err := url.Parse(u.URL); err != nil {
+		return fmt.Errorf("invalid influx URL: %w", err)
+	}
+
+	if err := u.newClient(); err != nil {
+		return err
+	}
+
+	var writeErrs []error
+
+	drained := make(chan struct{})
+
 	if u.IsVersion2 {
-		// we're a version 2
-		tlsConfig := &tls.Config{InsecureSkipVerify: !u.VerifySSL} // nolint: gosec
-		serverOptions := influx.DefaultOptions().SetTLSConfig(tlsConfig).SetBatchSize(u.BatchSize)
-		u.InfluxV2Client = influx.NewClientWithOptions(u.URL, u.AuthToken, serverOptions)
+		// The v2 client writes in the background, and only reports failures on this
+		// channel. Closing the client flushes it, then closes the channel.
+		errs := u.InfluxV2Client.WriteAPI(u.Org, u.Bucket).Errors()
+
+		go func() {
+			defer close(drained)
+
+			for err := range errs {
+				writeErrs = append(writeErrs, err)
+			}
+		}()
 	} else {
-		u.InfluxV1Client, err = influxV1.NewHTTPClient(influxV1.HTTPConfig{
-			Addr:      u.URL,
-			Username:  u.User,
-			Password:  u.Pass,
-			TLSConfig: &tls.Config{InsecureSkipVerify: !u.VerifySSL}, // nolint: gosec
-		})
+		close(drained)
+	}
+
+	start := time.Now()
+	report, err := u.ReportMetrics(snap.Metrics, snap.Events)
+	closeErr := u.close()
+	<-drained
+	err = errors.Join(append([]error{err, closeErr}, writeErrs...)...)
+
+	points := 0
+	if report != nil {
+		points = report.points()
+	}
+
+	poller.RecordWrite(PluginName, time.Since(start), points, err)
+
+	if err != nil {
+		return err
+	}
+
+	u.Logf("UniFi Metrics Recorded. %v", report)
+
+	return nil
+}
+
+// newClient creates the InfluxDB v1 or v2 client for the current config.
+// The token or password is resolved here, so a new client uses the current secret.
+func (u *InfluxUnifi) newClient() error {
+	if u.IsVersion2 {
+		token, err := poller.ResolveSecret(u.AuthToken)
 		if err != nil {
-			return fmt.Errorf("making client: %w", err)
+			return fmt.Errorf("influxdb auth_token: %w", err)
 		}
+
+		// we're a version 2
+		tlsConfig := &tls.Config{InsecureSkipVerify: !u.VerifySSL} // nolint: gosec
+		serverOptions := influx.DefaultOptions().SetTLSConfig(tlsConfig).SetBatchSize(u.BatchSize)
+		u.InfluxV1Client, u.InfluxV2Client = nil, influx.NewClientWithOptions(u.URL, token, serverOptions)
+
+		return nil
+	}
+
+	pass, err := poller.ResolveSecret(u.Pass)
+	if err != nil {
+		return fmt.Errorf("influxdb pass: %w", err)
 	}
 
-	fake := *u.Config
-	fake.Pass = strconv.FormatBool(fake.Pass != "")
+	u.InfluxV2Client = nil
 
-	webserver.UpdateOutput(&webserver.Output{Name: PluginName, Config: fake})
-	u.PollController()
+	u.InfluxV1Client, err = influxV1.NewHTTPClient(influxV1.HTTPConfig{
+		Addr:      u.URL,
+		Username:  u.User,
+		Password:  pass,
+		TLSConfig: &tls.Config{InsecureSkipVerify: !u.VerifySSL}, // nolint: gosec
+	})
+	if err != nil {
+		return fmt.Errorf("making client: %w", err)
+	}
+
+	return nil
+}
+
+// renewClient replaces the client after a failed write when the token or password
+// is a secret reference, so a rotated secret is picked up on the next write.
+func (u *InfluxUnifi) renewClient() {
+	if !poller.IsSecretRef(u.AuthToken) && !poller.IsSecretRef(u.Pass) {
+		return
+	}
+
+	// A cached exec:// secret is resolved again, as it may have been rotated.
+	poller.ForgetSecret(u.AuthToken)
+	poller.ForgetSecret(u.Pass)
+
+	v1, v2 := u.InfluxV1Client, u.InfluxV2Client
+
+	if err := u.newClient(); err != nil {
+		u.LogErrorf("Renewing InfluxDB client, keeping the current one: %v", err)
+		u.InfluxV1Client, u.InfluxV2Client = v1, v2
+
+		return
+	}
+
+	if v2 != nil {
+		v2.Close()
+	}
+
+	if v1 != nil {
+		_ = v1.Close()
+	}
+}
+
+// updateWeb sends the current config, minus the password, to the web interface.
+func (u *InfluxUnifi) updateWeb() {
+	webserver.UpdateOutput(&webserver.Output{Name: PluginName, Config: u.redacted()})
+}
+
+// close flushes and closes whichever InfluxDB client is in use.
+func (u *InfluxUnifi) close() error {
+	if u.InfluxV2Client != nil {
+		u.Logf("Flushing and closing InfluxDB client")
+		u.InfluxV2Client.Close()
+
+		return nil
+	}
+
+	if u.InfluxV1Client != nil {
+		if err := u.InfluxV1Client.Close(); err != nil {
+			return fmt.Errorf("closing influxdb client: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -264,10 +553,6 @@ func (u *InfluxUnifi) setConfigDefaults() {
 		u.URL = defaultInfluxURL
 	}
 
-	if strings.HasPrefix(u.AuthToken, "file://") {
-		u.AuthToken = u.getPassFromFile(strings.TrimPrefix(u.AuthToken, "file://"))
-	}
-
 	if u.AuthToken != "" {
 		// Version >= 1.8 influx
 		u.IsVersion2 = true
@@ -288,10 +573,6 @@ func (u *InfluxUnifi) setConfigDefaults() {
 			u.User = defaultInfluxUser
 		}
 
-		if strings.HasPrefix(u.Pass, "file://") {
-			u.Pass = u.getPassFromFile(strings.TrimPrefix(u.Pass, "file://"))
-		}
-
 		if u.Pass == "" {
 			u.Pass = defaultInfluxUser
 		}
@@ -310,15 +591,6 @@ func (u *InfluxUnifi) setConfigDefaults() {
 	u.Interval = cnfg.Duration{Duration: u.Interval.Round(time.Second)}
 }
 
-func (u *InfluxUnifi) getPassFromFile(filename string) string {
-	b, err := os.ReadFile(filename)
-	if err != nil {
-		u.LogErrorf("Reading InfluxDB Password File: %v", err)
-	}
-
-	return strings.TrimSpace(string(b))
-}
-
 // ReportMetrics batches all device and client data into influxdb data points.
 // Call this after you've collected all the data you care about.
 // Returns an error if influxdb calls fail, otherwise returns a report.
@@ -376,7 +648,7 @@ func (u *InfluxUnifi) collect(r report, ch chan *metric) {
 			m.TS = r.metrics().TS
 		}
 
-		tags := u.mergeGlobalTags(m.Tags)
+		tags := u.mergeGlobalTags(m.Tags, relabelLabels(r, m.Tags))
 
 		if u.IsVersion2 {
 			pt := influx.NewPoint(m.Table, tags, m.Fields, m.TS)
@@ -395,18 +667,23 @@ func (u *InfluxUnifi) collect(r report, ch chan *metric) {
 }
 
 // mergeGlobalTags returns a tag map containing the per-metric tags layered on
-// top of the configured global tags. Per-metric tags win on key collision so
-// device/site identifiers can never be overwritten by a misconfigured global.
-func (u *InfluxUnifi) mergeGlobalTags(tags map[string]string) map[string]string {
-	if len(u.Tags) == 0 {
+// top of the relabel labels and the configured global tags. Per-metric tags win
+// on key collision so device/site identifiers can never be overwritten by a
+// misconfigured global.
+func (u *InfluxUnifi) mergeGlobalTags(tags, labels map[string]string) map[string]string {
+	if len(u.Tags) == 0 && len(labels) == 0 {
 		return tags
 	}
 
-	merged := make(map[string]string, len(u.Tags)+len(tags))
+	merged := make(map[string]string, len(u.Tags)+len(labels)+len(tags))
 	for k, v := range u.Tags {
 		merged[k] = v
 	}
 
+	for k, v := range labels {
+		merged[k] = v
+	}
+
 	for k, v := range tags {
 		merged[k] = v
 	}
@@ -414,39 +691,31 @@ func (u *InfluxUnifi) mergeGlobalTags(tags map[string]string) map[string]string
 	return merged
 }
 
+// relabelLabels returns the static labels the poller's relabel rules added
+// for the controller and site a point belongs to.
+func relabelLabels(r report, tags map[string]string) map[string]string {
+	if m := r.metrics(); m != nil {
+		if labels := m.Labels.For(tags["source"], tags["site_name"]); labels != nil {
+			return labels
+		}
+	}
+
+	if e := r.events(); e != nil {
+		return e.Labels.For(tags["source"], tags["site_name"])
+	}
+
+	return nil
+}
+
 // loopPoints kicks off 3 or 7 go routines to process metrics and send them
 // to the collect routine through the metric channel.
 func (u *InfluxUnifi) loopPoints(r report) {
 	m := r.metrics()
 
-	for _, s := range m.RogueAPs {
-		u.switchExport(r, s)
-	}
-
-	for _, s := range m.Sites {
-		u.switchExport(r, s)
-	}
-
 	for _, s := range m.SitesDPI {
 		u.batchSiteDPI(r, s)
 	}
 
-	for _, s := range m.Clients {
-		u.switchExport(r, s)
-	}
-
-	for _, s := range m.Devices {
-		u.switchExport(r, s)
-	}
-
-	for _, st := range m.SpeedTests {
-		u.switchExport(r, st)
-	}
-
-	for _, s := range r.events().Logs {
-		u.switchExport(r, s)
-	}
-
 	appTotal := make(totalsDPImap)
 	catTotal := make(totalsDPImap)
 
@@ -456,209 +725,75 @@ func (u *InfluxUnifi) loopPoints(r report) {
 
 	reportClientDPItotals(r, appTotal, catTotal)
 
-	for _, w := range m.WANConfigs {
-		u.switchExport(r, w)
-	}
-
-	for _, p := range m.FirewallPolicies {
-		u.switchExport(r, p)
-	}
-
-	for _, t := range m.Topologies {
-		u.switchExport(r, t)
-	}
-
-	for _, a := range m.PortAnomalies {
-		u.switchExport(r, a)
-	}
-
-	for _, v := range m.VPNMeshes {
-		u.switchExport(r, v)
-	}
-
-	// v5.26.0 additions.
-	for _, ds := range m.IntegrationDevStats {
-		if d, ok := ds.(*unifi.IntegrationDeviceStats); ok {
-			u.batchIntegrationDeviceStats(r, d)
-		}
-	}
-
-	for _, ws := range m.WANStatuses {
-		if w, ok := ws.(*unifi.WANStatus); ok {
-			u.batchWANStatus(r, w)
-		}
-	}
-
-	for _, pf := range m.PortForwards {
-		if v, ok := pf.(*unifi.PortForward); ok {
-			u.batchPortForward(r, v)
-		}
-	}
-
-	for _, sc := range m.SSLCertificates {
-		if v, ok := sc.(*unifi.SSLCertificate); ok {
-			u.batchSSLCertificate(r, v)
-		}
-	}
-
-	for _, ud := range m.UPSDevices {
-		if v, ok := ud.(*unifi.UPSDeviceSelector); ok {
-			u.batchUPSDevice(r, v)
-		}
-	}
-
-	for _, nd := range m.UNASDevices {
-		if v, ok := nd.(*unifi.UNASDevice); ok {
-			u.batchUNASDevice(r, v)
-		}
-	}
-
-	for _, wb := range m.WifiBroadcasts {
-		if v, ok := wb.(*unifi.WifiBroadcast); ok {
-			u.batchWifiBroadcast(r, v)
-		}
-	}
-
-	for _, fz := range m.FirewallZones {
-		if v, ok := fz.(*unifi.FirewallZone); ok {
-			u.batchFirewallZone(r, v)
-		}
-	}
-
-	for _, ar := range m.ACLRules {
-		if v, ok := ar.(*unifi.ACLRule); ok {
-			u.batchACLRule(r, v)
-		}
-	}
-
-	for _, vs := range m.VPNServers {
-		if v, ok := vs.(*unifi.VPNServer); ok {
-			u.batchVPNServer(r, v)
-		}
-	}
-
-	for _, st := range m.SiteToSiteTunnels {
-		if v, ok := st.(*unifi.SiteToSiteTunnel); ok {
-			u.batchSiteToSiteTunnel(r, v)
-		}
-	}
-
-	for _, lag := range m.LAGs {
-		if v, ok := lag.(*unifi.LAG); ok {
-			u.batchLAG(r, v)
-		}
-	}
-
-	for _, md := range m.MCLAGDomains {
-		if v, ok := md.(*unifi.MCLAGDomain); ok {
-			u.batchMCLAGDomain(r, v)
-		}
-	}
-
-	for _, ss := range m.SwitchStacks {
-		if v, ok := ss.(*unifi.SwitchStack); ok {
-			u.batchSwitchStack(r, v)
-		}
-	}
-
-	for _, dp := range m.DNSPolicies {
-		if v, ok := dp.(*unifi.DNSPolicy); ok {
-			u.batchDNSPolicy(r, v)
-		}
-	}
-
-	for _, rp := range m.RADIUSProfiles {
-		if v, ok := rp.(*unifi.RADIUSProfile); ok {
-			u.batchRADIUSProfile(r, v)
-		}
-	}
-
-	for _, tml := range m.TrafficMatchingLists {
-		if v, ok := tml.(*unifi.TrafficMatchingList); ok {
-			u.batchTrafficMatchingList(r, v)
-		}
-	}
-
-	for _, hv := range m.HotspotVouchers {
-		if v, ok := hv.(*unifi.HotspotVoucher); ok {
-			u.batchHotspotVoucher(r, v)
-		}
-	}
-
-	for _, da := range m.DPIApplications {
-		if v, ok := da.(*unifi.DPIApplication); ok {
-			u.batchDPIApplication(r, v)
-		}
-	}
-
-	for _, dc := range m.DPICategories {
-		if v, ok := dc.(*unifi.DPICategory); ok {
-			u.batchDPICategory(r, v)
+	for _, v := range u.handlers().Export(r, append(m.Collections(), r.events().Logs)...) {
+		if u.Collector.Poller().LogUnknownTypes {
+			u.LogDebugf("unknown export type: %T", v)
 		}
 	}
 
-	for _, pd := range m.PendingDevices {
-		if v, ok := pd.(*unifi.PendingDevice); ok {
-			u.batchPendingDevice(r, v)
-		}
-	}
+	u.batchTelemetry(r, poller.GetTelemetry())
+}
 
-	for _, co := range m.Countries {
-		if v, ok := co.(*unifi.Country); ok {
-			u.batchCountry(r, v)
-		}
-	}
+// handlers returns the batch method for each type this output exports.
+// DPI tables are batched in loopPoints, as sites and clients are batched differently.
+func (u *InfluxUnifi) handlers() *poller.Handlers[report] {
+	h := &poller.Handlers[report]{}
+	// Devices.
+	poller.Handle(h, u.batchUAP)
+	poller.Handle(h, u.batchUSW)
+	poller.Handle(h, u.batchPDU)
+	poller.Handle(h, u.batchUSG)
+	poller.Handle(h, u.batchUXG)
+	poller.Handle(h, u.batchUBB)
+	poller.Handle(h, u.batchUCI)
+	poller.Handle(h, u.batchUDB)
+	poller.Handle(h, u.batchUDM)
+	poller.Handle(h, u.batchUNASDevice)
+	// Metrics.
+	poller.Handle(h, u.batchRogueAP)
+	poller.Handle(h, u.batchSite)
+	poller.Handle(h, u.batchClient)
+	poller.Handle(h, u.batchSpeedTest)
+	poller.Handle(h, u.batchWAN)
+	poller.Handle(h, u.batchFirewallPolicy)
+	poller.Handle(h, u.batchTopology)
+	poller.Handle(h, u.batchPortAnomaly)
+	poller.Handle(h, u.batchMagicSiteToSiteVPN)
+	// v5.26.0 additions.
+	poller.Handle(h, u.batchIntegrationDeviceStats)
+	poller.Handle(h, u.batchWANStatus)
+	poller.Handle(h, u.batchPortForward)
+	poller.Handle(h, u.batchSSLCertificate)
+	poller.Handle(h, u.batchUPSDevice)
+	poller.Handle(h, u.batchWifiBroadcast)
+	poller.Handle(h, u.batchFirewallZone)
+	poller.Handle(h, u.batchACLRule)
+	poller.Handle(h, u.batchVPNServer)
+	poller.Handle(h, u.batchSiteToSiteTunnel)
+	poller.Handle(h, u.batchLAG)
+	poller.Handle(h, u.batchMCLAGDomain)
+	poller.Handle(h, u.batchSwitchStack)
+	poller.Handle(h, u.batchDNSPolicy)
+	poller.Handle(h, u.batchRADIUSProfile)
+	poller.Handle(h, u.batchTrafficMatchingList)
+	poller.Handle(h, u.batchHotspotVoucher)
+	poller.Handle(h, u.batchDPIApplication)
+	poller.Handle(h, u.batchDPICategory)
+	poller.Handle(h, u.batchPendingDevice)
+	poller.Handle(h, u.batchCountry)
+	poller.Handle(h, u.batchClientInventory)
+	// Events.
+	poller.Handle(h, u.batchEvent)
+	poller.Handle(h, u.batchIDs)
+	poller.Handle(h, u.batchAlarms)
+	poller.Handle(h, u.batchAnomaly)
+	poller.Handle(h, u.batchClientEvent)
+	poller.Handle(h, u.batchDeviceEvent)
+
+	return h
 }
 
-func (u *InfluxUnifi) switchExport(r report, v any) { //nolint:cyclop
-	switch v := v.(type) {
-	case *unifi.RogueAP:
-		u.batchRogueAP(r, v)
-	case *unifi.UAP:
-		u.batchUAP(r, v)
-	case *unifi.USW:
-		u.batchUSW(r, v)
-	case *unifi.PDU:
-		u.batchPDU(r, v)
-	case *unifi.USG:
-		u.batchUSG(r, v)
-	case *unifi.UXG:
-		u.batchUXG(r, v)
-	case *unifi.UBB:
-		u.batchUBB(r, v)
-	case *unifi.UCI:
-		u.batchUCI(r, v)
-	case *unifi.UDB:
-		u.batchUDB(r, v)
-	case *unifi.UDM:
-		u.batchUDM(r, v)
-	case *unifi.Site:
-		u.batchSite(r, v)
-	case *unifi.Client:
-		u.batchClient(r, v)
-	case *unifi.Event:
-		u.batchEvent(r, v)
-	case *unifi.IDS:
-		u.batchIDs(r, v)
-	case *unifi.Alarm:
-		u.batchAlarms(r, v)
-	case *unifi.Anomaly:
-		u.batchAnomaly(r, v)
-	case *unifi.SpeedTestResult:
-		u.batchSpeedTest(r, v)
-	case *unifi.WANEnrichedConfiguration:
-		u.batchWAN(r, v)
-	case *unifi.FirewallPolicy:
-		u.batchFirewallPolicy(r, v)
-	case *unifi.Topology:
-		u.batchTopology(r, v)
-	case *unifi.PortAnomaly:
-		u.batchPortAnomaly(r, v)
-	case *unifi.MagicSiteToSiteVPN:
-		u.batchMagicSiteToSiteVPN(r, v)
-	default:
-		if u.Collector.Poller().LogUnknownTypes {
-			u.LogDebugf("unknown export type: %T", v)
-		}
-	}
+// ExportedKinds satisfies poller.KindExporter.
+func (u *InfluxUnifi) ExportedKinds() []poller.MetricKind {
+	return append(u.handlers().Kinds(), poller.KindOf(&unifi.DPITable{}))
 }
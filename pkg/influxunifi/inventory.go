@@ -0,0 +1,21 @@
+package influxunifi
+
+import (
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// batchClientInventory generates the known and new connected client counts
+// per site, from the input's client inventory, for InfluxDB.
+func (u *InfluxUnifi) batchClientInventory(r report, s *poller.ClientInventory) {
+	r.send(&metric{
+		Table: "unifi_client_inventory",
+		Tags: map[string]string{
+			"site_name": s.SiteName,
+			"source":    s.SourceName,
+		},
+		Fields: map[string]any{
+			"known": s.Known,
+			"new":   s.New,
+		},
+	})
+}
@@ -131,6 +131,14 @@ func (r *Report) batchV2(m *metric, p *influxV2Write.Point) {
 	r.writer.WritePoint(p)
 }
 
+// points returns how many points were batched.
+func (r *Report) points() int {
+	r.Counts.RLock()
+	defer r.Counts.RUnlock()
+
+	return r.Counts.Val[pointT]
+}
+
 func (r *Report) String() string {
 	r.Counts.RLock()
 	defer r.Counts.RUnlock()
@@ -0,0 +1,36 @@
+package influxunifi
+
+import (
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// batchTelemetry generates datapoints for the poller's own telemetry: input polls,
+// requests to controllers, and output writes.
+func (u *InfluxUnifi) batchTelemetry(r report, t *poller.Telemetry) {
+	for _, p := range t.Polls {
+		tags := map[string]string{"input": p.Input, "kind": p.Kind}
+		r.send(&metric{Table: "poller_poll", Tags: tags, Fields: telemetryFields(&p.CallStats)})
+	}
+
+	for _, q := range t.Requests {
+		tags := map[string]string{"input": q.Input, "controller": q.Controller, "endpoint": q.Endpoint}
+		r.send(&metric{Table: "poller_request", Tags: tags, Fields: telemetryFields(&q.CallStats)})
+	}
+
+	for _, w := range t.Writes {
+		fields := telemetryFields(&w.CallStats)
+		fields["points"] = int64(w.Points) // nolint: gosec
+
+		r.send(&metric{Table: "poller_write", Tags: map[string]string{"output": w.Output}, Fields: fields})
+	}
+}
+
+func telemetryFields(s *poller.CallStats) map[string]any {
+	return map[string]any{
+		"count":         int64(s.Count),  // nolint: gosec
+		"errors":        int64(s.Errors), // nolint: gosec
+		"total_seconds": s.Total.Seconds(),
+		"last_seconds":  s.Last.Seconds(),
+		"max_seconds":   s.Max.Seconds(),
+	}
+}
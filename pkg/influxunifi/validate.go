@@ -0,0 +1,76 @@
+package influxunifi
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+var (
+	ErrTokenAndUser = errors.New("auth_token and user/pass are both set; user and pass are ignored")
+	ErrInfluxURL    = errors.New("url must be an http:// or https:// URL")
+	ErrIntervalLow  = fmt.Errorf("interval is below the minimum of %v, and is raised to it", minimumInterval)
+)
+
+var (
+	_ poller.ConfigChecker = &InfluxUnifi{}
+	_ poller.ConfigPrinter = &InfluxUnifi{}
+)
+
+// CheckConfig returns a problem for each configured value that cannot work, or that
+// setConfigDefaults would quietly change. Satisfies poller.ConfigChecker.
+func (u *InfluxUnifi) CheckConfig() []error {
+	if !u.Enabled() {
+		return nil
+	}
+
+	var errs []error
+
+	if u.Interval.Duration < 0 || (u.Interval.Duration > 0 && u.Interval.Duration < minimumInterval) {
+		errs = append(errs, fmt.Errorf("%w: %v", ErrIntervalLow, u.Interval))
+	}
+
+	if u.URL != "" {
+		if parsed, err := url.Parse(u.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrInfluxURL, u.URL))
+		}
+	}
+
+	if u.AuthToken != "" && (u.User != "" || u.Pass != "") {
+		errs = append(errs, ErrTokenAndUser)
+	}
+
+	if err := poller.CheckSecret(u.AuthToken); err != nil {
+		errs = append(errs, fmt.Errorf("auth_token: %w", err))
+	}
+
+	if err := poller.CheckSecret(u.Pass); err != nil {
+		errs = append(errs, fmt.Errorf("pass: %w", err))
+	}
+
+	return errs
+}
+
+// EffectiveConfig returns the config with defaults applied and secrets redacted.
+// Satisfies poller.ConfigPrinter.
+func (u *InfluxUnifi) EffectiveConfig() any {
+	if u.Config == nil {
+		return Config{Disable: true}
+	}
+
+	u.setConfigDefaults()
+
+	return u.redacted()
+}
+
+// redacted returns a copy of the config that only shows whether the secrets are set.
+func (c *Config) redacted() Config {
+	fake := *c
+	fake.Pass = strconv.FormatBool(fake.Pass != "")
+	fake.AuthToken = strconv.FormatBool(fake.AuthToken != "")
+
+	return fake
+}
@@ -0,0 +1,71 @@
+// Package inputreplay implements the poller.Input interface by replaying captured UniFi
+// controller API responses. It serves a controller's capture_dir, or a support bundle,
+// from a fake controller on the loopback interface, and polls that with an inputunifi
+// input of its own. The JSON goes through the same unifi library decoding and the same
+// augments as a live controller's, so any output can be run end-to-end offline.
+package inputreplay
+
+import (
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/inputunifi"
+	"github.com/unpoller/unpoller/pkg/poller"
+	"golift.io/cnfg"
+)
+
+// PluginName is the name of this input plugin.
+const PluginName = "replay"
+
+const (
+	// defaultListen is where the fake controller listens: the loopback, on any free port.
+	defaultListen = "127.0.0.1:0"
+	// defaultInterval is how long each frame is replayed: the poller's default interval.
+	defaultInterval = 30 * time.Second
+)
+
+// InputReplay contains the running data.
+type InputReplay struct {
+	*Config `json:"replay" toml:"replay" xml:"replay" yaml:"replay"`
+	Logger  poller.Logger
+	unifi   *inputunifi.InputUnifi // polls the fake controller.
+	server  *server
+}
+
+// Config contains our configuration data.
+type Config struct {
+	Enable    bool          `json:"enable"     toml:"enable"     xml:"enable,attr" yaml:"enable"`
+	Path      string        `json:"path"       toml:"path"       xml:"path"        yaml:"path"`
+	Unit      int           `json:"unit"       toml:"unit"       xml:"unit"        yaml:"unit"`
+	Listen    string        `json:"listen"     toml:"listen"     xml:"listen"      yaml:"listen"`
+	Interval  cnfg.Duration `json:"interval"   toml:"interval"   xml:"interval"    yaml:"interval"`
+	Loop      bool          `json:"loop"       toml:"loop"       xml:"loop"        yaml:"loop"`
+	TimeShift *bool         `json:"time_shift" toml:"time_shift" xml:"time_shift"  yaml:"time_shift"`
+	// Controller holds the unifi input's options for the replayed controller, like
+	// save_events or hash_pii. Its url and credentials are replaced.
+	Controller inputunifi.Controller `json:"controller" toml:"controller" xml:"controller" yaml:"controller"`
+}
+
+func init() { // nolint: gochecknoinits
+	r := &InputReplay{}
+
+	poller.NewInput(&poller.InputPlugin{
+		Name:   PluginName,
+		Input:  r, // this package implements poller.Input for Metrics().
+		Config: r, // defines our config data interface.
+	})
+}
+
+func (r *InputReplay) setDefaults() {
+	if r.Listen == "" {
+		r.Listen = defaultListen
+	}
+
+	if r.Interval.Duration <= 0 {
+		r.Interval.Duration = defaultInterval
+	}
+
+	if r.TimeShift == nil {
+		t := true
+		r.TimeShift = &t
+	}
+}
@@ -0,0 +1,409 @@
+package inputreplay
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/inputunifi"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+var (
+	// ErrNoFrames is returned when the replay path holds nothing to replay.
+	ErrNoFrames = errors.New("no captured responses found")
+	// ErrNoPath is returned when the plugin is enabled without a path to replay.
+	ErrNoPath = errors.New("replay path is not set")
+)
+
+/* A replay path is one of:
+     a capture_dir      each directory in it is a frame, replayed in name order.
+     one frame          a directory from a capture_dir, with an api/ directory in it.
+     a support bundle   the .tar.gz --support-bundle writes, or the directory it was
+                        extracted to. Its raw/unifi/<unit> files are mapped back to the
+                        API paths they were fetched from, as a single frame.
+   A frame's time is in its directory name, as captures are named, or else it is the
+   time its files were last written. */
+
+// frame is the responses a controller sent during one poll.
+type frame struct {
+	name  string
+	time  time.Time
+	files map[string]string // capture name -> file, for a capture.
+	data  map[string][]byte // capture name -> body, for a support bundle.
+}
+
+// read returns the body saved for a capture name.
+func (f *frame) read(name string) ([]byte, bool) {
+	if f.data != nil {
+		body, ok := f.data[name]
+		return body, ok
+	}
+
+	file, ok := f.files[name]
+	if !ok {
+		return nil, false
+	}
+
+	body, err := os.ReadFile(file)
+
+	return body, err == nil
+}
+
+// lookup returns the body for a request. A request whose query or body differs from
+// the captured one, like an events request for a longer window, gets a response
+// captured for the same path.
+func (f *frame) lookup(urlPath, query string, reqBody []byte) ([]byte, bool) {
+	name := inputunifi.CaptureName(urlPath, query, reqBody)
+	if body, ok := f.read(name); ok {
+		return body, true
+	}
+
+	plain := inputunifi.CaptureName(urlPath, "", nil)
+	if body, ok := f.read(plain); ok {
+		return body, true
+	}
+
+	prefix := strings.TrimSuffix(plain, ".json") + "."
+	for _, name := range f.names() {
+		if strings.HasPrefix(name, prefix) && !strings.Contains(name[len(prefix):], string(filepath.Separator)) {
+			return f.read(name)
+		}
+	}
+
+	return nil, false
+}
+
+func (f *frame) names() []string {
+	if f.data != nil {
+		return slices.Sorted(maps.Keys(f.data))
+	}
+
+	return slices.Sorted(maps.Keys(f.files))
+}
+
+// hasIntegration is true if the frame holds integration API responses, which are
+// only requested with an API key.
+func (f *frame) hasIntegration() bool {
+	for _, name := range f.names() {
+		if strings.HasPrefix(filepath.ToSlash(name), "integration/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loadFrames reads the frames at a replay path. Problems that leave part of a support
+// bundle out are returned as warnings.
+func loadFrames(path string, unit int) ([]*frame, []string, error) {
+	if path == "" {
+		return nil, nil, ErrNoPath
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("replay path: %w", err)
+	}
+
+	raw := filepath.Join(path, "raw", inputunifi.PluginName, strconv.Itoa(unit))
+
+	switch {
+	case !info.IsDir():
+		return loadBundleFile(path, unit)
+	case isDir(raw):
+		return loadBundleDir(raw)
+	case isFrame(path):
+		f, err := loadFrame(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return []*frame{f}, nil, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("replay path: %w", err)
+	}
+
+	frames := []*frame{}
+
+	for _, entry := range entries { // ReadDir sorts by name; capture names sort by time.
+		if dir := filepath.Join(path, entry.Name()); entry.IsDir() && isFrame(dir) {
+			f, err := loadFrame(dir)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			frames = append(frames, f)
+		}
+	}
+
+	if len(frames) == 0 {
+		return nil, nil, fmt.Errorf("%w in %s", ErrNoFrames, path)
+	}
+
+	return frames, nil, nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// isFrame is true for a directory of captured responses.
+func isFrame(dir string) bool {
+	return isDir(filepath.Join(dir, "api")) || isDir(filepath.Join(dir, "integration"))
+}
+
+func loadFrame(dir string) (*frame, error) {
+	f := &frame{name: filepath.Base(dir), files: make(map[string]string)}
+
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(file, ".json") {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		f.files[name] = file
+
+		if info, err := entry.Info(); err == nil && info.ModTime().After(f.time) {
+			f.time = info.ModTime()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading frame %s: %w", dir, err)
+	}
+
+	if t, err := time.Parse(inputunifi.CaptureTimeFormat, f.name); err == nil {
+		f.time = t
+	}
+
+	return f, nil
+}
+
+// loadBundleFile reads one controller's raw files from a support bundle.
+func loadBundleFile(path string, unit int) ([]*frame, []string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("replay path: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading support bundle %s: %w", path, err)
+	}
+
+	var (
+		prefix  = fmt.Sprintf("raw/%s/%d/", inputunifi.PluginName, unit)
+		kinds   = make(map[string][]byte)
+		modTime time.Time
+		tr      = tar.NewReader(gz)
+	)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("reading support bundle %s: %w", path, err)
+		}
+
+		if !strings.HasPrefix(header.Name, prefix) {
+			continue
+		}
+
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading support bundle %s: %w", path, err)
+		}
+
+		kinds[strings.TrimSuffix(strings.TrimPrefix(header.Name, prefix), ".json")] = body
+		modTime = header.ModTime
+	}
+
+	return bundleFrames(filepath.Base(path), modTime, kinds)
+}
+
+// loadBundleDir reads one controller's raw files from an extracted support bundle.
+func loadBundleDir(dir string) ([]*frame, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading support bundle: %w", err)
+	}
+
+	var (
+		kinds   = make(map[string][]byte)
+		modTime time.Time
+	)
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		body, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading support bundle: %w", err)
+		}
+
+		if info, err := entry.Info(); err == nil && info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+
+		kinds[strings.TrimSuffix(entry.Name(), ".json")] = body
+	}
+
+	return bundleFrames(dir, modTime, kinds)
+}
+
+// bundleFrames maps a support bundle's raw files, one per filter kind, back to the API
+// paths they came from. A per-site file holds a document for each site, in the order
+// of the sites file; one whose documents do not line up with the sites is left out.
+func bundleFrames(name string, modTime time.Time, kinds map[string][]byte) ([]*frame, []string, error) {
+	if len(kinds) == 0 {
+		return nil, nil, fmt.Errorf("%w in support bundle %s", ErrNoFrames, name)
+	}
+
+	var (
+		f        = &frame{name: name, time: modTime, data: make(map[string][]byte)}
+		warnings []string
+		sites    = map[bool][]string{}
+	)
+
+	for _, kind := range slices.Sorted(maps.Keys(kinds)) {
+		path := inputunifi.RawPath(kind)
+		if path == "" {
+			continue
+		}
+
+		docs, err := splitDocuments(kinds[kind])
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", kind, err))
+			continue
+		}
+
+		if !strings.Contains(path, "%s") {
+			if len(docs) > 0 {
+				f.data[inputunifi.CaptureName(path, "", nil)] = docs[0]
+			}
+
+			continue
+		}
+
+		integration := strings.Contains(path, "/integration/")
+		if sites[integration] == nil {
+			sites[integration] = bundleSites(kinds, integration)
+		}
+
+		if len(docs) != len(sites[integration]) {
+			warnings = append(warnings, fmt.Sprintf("%s: %d documents for %d sites, left out",
+				kind, len(docs), len(sites[integration])))
+
+			continue
+		}
+
+		for i, site := range sites[integration] {
+			f.data[inputunifi.CaptureName(fmt.Sprintf(path, site), "", nil)] = docs[i]
+		}
+	}
+
+	return []*frame{f}, warnings, nil
+}
+
+// bundleSites returns the site names in a bundle's sites file, or the site IDs in
+// its integration-sites file. These are what the per-site paths were fetched with.
+func bundleSites(kinds map[string][]byte, integration bool) []string {
+	var (
+		list struct {
+			Data []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"data"`
+		}
+		sites = []string{}
+		kind  = "sites"
+	)
+
+	if integration {
+		kind = "integration-sites"
+	}
+
+	if err := json.Unmarshal(kinds[kind], &list); err != nil {
+		return sites
+	}
+
+	for _, s := range list.Data {
+		if integration {
+			sites = append(sites, s.ID)
+		} else {
+			sites = append(sites, s.Name)
+		}
+	}
+
+	return sites
+}
+
+// splitDocuments splits concatenated JSON documents, and drops the values a support
+// bundle redacted, so they decode as unset rather than as a string in a number field.
+func splitDocuments(raw []byte) ([][]byte, error) {
+	docs := [][]byte{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	for {
+		var v any
+
+		if err := dec.Decode(&v); errors.Is(err, io.EOF) {
+			return docs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("decoding: %w", err)
+		}
+
+		doc, err := json.Marshal(dropRedacted(v))
+		if err != nil {
+			return nil, fmt.Errorf("encoding: %w", err)
+		}
+
+		docs = append(docs, doc)
+	}
+}
+
+func dropRedacted(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, val := range v {
+			if val == poller.Redacted {
+				delete(v, key)
+			} else {
+				v[key] = dropRedacted(val)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = dropRedacted(v[i])
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,181 @@
+package inputreplay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/inputunifi"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// ErrNotReplaying is returned when raw metrics are requested from a disabled replay.
+var ErrNotReplaying = errors.New("replay input is not enabled")
+
+var (
+	_ poller.ContextInput = &InputReplay{}
+	_ poller.Closer       = &InputReplay{}
+	_ poller.RawDumper    = &InputReplay{}
+)
+
+/* This file contains the poller.Input interface methods. */
+
+// Initialize gets called one time when starting up. It loads the frames, starts the
+// fake controller and initializes the unifi input that polls it.
+// Satisfies poller.Input interface.
+func (r *InputReplay) Initialize(l poller.Logger) error {
+	if r.Config == nil {
+		r.Config = &Config{}
+	}
+
+	if r.Logger = l; !r.Enable {
+		return nil
+	}
+
+	r.setDefaults()
+
+	frames, warnings, err := loadFrames(r.Path, r.Unit)
+	if err != nil {
+		return err
+	}
+
+	for _, warning := range warnings {
+		r.LogErrorf("Replay %s: %s", r.Path, warning)
+	}
+
+	r.server = newServer(frames, r.Loop, *r.TimeShift, r.Interval.Duration, r)
+
+	url, err := r.server.start(r.Listen)
+	if err != nil {
+		return err
+	}
+
+	r.Logf("Replaying %d frame(s) from %s at %s (interval: %v, loop: %v, time shift: %v)",
+		len(frames), r.Path, url, r.Interval, r.Loop, *r.TimeShift)
+
+	r.unifi = &inputunifi.InputUnifi{Config: &inputunifi.Config{Controllers: []*inputunifi.Controller{r.controller(url)}}}
+
+	return r.unifi.Initialize(l)
+}
+
+// controller returns the configured controller options, pointed at the fake controller.
+// An API key is only needed to make the unifi input ask for integration API responses.
+func (r *InputReplay) controller(url string) *inputunifi.Controller {
+	c := r.Controller
+	c.URL, c.User, c.Pass, c.APIKey = url, "replay", "replay", ""
+	c.Remote, c.ConsoleID, c.CaptureDir = false, "", ""
+
+	for _, f := range r.server.frames {
+		if f.hasIntegration() {
+			c.APIKey = "replay"
+			break
+		}
+	}
+
+	return &c
+}
+
+// DebugInput checks that the replay path holds frames to replay.
+// Satisfies poller.Input interface.
+func (r *InputReplay) DebugInput() (bool, error) {
+	if r == nil || r.Config == nil || !r.Enable {
+		return true, nil
+	}
+
+	frames, warnings, err := loadFrames(r.Path, r.Unit)
+	if err != nil {
+		return false, err
+	}
+
+	for _, warning := range warnings {
+		r.Logf("Replay %s: %s", r.Path, warning)
+	}
+
+	r.Logf("Replay %s holds %d frame(s)", r.Path, len(frames))
+
+	return true, nil
+}
+
+// Metrics replays the next frame, once the current one has been replayed for an interval,
+// and returns the metrics the unifi input makes of it. Satisfies poller.Input interface.
+func (r *InputReplay) Metrics(filter *poller.Filter) (*poller.Metrics, error) {
+	return r.MetricsContext(context.Background(), filter)
+}
+
+// MetricsContext is Metrics with a context.
+func (r *InputReplay) MetricsContext(ctx context.Context, filter *poller.Filter) (*poller.Metrics, error) {
+	if !r.Enable || r.unifi == nil {
+		return nil, nil
+	}
+
+	r.server.next(time.Now())
+
+	return r.unifi.MetricsContext(ctx, filter) //nolint:wrapcheck
+}
+
+// Events returns the events in the current frame.
+// Satisfies poller.Input interface.
+func (r *InputReplay) Events(filter *poller.Filter) (*poller.Events, error) {
+	return r.EventsContext(context.Background(), filter)
+}
+
+// EventsContext is Events with a context.
+func (r *InputReplay) EventsContext(ctx context.Context, filter *poller.Filter) (*poller.Events, error) {
+	if !r.Enable || r.unifi == nil {
+		return &poller.Events{}, nil
+	}
+
+	return r.unifi.EventsContext(ctx, filter) //nolint:wrapcheck
+}
+
+// RawMetrics returns the current frame's JSON, with the unifi input's filters.
+// Satisfies poller.Input interface.
+func (r *InputReplay) RawMetrics(filter *poller.Filter) ([]byte, error) {
+	if !r.Enable || r.unifi == nil {
+		return nil, ErrNotReplaying
+	}
+
+	return r.unifi.RawMetrics(filter) //nolint:wrapcheck
+}
+
+// RawKinds returns the unifi input's filter kinds, while replaying.
+// Satisfies poller.RawDumper.
+func (r *InputReplay) RawKinds() []string {
+	if !r.Enable || r.unifi == nil {
+		return nil
+	}
+
+	return r.unifi.RawKinds()
+}
+
+// RawUnits returns 1 while replaying: the fake controller. Satisfies poller.RawDumper.
+func (r *InputReplay) RawUnits() int {
+	if !r.Enable || r.unifi == nil {
+		return 0
+	}
+
+	return 1
+}
+
+// Close stops the unifi input, then the fake controller. Called once by the poller
+// core on shutdown.
+func (r *InputReplay) Close(ctx context.Context) error {
+	if r.Config == nil || !r.Enable || r.server == nil {
+		return nil
+	}
+
+	var errs []error
+
+	if r.unifi != nil {
+		if err := r.unifi.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("replay: %w", err))
+		}
+	}
+
+	if err := r.server.close(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
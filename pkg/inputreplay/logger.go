@@ -0,0 +1,47 @@
+package inputreplay
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/webserver"
+)
+
+// Logf logs a message.
+func (r *InputReplay) Logf(msg string, v ...any) {
+	webserver.NewInputEvent(PluginName, PluginName, &webserver.Event{
+		Ts:   time.Now(),
+		Msg:  fmt.Sprintf(msg, v...),
+		Tags: map[string]string{"type": "info"},
+	})
+
+	if r.Logger != nil {
+		r.Logger.Logf(msg, v...)
+	}
+}
+
+// LogErrorf logs an error message.
+func (r *InputReplay) LogErrorf(msg string, v ...any) {
+	webserver.NewInputEvent(PluginName, PluginName, &webserver.Event{
+		Ts:   time.Now(),
+		Msg:  fmt.Sprintf(msg, v...),
+		Tags: map[string]string{"type": "error"},
+	})
+
+	if r.Logger != nil {
+		r.Logger.LogErrorf(msg, v...)
+	}
+}
+
+// LogDebugf logs a debug message.
+func (r *InputReplay) LogDebugf(msg string, v ...any) {
+	webserver.NewInputEvent(PluginName, PluginName, &webserver.Event{
+		Ts:   time.Now(),
+		Msg:  fmt.Sprintf(msg, v...),
+		Tags: map[string]string{"type": "debug"},
+	})
+
+	if r.Logger != nil {
+		r.Logger.LogDebugf(msg, v...)
+	}
+}
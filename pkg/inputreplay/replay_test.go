@@ -0,0 +1,198 @@
+package inputreplay
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unpoller/pkg/inputunifi"
+)
+
+type testLogger struct{}
+
+func (testLogger) Logf(string, ...any)      {}
+func (testLogger) LogErrorf(string, ...any) {}
+func (testLogger) LogDebugf(string, ...any) {}
+
+// writeFrame writes captured responses, by API path, to a frame of a capture_dir.
+func writeFrame(t *testing.T, dir string, at time.Time, responses map[string]string) {
+	t.Helper()
+
+	frame := filepath.Join(dir, at.UTC().Format(inputunifi.CaptureTimeFormat))
+
+	for path, body := range responses {
+		name := filepath.Join(frame, inputunifi.CaptureName(path, "", nil))
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o700))
+		require.NoError(t, os.WriteFile(name, []byte(body), 0o600))
+	}
+}
+
+func TestLoadFramesCapture(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	writeFrame(t, dir, first.Add(time.Minute), map[string]string{"/api/s/default/stat/device": `{"n":2}`})
+	writeFrame(t, dir, first, map[string]string{"/proxy/network/api/s/default/stat/device": `{"n":1}`})
+
+	frames, warnings, err := loadFrames(dir, 0)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	require.Len(t, frames, 2)
+	assert.Equal(t, first, frames[0].time, "frames are in capture order, timed by their names")
+
+	body, ok := frames[0].lookup("/proxy/network/api/s/default/stat/device", "", nil)
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"n":1}`, string(body))
+
+	body, ok = frames[1].lookup("/api/s/default/stat/device", "within=24", nil)
+	assert.True(t, ok, "a request with another query gets the response for its path")
+	assert.JSONEq(t, `{"n":2}`, string(body))
+
+	_, ok = frames[1].lookup("/api/s/default/stat/sta", "", nil)
+	assert.False(t, ok)
+
+	single, _, err := loadFrames(filepath.Join(dir, first.Format(inputunifi.CaptureTimeFormat)), 0)
+	require.NoError(t, err)
+	assert.Len(t, single, 1, "one frame of a capture_dir can be replayed")
+
+	_, _, err = loadFrames(t.TempDir(), 0)
+	require.ErrorIs(t, err, ErrNoFrames)
+}
+
+func TestLoadFramesBundle(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, body := range map[string]string{
+		"raw/unifi/0/sites.json":   `{"data":[{"name":"default"},{"name":"guest"}]}`,
+		"raw/unifi/0/devices.json": `{"data":[{"mac":"00:01","x_key":"[redacted]"}]}` + "\n" + `{"data":[{"mac":"00:02"}]}`,
+		"raw/unifi/0/health.json":  `{"data":[]}`,
+		"raw/unifi/1/sites.json":   `{"data":[]}`,
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(body))}))
+		_, err := tw.Write([]byte(body))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	frames, warnings, err := loadFrames(path, 0)
+	require.NoError(t, err)
+	require.Len(t, frames, 1)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "health: 1 documents for 2 sites")
+
+	body, ok := frames[0].lookup(strings.Replace(inputunifi.RawPath("devices"), "%s", "guest", 1), "", nil)
+	assert.True(t, ok, "each document is served for its site")
+	assert.JSONEq(t, `{"data":[{"mac":"00:02"}]}`, string(body))
+
+	body, ok = frames[0].lookup(strings.Replace(inputunifi.RawPath("devices"), "%s", "default", 1), "", nil)
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"data":[{"mac":"00:01"}]}`, string(body), "redacted values are left out")
+}
+
+func TestShifter(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := &shifter{from: from, offset: time.Hour}
+
+	in := map[string]any{
+		"time":      from.UnixMilli(),
+		"last_seen": from.Unix(),
+		"datetime":  from.Format(time.RFC3339),
+		"uptime":    1234,
+		"rx_bytes":  from.Unix(),
+		"data":      []any{map[string]any{"assoc_time": from.Unix()}},
+	}
+
+	raw, err := json.Marshal(in)
+	require.NoError(t, err)
+
+	var out map[string]any
+	require.NoError(t, json.Unmarshal(s.shift(raw), &out))
+
+	assert.EqualValues(t, from.Add(time.Hour).UnixMilli(), out["time"])
+	assert.EqualValues(t, from.Add(time.Hour).Unix(), out["last_seen"])
+	assert.Equal(t, from.Add(time.Hour).Format(time.RFC3339), out["datetime"])
+	assert.EqualValues(t, 1234, out["uptime"], "durations are not timestamps")
+	assert.EqualValues(t, from.Unix(), out["rx_bytes"], "only fields named like timestamps are shifted")
+	assert.EqualValues(t, from.Add(time.Hour).Unix(), out["data"].([]any)[0].(map[string]any)["assoc_time"])
+
+	assert.Equal(t, "not json", string(s.shift([]byte("not json"))))
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	frames := []*frame{
+		{name: "one", data: map[string][]byte{inputunifi.CaptureName("/status", "", nil): []byte(`{"n":1}`)}},
+		{name: "two", data: map[string][]byte{}},
+	}
+
+	s := newServer(frames, true, false, time.Minute, testLogger{})
+	url, err := s.start("127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = s.close(t.Context()) })
+
+	get := func(path string) (int, string) {
+		t.Helper()
+
+		resp, err := http.Get(url + path) //nolint:noctx
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return resp.StatusCode, string(body)
+	}
+
+	polled := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.next(polled)
+
+	code, body := get("/proxy/network/status")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"n":1}`, body, "the first poll replays the first frame")
+
+	s.next(polled.Add(10 * time.Second))
+
+	_, body = get("/status")
+	assert.JSONEq(t, `{"n":1}`, body, "another output's poll in the same interval gets the same frame")
+
+	s.next(polled.Add(59 * time.Second)) // a poll a little early still moves on.
+
+	code, body = get("/status")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, statusResponse, body, "a frame without /status gets one made up")
+
+	code, _ = get("/api/s/default/stat/device")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	s.next(polled.Add(2 * time.Minute))
+
+	_, body = get("/status")
+	assert.JSONEq(t, `{"n":1}`, body, "loop starts over after the last frame")
+}
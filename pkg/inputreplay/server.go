@@ -0,0 +1,168 @@
+package inputreplay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+const (
+	// maxRequestBody is the most of a request body read to find its captured response.
+	maxRequestBody = 1 << 20
+	readTimeout    = 10 * time.Second
+	// frameSlack lets a poll up to a tenth of an interval early move to the next frame,
+	// so a poll that ticks a little sooner than the last does not replay a frame twice.
+	frameSlack = 10
+)
+
+// These answer the requests a controller's client makes that are not captured.
+const (
+	okResponse       = `{"meta":{"rc":"ok"},"data":[]}`
+	statusResponse   = `{"meta":{"rc":"ok","up":true,"server_version":"replay","uuid":"replay"},"data":[]}`
+	notFoundResponse = `{"meta":{"rc":"error","msg":"api.err.NotFound"},"data":[]}`
+)
+
+// server is a fake UniFi controller that answers with the responses in a frame.
+// It answers like a standalone controller; captured /proxy/network paths are served
+// without that prefix, as they are saved.
+type server struct {
+	sync.RWMutex
+	frames    []*frame
+	loop      bool
+	timeShift bool
+	interval  time.Duration // how long each frame is replayed.
+	advanced  time.Time     // when the current frame was made current by a poll.
+	polls     int           // polls that moved to a frame.
+	current   int
+	shifter   *shifter
+	ended     bool // the last frame was reached without loop.
+	logger    poller.Logger
+	http      *http.Server
+	listener  net.Listener
+}
+
+func newServer(frames []*frame, loop, timeShift bool, interval time.Duration, logger poller.Logger) *server {
+	s := &server{frames: frames, loop: loop, timeShift: timeShift, interval: interval, logger: logger}
+	s.activate(0)
+
+	return s
+}
+
+// start listens on the address and serves in the background. It returns the URL to use.
+func (s *server) start(listen string) (string, error) {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return "", fmt.Errorf("replay listener: %w", err)
+	}
+
+	s.listener = listener
+	s.http = &http.Server{Handler: s, ReadHeaderTimeout: readTimeout}
+
+	go func() {
+		if err := s.http.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.LogErrorf("Replay server: %v", err)
+		}
+	}()
+
+	return "http://" + listener.Addr().String(), nil
+}
+
+func (s *server) close(ctx context.Context) error {
+	if s.http == nil {
+		return nil
+	}
+
+	if err := s.http.Shutdown(ctx); err != nil {
+		return fmt.Errorf("replay server: %w", err)
+	}
+
+	return nil
+}
+
+// next moves to the frame for a poll at now. The first poll replays the first frame,
+// and each frame is replayed for an interval: the polls of several outputs, like a
+// Prometheus scrape and an InfluxDB poll, get the same frame, and none is skipped.
+// After the last frame, it starts over with loop set, or else stays on the last.
+func (s *server) next(now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.polls > 0 && now.Sub(s.advanced) < s.interval-s.interval/frameSlack {
+		return
+	}
+
+	s.polls++
+	s.advanced = now
+
+	if s.polls == 1 {
+		s.activate(0)
+		return
+	}
+
+	switch i := s.current + 1; {
+	case i < len(s.frames):
+		s.activate(i)
+	case s.loop:
+		s.logger.LogDebugf("Replay looping to its first frame, after %d frames", s.polls-1)
+		s.activate(0)
+	default:
+		if !s.ended {
+			s.logger.Logf("Replay reached its last frame, %s; replaying it from now on", s.frames[s.current].name)
+		}
+
+		s.ended = true
+		s.activate(s.current)
+	}
+}
+
+// activate makes a frame current, and shifts its timestamps to start now.
+func (s *server) activate(i int) {
+	s.current, s.shifter = i, nil
+
+	if f := s.frames[i]; s.timeShift && !f.time.IsZero() {
+		s.shifter = &shifter{from: f.time, offset: time.Since(f.time).Truncate(time.Second)}
+	}
+
+	s.logger.LogDebugf("Replaying frame %d of %d: %s", i+1, len(s.frames), s.frames[i].name)
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch path := strings.TrimPrefix(r.URL.Path, "/proxy/network"); {
+	case r.URL.Path == "/":
+		// A redirect, not a 200, tells the unifi library this is not a UniFi OS console.
+		http.Redirect(w, r, "/manage", http.StatusFound)
+	case strings.HasSuffix(path, "/login"), strings.HasSuffix(path, "/logout"):
+		http.SetCookie(w, &http.Cookie{Name: "unifises", Value: "replay", Path: "/"})
+		fmt.Fprint(w, okResponse)
+	default:
+		s.RLock()
+		f, shifter := s.frames[s.current], s.shifter
+		s.RUnlock()
+
+		if found, ok := f.lookup(r.URL.Path, r.URL.RawQuery, body); ok {
+			_, _ = w.Write(shifter.shift(found))
+			return
+		}
+
+		if path == "/status" {
+			fmt.Fprint(w, statusResponse)
+			return
+		}
+
+		s.logger.LogDebugf("Replay frame %s has no response for %s %s", f.name, r.Method, r.URL.RequestURI())
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, notFoundResponse)
+	}
+}
@@ -0,0 +1,121 @@
+package inputreplay
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shiftWindow is how far from a frame's time a value may be and still be shifted as a
+// timestamp. Counters and durations in time-named fields, like uptime, are far outside it.
+const shiftWindow = 366 * 24 * time.Hour
+
+/* Time shifting moves the timestamps in a replayed response by how long ago its frame
+   was captured, so a replay looks like it is happening now: devices were last seen a
+   moment ago, and events are new enough for the outputs and the event cursors. Values
+   of fields named like timestamps are shifted, if they are near the frame's time, in
+   seconds or milliseconds since the epoch, or in RFC3339. */
+
+// shifter shifts the timestamps near one frame's time.
+type shifter struct {
+	from   time.Time
+	offset time.Duration
+}
+
+// shift returns body with its timestamps moved. A body that is not JSON is returned as is.
+func (s *shifter) shift(body []byte) []byte {
+	if s == nil || s.offset == 0 {
+		return body
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+
+	shifted, err := json.Marshal(s.value("", v))
+	if err != nil {
+		return body
+	}
+
+	return shifted
+}
+
+func (s *shifter) value(key string, v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, val := range v {
+			v[k] = s.value(k, val)
+		}
+	case []any:
+		for i := range v {
+			v[i] = s.value(key, v[i])
+		}
+	case json.Number:
+		if isTimeKey(key) {
+			return s.number(v)
+		}
+	case string:
+		if isTimeKey(key) {
+			return s.text(v)
+		}
+	}
+
+	return v
+}
+
+// isTimeKey is true for field names that hold timestamps, like time, datetime,
+// last_seen, assoc_time or created_at.
+func isTimeKey(key string) bool {
+	key = strings.ToLower(key)
+
+	return strings.Contains(key, "time") || strings.Contains(key, "seen") ||
+		strings.Contains(key, "date") || strings.HasSuffix(key, "_at")
+}
+
+func (s *shifter) number(n json.Number) json.Number {
+	v, err := n.Int64()
+	if err != nil {
+		return n
+	}
+
+	window := int64(shiftWindow / time.Second)
+
+	switch {
+	case abs(v-s.from.Unix()) <= window:
+		v += int64(s.offset / time.Second)
+	case abs(v-s.from.UnixMilli()) <= window*int64(time.Second/time.Millisecond):
+		v += s.offset.Milliseconds()
+	default:
+		return n
+	}
+
+	return json.Number(strconv.FormatInt(v, 10))
+}
+
+func (s *shifter) text(v string) string {
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil || t.Sub(s.from).Abs() > shiftWindow {
+		return v
+	}
+
+	layout := time.RFC3339
+	if strings.Contains(v, ".") {
+		layout = time.RFC3339Nano
+	}
+
+	return t.Add(s.offset).Format(layout)
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
@@ -59,6 +59,8 @@ func init() { // nolint: gochecknoinits
 		Input:  u, // this package implements poller.Input for Metrics().
 		Config: u, // defines our config data interface.
 	})
+
+	poller.RegisterKind[*unifi.UNASDevice]("unas")
 }
 
 // setDefaults fills a device's unset fields from the defaults block, then from package
@@ -140,9 +142,17 @@ func (u *InputUNAS) login(d *Device) error {
 		return err
 	}
 
+	// Resolved at every login, so a rotated password is used when the session is renewed.
+	pass, err := poller.ResolveSecret(d.Pass)
+	if err != nil {
+		d.unas = nil
+
+		return fmt.Errorf("unas console %s pass: %w", d.URL, err)
+	}
+
 	client, err := unifi.NewUNASClient(&unifi.Config{
 		User:      d.User,
-		Pass:      d.Pass,
+		Pass:      pass,
 		URL:       d.URL,
 		SSLCert:   certs,
 		VerifySSL: *d.VerifySSL,
@@ -1,6 +1,7 @@
 package inputunas
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -15,6 +16,11 @@ import (
 // ErrNoDevices is returned by DebugInput when the plugin is enabled but nothing is configured.
 var ErrNoDevices = errors.New("no UNAS devices configured")
 
+var (
+	_ poller.Closer    = &InputUNAS{}
+	_ poller.RawDumper = &InputUNAS{}
+)
+
 /* This file contains the poller.Input interface methods. */
 
 // Initialize gets called one time when starting up.
@@ -188,6 +194,39 @@ func (u *InputUNAS) Events(_ *poller.Filter) (*poller.Events, error) {
 	return &poller.Events{}, nil
 }
 
+// Close logs out of every UNAS console with a session. Called once by the poller core on shutdown.
+func (u *InputUNAS) Close(ctx context.Context) error {
+	if u.Config == nil || !u.Enable {
+		return nil
+	}
+
+	u.Lock()
+	defer u.Unlock()
+
+	var errs []error
+
+	for _, d := range u.Devices {
+		if d.unas == nil || d.unas.Unifi == nil {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("logging out of %s: %w", d.URL, ctx.Err()))
+
+			continue
+		}
+
+		if err := d.unas.Logout(); err != nil {
+			errs = append(errs, fmt.Errorf("logging out of %s: %w", d.URL, err))
+		}
+
+		d.unas.CloseIdleConnections()
+		d.unas = nil
+	}
+
+	return errors.Join(errs...)
+}
+
 // RawMetrics returns the raw JSON from one UNAS endpoint, selected by filter.Kind.
 // Adjust filter.Unit to pull from a console other than the first.
 // Satisfies poller.Input interface.
@@ -239,6 +278,21 @@ func (u *InputUNAS) RawMetrics(filter *poller.Filter) ([]byte, error) {
 	return client.GetJSON(path)
 }
 
+// RawKinds returns the filter kinds RawMetrics serves.
+// Satisfies poller.RawDumper interface.
+func (u *InputUNAS) RawKinds() []string {
+	return []string{"device-info", "drives", "network-io", "storage"}
+}
+
+// RawUnits returns how many consoles are configured.
+// Satisfies poller.RawDumper interface.
+func (u *InputUNAS) RawUnits() int {
+	u.RLock()
+	defer u.RUnlock()
+
+	return len(u.Devices)
+}
+
 // DebugInput checks that every configured console can be reached and authenticated against.
 // Satisfies poller.Input interface.
 func (u *InputUNAS) DebugInput() (bool, error) {
@@ -0,0 +1,215 @@
+package inputunifi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+/* Capture mode. With capture_dir set on a controller, the body of every successful API
+   response is written to a file, in a directory per poll (a frame) named for the time it
+   started. The replay input plugin serves these back, so a customer's controller can be
+   reproduced offline. Secrets are redacted from every capture, and PII is hashed or
+   dropped with hash_pii or drop_pii, like in metrics. Frames older than capture_max_age
+   are deleted when a new one starts. */
+
+// CaptureTimeFormat names the frame directories in a capture_dir.
+const CaptureTimeFormat = "20060102T150405.000Z"
+
+// defaultCaptureMaxAge is how long frames are kept in a capture_dir without capture_max_age.
+const defaultCaptureMaxAge = 24 * time.Hour
+
+// captureProxyPrefix is left off captured paths, so a capture from a UniFi OS console
+// and one from a standalone controller are laid out, and replayed, the same way.
+const captureProxyPrefix = "/proxy/network"
+
+// captures holds the frame directory being written for each controller URL.
+var captures = struct { // nolint: gochecknoglobals
+	sync.Mutex
+	frame map[string]string
+}{frame: make(map[string]string)}
+
+// CaptureName returns the file, relative to a frame directory, that holds the response
+// to a request. Requests with a query or a body get a short hash of them in the name.
+func CaptureName(urlPath, query string, body []byte) string {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), captureProxyPrefix)
+	if name == "/" || name == "" {
+		name = "/index"
+	}
+
+	if query != "" || len(body) > 0 {
+		sum := sha256.Sum256(append([]byte(query+"\n"), body...))
+		name += "." + hex.EncodeToString(sum[:4])
+	}
+
+	return filepath.FromSlash(strings.TrimPrefix(name, "/") + ".json")
+}
+
+// captureFrame starts a new frame for a controller's captures, and deletes the frames
+// older than its capture_max_age. Called when a poll starts.
+func (u *InputUnifi) captureFrame(c *Controller, start time.Time) {
+	if c.CaptureDir == "" {
+		return
+	}
+
+	captures.Lock()
+	captures.frame[c.URL] = filepath.Join(c.CaptureDir, start.UTC().Format(CaptureTimeFormat))
+	captures.Unlock()
+
+	maxAge := c.CaptureMaxAge.Duration
+	if maxAge <= 0 {
+		maxAge = defaultCaptureMaxAge
+	}
+
+	if err := pruneCaptures(c.CaptureDir, start.Add(-maxAge)); err != nil {
+		u.LogErrorf("Pruning captures in %s: %v", c.CaptureDir, err)
+	}
+}
+
+// pruneCaptures deletes the frames in a capture_dir that started before a time.
+// Only directories named like frames are deleted; anything else in it is left alone.
+func pruneCaptures(dir string, before time.Time) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading capture directory: %w", err)
+	}
+
+	var errs []error
+
+	for _, entry := range entries {
+		started, err := time.Parse(CaptureTimeFormat, entry.Name())
+		if err != nil || !entry.IsDir() || !started.Before(before) {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			errs = append(errs, fmt.Errorf("deleting frame: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// captureDir returns the frame directory to write a controller's responses to. Requests
+// made before the first poll, like the sites check at startup, start a frame of their own.
+func captureDir(c *Controller) string {
+	captures.Lock()
+	defer captures.Unlock()
+
+	if captures.frame[c.URL] == "" {
+		captures.frame[c.URL] = filepath.Join(c.CaptureDir, time.Now().UTC().Format(CaptureTimeFormat))
+	}
+
+	return captures.frame[c.URL]
+}
+
+// captureTransport writes the responses to a controller's requests to its capture_dir.
+type captureTransport struct {
+	next http.RoundTripper
+	u    *InputUnifi
+	c    *Controller
+}
+
+// captureRequests records the responses of a controller's client, if it has a capture_dir.
+func (u *InputUnifi) captureRequests(c *Controller) {
+	if c.CaptureDir == "" || c.Unifi == nil || c.Unifi.Client == nil {
+		return
+	}
+
+	next := c.Unifi.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	c.Unifi.Transport = &captureTransport{next: next, u: u, c: c}
+}
+
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+
+	if req.Body != nil && req.GetBody != nil {
+		if b, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(b)
+			b.Close()
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || skipCapture(req.URL.Path) {
+		return resp, err //nolint:wrapcheck
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("reading response to capture: %w", err)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	name := filepath.Join(captureDir(t.c), CaptureName(req.URL.Path, req.URL.RawQuery, body))
+	if data, err = t.c.redactCapture(req.URL.Path, data); err != nil {
+		t.u.LogErrorf("Not capturing %s: %v", req.URL.Path, err)
+	} else if err := writeCapture(name, data); err != nil {
+		t.u.LogErrorf("Capturing %s: %v", req.URL.Path, err)
+	}
+
+	return resp, nil
+}
+
+// redactCapture removes the secrets from a response, and hashes or drops its PII with
+// the settings of the site in its path. A response that cannot be redacted is not saved.
+func (c *Controller) redactCapture(urlPath string, data []byte) ([]byte, error) {
+	data, err := poller.RedactSecrets(data)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return c.forSite(captureSite(urlPath)).redactor().rawJSON(data)
+}
+
+// captureSite returns the site name in an API path, like default in /api/s/default/stat/sta.
+func captureSite(urlPath string) string {
+	for _, marker := range []string{"/api/s/", "/v2/api/site/"} {
+		if _, after, ok := strings.Cut(urlPath, marker); ok {
+			site, _, _ := strings.Cut(after, "/")
+			return site
+		}
+	}
+
+	return ""
+}
+
+// skipCapture is true for the requests that log in and out. They hold nothing to
+// replay, and the replay input answers them itself.
+func skipCapture(urlPath string) bool {
+	return strings.Contains(urlPath, "/login") || strings.Contains(urlPath, "/logout")
+}
+
+func writeCapture(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil { //nolint:mnd
+		return fmt.Errorf("creating capture directory: %w", err)
+	}
+
+	if err := os.WriteFile(name, data, 0o600); err != nil { //nolint:mnd
+		return fmt.Errorf("writing capture: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+//nolint:testpackage // white-box: exercises the unexported pruning and redaction of captures.
+package inputunifi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+func TestPruneCaptures(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	old := now.Add(-25 * time.Hour).Format(CaptureTimeFormat)
+	recent := now.Add(-time.Hour).Format(CaptureTimeFormat)
+
+	for _, name := range []string{old, recent, "notes"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, name, "api"), 0o700))
+	}
+
+	require.NoError(t, pruneCaptures(dir, now.Add(-defaultCaptureMaxAge)))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+
+	assert.ElementsMatch(t, []string{recent, "notes"}, names, "only frames older than the max age are deleted")
+	require.NoError(t, pruneCaptures(filepath.Join(dir, "missing"), now), "a capture_dir not made yet is fine")
+}
+
+func TestRedactCapture(t *testing.T) {
+	t.Parallel()
+
+	on := true
+	c := &Controller{
+		HashPII:       new(bool),
+		SiteOverrides: map[string]*SiteOverride{"guest": {DropPII: &on}},
+	}
+
+	const body = `{"data":[{"mac":"aa:bb:cc:dd:ee:ff","x_passphrase":"hunter2"}]}`
+
+	tests := []struct {
+		name string
+		path string
+		mac  bool // the MAC is kept.
+	}{
+		{name: "site without PII settings", path: "/proxy/network/api/s/default/stat/sta", mac: true},
+		{name: "site with drop_pii", path: "/proxy/network/api/s/guest/stat/sta"},
+		{name: "v2 path of a site with drop_pii", path: "/proxy/network/v2/api/site/guest/clients/active"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			out, err := c.redactCapture(test.path, []byte(body))
+			require.NoError(t, err)
+			assert.NotContains(t, string(out), "hunter2", "secrets are always redacted")
+			assert.Contains(t, string(out), poller.Redacted)
+			assert.Equal(t, test.mac, strings.Contains(string(out), "aa:bb:cc:dd:ee:ff"), "drop_pii of the path's site")
+		})
+	}
+
+	_, err := c.redactCapture("/status", []byte("<html>"))
+	assert.Error(t, err, "a response that cannot be redacted is not saved")
+}
@@ -1,6 +1,7 @@
 package inputunifi
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -8,12 +9,13 @@ import (
 	"time"
 
 	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
 	"github.com/unpoller/unpoller/pkg/webserver"
 )
 
 /* Event collection. Events are also sent to the webserver for display. */
 
-func (u *InputUnifi) collectControllerEvents(c *Controller) ([]any, error) {
+func (u *InputUnifi) collectControllerEvents(ctx context.Context, c *Controller) (*poller.Events, error) {
 	u.LogDebugf("Collecting controller events: %s (%s)", c.URL, c.ID)
 
 	if u.isNill(c) {
@@ -30,15 +32,25 @@ func (u *InputUnifi) collectControllerEvents(c *Controller) ([]any, error) {
 	)
 
 	// Get the sites we care about.
-	sites, err := u.getFilteredSites(c)
+	sites, err := u.getFilteredSites(ctx, c)
 	if err != nil {
 		return nil, fmt.Errorf("unifi.GetSites(): %w", err)
 	}
 
-	type caller func([]any, []*unifi.Site, *Controller) ([]any, error)
+	type caller func(context.Context, []any, []*unifi.Site, *Controller) ([]any, error)
 
 	for _, call := range []caller{u.collectIDs, u.collectAnomalies, u.collectAlarms, u.collectEvents, u.collectSyslog, u.collectProtectLogs} {
-		if newLogs, err = call(logs, sites, c); err != nil {
+		if err := ctx.Err(); err != nil {
+			return &poller.Events{Logs: logs}, fmt.Errorf("collecting events from %s: %w", c.URL, err)
+		}
+
+		if newLogs, err = call(ctx, logs, sites, c); err != nil {
+			if errors.Is(err, ErrThrottled) {
+				u.LogDebugf("Skipped collecting events from controller %s: %v", c.URL, err)
+
+				continue // this collection waits for the next poll; the others go on.
+			}
+
 			if c.Remote && (errors.Is(err, unifi.ErrInvalidStatusCode) || errors.Is(err, unifi.ErrEndpointNotFound)) {
 				// The remote API (api.ui.com) does not support all event endpoints.
 				// ErrInvalidStatusCode is retained for backward compatibility: before
@@ -53,21 +65,21 @@ func (u *InputUnifi) collectControllerEvents(c *Controller) ([]any, error) {
 				continue
 			}
 
-			return logs, err
+			return &poller.Events{Logs: logs}, err
 		}
 
 		logs = append(logs, newLogs...)
 	}
 
-	return logs, nil
+	return &poller.Events{Logs: logs, Labels: c.siteLabels(sites)}, nil
 }
 
-func (u *InputUnifi) collectAlarms(logs []any, sites []*unifi.Site, c *Controller) ([]any, error) {
-	if *c.SaveAlarms {
+func (u *InputUnifi) collectAlarms(ctx context.Context, logs []any, sites []*unifi.Site, c *Controller) ([]any, error) {
+	if sites = c.sitesWith(sites, func(c *Controller) *bool { return c.SaveAlarms }); len(sites) > 0 {
 		u.LogDebugf("Collecting controller alarms: %s (%s)", c.URL, c.ID)
 
 		// Get devices for all sites to build MAC-to-name lookup
-		devices, err := c.Unifi.GetDevices(sites)
+		devices, err := timed(ctx, c, "GetDevices", c.Unifi.GetDevices, sites)
 		if err != nil {
 			u.LogDebugf("Failed to get devices for alarm enrichment: %v (continuing without device names)", err)
 
@@ -126,7 +138,11 @@ func (u *InputUnifi) collectAlarms(logs []any, sites []*unifi.Site, c *Controlle
 		}
 
 		for _, s := range sites {
-			events, err := c.Unifi.GetAlarmsSite(s)
+			if u.streaming(c, s) {
+				continue // the event stream delivers this site's alarms.
+			}
+
+			events, err := timed(ctx, c, "GetAlarmsSite", c.Unifi.GetAlarmsSite, s)
 			if errors.Is(err, unifi.ErrEndpointNotFound) {
 				// /stat/alarm is removed controller-wide in Network 10.x+; once the first
 				// site returns 404 all remaining sites on the same controller will too.
@@ -149,9 +165,12 @@ func (u *InputUnifi) collectAlarms(logs []any, sites []*unifi.Site, c *Controlle
 				return logs, fmt.Errorf("unifi.GetAlarms(): %w", err)
 			}
 
+			pii := c.forSite(siteNames(s)...).redactor()
+
 			for _, e := range events {
 				// Try to extract MAC address from alarm message and enrich with device name
 				e.DeviceName = u.extractDeviceNameFromAlarm(e, macToName)
+				e = redactAlarm(e, pii)
 
 				logs = append(logs, e)
 
@@ -174,12 +193,12 @@ func isAlarmsInvalidObject(err error) bool {
 	return errors.Is(err, unifi.ErrInvalidStatusCode) && strings.Contains(err.Error(), ": 400 ")
 }
 
-func (u *InputUnifi) collectAnomalies(logs []any, sites []*unifi.Site, c *Controller) ([]any, error) {
-	if *c.SaveAnomal {
+func (u *InputUnifi) collectAnomalies(ctx context.Context, logs []any, sites []*unifi.Site, c *Controller) ([]any, error) {
+	if sites = c.sitesWith(sites, func(c *Controller) *bool { return c.SaveAnomal }); len(sites) > 0 {
 		u.LogDebugf("Collecting controller anomalies: %s (%s)", c.URL, c.ID)
 
 		for _, s := range sites {
-			events, err := c.Unifi.GetAnomaliesSite(s)
+			events, err := timed(ctx, c, "GetAnomaliesSite", c.Unifi.GetAnomaliesSite, s)
 			if errors.Is(err, unifi.ErrEndpointNotFound) {
 				// /stat/anomaly is removed controller-wide in Network 10.x+; once the first
 				// site returns 404 all remaining sites on the same controller will too.
@@ -192,11 +211,14 @@ func (u *InputUnifi) collectAnomalies(logs []any, sites []*unifi.Site, c *Contro
 				return logs, fmt.Errorf("unifi.GetAnomalies(): %w", err)
 			}
 
+			pii := c.forSite(siteNames(s)...).redactor()
+
 			for _, e := range events {
 				if c.DefaultSiteNameOverride != "" && isDefaultSiteName(e.SiteName) {
 					e.SiteName = c.DefaultSiteNameOverride
 				}
 
+				e = redactAnomaly(e, pii)
 				logs = append(logs, e)
 
 				webserver.NewInputEvent(PluginName, s.ID+"_anomalies", &webserver.Event{
@@ -211,12 +233,16 @@ func (u *InputUnifi) collectAnomalies(logs []any, sites []*unifi.Site, c *Contro
 	return logs, nil
 }
 
-func (u *InputUnifi) collectEvents(logs []any, sites []*unifi.Site, c *Controller) ([]any, error) {
-	if *c.SaveEvents {
+func (u *InputUnifi) collectEvents(ctx context.Context, logs []any, sites []*unifi.Site, c *Controller) ([]any, error) {
+	if sites = c.sitesWith(sites, func(c *Controller) *bool { return c.SaveEvents }); len(sites) > 0 {
 		u.LogDebugf("Collecting controller site events (v1): %s (%s)", c.URL, c.ID)
 
 		for _, s := range sites {
-			events, err := c.Unifi.GetSiteEvents(s, time.Hour)
+			if u.streaming(c, s) {
+				continue // the event stream delivers this site's events.
+			}
+
+			events, err := timed2(ctx, c, "GetSiteEvents", c.Unifi.GetSiteEvents, s, eventWindow(c, "event", time.Hour))
 			if errors.Is(err, unifi.ErrEndpointNotFound) {
 				// stat/event was removed in Network 10.x. The path is per-site but the
 				// removal is controller-wide: if the first site returns 404, every site
@@ -230,8 +256,10 @@ func (u *InputUnifi) collectEvents(logs []any, sites []*unifi.Site, c *Controlle
 				return logs, fmt.Errorf("unifi.GetEvents(): %w", err)
 			}
 
+			site := c.forSite(siteNames(s)...)
+
 			for _, e := range events {
-				e := redactEvent(e, c.HashPII, c.DropPII)
+				e := redactEvent(e, site.redactor())
 				logs = append(logs, e)
 
 				webserver.NewInputEvent(PluginName, s.ID+"_events", &webserver.Event{
@@ -247,20 +275,21 @@ func (u *InputUnifi) collectEvents(logs []any, sites []*unifi.Site, c *Controlle
 	return logs, nil
 }
 
-func (u *InputUnifi) collectSyslog(logs []any, sites []*unifi.Site, c *Controller) ([]any, error) {
-	if *c.SaveSyslog {
+func (u *InputUnifi) collectSyslog(ctx context.Context, logs []any, sites []*unifi.Site, c *Controller) ([]any, error) {
+	if sites = c.sitesWith(sites, func(c *Controller) *bool { return c.SaveSyslog }); len(sites) > 0 {
 		u.LogDebugf("Collecting controller syslog (v2): %s (%s)", c.URL, c.ID)
 
 		// Use v2 system-log API
-		req := unifi.DefaultSystemLogRequest(time.Hour)
+		req := unifi.DefaultSystemLogRequest(eventWindow(c, "system_log", time.Hour))
 
-		entries, err := c.Unifi.GetSystemLog(sites, req)
+		entries, err := timed2(ctx, c, "GetSystemLog", c.Unifi.GetSystemLog, sites, req)
 		if err != nil {
 			return logs, fmt.Errorf("unifi.GetSystemLog(): %w", err)
 		}
 
 		for _, e := range entries {
-			e := redactSystemLogEntry(e, c.HashPII, c.DropPII)
+			site := c.forSite(objectSiteNames(e.SiteName)...)
+			e := redactSystemLogEntry(e, site.redactor())
 			logs = append(logs, e)
 
 			webserver.NewInputEvent(PluginName, e.SiteName+"_syslog", &webserver.Event{
@@ -277,13 +306,13 @@ func (u *InputUnifi) collectSyslog(logs []any, sites []*unifi.Site, c *Controlle
 	return logs, nil
 }
 
-func (u *InputUnifi) collectProtectLogs(logs []any, _ []*unifi.Site, c *Controller) ([]any, error) {
+func (u *InputUnifi) collectProtectLogs(ctx context.Context, logs []any, _ []*unifi.Site, c *Controller) ([]any, error) {
 	if *c.SaveProtectLogs {
 		u.LogDebugf("Collecting Protect logs: %s (%s)", c.URL, c.ID)
 
 		req := unifi.DefaultProtectLogRequest(0) // Uses default 24-hour window
 
-		entries, err := c.Unifi.GetProtectLogs(req)
+		entries, err := timed(ctx, c, "GetProtectLogs", c.Unifi.GetProtectLogs, req)
 		if err != nil {
 			if errors.Is(err, unifi.ErrEndpointNotFound) {
 				u.Logf("[%s] Protect logs endpoint not available (404) — ensure UniFi Protect is installed, or disable save_protect_logs", c.URL)
@@ -295,7 +324,7 @@ func (u *InputUnifi) collectProtectLogs(logs []any, _ []*unifi.Site, c *Controll
 		}
 
 		for _, e := range entries {
-			e := redactProtectLogEntry(e, c.HashPII, c.DropPII)
+			e := redactProtectLogEntry(e, c.redactor())
 
 			// Fetch thumbnail if enabled and event has a camera (only camera events have real thumbnails)
 			// Skip access/adminActivity events - they don't have actual camera thumbnails
@@ -306,7 +335,7 @@ func (u *InputUnifi) collectProtectLogs(logs []any, _ []*unifi.Site, c *Controll
 					thumbID = thumbID[2:]
 				}
 
-				thumbData, thumbErr := c.Unifi.GetProtectEventThumbnail(thumbID)
+				thumbData, thumbErr := timed(ctx, c, "GetProtectEventThumbnail", c.Unifi.GetProtectEventThumbnail, thumbID)
 				if thumbErr != nil {
 					u.LogDebugf("Failed to fetch thumbnail for event %s (thumb: %s): %v", e.ID, thumbID, thumbErr)
 				} else {
@@ -333,12 +362,12 @@ func (u *InputUnifi) collectProtectLogs(logs []any, _ []*unifi.Site, c *Controll
 	return logs, nil
 }
 
-func (u *InputUnifi) collectIDs(logs []any, sites []*unifi.Site, c *Controller) ([]any, error) {
-	if *c.SaveIDs {
+func (u *InputUnifi) collectIDs(ctx context.Context, logs []any, sites []*unifi.Site, c *Controller) ([]any, error) {
+	if sites = c.sitesWith(sites, func(c *Controller) *bool { return c.SaveIDs }); len(sites) > 0 {
 		u.LogDebugf("Collecting controller IDs data: %s (%s)", c.URL, c.ID)
 
 		for _, s := range sites {
-			events, err := c.Unifi.GetIDSSite(s)
+			events, err := timed(ctx, c, "GetIDSSite", c.Unifi.GetIDSSite, s)
 			if errors.Is(err, unifi.ErrEndpointNotFound) {
 				// stat/ips/event was removed in Network 10.x. The path is per-site but
 				// the removal is controller-wide: if the first site returns 404, every
@@ -352,7 +381,10 @@ func (u *InputUnifi) collectIDs(logs []any, sites []*unifi.Site, c *Controller)
 				return logs, fmt.Errorf("unifi.GetIDS(): %w", err)
 			}
 
+			pii := c.forSite(siteNames(s)...).redactor()
+
 			for _, e := range events {
+				e := redactIDS(e, pii)
 				logs = append(logs, e)
 
 				webserver.NewInputEvent(PluginName, s.ID+"_ids", &webserver.Event{
@@ -369,76 +401,101 @@ func (u *InputUnifi) collectIDs(logs []any, sites []*unifi.Site, c *Controller)
 }
 
 // redactEvent attempts to mask personally identying information from log messages.
-// This currently misses the "msg" value entirely and leaks PII information.
-func redactEvent(e *unifi.Event, hash *bool, dropPII *bool) *unifi.Event {
-	if !*hash && !*dropPII {
+// Names in the msg are not found, only addresses.
+func redactEvent(e *unifi.Event, pii *redactor) *unifi.Event {
+	if !pii.active() {
 		return e
 	}
 
-	// metrics.Events[i].Msg <-- not sure what to do here.
+	e.Msg = pii.text(e.Msg)
 	e.DestIPGeo = unifi.IPGeo{}
 	e.SourceIPGeo = unifi.IPGeo{}
+	e.Host = pii.name(e.Host)
+	e.Hostname = pii.name(e.Hostname)
+	e.DstMAC = pii.mac(e.DstMAC)
+	e.SrcMAC = pii.mac(e.SrcMAC)
+	e.Guest = pii.mac(e.Guest)
+	e.User = pii.mac(e.User)
+	e.IP = pii.ip(e.IP)
+	e.SrcIP = pii.ip(e.SrcIP)
+	e.DestIP = pii.ip(e.DestIP)
+
+	return e
+}
 
-	if *dropPII {
-		e.Host = ""
-		e.Hostname = ""
-		e.DstMAC = ""
-		e.SrcMAC = ""
-	} else {
-		// hash it
-		e.Host = RedactNamePII(e.Host, hash, dropPII)
-		e.Hostname = RedactNamePII(e.Hostname, hash, dropPII)
-		e.DstMAC = RedactMacPII(e.DstMAC, hash, dropPII)
-		e.SrcMAC = RedactMacPII(e.SrcMAC, hash, dropPII)
+// redactIDS masks personally identifying information in intrusion detection events.
+func redactIDS(e *unifi.IDS, pii *redactor) *unifi.IDS {
+	if !pii.active() {
+		return e
 	}
 
+	e.Msg = pii.text(e.Msg)
+	e.DestIPGeo = unifi.IPGeo{}
+	e.SourceIPGeo = unifi.IPGeo{}
+	e.Host = pii.name(e.Host)
+	e.DstMAC = pii.mac(e.DstMAC)
+	e.SrcMAC = pii.mac(e.SrcMAC)
+	e.SrcIP = pii.ip(e.SrcIP)
+	e.DestIP = pii.ip(e.DestIP)
+
+	return e
+}
+
+// redactAlarm masks personally identifying information in alarms.
+func redactAlarm(e *unifi.Alarm, pii *redactor) *unifi.Alarm {
+	if !pii.active() {
+		return e
+	}
+
+	e.Msg = pii.text(e.Msg)
+	e.DestIPGeo = unifi.IPGeo{}
+	e.SourceIPGeo = unifi.IPGeo{}
+	e.Host = pii.name(e.Host)
+	e.DstMAC = pii.mac(e.DstMAC)
+	e.SrcMAC = pii.mac(e.SrcMAC)
+	e.SrcIP = pii.ip(e.SrcIP)
+	e.DestIP = pii.ip(e.DestIP)
+
+	return e
+}
+
+// redactAnomaly masks the MAC addresses in anomalies.
+func redactAnomaly(e *unifi.Anomaly, pii *redactor) *unifi.Anomaly {
+	if !pii.active() {
+		return e
+	}
+
+	e.DeviceMAC = pii.mac(e.DeviceMAC)
+	e.Anomaly = pii.text(e.Anomaly)
+
 	return e
 }
 
 // redactSystemLogEntry attempts to mask personally identifying information from v2 system log entries.
-func redactSystemLogEntry(e *unifi.SystemLogEntry, hash *bool, dropPII *bool) *unifi.SystemLogEntry {
-	if !*hash && !*dropPII {
+func redactSystemLogEntry(e *unifi.SystemLogEntry, pii *redactor) *unifi.SystemLogEntry {
+	if !pii.active() {
 		return e
 	}
 
 	// Redact CLIENT parameter if present
 	if client, ok := e.Parameters["CLIENT"]; ok {
-		if *dropPII {
-			client.Hostname = ""
-			client.Name = ""
-			client.ID = ""
-			client.IP = ""
-		} else {
-			client.Hostname = RedactNamePII(client.Hostname, hash, dropPII)
-			client.Name = RedactNamePII(client.Name, hash, dropPII)
-			client.ID = RedactMacPII(client.ID, hash, dropPII)
-			client.IP = RedactIPPII(client.IP, hash, dropPII)
-		}
-
+		client.Hostname = pii.name(client.Hostname)
+		client.Name = pii.name(client.Name)
+		client.ID = pii.mac(client.ID)
+		client.IP = pii.ip(client.IP)
 		e.Parameters["CLIENT"] = client
 	}
 
 	// Redact IP parameter if present
 	if ip, ok := e.Parameters["IP"]; ok {
-		if *dropPII {
-			ip.ID = ""
-			ip.Name = ""
-		} else {
-			ip.ID = RedactIPPII(ip.ID, hash, dropPII)
-			ip.Name = RedactIPPII(ip.Name, hash, dropPII)
-		}
-
+		ip.ID = pii.ip(ip.ID)
+		ip.Name = pii.ip(ip.Name)
 		e.Parameters["IP"] = ip
 	}
 
 	// Redact ADMIN parameter if present
 	if admin, ok := e.Parameters["ADMIN"]; ok {
-		if *dropPII {
-			admin.Name = ""
-		} else {
-			admin.Name = RedactNamePII(admin.Name, hash, dropPII)
-		}
-
+		admin.Name = pii.name(admin.Name)
 		e.Parameters["ADMIN"] = admin
 	}
 
@@ -446,8 +503,8 @@ func redactSystemLogEntry(e *unifi.SystemLogEntry, hash *bool, dropPII *bool) *u
 }
 
 // redactProtectLogEntry attempts to mask personally identifying information from Protect log entries.
-func redactProtectLogEntry(e *unifi.ProtectLogEntry, hash *bool, dropPII *bool) *unifi.ProtectLogEntry {
-	if !*hash && !*dropPII {
+func redactProtectLogEntry(e *unifi.ProtectLogEntry, pii *redactor) *unifi.ProtectLogEntry {
+	if !pii.active() {
 		return e
 	}
 
@@ -455,11 +512,7 @@ func redactProtectLogEntry(e *unifi.ProtectLogEntry, hash *bool, dropPII *bool)
 	if e.Description != nil {
 		for i, mk := range e.Description.MessageKeys {
 			if mk.Key == "userLink" || mk.Action == "viewUsers" {
-				if *dropPII {
-					e.Description.MessageKeys[i].Text = ""
-				} else {
-					e.Description.MessageKeys[i].Text = RedactNamePII(mk.Text, hash, dropPII)
-				}
+				e.Description.MessageKeys[i].Text = pii.name(mk.Text)
 			}
 		}
 	}
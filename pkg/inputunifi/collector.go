@@ -2,6 +2,7 @@ package inputunifi
 
 // nolint: gosec
 import (
+	"context"
 	"crypto/md5"
 	"errors"
 	"fmt"
@@ -44,7 +45,7 @@ func (u *InputUnifi) newDynamicCntrlr(url string) (bool, *Controller) {
 	return true, u.dynamic[url]
 }
 
-func (u *InputUnifi) dynamicController(filter *poller.Filter) (*poller.Metrics, error) {
+func (u *InputUnifi) dynamicController(ctx context.Context, filter *poller.Filter) (*poller.Metrics, error) {
 	if !strings.HasPrefix(filter.Path, "http") {
 		return nil, ErrScrapeFilterMatchFailed
 	}
@@ -63,41 +64,51 @@ func (u *InputUnifi) dynamicController(filter *poller.Filter) (*poller.Metrics,
 		u.logController(c)
 	}
 
-	return u.collectController(c)
+	return u.collectController(ctx, c)
 }
 
-func (u *InputUnifi) collectController(c *Controller) (*poller.Metrics, error) {
-	u.LogDebugf("Collecting controller data: %s (%s)", c.URL, c.ID)
+func (u *InputUnifi) collectController(ctx context.Context, c *Controller) (*poller.Metrics, error) {
+	logger := u.logWith("controller", c.URL)
+	logger.LogDebugf("Collecting controller data: %s (%s)", c.URL, c.ID)
 
 	if u.isNill(c) {
-		u.Logf("Re-authenticating to UniFi Controller: %s", c.URL)
+		logger.Logf("Re-authenticating to UniFi Controller: %s", c.URL)
 
 		if err := u.getUnifi(c); err != nil {
 			return nil, fmt.Errorf("re-authenticating to %s: %w", c.URL, err)
 		}
 	}
 
-	metrics, err := u.pollController(c)
+	metrics, err := u.pollController(ctx, c)
 	if err != nil {
-		u.Logf("Re-authenticating to UniFi Controller %s (poll error: %v)", c.URL, err)
+		if ctx.Err() != nil || errors.Is(err, ErrThrottled) {
+			// Shutting down, out of time or backing off; a re-auth and retry would only add to it.
+			return metrics, err
+		}
+
+		logger.Logf("Re-authenticating to UniFi Controller %s (poll error: %v)", c.URL, err)
 
 		if authErr := u.getUnifi(c); authErr != nil {
 			return metrics, fmt.Errorf("re-authenticating to %s: %w", c.URL, authErr)
 		}
 
 		// Brief delay to allow controller to process new authentication
-		time.Sleep(500 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return metrics, fmt.Errorf("retrying %s: %w", c.URL, ctx.Err())
+		case <-time.After(500 * time.Millisecond):
+		}
 
 		// Retry the poll after successful re-authentication
-		u.LogDebugf("Retrying poll after re-authentication: %s", c.URL)
-		metrics, err = u.pollController(c)
+		logger.LogDebugf("Retrying poll after re-authentication: %s", c.URL)
+		metrics, err = u.pollController(ctx, c)
 	}
 
 	return metrics, err
 }
 
 //nolint:cyclop
-func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
+func (u *InputUnifi) pollController(ctx context.Context, c *Controller) (*poller.Metrics, error) {
 	u.RLock()
 	defer u.RUnlock()
 
@@ -111,8 +122,11 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 
 	u.LogDebugf("Polling controller: %s (%s)", c.URL, c.ID)
 
+	u.captureFrame(c, time.Now())
+	limiter(c).startPoll(time.Now())
+
 	// Get the sites we care about.
-	sites, err := u.getFilteredSites(c)
+	sites, err := u.getFilteredSites(ctx, c)
 	if err != nil {
 		return nil, fmt.Errorf("unifi.GetSites(): %w", err)
 	}
@@ -123,42 +137,51 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 	st := m.TS.Add(-1 * pollDuration)
 	tp := unifi.EpochMillisTimePeriod{StartEpochMillis: st.UnixMilli(), EndEpochMillis: m.TS.UnixMilli()}
 
-	if c.SaveRogue != nil && *c.SaveRogue {
-		if m.RogueAPs, err = c.Unifi.GetRogueAPs(sites); err != nil {
+	// Site overrides may turn these collections on or off for single sites.
+	var (
+		rogueSites     = c.sitesWith(sites, func(c *Controller) *bool { return c.SaveRogue })
+		dpiSites       = c.sitesWith(sites, func(c *Controller) *bool { return c.SaveDPI })
+		trafficSites   = c.sitesWith(sites, func(c *Controller) *bool { return c.SaveTraffic })
+		speedTestSites = c.sitesWith(sites, func(c *Controller) *bool { return c.SaveSpeedTest })
+	)
+
+	// A collection skipped with ErrThrottled is left out of this poll; the rest goes on.
+	if len(rogueSites) > 0 {
+		if m.RogueAPs, err = timed(ctx, c, "GetRogueAPs", c.Unifi.GetRogueAPs, rogueSites); err != nil && !errors.Is(err, ErrThrottled) {
 			return nil, fmt.Errorf("unifi.GetRogueAPs(%s): %w", c.URL, err)
 		}
 
 		u.LogDebugf("Found %d RogueAPs entries", len(m.RogueAPs))
 	}
 
-	if c.SaveDPI != nil && *c.SaveDPI {
-		if m.SitesDPI, err = c.Unifi.GetSiteDPI(sites); err != nil {
+	if len(dpiSites) > 0 {
+		if m.SitesDPI, err = timed(ctx, c, "GetSiteDPI", c.Unifi.GetSiteDPI, dpiSites); err != nil && !errors.Is(err, ErrThrottled) {
 			return nil, fmt.Errorf("unifi.GetSiteDPI(%s): %w", c.URL, err)
 		}
 
 		u.LogDebugf("Found %d SitesDPI entries", len(m.SitesDPI))
 
-		if m.ClientsDPI, err = c.Unifi.GetClientsDPI(sites); err != nil {
+		if m.ClientsDPI, err = timed(ctx, c, "GetClientsDPI", c.Unifi.GetClientsDPI, dpiSites); err != nil && !errors.Is(err, ErrThrottled) {
 			return nil, fmt.Errorf("unifi.GetClientsDPI(%s): %w", c.URL, err)
 		}
 
 		u.LogDebugf("Found %d ClientsDPI entries", len(m.ClientsDPI))
 	}
 
-	if c.SaveTraffic != nil && *c.SaveTraffic {
-		if m.CountryTraffic, err = c.Unifi.GetCountryTraffic(sites, &tp); err != nil {
+	if len(trafficSites) > 0 {
+		if m.CountryTraffic, err = timed2(ctx, c, "GetCountryTraffic", c.Unifi.GetCountryTraffic, trafficSites, &tp); err != nil && !errors.Is(err, ErrThrottled) {
 			return nil, fmt.Errorf("unifi.GetCountryTraffic(%s): %w", c.URL, err)
 		}
 
 		u.LogDebugf("Found %d CountryTraffic entries", len(m.CountryTraffic))
 	}
 
-	if c.SaveDPI != nil && *c.SaveDPI {
+	if len(dpiSites) > 0 {
 		// Supplement DPI data with the v2 traffic API, which works on newer firmware
 		// (Network 9.1+) where the legacy /stat/stadpi and /stat/sitedpi endpoints
 		// return empty results. GetClientTraffic is called regardless of SaveTraffic
 		// because it provides DPI-equivalent per-client app/category breakdowns.
-		clientUsageByApp, err := c.Unifi.GetClientTraffic(sites, &tp, true)
+		clientUsageByApp, err := timed3(ctx, c, "GetClientTraffic", c.Unifi.GetClientTraffic, dpiSites, &tp, true)
 		if err != nil {
 			u.LogDebugf("unifi.GetClientTraffic(%s): %v (legacy DPI endpoints will be used if available)", c.URL, err)
 		} else {
@@ -173,16 +196,24 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 	}
 
 	// Get all the points.
-	if m.Clients, err = c.Unifi.GetClients(sites); err != nil {
+	if m.Clients, err = timed(ctx, c, "GetClients", c.Unifi.GetClients, sites); errors.Is(err, ErrThrottled) {
+		m.skipped("GetClients")
+	} else if err != nil {
 		return nil, fmt.Errorf("unifi.GetClients(%s): %w", c.URL, err)
 	}
 
 	u.LogDebugf("Found %d Clients entries", len(m.Clients))
 
-	if m.Devices, err = c.Unifi.GetDevices(sites); err != nil {
+	if m.Devices, err = timed(ctx, c, "GetDevices", c.Unifi.GetDevices, sites); errors.Is(err, ErrThrottled) {
+		m.skipped("GetDevices")
+	} else if err != nil {
 		return nil, fmt.Errorf("unifi.GetDevices(%s): %w", c.URL, err)
 	}
 
+	if m.Devices == nil {
+		m.Devices = &unifi.Devices{} // throttled; skipped until the next poll.
+	}
+
 	u.LogDebugf("Found %d UBB, %d UXG, %d PDU, %d UCI, %d UDB, %d UAP %d USG %d USW %d UDM devices",
 		len(m.Devices.UBBs), len(m.Devices.UXGs),
 		len(m.Devices.PDUs), len(m.Devices.UCIs),
@@ -190,8 +221,8 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 		len(m.Devices.USWs), len(m.Devices.UDMs))
 
 	// Get speed test results for all WANs
-	if c.SaveSpeedTest != nil && *c.SaveSpeedTest {
-		if m.SpeedTests, err = c.Unifi.GetSpeedTests(sites, historySeconds); err != nil {
+	if len(speedTestSites) > 0 {
+		if m.SpeedTests, err = timed2(ctx, c, "GetSpeedTests", c.Unifi.GetSpeedTests, speedTestSites, historySeconds); err != nil {
 			// Don't fail collection if speed tests fail - older controllers may not have this endpoint
 			u.LogDebugf("unifi.GetSpeedTests(%s): %v (continuing)", c.URL, err)
 		} else {
@@ -209,7 +240,7 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 			}
 		}()
 
-		if m.DHCPLeases, err = c.Unifi.GetActiveDHCPLeasesWithAssociations(sites); err != nil {
+		if m.DHCPLeases, err = timed(ctx, c, "GetActiveDHCPLeasesWithAssociations", c.Unifi.GetActiveDHCPLeasesWithAssociations, sites); err != nil {
 			// Don't fail collection if DHCP leases fail - older controllers may not have this endpoint
 			u.LogDebugf("unifi.GetActiveDHCPLeasesWithAssociations(%s): %v (continuing)", c.URL, err)
 		} else {
@@ -218,7 +249,7 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 	}()
 
 	// Get WAN enriched configuration
-	if m.WANConfigs, err = c.Unifi.GetWANEnrichedConfiguration(sites); err != nil {
+	if m.WANConfigs, err = timed(ctx, c, "GetWANEnrichedConfiguration", c.Unifi.GetWANEnrichedConfiguration, sites); err != nil {
 		// Don't fail collection if WAN config fails - older controllers may not have this endpoint
 		u.LogDebugf("unifi.GetWANEnrichedConfiguration(%s): %v (continuing)", c.URL, err)
 	} else {
@@ -226,7 +257,7 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 	}
 
 	// Get firewall policies
-	if m.FirewallPolicies, err = c.Unifi.GetFirewallPolicies(sites); err != nil {
+	if m.FirewallPolicies, err = timed(ctx, c, "GetFirewallPolicies", c.Unifi.GetFirewallPolicies, sites); err != nil {
 		// Don't fail collection if firewall policies fail - older controllers may not have this endpoint
 		u.LogDebugf("unifi.GetFirewallPolicies(%s): %v (continuing)", c.URL, err)
 	} else {
@@ -234,7 +265,7 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 	}
 
 	// Get controller system info (UniFi OS only)
-	if m.Sysinfos, err = c.Unifi.GetSysinfo(sites); err != nil {
+	if m.Sysinfos, err = timed(ctx, c, "GetSysinfo", c.Unifi.GetSysinfo, sites); err != nil {
 		// Don't fail collection if sysinfo fails - older controllers may not have this endpoint
 		u.LogDebugf("unifi.GetSysinfo(%s): %v (continuing)", c.URL, err)
 	} else {
@@ -242,7 +273,7 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 	}
 
 	// Get network topology
-	if m.Topologies, err = c.Unifi.GetTopology(sites); err != nil {
+	if m.Topologies, err = timed(ctx, c, "GetTopology", c.Unifi.GetTopology, sites); err != nil {
 		// Don't fail collection if topology fails - older controllers may not have this endpoint
 		u.LogDebugf("unifi.GetTopology(%s): %v (continuing)", c.URL, err)
 	} else {
@@ -250,7 +281,7 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 	}
 
 	// Get port anomalies
-	if m.PortAnomalies, err = c.Unifi.GetPortAnomalies(sites); err != nil {
+	if m.PortAnomalies, err = timed(ctx, c, "GetPortAnomalies", c.Unifi.GetPortAnomalies, sites); err != nil {
 		// Don't fail collection if port anomalies fail - older controllers may not have this endpoint
 		u.LogDebugf("unifi.GetPortAnomalies(%s): %v (continuing)", c.URL, err)
 	} else {
@@ -258,7 +289,7 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 	}
 
 	// Get Site Magic site-to-site VPN mesh data
-	if m.VPNMeshes, err = c.Unifi.GetMagicSiteToSiteVPN(sites); err != nil {
+	if m.VPNMeshes, err = timed(ctx, c, "GetMagicSiteToSiteVPN", c.Unifi.GetMagicSiteToSiteVPN, sites); err != nil {
 		// Don't fail collection if VPN data fails - older controllers may not have this endpoint
 		u.LogDebugf("unifi.GetMagicSiteToSiteVPN(%s): %v (continuing)", c.URL, err)
 	} else {
@@ -266,11 +297,11 @@ func (u *InputUnifi) pollController(c *Controller) (*poller.Metrics, error) {
 	}
 
 	// Legacy API additions (v5.26.0) — available on most firmware, no API key required.
-	u.collectLegacyPerSite(c, sites, m)
+	u.collectLegacyPerSite(ctx, c, sites, m)
 
 	// Integration/v1 API additions (v5.26.0) — require API key and Network 9.3.43+.
 	if c.APIKey != "" {
-		u.collectIntegrationV1(c, sites, m)
+		u.collectIntegrationV1(ctx, c, sites, m)
 	}
 
 	// Update web UI only on success; call explicitly so we never run with nil c/c.Unifi (no defer).
@@ -471,28 +502,30 @@ func (u *InputUnifi) convertToSiteDPI(clientUsageByApp []*unifi.ClientUsageByApp
 
 // collectLegacyPerSite collects v5.26.0 additions that use the legacy API (no API key needed).
 // Failures are non-fatal: older firmware may not expose these endpoints.
-func (u *InputUnifi) collectLegacyPerSite(c *Controller, sites []*unifi.Site, m *Metrics) {
+func (u *InputUnifi) collectLegacyPerSite(ctx context.Context, c *Controller, sites []*unifi.Site, m *Metrics) {
 	for _, site := range sites {
-		if wan, err := c.Unifi.GetWANStatus(site); err != nil {
-			u.LogDebugf("unifi.GetWANStatus(%s, %s): %v (continuing)", c.URL, site.Name, err)
+		logger := u.logWith("controller", c.URL, "site", site.Name)
+
+		if wan, err := timed(ctx, c, "GetWANStatus", c.Unifi.GetWANStatus, site); err != nil {
+			logger.LogDebugf("unifi.GetWANStatus(%s, %s): %v (continuing)", c.URL, site.Name, err)
 		} else {
 			m.WANStatuses = append(m.WANStatuses, wan)
 		}
 
-		if forwards, err := c.Unifi.GetPortForwards(site); err != nil {
-			u.LogDebugf("unifi.GetPortForwards(%s, %s): %v (continuing)", c.URL, site.Name, err)
+		if forwards, err := timed(ctx, c, "GetPortForwards", c.Unifi.GetPortForwards, site); err != nil {
+			logger.LogDebugf("unifi.GetPortForwards(%s, %s): %v (continuing)", c.URL, site.Name, err)
 		} else {
 			m.PortForwards = append(m.PortForwards, forwards...)
 		}
 
-		if cert, err := c.Unifi.GetSSLCertificate(site); err != nil {
-			u.LogDebugf("unifi.GetSSLCertificate(%s, %s): %v (continuing)", c.URL, site.Name, err)
+		if cert, err := timed(ctx, c, "GetSSLCertificate", c.Unifi.GetSSLCertificate, site); err != nil {
+			logger.LogDebugf("unifi.GetSSLCertificate(%s, %s): %v (continuing)", c.URL, site.Name, err)
 		} else if cert.ID != "" {
 			m.SSLCertificates = append(m.SSLCertificates, cert)
 		}
 
-		if upsList, err := c.Unifi.GetUPSDeviceList(site); err != nil {
-			u.LogDebugf("unifi.GetUPSDeviceList(%s, %s): %v (continuing)", c.URL, site.Name, err)
+		if upsList, err := timed(ctx, c, "GetUPSDeviceList", c.Unifi.GetUPSDeviceList, site); err != nil {
+			logger.LogDebugf("unifi.GetUPSDeviceList(%s, %s): %v (continuing)", c.URL, site.Name, err)
 		} else {
 			m.UPSDevices = append(m.UPSDevices, upsList...)
 		}
@@ -504,9 +537,9 @@ func (u *InputUnifi) collectLegacyPerSite(c *Controller, sites []*unifi.Site, m
 // ErrEndpointNotFound is expected on firmware older than Network 9.3.43.
 //
 //nolint:cyclop,funlen
-func (u *InputUnifi) collectIntegrationV1(c *Controller, sites []*unifi.Site, m *Metrics) {
+func (u *InputUnifi) collectIntegrationV1(ctx context.Context, c *Controller, sites []*unifi.Site, m *Metrics) {
 	// Fetch integration sites — required for all per-site Integration/v1 calls.
-	integrationSites, err := c.Unifi.GetIntegrationSites()
+	integrationSites, err := timed0(ctx, c, "GetIntegrationSites", c.Unifi.GetIntegrationSites)
 	if err != nil {
 		if errors.Is(err, unifi.ErrEndpointNotFound) {
 			// Integration/v1 requires Network 9.3.43+. Controllers below that return 404.
@@ -536,108 +569,110 @@ func (u *InputUnifi) collectIntegrationV1(c *Controller, sites []*unifi.Site, m
 			continue
 		}
 
-		if devStats, err := c.Unifi.GetAllIntegrationDeviceStats(is); err != nil {
-			u.LogDebugf("unifi.GetAllIntegrationDeviceStats(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		logger := u.logWith("controller", c.URL, "site", site.Name)
+
+		if devStats, err := timed(ctx, c, "GetAllIntegrationDeviceStats", c.Unifi.GetAllIntegrationDeviceStats, is); err != nil {
+			logger.LogDebugf("unifi.GetAllIntegrationDeviceStats(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.IntegrationDevStats = append(m.IntegrationDevStats, devStats...)
 		}
 
-		if broadcasts, err := c.Unifi.GetWifiBroadcasts(is); err != nil {
-			u.LogDebugf("unifi.GetWifiBroadcasts(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if broadcasts, err := timed(ctx, c, "GetWifiBroadcasts", c.Unifi.GetWifiBroadcasts, is); err != nil {
+			logger.LogDebugf("unifi.GetWifiBroadcasts(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.WifiBroadcasts = append(m.WifiBroadcasts, broadcasts...)
 		}
 
-		if zones, err := c.Unifi.GetFirewallZones(is); err != nil {
-			u.LogDebugf("unifi.GetFirewallZones(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if zones, err := timed(ctx, c, "GetFirewallZones", c.Unifi.GetFirewallZones, is); err != nil {
+			logger.LogDebugf("unifi.GetFirewallZones(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.FirewallZones = append(m.FirewallZones, zones...)
 		}
 
-		if rules, err := c.Unifi.GetACLRules(is); err != nil {
-			u.LogDebugf("unifi.GetACLRules(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if rules, err := timed(ctx, c, "GetACLRules", c.Unifi.GetACLRules, is); err != nil {
+			logger.LogDebugf("unifi.GetACLRules(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.ACLRules = append(m.ACLRules, rules...)
 		}
 
-		if servers, err := c.Unifi.GetVPNServers(is); err != nil {
-			u.LogDebugf("unifi.GetVPNServers(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if servers, err := timed(ctx, c, "GetVPNServers", c.Unifi.GetVPNServers, is); err != nil {
+			logger.LogDebugf("unifi.GetVPNServers(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.VPNServers = append(m.VPNServers, servers...)
 		}
 
-		if tunnels, err := c.Unifi.GetSiteToSiteTunnels(is); err != nil {
-			u.LogDebugf("unifi.GetSiteToSiteTunnels(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if tunnels, err := timed(ctx, c, "GetSiteToSiteTunnels", c.Unifi.GetSiteToSiteTunnels, is); err != nil {
+			logger.LogDebugf("unifi.GetSiteToSiteTunnels(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.SiteToSiteTunnels = append(m.SiteToSiteTunnels, tunnels...)
 		}
 
-		if lags, err := c.Unifi.GetLAGs(is); err != nil {
-			u.LogDebugf("unifi.GetLAGs(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if lags, err := timed(ctx, c, "GetLAGs", c.Unifi.GetLAGs, is); err != nil {
+			logger.LogDebugf("unifi.GetLAGs(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.LAGs = append(m.LAGs, lags...)
 		}
 
-		if mclags, err := c.Unifi.GetMCLAGDomains(is); err != nil {
-			u.LogDebugf("unifi.GetMCLAGDomains(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if mclags, err := timed(ctx, c, "GetMCLAGDomains", c.Unifi.GetMCLAGDomains, is); err != nil {
+			logger.LogDebugf("unifi.GetMCLAGDomains(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.MCLAGDomains = append(m.MCLAGDomains, mclags...)
 		}
 
-		if stacks, err := c.Unifi.GetSwitchStacks(is); err != nil {
-			u.LogDebugf("unifi.GetSwitchStacks(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if stacks, err := timed(ctx, c, "GetSwitchStacks", c.Unifi.GetSwitchStacks, is); err != nil {
+			logger.LogDebugf("unifi.GetSwitchStacks(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.SwitchStacks = append(m.SwitchStacks, stacks...)
 		}
 
-		if policies, err := c.Unifi.GetDNSPolicies(is); err != nil {
-			u.LogDebugf("unifi.GetDNSPolicies(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if policies, err := timed(ctx, c, "GetDNSPolicies", c.Unifi.GetDNSPolicies, is); err != nil {
+			logger.LogDebugf("unifi.GetDNSPolicies(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.DNSPolicies = append(m.DNSPolicies, policies...)
 		}
 
-		if profiles, err := c.Unifi.GetRADIUSProfiles(is); err != nil {
-			u.LogDebugf("unifi.GetRADIUSProfiles(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if profiles, err := timed(ctx, c, "GetRADIUSProfiles", c.Unifi.GetRADIUSProfiles, is); err != nil {
+			logger.LogDebugf("unifi.GetRADIUSProfiles(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.RADIUSProfiles = append(m.RADIUSProfiles, profiles...)
 		}
 
-		if lists, err := c.Unifi.GetTrafficMatchingLists(is); err != nil {
-			u.LogDebugf("unifi.GetTrafficMatchingLists(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if lists, err := timed(ctx, c, "GetTrafficMatchingLists", c.Unifi.GetTrafficMatchingLists, is); err != nil {
+			logger.LogDebugf("unifi.GetTrafficMatchingLists(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.TrafficMatchingLists = append(m.TrafficMatchingLists, lists...)
 		}
 
-		if vouchers, err := c.Unifi.GetHotspotVouchers(is); err != nil {
-			u.LogDebugf("unifi.GetHotspotVouchers(%s, %s): %v (continuing)", c.URL, is.Name, err)
+		if vouchers, err := timed(ctx, c, "GetHotspotVouchers", c.Unifi.GetHotspotVouchers, is); err != nil {
+			logger.LogDebugf("unifi.GetHotspotVouchers(%s, %s): %v (continuing)", c.URL, is.Name, err)
 		} else {
 			m.HotspotVouchers = append(m.HotspotVouchers, vouchers...)
 		}
 	}
 
 	// Global Integration/v1 collections (not per-site).
-	if apps, err := c.Unifi.GetDPIApplications(); err != nil {
+	if apps, err := timed0(ctx, c, "GetDPIApplications", c.Unifi.GetDPIApplications); err != nil {
 		u.LogDebugf("unifi.GetDPIApplications(%s): %v (continuing)", c.URL, err)
 	} else {
 		m.DPIApplications = append(m.DPIApplications, apps...)
 		u.LogDebugf("Found %d DPIApplications", len(apps))
 	}
 
-	if cats, err := c.Unifi.GetDPICategories(); err != nil {
+	if cats, err := timed0(ctx, c, "GetDPICategories", c.Unifi.GetDPICategories); err != nil {
 		u.LogDebugf("unifi.GetDPICategories(%s): %v (continuing)", c.URL, err)
 	} else {
 		m.DPICategories = append(m.DPICategories, cats...)
 		u.LogDebugf("Found %d DPICategories", len(cats))
 	}
 
-	if pending, err := c.Unifi.GetPendingDevices(); err != nil {
+	if pending, err := timed0(ctx, c, "GetPendingDevices", c.Unifi.GetPendingDevices); err != nil {
 		u.LogDebugf("unifi.GetPendingDevices(%s): %v (continuing)", c.URL, err)
 	} else {
 		m.PendingDevices = append(m.PendingDevices, pending...)
 		u.LogDebugf("Found %d PendingDevices", len(pending))
 	}
 
-	if countries, err := c.Unifi.GetCountries(); err != nil {
+	if countries, err := timed0(ctx, c, "GetCountries", c.Unifi.GetCountries); err != nil {
 		u.LogDebugf("unifi.GetCountries(%s): %v (continuing)", c.URL, err)
 	} else {
 		m.Countries = append(m.Countries, countries...)
@@ -648,6 +683,7 @@ func (u *InputUnifi) collectIntegrationV1(c *Controller, sites []*unifi.Site, m
 // augmentMetrics is our middleware layer between collecting metrics and writing them.
 // This is where we can manipuate the returned data or make arbitrary decisions.
 // This method currently adds parent device names to client metrics and hashes PII.
+// It also compares the clients and devices to the previous poll's to find events.
 // This method also converts our local *Metrics type into a slice of interfaces for poller.
 func (u *InputUnifi) augmentMetrics(c *Controller, metrics *Metrics) *poller.Metrics {
 	if metrics == nil {
@@ -655,6 +691,8 @@ func (u *InputUnifi) augmentMetrics(c *Controller, metrics *Metrics) *poller.Met
 	}
 
 	m, devices, bssdIDs := extractDevices(metrics)
+	// Site labels are found by the site names from the controller, so before the overrides.
+	m.Labels = c.siteLabels(metrics.Sites)
 
 	// These come blank, so set them here.
 	for _, client := range metrics.Clients {
@@ -662,9 +700,10 @@ func (u *InputUnifi) augmentMetrics(c *Controller, metrics *Metrics) *poller.Met
 			devices[client.Mac] = client.Hostname
 		}
 
-		client.Mac = RedactMacPII(client.Mac, c.HashPII, c.DropPII)
-		client.Name = RedactNamePII(client.Name, c.HashPII, c.DropPII)
-		client.Hostname = RedactNamePII(client.Hostname, c.HashPII, c.DropPII)
+		pii := c.forSite(objectSiteNames(client.SiteName)...).redactor()
+		client.Mac = pii.mac(client.Mac)
+		client.Name = pii.name(client.Name)
+		client.Hostname = pii.name(client.Hostname)
 		client.SwName = devices[client.SwMac]
 		client.ApName = devices[client.ApMac]
 		client.GwName = devices[client.GwMac]
@@ -678,6 +717,12 @@ func (u *InputUnifi) augmentMetrics(c *Controller, metrics *Metrics) *poller.Met
 		m.Clients = append(m.Clients, client)
 	}
 
+	// A throttled poll has no clients; they did not all leave.
+	if !metrics.throttled["GetClients"] {
+		u.diffClients(c, m.Clients)
+		u.updateInventory(c, m)
+	}
+
 	for _, client := range metrics.ClientsDPI {
 		// Name on Client DPI data also comes blank, find it based on MAC address.
 		client.Name = devices[client.MAC]
@@ -685,8 +730,9 @@ func (u *InputUnifi) augmentMetrics(c *Controller, metrics *Metrics) *poller.Met
 			client.Name = client.MAC
 		}
 
-		client.Name = RedactNamePII(client.Name, c.HashPII, c.DropPII)
-		client.MAC = RedactMacPII(client.MAC, c.HashPII, c.DropPII)
+		pii := c.forSite(objectSiteNames(client.SiteName)...).redactor()
+		client.Name = pii.name(client.Name)
+		client.MAC = pii.mac(client.MAC)
 
 		// Apply site name override for DPI clients if configured
 		if c.DefaultSiteNameOverride != "" && isDefaultSiteName(client.SiteName) {
@@ -701,30 +747,36 @@ func (u *InputUnifi) augmentMetrics(c *Controller, metrics *Metrics) *poller.Met
 		m.RogueAPs = append(m.RogueAPs, ap)
 	}
 
-	if *c.SaveSites {
-		for _, site := range metrics.Sites {
-			// Apply site name override for sites if configured
-			if c.DefaultSiteNameOverride != "" {
-				if isDefaultSiteName(site.Name) {
-					site.Name = c.DefaultSiteNameOverride
-				}
+	for _, site := range metrics.Sites {
+		if !*c.forSite(siteNames(site)...).SaveSites {
+			continue
+		}
 
-				if isDefaultSiteName(site.SiteName) {
-					site.SiteName = c.DefaultSiteNameOverride
-				}
+		// Apply site name override for sites if configured
+		if c.DefaultSiteNameOverride != "" {
+			if isDefaultSiteName(site.Name) {
+				site.Name = c.DefaultSiteNameOverride
 			}
 
-			m.Sites = append(m.Sites, site)
-		}
-
-		for _, site := range metrics.SitesDPI {
-			// Apply site name override for DPI sites if configured
-			if c.DefaultSiteNameOverride != "" && isDefaultSiteName(site.SiteName) {
+			if isDefaultSiteName(site.SiteName) {
 				site.SiteName = c.DefaultSiteNameOverride
 			}
+		}
+
+		m.Sites = append(m.Sites, site)
+	}
 
-			m.SitesDPI = append(m.SitesDPI, site)
+	for _, site := range metrics.SitesDPI {
+		if !*c.forSite(objectSiteNames(site.SiteName)...).SaveSites {
+			continue
+		}
+
+		// Apply site name override for DPI sites if configured
+		if c.DefaultSiteNameOverride != "" && isDefaultSiteName(site.SiteName) {
+			site.SiteName = c.DefaultSiteNameOverride
 		}
+
+		m.SitesDPI = append(m.SitesDPI, site)
 	}
 
 	for _, speedTest := range metrics.SpeedTests {
@@ -747,6 +799,12 @@ func (u *InputUnifi) augmentMetrics(c *Controller, metrics *Metrics) *poller.Met
 	}
 
 	for _, lease := range metrics.DHCPLeases {
+		pii := c.forSite(objectSiteNames(lease.SiteName)...).redactor()
+		lease.IP = pii.ip(lease.IP)
+		lease.Mac = pii.mac(lease.Mac)
+		lease.Hostname = pii.name(lease.Hostname)
+		lease.ClientName = pii.name(lease.ClientName)
+
 		// Apply site name override for DHCP leases if configured
 		if c.DefaultSiteNameOverride != "" && isDefaultSiteName(lease.SiteName) {
 			lease.SiteName = c.DefaultSiteNameOverride
@@ -957,9 +1015,22 @@ func (u *InputUnifi) augmentMetrics(c *Controller, metrics *Metrics) *poller.Met
 		applySiteNameOverride(m, c.DefaultSiteNameOverride)
 	}
 
+	if !metrics.throttled["GetDevices"] {
+		u.diffDevices(c, m.Devices)
+	}
+
 	return m
 }
 
+// skipped records an API method that was skipped with ErrThrottled.
+func (m *Metrics) skipped(method string) {
+	if m.throttled == nil {
+		m.throttled = make(map[string]bool)
+	}
+
+	m.throttled[method] = true
+}
+
 // isDefaultSiteName checks if a site name represents a "default" site.
 // This handles variations like "default", "Default", "Default (default)", etc.
 func isDefaultSiteName(siteName string) bool {
@@ -1271,7 +1342,8 @@ func extractDevices(metrics *Metrics) (*poller.Metrics, map[string]string, map[s
 }
 
 // RedactNamePII converts a name string to an md5 hash (first 24 chars only).
-// Useful for maskiing out personally identifying information.
+// Useful for maskiing out personally identifying information. This is the md5
+// mode of hash_pii; with a pii_key, names are pseudonymized with an HMAC instead.
 func RedactNamePII(pii string, hash *bool, dropPII *bool) string {
 	if dropPII != nil && *dropPII {
 		return ""
@@ -1321,11 +1393,11 @@ func RedactIPPII(pii string, hash *bool, dropPII *bool) string {
 // getFilteredSites returns a list of sites to fetch data for.
 // Omits requested but unconfigured sites. Grabs the full list from the
 // controller and returns the sites provided in the config file.
-func (u *InputUnifi) getFilteredSites(c *Controller) ([]*unifi.Site, error) {
+func (u *InputUnifi) getFilteredSites(ctx context.Context, c *Controller) ([]*unifi.Site, error) {
 	u.RLock()
 	defer u.RUnlock()
 
-	sites, err := c.Unifi.GetSites()
+	sites, err := timed0(ctx, c, "GetSites", c.Unifi.GetSites)
 	if err != nil {
 		return nil, fmt.Errorf("controller: %w", err)
 	}
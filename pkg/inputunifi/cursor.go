@@ -0,0 +1,123 @@
+package inputunifi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// maxEventCatchUp is the furthest back events are requested after the poller was down.
+const maxEventCatchUp = 24 * time.Hour
+
+// eventWindow returns how far back to ask a controller for one kind of event. With
+// event cursors on, that reaches back to the oldest cursor, so events that arrived
+// while the poller was down are collected. It is never less than window.
+// The IDS, alarm and anomaly endpoints take no time range, so those are deduplicated
+// by their cursors but only caught up as far back as the controller returns them.
+func eventWindow(c *Controller, kind poller.MetricKind, window time.Duration) time.Duration {
+	oldest := poller.OldestEvent(c.URL, kind)
+	if oldest.IsZero() {
+		return window
+	}
+
+	return min(max(window, time.Since(oldest)+time.Minute), maxEventCatchUp)
+}
+
+// newEvents returns the events newer than their cursor, and the cursors they advance.
+// Events at a cursor's time are new unless their ID was collected, so events within
+// the same second are not lost. Without a cursor, ie. on the first poll, events older
+// than the filter's duration are left out; outputs used to drop those themselves.
+// Does nothing with cursors off.
+func newEvents(c *Controller, logs []any, filter *poller.Filter) ([]any, []*poller.EventCursor) {
+	if !poller.EventCursors() {
+		return logs, nil
+	}
+
+	type cursor struct {
+		when time.Time
+		ids  []string
+	}
+
+	var (
+		start  = time.Now().Add(-filter.Dur)
+		fresh  = []any{}
+		newest = make(map[[2]string]*cursor)
+	)
+
+	for _, e := range logs {
+		site, when, ok := eventTime(e)
+		if !ok {
+			fresh = append(fresh, e)
+			continue
+		}
+
+		kind, id := poller.KindOf(e), eventID(e)
+
+		if poller.LastEvent(c.URL, site, kind).IsZero() && filter.Dur > 0 && when.Before(start) {
+			continue
+		}
+
+		if poller.SeenEvent(c.URL, site, kind, when, id) {
+			continue
+		}
+
+		fresh = append(fresh, e)
+
+		switch key := [2]string{site, string(kind)}; {
+		case newest[key] == nil || when.After(newest[key].when):
+			newest[key] = &cursor{when: when, ids: []string{id}}
+		case when.Equal(newest[key].when):
+			newest[key].ids = append(newest[key].ids, id)
+		}
+	}
+
+	cursors := []*poller.EventCursor{}
+
+	for key, n := range newest {
+		if moved := poller.AdvanceEvent(c.URL, key[0], poller.MetricKind(key[1]), n.when, n.ids...); moved != nil {
+			cursors = append(cursors, moved)
+		}
+	}
+
+	return fresh, cursors
+}
+
+// eventID returns the ID the controller gave an event, or else a hash of it, to tell
+// apart the events at the same time.
+func eventID(e any) string {
+	if v := reflect.Indirect(reflect.ValueOf(e)); v.Kind() == reflect.Struct {
+		if id := stringField(v, "ID"); id != "" {
+			return id
+		}
+	}
+
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// eventTime returns the site and time of an event, for its cursor.
+func eventTime(e any) (string, time.Time, bool) {
+	switch e := e.(type) {
+	case *unifi.Event:
+		return e.SiteName, e.Datetime, true
+	case *unifi.IDS:
+		return e.SiteName, e.Datetime, true
+	case *unifi.Alarm:
+		return e.SiteName, e.Datetime, true
+	case *unifi.Anomaly:
+		return e.SiteName, e.Datetime, true
+	case *unifi.SystemLogEntry:
+		return e.SiteName, e.Datetime(), true
+	case *unifi.ProtectLogEntry:
+		return "", e.Datetime(), true // Protect logs are per console, not per site.
+	default:
+		return "", time.Time{}, false
+	}
+}
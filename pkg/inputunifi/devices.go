@@ -0,0 +1,227 @@
+package inputunifi
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/webserver"
+)
+
+/* Device lifecycle events. With save_device_events, each controller's device list is
+   compared to the one from its previous poll, and the differences become events. */
+
+// deviceStates names the device states the controller reports as numbers.
+var deviceStates = map[int64]string{ // nolint: gochecknoglobals
+	0:  "disconnected",
+	1:  "connected",
+	2:  "pending_adoption",
+	4:  "upgrading",
+	5:  "provisioning",
+	6:  "heartbeat_missed",
+	7:  "adopting",
+	9:  "adoption_error",
+	10: "adoption_failed",
+	11: "isolated",
+}
+
+// deviceKey identifies a device on a controller.
+type deviceKey struct {
+	site, mac string
+}
+
+// deviceSnapshot is what is compared between two sightings of a device.
+type deviceSnapshot struct {
+	source, site, mac, name string
+	model, kind, version    string
+	uplink                  string // MAC of the uplink parent.
+	state                   int64
+	uptime                  int64
+}
+
+// deviceSnapshots holds each controller's device list from its last poll.
+// Keys are controller URLs.
+type deviceSnapshots struct {
+	sync.Mutex
+	last map[string]map[deviceKey]*deviceSnapshot
+}
+
+// diffDevices compares a controller's devices to those from its previous poll, and
+// holds the reboots, upgrades, state and uplink changes, and devices that appeared or
+// disappeared, for the next events collection. The first poll only records the devices.
+func (u *InputUnifi) diffDevices(c *Controller, devices []any) {
+	if c.SaveDeviceEvents == nil || !*c.SaveDeviceEvents {
+		return
+	}
+
+	now := time.Now()
+	current := make(map[deviceKey]*deviceSnapshot, len(devices))
+	names := make(map[string]string, len(devices))
+
+	for _, d := range devices {
+		if snap, ok := snapshotDevice(d); ok {
+			current[deviceKey{site: snap.site, mac: snap.mac}] = snap
+			names[snap.mac] = snap.name
+		}
+	}
+
+	s := &u.devices
+	s.Lock()
+	defer s.Unlock()
+
+	if s.last == nil {
+		s.last = make(map[string]map[deviceKey]*deviceSnapshot)
+	}
+
+	last, polled := s.last[c.URL]
+	s.last[c.URL] = current
+
+	if !polled {
+		return
+	}
+
+	events := []*poller.DeviceEvent{}
+
+	for key, snap := range current {
+		events = append(events, deviceChanges(c, last[key], snap, names, now)...)
+	}
+
+	for key, snap := range last {
+		if _, ok := current[key]; !ok {
+			events = append(events, deviceEvent(c, poller.DeviceRemoved, snap, names, now))
+		}
+	}
+
+	slices.SortFunc(events, func(a, b *poller.DeviceEvent) int {
+		return cmp.Or(cmp.Compare(a.SiteName, b.SiteName), cmp.Compare(a.Mac, b.Mac), cmp.Compare(a.Type, b.Type))
+	})
+
+	held := make([]any, len(events))
+
+	for i, e := range events {
+		held[i] = e
+
+		webserver.NewInputEvent(PluginName, e.SiteName+"_device_events", &webserver.Event{
+			Msg: e.Msg(), Ts: e.Time, Tags: map[string]string{
+				"type": e.Type, "mac": e.Mac, "site_name": e.SiteName, "source": e.SourceName,
+			},
+		})
+	}
+
+	u.held.hold(c.URL, held)
+}
+
+// deviceChanges returns the events between two sightings of a device. prev is nil
+// for a device that was not in the previous poll's list.
+func deviceChanges(c *Controller, prev, snap *deviceSnapshot, names map[string]string, now time.Time) []*poller.DeviceEvent {
+	if prev == nil {
+		return []*poller.DeviceEvent{deviceEvent(c, poller.DeviceAdded, snap, names, now)}
+	}
+
+	events := []*poller.DeviceEvent{}
+
+	if snap.uptime > 0 && snap.uptime < prev.uptime {
+		e := deviceEvent(c, poller.DeviceRebooted, snap, names, now)
+		e.FromUptime = prev.uptime
+		events = append(events, e)
+	}
+
+	if snap.version != prev.version && snap.version != "" && prev.version != "" {
+		e := deviceEvent(c, poller.DeviceUpgraded, snap, names, now)
+		e.FromVersion = prev.version
+		events = append(events, e)
+	}
+
+	if snap.state != prev.state {
+		e := deviceEvent(c, poller.DeviceStateChanged, snap, names, now)
+		e.FromState = deviceState(prev.state)
+		events = append(events, e)
+	}
+
+	if snap.uplink != prev.uplink && snap.uplink != "" && prev.uplink != "" {
+		e := deviceEvent(c, poller.DeviceUplinkChanged, snap, names, now)
+		e.FromUplink = cmp.Or(names[prev.uplink], prev.uplink)
+		events = append(events, e)
+	}
+
+	return events
+}
+
+func deviceEvent(c *Controller, kind string, snap *deviceSnapshot, names map[string]string, now time.Time) *poller.DeviceEvent {
+	return &poller.DeviceEvent{
+		Time:       now,
+		Type:       kind,
+		SourceName: cmp.Or(snap.source, c.URL),
+		SiteName:   snap.site,
+		Mac:        snap.mac,
+		Name:       snap.name,
+		Model:      snap.model,
+		DeviceType: snap.kind,
+		Version:    snap.version,
+		State:      deviceState(snap.state),
+		Uplink:     cmp.Or(names[snap.uplink], snap.uplink),
+		Uptime:     snap.uptime,
+	}
+}
+
+// deviceState returns the name of a device state, or its number if it has none.
+func deviceState(state int64) string {
+	if name, ok := deviceStates[state]; ok {
+		return name
+	}
+
+	return strconv.FormatInt(state, 10)
+}
+
+// snapshotDevice reads the fields every device type shares: UAP, USW, USG, UDM and the
+// rest each have their own struct, with the same field names. Like the relabel stage,
+// this reads them by name so new device types need no changes here.
+func snapshotDevice(d any) (*deviceSnapshot, bool) {
+	v := reflect.ValueOf(d)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	v = v.Elem()
+
+	snap := &deviceSnapshot{
+		source:  stringField(v, "SourceName"),
+		site:    stringField(v, "SiteName"),
+		mac:     stringField(v, "Mac"),
+		name:    stringField(v, "Name"),
+		model:   stringField(v, "Model"),
+		kind:    stringField(v, "Type"),
+		version: stringField(v, "Version"),
+		state:   flexIntField(v, "State"),
+		uptime:  flexIntField(v, "Uptime"),
+	}
+
+	if uplink := v.FieldByName("Uplink"); uplink.IsValid() && uplink.Kind() == reflect.Struct {
+		snap.uplink = stringField(uplink, "UplinkMac")
+	}
+
+	return snap, snap.mac != ""
+}
+
+func stringField(v reflect.Value, name string) string {
+	if f := v.FieldByName(name); f.IsValid() && f.Kind() == reflect.String {
+		return f.String()
+	}
+
+	return ""
+}
+
+func flexIntField(v reflect.Value, name string) int64 {
+	if f := v.FieldByName(name); f.IsValid() && f.CanInterface() {
+		if i, ok := f.Interface().(unifi.FlexInt); ok {
+			return i.Int64()
+		}
+	}
+
+	return 0
+}
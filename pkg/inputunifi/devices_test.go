@@ -0,0 +1,105 @@
+//nolint:testpackage // white-box: exercises the unexported device list comparison.
+package inputunifi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+func testDevice(change func(*deviceSnapshot)) *deviceSnapshot {
+	snap := &deviceSnapshot{
+		site: "default", mac: "aa:bb:cc:dd:ee:ff", name: "office", model: "U6LR", kind: "uap",
+		version: "6.6.55", uplink: "11:11:11:11:11:11", state: 1, uptime: 3600,
+	}
+
+	if change != nil {
+		change(snap)
+	}
+
+	return snap
+}
+
+func TestDeviceChanges(t *testing.T) {
+	t.Parallel()
+
+	c := &Controller{URL: "https://unifi"}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	names := map[string]string{"11:11:11:11:11:11": "core switch"}
+	event := func(kind string, change func(*poller.DeviceEvent)) *poller.DeviceEvent {
+		e := &poller.DeviceEvent{
+			Time: now, Type: kind, SourceName: c.URL, SiteName: "default", Mac: "aa:bb:cc:dd:ee:ff",
+			Name: "office", Model: "U6LR", DeviceType: "uap", Version: "6.6.55", State: "connected",
+			Uplink: "core switch", Uptime: 3600,
+		}
+
+		if change != nil {
+			change(e)
+		}
+
+		return e
+	}
+
+	tests := []struct {
+		name string
+		prev *deviceSnapshot
+		snap *deviceSnapshot
+		want []*poller.DeviceEvent
+	}{
+		{
+			name: "added",
+			snap: testDevice(nil),
+			want: []*poller.DeviceEvent{event(poller.DeviceAdded, nil)},
+		},
+		{
+			name: "unchanged",
+			prev: testDevice(func(d *deviceSnapshot) { d.uptime = 3000 }),
+			snap: testDevice(nil),
+			want: []*poller.DeviceEvent{},
+		},
+		{
+			name: "rebooted",
+			prev: testDevice(func(d *deviceSnapshot) { d.uptime = 86400 }),
+			snap: testDevice(nil),
+			want: []*poller.DeviceEvent{event(poller.DeviceRebooted, func(e *poller.DeviceEvent) { e.FromUptime = 86400 })},
+		},
+		{
+			name: "uptime not reported",
+			prev: testDevice(nil),
+			snap: testDevice(func(d *deviceSnapshot) { d.uptime = 0 }),
+			want: []*poller.DeviceEvent{},
+		},
+		{
+			name: "upgraded, so rebooted",
+			prev: testDevice(func(d *deviceSnapshot) { d.version, d.uptime = "6.6.50", 86400 }),
+			snap: testDevice(nil),
+			want: []*poller.DeviceEvent{
+				event(poller.DeviceRebooted, func(e *poller.DeviceEvent) { e.FromUptime = 86400 }),
+				event(poller.DeviceUpgraded, func(e *poller.DeviceEvent) { e.FromVersion = "6.6.50" }),
+			},
+		},
+		{
+			name: "state changed",
+			prev: testDevice(func(d *deviceSnapshot) { d.state = 4 }),
+			snap: testDevice(nil),
+			want: []*poller.DeviceEvent{event(poller.DeviceStateChanged, func(e *poller.DeviceEvent) { e.FromState = "upgrading" })},
+		},
+		{
+			name: "uplink changed",
+			prev: testDevice(func(d *deviceSnapshot) { d.uplink = "22:22:22:22:22:22" }),
+			snap: testDevice(nil),
+			want: []*poller.DeviceEvent{
+				event(poller.DeviceUplinkChanged, func(e *poller.DeviceEvent) { e.FromUplink = "22:22:22:22:22:22" }),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.want, deviceChanges(c, test.prev, test.snap, names, now))
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package inputunifi
+
+import "sync"
+
+// maxHeldEvents caps the events held per controller, in case nothing collects events.
+const maxHeldEvents = 10000
+
+// heldEvents are events found while collecting metrics, ie. by comparing client and
+// device lists, held by controller URL until the next events collection returns them.
+type heldEvents struct {
+	sync.Mutex
+	events map[string][]any
+}
+
+// hold adds events for a controller. The oldest are dropped past maxHeldEvents.
+func (h *heldEvents) hold(url string, events []any) {
+	if len(events) == 0 {
+		return
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	if h.events == nil {
+		h.events = make(map[string][]any)
+	}
+
+	h.events[url] = append(h.events[url], events...)
+
+	if over := len(h.events[url]) - maxHeldEvents; over > 0 {
+		h.events[url] = h.events[url][over:]
+	}
+}
+
+// take returns the events held for a controller, and forgets them.
+func (h *heldEvents) take(url string) []any {
+	h.Lock()
+	defer h.Unlock()
+
+	events := h.events[url]
+	delete(h.events, url)
+
+	return events
+}
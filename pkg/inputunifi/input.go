@@ -3,7 +3,7 @@
 package inputunifi
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -24,6 +24,8 @@ const (
 	defaultPass    = "unifipoller"
 	defaultSite    = "all"
 	defaultTimeout = 60 * time.Second
+	// defaultMaxConcurrency is how many controllers are polled at once when max_concurrency is not set.
+	defaultMaxConcurrency = 4
 )
 
 // InputUnifi contains the running data.
@@ -32,49 +34,77 @@ type InputUnifi struct {
 	dynamic    map[string]*Controller
 	sync.Mutex // to lock the map above.
 	Logger     poller.Logger
+	inflight   sync.Map      // inflightKey -> struct{}, controllers with a collection running.
+	streams    sync.Map      // streamKey -> struct{}, sites with a connected event stream.
+	restream   chan struct{} // tells StreamEvents the controllers changed.
+	sessions   clientSessions
+	devices    deviceSnapshots
+	held       heldEvents // client and device events, until the next events collection.
+	inventory  clientInventory
+	discovery  rediscovery
+	configured []*Controller // the controllers as configured, before remote discovery.
 }
 
 // Controller represents the configuration for a UniFi Controller.
 // Each polled controller may have its own configuration.
 type Controller struct {
-	VerifySSL               *bool         `json:"verify_ssl"                 toml:"verify_ssl"                 xml:"verify_ssl"                 yaml:"verify_ssl"`
-	SaveAnomal              *bool         `json:"save_anomalies"             toml:"save_anomalies"             xml:"save_anomalies"             yaml:"save_anomalies"`
-	SaveAlarms              *bool         `json:"save_alarms"                toml:"save_alarms"                xml:"save_alarms"                yaml:"save_alarms"`
-	SaveEvents              *bool         `json:"save_events"                toml:"save_events"                xml:"save_events"                yaml:"save_events"`
-	SaveSyslog              *bool         `json:"save_syslog"                toml:"save_syslog"                xml:"save_syslog"                yaml:"save_syslog"`
-	SaveProtectLogs         *bool         `json:"save_protect_logs"          toml:"save_protect_logs"          xml:"save_protect_logs"          yaml:"save_protect_logs"`
-	ProtectThumbnails       *bool         `json:"protect_thumbnails"         toml:"protect_thumbnails"         xml:"protect_thumbnails"         yaml:"protect_thumbnails"`
-	SaveIDs                 *bool         `json:"save_ids"                   toml:"save_ids"                   xml:"save_ids"                   yaml:"save_ids"`
-	SaveDPI                 *bool         `json:"save_dpi"                   toml:"save_dpi"                   xml:"save_dpi"                   yaml:"save_dpi"`
-	SaveTraffic             *bool         `json:"save_traffic"               toml:"save_traffic"               xml:"save_traffic"               yaml:"save_traffic"`
-	SaveRogue               *bool         `json:"save_rogue"                 toml:"save_rogue"                 xml:"save_rogue"                 yaml:"save_rogue"`
-	SaveSpeedTest           *bool         `json:"save_speedtest"             toml:"save_speedtest"             xml:"save_speedtest"             yaml:"save_speedtest"`
-	HashPII                 *bool         `json:"hash_pii"                   toml:"hash_pii"                   xml:"hash_pii"                   yaml:"hash_pii"`
-	DropPII                 *bool         `json:"drop_pii"                   toml:"drop_pii"                   xml:"drop_pii"                   yaml:"drop_pii"`
-	SaveSites               *bool         `json:"save_sites"                 toml:"save_sites"                 xml:"save_sites"                 yaml:"save_sites"`
-	Timeout                 cnfg.Duration `json:"timeout"                    toml:"timeout"                    xml:"timeout"                    yaml:"timeout"`
-	CertPaths               []string      `json:"ssl_cert_paths"             toml:"ssl_cert_paths"             xml:"ssl_cert_path"              yaml:"ssl_cert_paths"`
-	User                    string        `json:"user"                       toml:"user"                       xml:"user"                       yaml:"user"`
-	Pass                    string        `json:"pass"                       toml:"pass"                       xml:"pass"                       yaml:"pass"`
-	APIKey                  string        `json:"api_key"                    toml:"api_key"                    xml:"api_key"                    yaml:"api_key"`
-	URL                     string        `json:"url"                        toml:"url"                        xml:"url"                        yaml:"url"`
-	Sites                   []string      `json:"sites"                      toml:"sites"                      xml:"site"                       yaml:"sites"`
-	DefaultSiteNameOverride string        `json:"default_site_name_override" toml:"default_site_name_override" xml:"default_site_name_override" yaml:"default_site_name_override"`
-	Remote                  bool          `json:"remote"                     toml:"remote"                     xml:"remote,attr"                yaml:"remote"`
-	ConsoleID               string        `json:"console_id,omitempty"       toml:"console_id,omitempty"       xml:"console_id,omitempty"       yaml:"console_id,omitempty"`
-	Unifi                   *unifi.Unifi  `json:"-"                          toml:"-"                          xml:"-"                          yaml:"-"`
-	ID                      string        `json:"id,omitempty"` // this is an output, not an input.
+	VerifySSL               *bool                    `json:"verify_ssl"                 toml:"verify_ssl"                 xml:"verify_ssl"                 yaml:"verify_ssl"`
+	SaveAnomal              *bool                    `json:"save_anomalies"             toml:"save_anomalies"             xml:"save_anomalies"             yaml:"save_anomalies"`
+	SaveAlarms              *bool                    `json:"save_alarms"                toml:"save_alarms"                xml:"save_alarms"                yaml:"save_alarms"`
+	SaveEvents              *bool                    `json:"save_events"                toml:"save_events"                xml:"save_events"                yaml:"save_events"`
+	SaveSyslog              *bool                    `json:"save_syslog"                toml:"save_syslog"                xml:"save_syslog"                yaml:"save_syslog"`
+	SaveProtectLogs         *bool                    `json:"save_protect_logs"          toml:"save_protect_logs"          xml:"save_protect_logs"          yaml:"save_protect_logs"`
+	ProtectThumbnails       *bool                    `json:"protect_thumbnails"         toml:"protect_thumbnails"         xml:"protect_thumbnails"         yaml:"protect_thumbnails"`
+	EventStream             *bool                    `json:"event_stream"               toml:"event_stream"               xml:"event_stream"               yaml:"event_stream"`
+	SaveClientEvents        *bool                    `json:"save_client_events"         toml:"save_client_events"         xml:"save_client_events"         yaml:"save_client_events"`
+	SaveDeviceEvents        *bool                    `json:"save_device_events"         toml:"save_device_events"         xml:"save_device_events"         yaml:"save_device_events"`
+	ClientInventory         *bool                    `json:"client_inventory"           toml:"client_inventory"           xml:"client_inventory"           yaml:"client_inventory"`
+	SaveIDs                 *bool                    `json:"save_ids"                   toml:"save_ids"                   xml:"save_ids"                   yaml:"save_ids"`
+	SaveDPI                 *bool                    `json:"save_dpi"                   toml:"save_dpi"                   xml:"save_dpi"                   yaml:"save_dpi"`
+	SaveTraffic             *bool                    `json:"save_traffic"               toml:"save_traffic"               xml:"save_traffic"               yaml:"save_traffic"`
+	SaveRogue               *bool                    `json:"save_rogue"                 toml:"save_rogue"                 xml:"save_rogue"                 yaml:"save_rogue"`
+	SaveSpeedTest           *bool                    `json:"save_speedtest"             toml:"save_speedtest"             xml:"save_speedtest"             yaml:"save_speedtest"`
+	HashPII                 *bool                    `json:"hash_pii"                   toml:"hash_pii"                   xml:"hash_pii"                   yaml:"hash_pii"`
+	DropPII                 *bool                    `json:"drop_pii"                   toml:"drop_pii"                   xml:"drop_pii"                   yaml:"drop_pii"`
+	SaveSites               *bool                    `json:"save_sites"                 toml:"save_sites"                 xml:"save_sites"                 yaml:"save_sites"`
+	Timeout                 cnfg.Duration            `json:"timeout"                    toml:"timeout"                    xml:"timeout"                    yaml:"timeout"`
+	PollTimeout             cnfg.Duration            `json:"poll_timeout"               toml:"poll_timeout"               xml:"poll_timeout"               yaml:"poll_timeout"`
+	RateLimit               float64                  `json:"rate_limit"                 toml:"rate_limit"                 xml:"rate_limit"                 yaml:"rate_limit"`
+	RateBurst               int                      `json:"rate_burst"                 toml:"rate_burst"                 xml:"rate_burst"                 yaml:"rate_burst"`
+	Intervals               map[string]cnfg.Duration `json:"intervals"                  toml:"intervals"                  xml:"intervals"                  yaml:"intervals"`
+	SiteOverrides           map[string]*SiteOverride `json:"site_overrides"             toml:"site_overrides"             xml:"site_override"              yaml:"site_overrides"`
+	CertPaths               []string                 `json:"ssl_cert_paths"             toml:"ssl_cert_paths"             xml:"ssl_cert_path"              yaml:"ssl_cert_paths"`
+	User                    string                   `json:"user"                       toml:"user"                       xml:"user"                       yaml:"user"`
+	Pass                    string                   `json:"pass"                       toml:"pass"                       xml:"pass"                       yaml:"pass"`
+	APIKey                  string                   `json:"api_key"                    toml:"api_key"                    xml:"api_key"                    yaml:"api_key"`
+	PIIHash                 string                   `json:"pii_hash"                   toml:"pii_hash"                   xml:"pii_hash"                   yaml:"pii_hash"`
+	PIIKey                  string                   `json:"pii_key"                    toml:"pii_key"                    xml:"pii_key"                    yaml:"pii_key"`
+	URL                     string                   `json:"url"                        toml:"url"                        xml:"url"                        yaml:"url"`
+	Sites                   []string                 `json:"sites"                      toml:"sites"                      xml:"site"                       yaml:"sites"`
+	DefaultSiteNameOverride string                   `json:"default_site_name_override" toml:"default_site_name_override" xml:"default_site_name_override" yaml:"default_site_name_override"`
+	Remote                  bool                     `json:"remote"                     toml:"remote"                     xml:"remote,attr"                yaml:"remote"`
+	ConsoleID               string                   `json:"console_id,omitempty"       toml:"console_id,omitempty"       xml:"console_id,omitempty"       yaml:"console_id,omitempty"`
+	CaptureDir              string                   `json:"capture_dir"                toml:"capture_dir"                xml:"capture_dir"                yaml:"capture_dir"`
+	CaptureMaxAge           cnfg.Duration            `json:"capture_max_age"            toml:"capture_max_age"            xml:"capture_max_age"            yaml:"capture_max_age"`
+	Unifi                   *unifi.Unifi             `json:"-"                          toml:"-"                          xml:"-"                          yaml:"-"`
+	ID                      string                   `json:"id,omitempty"` // this is an output, not an input.
+	piiKey                  string                   // pii_key, read from its secret reference.
+	piiKeyErr               error                    // why pii_key could not be read, with hmac selected.
+	results                 *resultCache             // the last result of each scheduled request.
 }
 
 // Config contains our configuration data.
 type Config struct {
-	sync.RWMutex               // locks the Unifi struct member when re-authing to unifi.
-	Default      Controller    `json:"defaults"       toml:"defaults"       xml:"default"        yaml:"defaults"`
-	Disable      bool          `json:"disable"        toml:"disable"        xml:"disable,attr"   yaml:"disable"`
-	Dynamic      bool          `json:"dynamic"        toml:"dynamic"        xml:"dynamic,attr"   yaml:"dynamic"`
-	Remote       bool          `json:"remote"         toml:"remote"         xml:"remote,attr"    yaml:"remote"`
-	RemoteAPIKey string        `json:"remote_api_key" toml:"remote_api_key" xml:"remote_api_key" yaml:"remote_api_key"`
-	Controllers  []*Controller `json:"controllers"    toml:"controller"     xml:"controller"     yaml:"controllers"`
+	sync.RWMutex                  // locks the Unifi struct member when re-authing to unifi.
+	Default         Controller    `json:"defaults"            toml:"defaults"            xml:"default"             yaml:"defaults"`
+	Disable         bool          `json:"disable"             toml:"disable"             xml:"disable,attr"        yaml:"disable"`
+	Dynamic         bool          `json:"dynamic"             toml:"dynamic"             xml:"dynamic,attr"        yaml:"dynamic"`
+	Remote          bool          `json:"remote"              toml:"remote"              xml:"remote,attr"         yaml:"remote"`
+	RemoteAPIKey    string        `json:"remote_api_key"      toml:"remote_api_key"      xml:"remote_api_key"      yaml:"remote_api_key"`
+	MaxConcurrency  int           `json:"max_concurrency"     toml:"max_concurrency"     xml:"max_concurrency"     yaml:"max_concurrency"`
+	Rediscover      cnfg.Duration `json:"rediscover_interval" toml:"rediscover_interval" xml:"rediscover_interval" yaml:"rediscover_interval"`
+	InventoryMaxAge cnfg.Duration `json:"inventory_max_age"   toml:"inventory_max_age"   xml:"inventory_max_age"   yaml:"inventory_max_age"`
+	Controllers     []*Controller `json:"controllers"         toml:"controller"          xml:"controller"          yaml:"controllers"`
 }
 
 // Metrics is simply a useful container for everything.
@@ -117,6 +147,9 @@ type Metrics struct {
 	DPICategories        []*unifi.DPICategory
 	PendingDevices       []*unifi.PendingDevice
 	Countries            []*unifi.Country
+	// throttled holds the API methods skipped with ErrThrottled in this poll. Their
+	// collections are empty, and must not be taken for clients and devices that left.
+	throttled map[string]bool
 }
 
 func init() { // nolint: gochecknoinits
@@ -129,6 +162,8 @@ func init() { // nolint: gochecknoinits
 		Input:  u, // this library implements poller.Input interface for Metrics().
 		Config: u, // Defines our config data interface.
 	})
+
+	registerKinds()
 }
 
 // getCerts reads in cert files from disk and stores them as a slice of of byte slices.
@@ -151,12 +186,17 @@ func (c *Controller) getCerts() ([][]byte, error) {
 	return b, nil
 }
 
-const maxAuthRetries = 5
-
 // getUnifi (re-)authenticates to a unifi controller.
 // If certificate files are provided, they are re-read.
-// On 429 Too Many Requests, retries with exponential backoff (and Retry-After when present) up to maxAuthRetries.
+// On 429 Too Many Requests, the controller is throttled for its Retry-After, and
+// logging in is tried again on a later poll; this never sleeps holding the lock.
+// Logging in runs at startup and reload too, so it waits for its token without a context.
 func (u *InputUnifi) getUnifi(c *Controller) error {
+	l := limiter(c)
+	if err := l.wait(context.Background(), "login"); err != nil {
+		return fmt.Errorf("unifi controller: %w", err)
+	}
+
 	u.Lock()
 	defer u.Unlock()
 
@@ -169,10 +209,23 @@ func (u *InputUnifi) getUnifi(c *Controller) error {
 		return err
 	}
 
+	// Secrets are resolved at every login, so a rotated password or key is picked
+	// up by the re-authentication that follows a failed poll. exec:// secrets are
+	// reused until they expire, a login fails, or the config is reloaded.
+	pass, err := poller.ResolveSecret(c.Pass)
+	if err != nil {
+		return fmt.Errorf("unifi controller pass: %w", err)
+	}
+
+	apiKey, err := poller.ResolveSecret(c.APIKey)
+	if err != nil {
+		return fmt.Errorf("unifi controller api_key: %w", err)
+	}
+
 	cfg := &unifi.Config{
 		User:      c.User,
-		Pass:      c.Pass,
-		APIKey:    c.APIKey,
+		Pass:      pass,
+		APIKey:    apiKey,
 		URL:       c.URL,
 		SSLCert:   certs,
 		VerifySSL: *c.VerifySSL,
@@ -181,47 +234,30 @@ func (u *InputUnifi) getUnifi(c *Controller) error {
 		DebugLog:  u.LogDebugf,
 	}
 
-	var lastErr error
-
-	backoff := 30 * time.Second
-
-	for attempt := 0; attempt < maxAuthRetries; attempt++ {
-		c.Unifi, lastErr = unifi.NewUnifi(cfg)
-		if lastErr == nil {
-			u.LogDebugf("Authenticated with controller successfully, %s", c.URL)
-
-			return nil
-		}
+	if c.Unifi, err = unifi.NewUnifi(cfg); err == nil {
+		u.LogDebugf("Authenticated with controller successfully, %s", c.URL)
+		u.captureRequests(c)
 
-		if !errors.Is(lastErr, unifi.ErrTooManyRequests) {
-			c.Unifi = nil
+		return nil
+	}
 
-			return fmt.Errorf("unifi controller: %w", lastErr)
-		}
+	c.Unifi = nil
 
-		var rl *unifi.RateLimitError
-		if errors.As(lastErr, &rl) && rl.RetryAfter > 0 {
-			backoff = rl.RetryAfter
-		}
+	// The secrets may have been rotated; resolve them again on the next try.
+	poller.ForgetSecret(c.Pass)
+	poller.ForgetSecret(c.APIKey)
 
-		if attempt < maxAuthRetries-1 {
-			u.Logf("Controller %s returned 429 Too Many Requests; waiting %v before retry (%d/%d)",
-				c.URL, backoff, attempt+1, maxAuthRetries)
-			time.Sleep(backoff)
+	if wait := l.backOff(err, "login"); wait > 0 {
+		u.Logf("Controller %s returned 429 Too Many Requests; throttling it for %v", c.URL, wait)
 
-			if backoff < 5*time.Minute {
-				backoff = backoff * 2
-			}
-		}
+		return fmt.Errorf("unifi controller: %w: %w", ErrThrottled, err)
 	}
 
-	c.Unifi = nil
-
-	return fmt.Errorf("unifi controller: %w (gave up after %d retries)", lastErr, maxAuthRetries)
+	return fmt.Errorf("unifi controller: %w", err)
 }
 
 // checkSites makes sure the list of provided sites exists on the controller.
-// This only runs once during initialization.
+// This runs during initialization, on reload, and on rediscovery.
 func (u *InputUnifi) checkSites(c *Controller) error {
 	u.RLock()
 	defer u.RUnlock()
@@ -232,7 +268,7 @@ func (u *InputUnifi) checkSites(c *Controller) error {
 
 	u.LogDebugf("Checking Controller Sites List")
 
-	sites, err := c.Unifi.GetSites()
+	sites, err := timed0(context.Background(), c, "GetSites", c.Unifi.GetSites)
 	if err != nil {
 		return fmt.Errorf("controller: %w", err)
 	}
@@ -242,7 +278,13 @@ func (u *InputUnifi) checkSites(c *Controller) error {
 		msg = append(msg, site.Name+" ("+site.Desc+")")
 	}
 
-	u.Logf("Found %d site(s) on controller %s: %v", len(msg), c.URL, strings.Join(msg, ", "))
+	if u.siteChanges(c, sites) {
+		u.Logf("Found %d site(s) on controller %s: %v", len(msg), c.URL, strings.Join(msg, ", "))
+
+		if unknown := c.unknownOverrides(sites); len(unknown) > 0 {
+			u.LogErrorf("Site overrides match no site on controller %s: %v", c.URL, strings.Join(unknown, ", "))
+		}
+	}
 
 	if StringInSlice("all", c.Sites) {
 		c.Sites = []string{"all"}
@@ -273,15 +315,6 @@ FIRST:
 	return nil
 }
 
-func (u *InputUnifi) getPassFromFile(filename string) string {
-	b, err := os.ReadFile(filename)
-	if err != nil {
-		u.LogErrorf("Reading UniFi Password File: %v", err)
-	}
-
-	return strings.TrimSpace(string(b))
-}
-
 // setDefaults sets the default defaults.
 func (u *InputUnifi) setDefaults(c *Controller) { //nolint:cyclop
 	t := true
@@ -342,6 +375,22 @@ func (u *InputUnifi) setDefaults(c *Controller) { //nolint:cyclop
 		c.ProtectThumbnails = &f
 	}
 
+	if c.EventStream == nil {
+		c.EventStream = &f
+	}
+
+	if c.SaveClientEvents == nil {
+		c.SaveClientEvents = &f
+	}
+
+	if c.SaveDeviceEvents == nil {
+		c.SaveDeviceEvents = &f
+	}
+
+	if c.ClientInventory == nil {
+		c.ClientInventory = &f
+	}
+
 	if c.SaveAlarms == nil {
 		c.SaveAlarms = &f
 	}
@@ -360,14 +409,6 @@ func (u *InputUnifi) setDefaults(c *Controller) { //nolint:cyclop
 		c.URL = defaultURL
 	}
 
-	if strings.HasPrefix(c.Pass, "file://") {
-		c.Pass = u.getPassFromFile(strings.TrimPrefix(c.Pass, "file://"))
-	}
-
-	if strings.HasPrefix(c.APIKey, "file://") {
-		c.APIKey = u.getPassFromFile(strings.TrimPrefix(c.APIKey, "file://"))
-	}
-
 	if c.Remote {
 		// Remote mode: only API key is used, no user/pass
 		// For remote mode, API key is required
@@ -398,6 +439,10 @@ func (u *InputUnifi) setDefaults(c *Controller) { //nolint:cyclop
 	if c.Timeout.Duration == 0 {
 		c.Timeout.Duration = defaultTimeout
 	}
+
+	if c.CaptureMaxAge.Duration == 0 {
+		c.CaptureMaxAge.Duration = defaultCaptureMaxAge
+	}
 }
 
 // setControllerDefaults sets defaults for the for controllers.
@@ -424,6 +469,14 @@ func (u *InputUnifi) setControllerDefaults(c *Controller) *Controller { //nolint
 		c.DropPII = u.Default.DropPII
 	}
 
+	if c.PIIHash == "" {
+		c.PIIHash = u.Default.PIIHash
+	}
+
+	if c.PIIKey == "" {
+		c.PIIKey = u.Default.PIIKey
+	}
+
 	if c.SaveDPI == nil {
 		c.SaveDPI = u.Default.SaveDPI
 	}
@@ -460,6 +513,22 @@ func (u *InputUnifi) setControllerDefaults(c *Controller) *Controller { //nolint
 		c.ProtectThumbnails = u.Default.ProtectThumbnails
 	}
 
+	if c.EventStream == nil {
+		c.EventStream = u.Default.EventStream
+	}
+
+	if c.SaveClientEvents == nil {
+		c.SaveClientEvents = u.Default.SaveClientEvents
+	}
+
+	if c.SaveDeviceEvents == nil {
+		c.SaveDeviceEvents = u.Default.SaveDeviceEvents
+	}
+
+	if c.ClientInventory == nil {
+		c.ClientInventory = u.Default.ClientInventory
+	}
+
 	if c.SaveAlarms == nil {
 		c.SaveAlarms = u.Default.SaveAlarms
 	}
@@ -477,12 +546,13 @@ func (u *InputUnifi) setControllerDefaults(c *Controller) *Controller { //nolint
 		}
 	}
 
-	if strings.HasPrefix(c.Pass, "file://") {
-		c.Pass = u.getPassFromFile(strings.TrimPrefix(c.Pass, "file://"))
+	if c.results == nil {
+		c.results = &resultCache{}
 	}
 
-	if strings.HasPrefix(c.APIKey, "file://") {
-		c.APIKey = u.getPassFromFile(strings.TrimPrefix(c.APIKey, "file://"))
+	// The password and API key are resolved at each login, so rotated secrets are used.
+	if err := c.resolvePIIKey(); err != nil {
+		u.LogErrorf("%s: %v; PII to hash is dropped until it can be read", c.URL, err)
 	}
 
 	if c.Remote {
@@ -529,6 +599,28 @@ func (u *InputUnifi) setControllerDefaults(c *Controller) *Controller { //nolint
 		c.Timeout = u.Default.Timeout
 	}
 
+	if c.PollTimeout.Duration == 0 {
+		c.PollTimeout = u.Default.PollTimeout
+	}
+
+	if c.RateLimit == 0 {
+		c.RateLimit = u.Default.RateLimit
+	}
+
+	if c.RateBurst == 0 {
+		c.RateBurst = u.Default.RateBurst
+	}
+
+	if c.CaptureMaxAge.Duration == 0 {
+		c.CaptureMaxAge = u.Default.CaptureMaxAge
+	}
+
+	c.Intervals = mergeIntervals(c.Intervals, u.Default.Intervals)
+
+	if c.SiteOverrides == nil {
+		c.SiteOverrides = u.Default.SiteOverrides
+	}
+
 	return c
 }
 
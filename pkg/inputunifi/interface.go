@@ -3,8 +3,13 @@ package inputunifi
 /* This file contains the three poller.Input interface methods. */
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/unpoller/unifi/v5"
 	"github.com/unpoller/unpoller/pkg/poller"
@@ -14,7 +19,13 @@ import (
 var (
 	ErrDynamicLookupsDisabled = fmt.Errorf("filter path requested but dynamic lookups disabled")
 	ErrControllerNumNotFound  = fmt.Errorf("controller number not found")
-	ErrNoFilterKindProvided   = fmt.Errorf("must provide filter: devices, clients, other")
+	ErrNoFilterKindProvided   = fmt.Errorf("must provide filter: other, or one of: %s", strings.Join(rawKinds(), ", "))
+)
+
+var (
+	_ poller.ContextInput = &InputUnifi{}
+	_ poller.Closer       = &InputUnifi{}
+	_ poller.RawDumper    = &InputUnifi{}
 )
 
 // Initialize gets called one time when starting up.
@@ -30,6 +41,52 @@ func (u *InputUnifi) Initialize(l poller.Logger) error {
 		return nil
 	}
 
+	u.restream = make(chan struct{}, 1)
+
+	_ = u.configureControllers() // errors are logged; the configured controllers are polled.
+
+	if err := u.checkPIIKeys(); err != nil {
+		return err
+	}
+
+	for i, c := range u.Controllers {
+		if err := u.getUnifi(u.setControllerDefaults(c)); err != nil {
+			u.LogErrorf("Controller %d of %d Auth or Connection Error, retrying: %v", i+1, len(u.Controllers), err)
+
+			continue
+		}
+
+		if err := u.checkSites(c); err != nil {
+			u.LogErrorf("checking sites on %s: %v", c.URL, err)
+		}
+
+		u.Logf("Configured UniFi Controller %d of %d:", i+1, len(u.Controllers))
+		u.logController(c)
+	}
+
+	if len(u.Controllers) > 1 {
+		u.Logf("Polling up to %d UniFi Controllers at a time", u.maxConcurrency())
+	}
+
+	if u.Rediscover.Duration > 0 {
+		u.Logf("Rediscovering sites and remote consoles every %v", u.Rediscover)
+	}
+
+	u.discovery.last = time.Now()
+
+	webserver.UpdateInput(&webserver.Input{Name: PluginName, Config: formatConfig(u.Config)})
+
+	return nil
+}
+
+// configureControllers builds the list of controllers to poll from the config,
+// discovering remote consoles when remote mode is enabled. A discovery error is
+// logged and returned; the controllers it could not discover are left out.
+func (u *InputUnifi) configureControllers() error {
+	u.configured = cloneControllers(u.Controllers)
+
+	var errs []error
+
 	// Discover remote controllers if remote mode is enabled at config level
 	if u.Remote && u.RemoteAPIKey != "" {
 		u.Logf("Remote API mode enabled, discovering controllers...")
@@ -37,6 +94,7 @@ func (u *InputUnifi) Initialize(l poller.Logger) error {
 		discovered, err := u.discoverRemoteControllers(u.RemoteAPIKey)
 		if err != nil {
 			u.LogErrorf("Failed to discover remote controllers: %v", err)
+			errs = append(errs, err)
 		} else if len(discovered) > 0 {
 			// Replace controllers with discovered ones when using config-level remote mode
 			u.Controllers = discovered
@@ -55,6 +113,7 @@ func (u *InputUnifi) Initialize(l poller.Logger) error {
 				discovered, err := u.discoverRemoteControllers(c.APIKey)
 				if err != nil {
 					u.LogErrorf("Failed to discover remote controllers for controller: %v", err)
+					errs = append(errs, err)
 
 					continue
 				}
@@ -83,24 +142,7 @@ func (u *InputUnifi) Initialize(l poller.Logger) error {
 		u.logController(&u.Default)
 	}
 
-	for i, c := range u.Controllers {
-		if err := u.getUnifi(u.setControllerDefaults(c)); err != nil {
-			u.LogErrorf("Controller %d of %d Auth or Connection Error, retrying: %v", i+1, len(u.Controllers), err)
-
-			continue
-		}
-
-		if err := u.checkSites(c); err != nil {
-			u.LogErrorf("checking sites on %s: %v", c.URL, err)
-		}
-
-		u.Logf("Configured UniFi Controller %d of %d:", i+1, len(u.Controllers))
-		u.logController(c)
-	}
-
-	webserver.UpdateInput(&webserver.Input{Name: PluginName, Config: formatConfig(u.Config)})
-
-	return nil
+	return errors.Join(errs...)
 }
 
 func (u *InputUnifi) DebugInput() (bool, error) {
@@ -168,7 +210,26 @@ func (u *InputUnifi) logController(c *Controller) {
 	}
 
 	u.Logf("   => Mode: %s", mode)
-	u.Logf("   => URL: %s (verify SSL: %v, timeout: %v)", c.URL, *c.VerifySSL, c.Timeout.Duration)
+	u.Logf("   => URL: %s (verify SSL: %v, timeout: %v, poll timeout: %v)",
+		c.URL, *c.VerifySSL, c.Timeout.Duration, c.PollTimeout.Duration)
+
+	if c.RateLimit > 0 {
+		burst := c.RateBurst
+		if burst < 1 {
+			burst = defaultRateBurst
+		}
+
+		u.Logf("   => Rate Limit: %v requests/second (burst: %d)", c.RateLimit, burst)
+	}
+
+	if len(c.Intervals) > 0 {
+		intervals := []string{}
+		for _, name := range slices.Sorted(maps.Keys(c.Intervals)) {
+			intervals = append(intervals, name+": "+c.Intervals[name].String())
+		}
+
+		u.Logf("   => Collection Intervals: %s", strings.Join(intervals, ", "))
+	}
 
 	if len(c.CertPaths) > 0 {
 		u.Logf("   => Cert Files: %s", strings.Join(c.CertPaths, ", "))
@@ -184,24 +245,50 @@ func (u *InputUnifi) logController(c *Controller) {
 		u.Logf("   => Username: %s (has password: %v) (has api-key: %v)", c.User, c.Pass != "", c.APIKey != "")
 	}
 
-	u.Logf("   => Hash PII %v / Drop PII %v / Poll Sites: %s", *c.HashPII, *c.DropPII, strings.Join(c.Sites, ", "))
+	u.Logf("   => Hash PII %v (%s) / Drop PII %v / Poll Sites: %s",
+		*c.HashPII, c.piiHash(), *c.DropPII, strings.Join(c.Sites, ", "))
+
+	if *c.HashPII && c.PIIHash == "" && c.PIIKey == "" {
+		u.Logf("   => Hash PII uses unkeyed MD5 hashes, which can be reversed: set pii_key to use HMAC")
+	}
+
 	u.Logf("   => Save Sites %v / Save DPI %v (metrics)", *c.SaveSites, *c.SaveDPI)
-	u.Logf("   => Save Events %v / Save Syslog %v / Save IDs %v (logs)", *c.SaveEvents, *c.SaveSyslog, *c.SaveIDs)
+	u.Logf("   => Save Events %v / Save Syslog %v / Save IDs %v (logs) / Event Stream %v",
+		*c.SaveEvents, *c.SaveSyslog, *c.SaveIDs, *c.EventStream)
 	u.Logf("   => Save Alarms %v / Anomalies %v / Protect Logs %v (thumbnails: %v)", *c.SaveAlarms, *c.SaveAnomal, *c.SaveProtectLogs, *c.ProtectThumbnails)
+	u.Logf("   => Save Client Events %v / Device Events %v (from client and device lists) / Client Inventory %v",
+		*c.SaveClientEvents, *c.SaveDeviceEvents, *c.ClientInventory)
 	u.Logf("   => Save Rogue APs: %v", *c.SaveRogue)
 	u.Logf("   => Save Traffic %v", *c.SaveTraffic)
 	u.Logf("   => Save Speed Tests: %v", *c.SaveSpeedTest)
+
+	if c.CaptureDir != "" {
+		u.Logf("   => Capturing API responses to: %s", c.CaptureDir)
+	}
+
+	if len(c.SiteOverrides) > 0 {
+		u.Logf("   => Site Overrides: %s", strings.Join(slices.Sorted(maps.Keys(c.SiteOverrides)), ", "))
+	}
 }
 
 // Events allows you to pull only events (and IDs) from the UniFi Controller.
-// This does not fully respect HashPII, but it may in the future!
+// PII in events, IDS, syslog and Protect logs is redacted with hash_pii and drop_pii,
+// except for what is in the free-form messages.
 // Use Filter.Path to pick a specific controller, otherwise poll them all!
 func (u *InputUnifi) Events(filter *poller.Filter) (*poller.Events, error) {
+	return u.EventsContext(context.Background(), filter)
+}
+
+// EventsContext is Events with a context. Canceling it stops waiting on the
+// controllers, and stops collecting between endpoints.
+func (u *InputUnifi) EventsContext(ctx context.Context, filter *poller.Filter) (*poller.Events, error) {
 	if u.Disable {
 		return nil, nil
 	}
 
 	logs := []any{}
+	labels := poller.Labels{}
+	cursors := []*poller.EventCursor{}
 
 	if filter == nil {
 		filter = &poller.Filter{}
@@ -209,21 +296,24 @@ func (u *InputUnifi) Events(filter *poller.Filter) (*poller.Events, error) {
 
 	var collectionErrors []error
 
-	for _, c := range u.Controllers {
-		if filter.Path != "" && !strings.EqualFold(c.URL, filter.Path) {
-			continue
-		}
+	for _, r := range pollControllers(ctx, u, "events", filter, u.collectControllerEvents) {
+		if errors.Is(r.err, ErrThrottled) {
+			u.Logf("Skipped collecting events from controller %s: %v", r.c.URL, r.err)
 
-		events, err := u.collectControllerEvents(c)
-		if err != nil {
+			continue
+		} else if r.err != nil {
 			// Log error but continue to next controller
-			u.LogErrorf("Failed to collect events from controller %s: %v", c.URL, err)
-			collectionErrors = append(collectionErrors, fmt.Errorf("%s: %w", c.URL, err))
+			u.LogErrorf("Failed to collect events from controller %s: %v", r.c.URL, r.err)
+			collectionErrors = append(collectionErrors, fmt.Errorf("%s: %w", r.c.URL, r.err))
 
 			continue
 		}
 
-		logs = append(logs, events...)
+		fresh, moved := newEvents(r.c, r.val.Logs, filter)
+		logs = append(logs, fresh...)
+		logs = append(logs, u.held.take(r.c.URL)...)
+		cursors = append(cursors, moved...)
+		labels.Merge(r.val.Labels)
 	}
 
 	// Return collected events even if some controllers failed
@@ -232,16 +322,24 @@ func (u *InputUnifi) Events(filter *poller.Filter) (*poller.Events, error) {
 		return nil, collectionErrors[0]
 	}
 
-	return &poller.Events{Logs: logs}, nil
+	return &poller.Events{Logs: logs, Labels: labels, Cursors: cursors}, nil
 }
 
 // Metrics grabs all the measurements from a UniFi controller and returns them.
 // Set Filter.Path to a controller URL for a specific controller (or get them all).
 func (u *InputUnifi) Metrics(filter *poller.Filter) (*poller.Metrics, error) {
+	return u.MetricsContext(context.Background(), filter)
+}
+
+// MetricsContext is Metrics with a context. Canceling it stops waiting on the
+// controllers; controllers not yet polled are reported down.
+func (u *InputUnifi) MetricsContext(ctx context.Context, filter *poller.Filter) (*poller.Metrics, error) {
 	if u.Disable {
 		return nil, nil
 	}
 
+	u.rediscoverIfDue()
+
 	metrics := &poller.Metrics{}
 
 	if filter == nil {
@@ -250,43 +348,46 @@ func (u *InputUnifi) Metrics(filter *poller.Filter) (*poller.Metrics, error) {
 
 	var collectionErrors []error
 
-	// Check if the request is for an existing, configured controller (or all controllers)
-	for _, c := range u.Controllers {
-		if filter.Path != "" && !strings.EqualFold(c.URL, filter.Path) {
-			// continue only if we have a filter path and it doesn't match.
-			continue
-		}
+	// Poll the existing, configured controller (or all controllers) that match the filter.
+	for _, r := range pollControllers(ctx, u, "metrics", filter, u.collectController) {
+		c, m, err := r.c, r.val, r.err
+		skipped := limiter(c).takeSkipped()
 
-		m, err := u.collectController(c)
-		if err != nil {
+		if errors.Is(err, ErrThrottled) {
+			// The controller asked us to back off; it is not down, and this is not a failure.
+			u.Logf("Skipped collecting metrics from controller %s: %v", c.URL, err)
+
+			metrics.ControllerStatuses = append(metrics.ControllerStatuses, poller.ControllerStatus{
+				Source:    controllerSource(c),
+				Up:        true,
+				Throttled: true,
+			})
+
+			continue
+		} else if err != nil {
 			// Log error but continue to next controller
 			u.LogErrorf("Failed to collect metrics from controller %s: %v", c.URL, err)
 			collectionErrors = append(collectionErrors, fmt.Errorf("%s: %w", c.URL, err))
 
 			// Record controller as down so output plugins can expose the status.
-			source := c.URL
-			if c.ID != "" {
-				source = c.ID
-			}
-
 			metrics.ControllerStatuses = append(metrics.ControllerStatuses, poller.ControllerStatus{
-				Source: source,
+				Source: controllerSource(c),
 				Up:     false,
 			})
 
 			continue
 		}
 
-		// Record controller as up.
-		source := c.URL
-		if c.ID != "" {
-			source = c.ID
+		if len(skipped) > 0 {
+			u.Logf("Skipped %s on controller %s while it was throttled", strings.Join(skipped, ", "), c.URL)
 		}
 
+		// Record controller as up.
 		if m != nil {
 			m.ControllerStatuses = append(m.ControllerStatuses, poller.ControllerStatus{
-				Source: source,
-				Up:     true,
+				Source:    controllerSource(c),
+				Up:        true,
+				Throttled: len(skipped) > 0,
 			})
 		}
 
@@ -307,22 +408,40 @@ func (u *InputUnifi) Metrics(filter *poller.Filter) (*poller.Metrics, error) {
 		return metrics, nil
 	}
 
-	if !u.Dynamic {
+	u.Lock()
+	dynamic := u.Dynamic // a reload may change it.
+	u.Unlock()
+
+	if !dynamic {
 		return nil, ErrDynamicLookupsDisabled
 	}
 
 	// Attempt a dynamic metrics fetch from an unconfigured controller.
-	return u.dynamicController(filter)
+	return u.dynamicController(ctx, filter)
+}
+
+// controllerSource is how a controller is identified in its poller.ControllerStatus.
+func controllerSource(c *Controller) string {
+	if c.ID != "" {
+		return c.ID
+	}
+
+	return c.URL
 }
 
-// RawMetrics returns API output from the first configured UniFi controller.
+// RawMetrics returns API output from the first configured UniFi controller, for one of
+// the filter kinds in rawPaths, or for the path in filter.Path with the "other" kind.
 // Adjust filter.Unit to pull from a controller other than the first.
 func (u *InputUnifi) RawMetrics(filter *poller.Filter) ([]byte, error) {
-	if l := len(u.Controllers); filter.Unit >= l {
+	u.Lock()
+	controllers := u.Controllers
+	u.Unlock()
+
+	if l := len(controllers); filter.Unit >= l {
 		return nil, fmt.Errorf("%d controller(s) configured, '%d': %w", l, filter.Unit, ErrControllerNumNotFound)
 	}
 
-	c := u.Controllers[filter.Unit]
+	c := controllers[filter.Unit]
 	if u.isNill(c) {
 		u.Logf("Re-authenticating to UniFi Controller: %s", c.URL)
 
@@ -335,21 +454,32 @@ func (u *InputUnifi) RawMetrics(filter *poller.Filter) ([]byte, error) {
 		return nil, err
 	}
 
-	sites, err := u.getFilteredSites(c)
+	sites, err := u.getFilteredSites(context.Background(), c)
 	if err != nil {
 		return nil, err
 	}
 
-	switch filter.Kind {
-	case "d", "device", "devices":
-		return u.getSitesJSON(c, unifi.APIDevicePath, sites)
-	case "client", "clients", "c":
-		return u.getSitesJSON(c, unifi.APIClientPath, sites)
-	case "other", "o":
-		return c.Unifi.GetJSON(filter.Path)
-	default:
+	var raw []byte
+
+	switch kind := rawKind(filter.Kind); {
+	case filter.Kind == "other", filter.Kind == "o":
+		raw, err = c.Unifi.GetJSON(filter.Path)
+	case rawPaths[kind].integration:
+		raw, err = u.getIntegrationJSON(c, rawPaths[kind].path)
+	case rawPaths[kind].path == "":
 		return []byte{}, ErrNoFilterKindProvided
+	case strings.Contains(rawPaths[kind].path, "%s"):
+		return u.getSitesJSON(c, rawPaths[kind].path, sites)
+	default:
+		raw, err = c.Unifi.GetJSON(rawPaths[kind].path)
 	}
+
+	if err != nil {
+		return raw, err //nolint:wrapcheck
+	}
+
+	// PII leaves in raw output no more than it does in metrics.
+	return c.redactor().rawJSON(raw)
 }
 
 func (u *InputUnifi) getSitesJSON(c *Controller, path string, sites []*unifi.Site) ([]byte, error) {
@@ -364,8 +494,73 @@ func (u *InputUnifi) getSitesJSON(c *Controller, path string, sites []*unifi.Sit
 			return allJSON, fmt.Errorf("controller: %w", err)
 		}
 
+		if body, err = c.forSite(siteNames(s)...).redactor().rawJSON(body); err != nil {
+			return allJSON, err
+		}
+
 		allJSON = append(allJSON, body...)
 	}
 
 	return allJSON, nil
 }
+
+// Close logs out of every controller with a session, configured and dynamic,
+// and drops its idle connections. Called once by the poller core on shutdown.
+func (u *InputUnifi) Close(ctx context.Context) error {
+	if u.Config == nil || u.Disable {
+		return nil
+	}
+
+	u.Lock()
+	controllers := append([]*Controller{}, u.Controllers...)
+
+	for _, c := range u.dynamic {
+		controllers = append(controllers, c)
+	}
+	u.Unlock()
+
+	u.closeInventory()
+
+	var errs []error
+
+	for _, c := range controllers {
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("logging out of %s: %w", c.URL, ctx.Err()))
+
+			continue
+		}
+
+		if err := u.logout(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// logout ends a user/pass session. API key auth has no session to end.
+func (u *InputUnifi) logout(c *Controller) error {
+	u.Lock()
+	defer u.Unlock()
+
+	if c.Unifi == nil {
+		return nil
+	}
+
+	defer func() {
+		c.Unifi.CloseIdleConnections()
+		c.Unifi = nil
+	}()
+
+	if c.APIKey != "" {
+		return nil
+	}
+
+	u.LogDebugf("Logging out of UniFi Controller: %s", c.URL)
+
+	if err := c.Unifi.Logout(); err != nil {
+		return fmt.Errorf("logging out of %s: %w", c.URL, err)
+	}
+
+	return nil
+}
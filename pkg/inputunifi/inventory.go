@@ -0,0 +1,252 @@
+package inputunifi
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/webserver"
+)
+
+/* Client inventory. With client_inventory, every client MAC seen is remembered, with
+   when and where, and the first sighting of a MAC becomes a client_new event. Clients
+   not seen for inventory_max_age are forgotten, and are new again if they come back.
+   The inventory is saved, and copied to the web server, at most once a minute while it
+   changes, and on shutdown. */
+
+const (
+	// inventoryFile is the inventory's file in the poller's state_dir.
+	inventoryFile = "unpoller-inventory.json"
+	// newClientAge is how long after its first sighting a client counts as new.
+	newClientAge = 24 * time.Hour
+	// inventorySaveInterval is the least time between saves of the inventory. The whole
+	// file is written, so a burst of new clients on a busy network is one write.
+	inventorySaveInterval = time.Minute
+	// defaultInventoryMaxAge is how long a client that is not seen is kept, without
+	// inventory_max_age.
+	defaultInventoryMaxAge = 90 * 24 * time.Hour
+	// maxInventoryValues is how many sources, sites, networks, APs and hostnames
+	// are kept per client; the oldest are dropped.
+	maxInventoryValues = 16
+)
+
+// clientInventory is every client seen on the controllers with client_inventory,
+// keyed by MAC. MACs are stored as redacted by hash_pii, and with drop_pii clients
+// have no MAC, so they are not inventoried.
+type clientInventory struct {
+	sync.Mutex
+	loaded  bool            // the inventory was read, or there was none to read.
+	found   bool            // the inventory was read from a file.
+	seeded  map[string]bool // controllers whose clients are in the inventory.
+	clients map[string]*webserver.InventoryClient
+	changed bool // clients were seen since the inventory was saved.
+	saved   time.Time
+}
+
+// savedInventory is the format of the inventoryFile.
+type savedInventory struct {
+	Clients []*webserver.InventoryClient `json:"clients"`
+}
+
+// updateInventory adds a controller's clients to the inventory, holds a client_new
+// event for each MAC never seen before, and publishes the known and new client
+// counts for each site to m. When there was no inventory to load, a controller's
+// first poll only fills it in; otherwise every client would be new.
+func (u *InputUnifi) updateInventory(c *Controller, m *poller.Metrics) {
+	if c.ClientInventory == nil || !*c.ClientInventory {
+		return
+	}
+
+	u.Lock()
+	maxAge := u.InventoryMaxAge.Duration
+	u.Unlock()
+
+	inv := &u.inventory
+	inv.Lock()
+	defer inv.Unlock()
+
+	u.loadInventory()
+
+	var (
+		now    = time.Now()
+		seeded = inv.found || inv.seeded[c.URL]
+		counts = make(map[[2]string]*poller.ClientInventory)
+		events = []any{}
+	)
+
+	inv.seeded[c.URL] = true
+
+	for _, v := range m.Clients {
+		client, ok := v.(*unifi.Client)
+		if !ok || client.Mac == "" {
+			continue
+		}
+
+		record, ok := inv.clients[client.Mac]
+		if !ok {
+			record = &webserver.InventoryClient{MAC: client.Mac, FirstSeen: now}
+			inv.clients[client.Mac] = record
+
+			if seeded {
+				e := clientEvent(c, poller.ClientNew, client, now)
+				events = append(events, e)
+
+				webserver.NewInputEvent(PluginName, e.SiteName+"_client_events", &webserver.Event{
+					Msg: e.Msg(), Ts: e.Time, Tags: map[string]string{
+						"type": e.Type, "mac": e.Mac, "site_name": e.SiteName, "source": e.SourceName,
+					},
+				})
+			}
+		}
+
+		sightClient(record, client, c, now)
+		inv.changed = true
+
+		key := [2]string{record.Sources[len(record.Sources)-1], client.SiteName}
+		if counts[key] == nil {
+			counts[key] = &poller.ClientInventory{SourceName: key[0], SiteName: key[1]}
+		}
+
+		if now.Sub(record.FirstSeen) < newClientAge {
+			counts[key].New++
+		} else {
+			counts[key].Known++
+		}
+	}
+
+	u.held.hold(c.URL, events)
+
+	sites := slices.SortedFunc(maps.Values(counts), func(a, b *poller.ClientInventory) int {
+		return cmp.Or(cmp.Compare(a.SourceName, b.SourceName), cmp.Compare(a.SiteName, b.SiteName))
+	})
+	poller.Publish(m, sites...)
+
+	if inv.changed && now.Sub(inv.saved) >= inventorySaveInterval {
+		u.pruneInventory(now, maxAge)
+		u.saveInventory(now)
+	}
+}
+
+// sightClient records where and when a client was seen.
+func sightClient(record *webserver.InventoryClient, client *unifi.Client, c *Controller, now time.Time) {
+	record.LastSeen = now
+	record.OUI = cmp.Or(client.Oui, record.OUI)
+	record.Sources = addValue(record.Sources, cmp.Or(client.SourceName, c.URL))
+	record.Sites = addValue(record.Sites, client.SiteName)
+	record.Networks = addValue(record.Networks, cmp.Or(client.Network, client.Essid))
+	record.Hostnames = addValue(record.Hostnames, cmp.Or(client.Hostname, client.Name))
+
+	if !client.IsWired.Val {
+		record.APs = addValue(record.APs, client.ApName)
+	}
+}
+
+// addValue moves v to the end of list, adding it if it is not there, so the most
+// recent value is last. Empty values are ignored.
+func addValue(list []string, v string) []string {
+	if v == "" {
+		return list
+	}
+
+	list = slices.DeleteFunc(list, func(s string) bool { return s == v })
+	list = append(list, v)
+
+	if over := len(list) - maxInventoryValues; over > 0 {
+		list = list[over:]
+	}
+
+	return list
+}
+
+// loadInventory reads the inventory from the state_dir, once. Call with the lock held.
+func (u *InputUnifi) loadInventory() {
+	inv := &u.inventory
+	if inv.loaded {
+		return
+	}
+
+	inv.loaded = true
+	inv.seeded = make(map[string]bool)
+	inv.clients = make(map[string]*webserver.InventoryClient)
+
+	var saved savedInventory
+
+	found, err := poller.LoadStateFile(inventoryFile, &saved)
+	if err != nil {
+		u.LogErrorf("Loading client inventory, starting a new one: %v", err)
+		return
+	}
+
+	for _, record := range saved.Clients {
+		inv.clients[record.MAC] = record
+	}
+
+	if inv.found = found; found {
+		u.Logf("Loaded %d clients from the client inventory", len(inv.clients))
+	}
+}
+
+// pruneInventory forgets the clients not seen for maxAge. Call with the lock held.
+func (u *InputUnifi) pruneInventory(now time.Time, maxAge time.Duration) {
+	if maxAge <= 0 {
+		maxAge = defaultInventoryMaxAge
+	}
+
+	pruned := 0
+
+	for mac, record := range u.inventory.clients {
+		if now.Sub(record.LastSeen) > maxAge {
+			delete(u.inventory.clients, mac)
+			pruned++
+		}
+	}
+
+	if pruned > 0 {
+		u.LogDebugf("Removed %d clients not seen in %v from the client inventory", pruned, maxAge)
+	}
+}
+
+// saveInventory writes the inventory to the state_dir, and copies it to the web server.
+// Call with the lock held.
+func (u *InputUnifi) saveInventory(now time.Time) {
+	list := u.inventoryList()
+	webserver.UpdateInput(&webserver.Input{Name: PluginName, Inventory: list})
+
+	u.inventory.changed = false
+	u.inventory.saved = now
+
+	if err := poller.SaveStateFile(inventoryFile, &savedInventory{Clients: list}); err != nil {
+		u.LogErrorf("Saving client inventory: %v", err)
+	}
+}
+
+// inventoryList returns a copy of the inventory, sorted by MAC. Call with the lock held.
+func (u *InputUnifi) inventoryList() webserver.Inventory {
+	list := make(webserver.Inventory, 0, len(u.inventory.clients))
+
+	for _, mac := range slices.Sorted(maps.Keys(u.inventory.clients)) {
+		record := *u.inventory.clients[mac]
+		record.Sources = slices.Clone(record.Sources)
+		record.Sites = slices.Clone(record.Sites)
+		record.Networks = slices.Clone(record.Networks)
+		record.APs = slices.Clone(record.APs)
+		record.Hostnames = slices.Clone(record.Hostnames)
+		list = append(list, &record)
+	}
+
+	return list
+}
+
+// closeInventory saves the inventory on shutdown, if there is one.
+func (u *InputUnifi) closeInventory() {
+	u.inventory.Lock()
+	defer u.inventory.Unlock()
+
+	if u.inventory.loaded && u.inventory.changed {
+		u.saveInventory(time.Now())
+	}
+}
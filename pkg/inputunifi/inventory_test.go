@@ -0,0 +1,86 @@
+//nolint:testpackage // white-box: exercises the unexported pruning and saving of the inventory.
+package inputunifi
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/webserver"
+)
+
+func TestPruneInventory(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		kept   []string
+	}{
+		{name: "default max age", kept: []string{"today", "last week", "last month"}},
+		{name: "max age set", maxAge: 10 * 24 * time.Hour, kept: []string{"today", "last week"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			u := &InputUnifi{}
+			u.inventory.clients = map[string]*webserver.InventoryClient{
+				"today":      {LastSeen: now.Add(-time.Hour)},
+				"last week":  {LastSeen: now.Add(-7 * 24 * time.Hour)},
+				"last month": {LastSeen: now.Add(-30 * 24 * time.Hour)},
+				"last year":  {LastSeen: now.Add(-365 * 24 * time.Hour)},
+			}
+
+			u.pruneInventory(now, test.maxAge)
+
+			kept := make([]string, 0, len(u.inventory.clients))
+			for mac := range u.inventory.clients {
+				kept = append(kept, mac)
+			}
+
+			assert.ElementsMatch(t, test.kept, kept)
+		})
+	}
+}
+
+func TestUpdateInventorySavesOncePerInterval(t *testing.T) {
+	t.Parallel()
+
+	on := true
+	u := &InputUnifi{Config: &Config{}}
+	c := &Controller{URL: "https://unifi", ClientInventory: &on}
+	poll := func(macs ...string) {
+		m := &poller.Metrics{}
+		for _, mac := range macs {
+			m.Clients = append(m.Clients, testClient(func(c *unifi.Client) { c.Mac = mac }))
+		}
+
+		u.updateInventory(c, m)
+	}
+
+	poll("aa:aa:aa:aa:aa:aa")
+
+	saved := u.inventory.saved
+	require.False(t, saved.IsZero(), "the first poll is saved")
+	assert.False(t, u.inventory.changed)
+
+	poll("aa:aa:aa:aa:aa:aa", "bb:bb:bb:bb:bb:bb")
+
+	assert.Equal(t, saved, u.inventory.saved, "a new client is not saved until the interval passes")
+	assert.True(t, u.inventory.changed, "the new client is saved with the next save")
+	assert.True(t, slices.ContainsFunc(u.inventoryList(), func(c *webserver.InventoryClient) bool {
+		return c.MAC == "bb:bb:bb:bb:bb:bb"
+	}))
+
+	u.inventory.saved = saved.Add(-inventorySaveInterval)
+	poll("aa:aa:aa:aa:aa:aa")
+
+	assert.False(t, u.inventory.changed, "it is saved once the interval passed")
+}
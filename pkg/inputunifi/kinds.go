@@ -0,0 +1,67 @@
+package inputunifi
+
+import (
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// registerKinds names every type this input puts into poller.Metrics and poller.Events,
+// so the core can tell which of them an output does not export.
+func registerKinds() {
+	// Devices.
+	poller.RegisterKind[*unifi.UAP]("uap")
+	poller.RegisterKind[*unifi.USW]("usw")
+	poller.RegisterKind[*unifi.PDU]("pdu")
+	poller.RegisterKind[*unifi.USG]("usg")
+	poller.RegisterKind[*unifi.UXG]("uxg")
+	poller.RegisterKind[*unifi.UBB]("ubb")
+	poller.RegisterKind[*unifi.UCI]("uci")
+	poller.RegisterKind[*unifi.UDB]("udb")
+	poller.RegisterKind[*unifi.UDM]("udm")
+	// Metrics.
+	poller.RegisterKind[*unifi.Site]("site")
+	poller.RegisterKind[*unifi.Client]("client")
+	poller.RegisterKind[*unifi.DPITable]("dpi")
+	poller.RegisterKind[*unifi.RogueAP]("rogue_ap")
+	poller.RegisterKind[*unifi.SpeedTestResult]("speed_test")
+	poller.RegisterKind[*unifi.UsageByCountry]("country_traffic")
+	poller.RegisterKind[*unifi.DHCPLease]("dhcp_lease")
+	poller.RegisterKind[*unifi.WANEnrichedConfiguration]("wan")
+	poller.RegisterKind[*unifi.Sysinfo]("sysinfo")
+	poller.RegisterKind[*unifi.FirewallPolicy]("firewall_policy")
+	poller.RegisterKind[*unifi.Topology]("topology")
+	poller.RegisterKind[*unifi.PortAnomaly]("port_anomaly")
+	poller.RegisterKind[*unifi.MagicSiteToSiteVPN]("vpn_mesh")
+	// Integration API, added in v5.26.0.
+	poller.RegisterKind[*unifi.WANStatus]("wan_status")
+	poller.RegisterKind[*unifi.PortForward]("port_forward")
+	poller.RegisterKind[*unifi.SSLCertificate]("ssl_certificate")
+	poller.RegisterKind[*unifi.UPSDeviceSelector]("ups")
+	poller.RegisterKind[*unifi.IntegrationDeviceStats]("device_stats")
+	poller.RegisterKind[*unifi.WifiBroadcast]("wifi_broadcast")
+	poller.RegisterKind[*unifi.FirewallZone]("firewall_zone")
+	poller.RegisterKind[*unifi.ACLRule]("acl_rule")
+	poller.RegisterKind[*unifi.VPNServer]("vpn_server")
+	poller.RegisterKind[*unifi.SiteToSiteTunnel]("site_to_site_tunnel")
+	poller.RegisterKind[*unifi.LAG]("lag")
+	poller.RegisterKind[*unifi.MCLAGDomain]("mclag_domain")
+	poller.RegisterKind[*unifi.SwitchStack]("switch_stack")
+	poller.RegisterKind[*unifi.DNSPolicy]("dns_policy")
+	poller.RegisterKind[*unifi.RADIUSProfile]("radius_profile")
+	poller.RegisterKind[*unifi.TrafficMatchingList]("traffic_matching_list")
+	poller.RegisterKind[*unifi.HotspotVoucher]("hotspot_voucher")
+	poller.RegisterKind[*unifi.DPIApplication]("dpi_application")
+	poller.RegisterKind[*unifi.DPICategory]("dpi_category")
+	poller.RegisterKind[*unifi.PendingDevice]("pending_device")
+	poller.RegisterKind[*unifi.Country]("country")
+	poller.RegisterKind[*poller.ClientInventory]("client_inventory")
+	// Events.
+	poller.RegisterKind[*unifi.Event]("event")
+	poller.RegisterKind[*unifi.IDS]("ids")
+	poller.RegisterKind[*unifi.Alarm]("alarm")
+	poller.RegisterKind[*unifi.Anomaly]("anomaly")
+	poller.RegisterKind[*unifi.SystemLogEntry]("system_log")
+	poller.RegisterKind[*unifi.ProtectLogEntry]("protect_log")
+	poller.RegisterKind[*poller.ClientEvent]("client_event")
+	poller.RegisterKind[*poller.DeviceEvent]("device_event")
+}
@@ -0,0 +1,272 @@
+package inputunifi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+/* PII pseudonymization. With hash_pii and a pii_key, names, MACs and IPs are replaced
+   with keyed HMAC-SHA256 pseudonyms: the same value always gets the same pseudonym,
+   but without the key nobody can work back to the value, and two installs with their
+   own keys do not share pseudonyms. Without a key, or with pii_hash = "md5", the old
+   unkeyed MD5 hashes are used, so existing dashboards keep working. A pii_key that is
+   set, or pii_hash = "hmac", selects HMAC; if that key is missing or cannot be read,
+   startup and reloads fail, and PII to hash is dropped rather than hashed without it.
+
+   Free text, like an event's msg, has its MAC and IP addresses replaced. Names in free
+   text cannot be told apart from the rest of it, and are left as they are. Raw API
+   output, for --dumpjson and support bundles, is redacted by key name: see rawJSON. */
+
+// PII hash modes for the pii_hash setting.
+const (
+	PIIHashHMAC = "hmac"
+	PIIHashMD5  = "md5"
+)
+
+// droppedPII replaces the addresses in free text with drop_pii.
+const droppedPII = "[redacted]"
+
+// piiInText matches what may be a MAC or IP address in free text.
+var piiInText = regexp.MustCompile( // nolint: gochecknoglobals
+	`(?:[0-9A-Fa-f]{2}[:-]){5}[0-9A-Fa-f]{2}|(?:\d{1,3}\.){3}\d{1,3}|[0-9A-Fa-f]*::?[0-9A-Fa-f:.]*[0-9A-Fa-f]`)
+
+// redactor drops or pseudonymizes PII with the settings of a controller or site.
+type redactor struct {
+	hash bool
+	drop bool
+	key  []byte // HMAC key; nil for MD5 hashes.
+}
+
+// piiHash returns the hash the controller uses for hash_pii.
+func (c *Controller) piiHash() string {
+	if c.PIIHash == PIIHashMD5 || (c.PIIHash == "" && c.PIIKey == "") {
+		return PIIHashMD5
+	}
+
+	return PIIHashHMAC
+}
+
+// resolvePIIKey reads the controller's pii_key, which may be a secret reference. With
+// HMAC selected, a key that is missing or cannot be read is an error.
+func (c *Controller) resolvePIIKey() error {
+	key, err := poller.ResolveSecret(c.PIIKey)
+
+	switch {
+	case c.piiHash() != PIIHashHMAC:
+		c.piiKey, c.piiKeyErr = "", nil
+	case err != nil:
+		c.piiKey, c.piiKeyErr = "", fmt.Errorf("%w: %w", ErrPIIKeyRead, err)
+	case key == "":
+		c.piiKey, c.piiKeyErr = "", ErrPIIKeyMissing
+	default:
+		c.piiKey, c.piiKeyErr = key, nil
+	}
+
+	return c.piiKeyErr
+}
+
+// checkPIIKeys returns an error for each controller whose pii_key is selected but
+// cannot be read. Startup and reloads fail on these, instead of hashing without a key.
+func (u *InputUnifi) checkPIIKeys() error {
+	var errs []error
+
+	for _, c := range u.Controllers {
+		if err := u.setControllerDefaults(c).piiKeyErr; err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.URL, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// redactor returns the controller's PII redactor. Use c.forSite first for data
+// from a site with overrides. PII to hash is dropped if the HMAC key could not be read.
+func (c *Controller) redactor() *redactor {
+	r := &redactor{hash: c.HashPII != nil && *c.HashPII, drop: c.DropPII != nil && *c.DropPII}
+	if c.piiHash() == PIIHashHMAC {
+		r.key = []byte(c.piiKey)
+		r.drop = r.drop || (r.hash && c.piiKey == "")
+	}
+
+	return r
+}
+
+// active returns true if the redactor changes anything.
+func (r *redactor) active() bool {
+	return r.hash || r.drop
+}
+
+// redact returns "" when dropping PII, and pii when not hashing it. ok is true when
+// the caller should replace pii with its pseudonym.
+func (r *redactor) redact(pii string) (string, bool) {
+	if r.drop {
+		return "", false
+	}
+
+	return pii, r.hash && pii != ""
+}
+
+// name pseudonymizes a name, hostname or other free-form value.
+func (r *redactor) name(pii string) string {
+	out, ok := r.redact(pii)
+	if !ok {
+		return out
+	}
+
+	if r.key == nil {
+		return RedactNamePII(pii, &r.hash, &r.drop)
+	}
+
+	return hex.EncodeToString(r.sum("name", pii))[:24]
+}
+
+// mac pseudonymizes a MAC address. Pseudonyms are MAC addresses too, marked locally
+// administered, and the same address in any notation gets the same pseudonym.
+func (r *redactor) mac(pii string) string {
+	out, ok := r.redact(pii)
+	if !ok {
+		return out
+	}
+
+	if r.key == nil {
+		return RedactMacPII(pii, &r.hash, &r.drop)
+	}
+
+	normal := strings.NewReplacer(":", "", "-", "", ".", "").Replace(strings.ToLower(pii))
+	sum := r.sum("mac", normal)[:6]
+	sum[0] = sum[0]&0xfc | 0x02 // unicast, locally administered.
+
+	return net.HardwareAddr(sum).String()
+}
+
+// ip pseudonymizes an IP address.
+func (r *redactor) ip(pii string) string {
+	out, ok := r.redact(pii)
+	if !ok {
+		return out
+	}
+
+	if r.key == nil {
+		return RedactIPPII(pii, &r.hash, &r.drop)
+	}
+
+	s := hex.EncodeToString(r.sum("ip", pii))
+
+	return fmt.Sprintf("%s.%s.%s", s[:4], s[4:8], s[8:12])
+}
+
+// text replaces the MAC and IP addresses in free text, like an event's msg, with
+// their pseudonyms, or with [redacted] when dropping PII.
+func (r *redactor) text(pii string) string {
+	if !r.active() {
+		return pii
+	}
+
+	return piiInText.ReplaceAllStringFunc(pii, func(found string) string {
+		_, macErr := net.ParseMAC(found)
+
+		switch {
+		case macErr != nil && net.ParseIP(found) == nil:
+			return found // not an address, ie. a time of day.
+		case r.drop:
+			return droppedPII
+		case macErr == nil:
+			return r.mac(found)
+		default:
+			return r.ip(found)
+		}
+	})
+}
+
+// piiNameKeys are the JSON keys of names and hostnames in raw API output. "name" is
+// only PII in objects with a MAC, like clients and devices; not, say, in a site.
+var piiNameKeys = []string{"hostname", "host_name", "client_name", "device_name", "name"} // nolint: gochecknoglobals
+
+// rawJSON redacts one or more JSON documents, like the concatenated responses of several
+// sites. The values of keys ending in mac or ip are addresses, those in piiNameKeys are
+// names, and every other string has the addresses in it replaced like text.
+func (r *redactor) rawJSON(raw []byte) ([]byte, error) {
+	if !r.active() {
+		return raw, nil
+	}
+
+	var out bytes.Buffer
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+
+	for {
+		var v any
+
+		if err := dec.Decode(&v); err == io.EOF { //nolint:errorlint // Decode returns it unwrapped.
+			return out.Bytes(), nil
+		} else if err != nil {
+			return nil, fmt.Errorf("redacting PII: %w", err) // not valid json, so it cannot be redacted.
+		}
+
+		if err := enc.Encode(r.rawValue("", v, false)); err != nil {
+			return nil, fmt.Errorf("redacting PII: %w", err)
+		}
+	}
+}
+
+// rawValue redacts a JSON value found at key. named is true in an object with a MAC.
+func (r *redactor) rawValue(key string, v any, named bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		_, named = v["mac"]
+
+		for k, val := range v {
+			v[k] = r.rawValue(k, val, named)
+		}
+	case []any:
+		for i := range v {
+			v[i] = r.rawValue(key, v[i], named) // ie. a list of IPs.
+		}
+	case string:
+		return r.rawString(strings.ToLower(key), v, named)
+	}
+
+	return v
+}
+
+func (r *redactor) rawString(key, value string, named bool) string {
+	_, macErr := net.ParseMAC(value)
+
+	switch {
+	case strings.HasSuffix(key, "mac") && macErr == nil:
+		return r.mac(value)
+	case (key == "ip" || strings.HasSuffix(key, "_ip")) && net.ParseIP(value) != nil:
+		return r.ip(value)
+	case key == "name" && !named:
+		return r.text(value)
+	case slices.Contains(piiNameKeys, key):
+		return r.name(value)
+	default:
+		return r.text(value)
+	}
+}
+
+// sum returns the HMAC of a value. The kind keeps a name and an address that are
+// spelled the same from getting the same pseudonym.
+func (r *redactor) sum(kind, pii string) []byte {
+	h := hmac.New(sha256.New, r.key)
+	h.Write([]byte(kind + "\x00" + pii))
+
+	return h.Sum(nil)
+}
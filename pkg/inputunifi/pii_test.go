@@ -0,0 +1,67 @@
+//nolint:testpackage // white-box: exercises the unexported redaction of raw API output.
+package inputunifi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactRawJSON(t *testing.T) {
+	t.Parallel()
+
+	const raw = `{"data":[{"mac":"aa:bb:cc:dd:ee:ff","name":"Kitchen AP","ip":"192.168.1.20",` +
+		`"uplink":{"gw_mac":"11:22:33:44:55:66"},"msg":"User 192.168.1.20 roamed","rx_bytes":123456789012}]}` +
+		"\n" + `{"data":[{"name":"default","desc":"Default"}]}`
+
+	on := true
+	redactors := map[string]*redactor{
+		"hashed":  {hash: true, key: []byte("not-a-secret")},
+		"dropped": {drop: true},
+	}
+
+	tests := []struct {
+		name     string
+		redactor string
+		gone     []string // PII that is not in the output.
+		kept     []string // what is kept as it was.
+	}{
+		{
+			name:     "hashed",
+			redactor: "hashed",
+			gone:     []string{"aa:bb:cc:dd:ee:ff", "11:22:33:44:55:66", "Kitchen AP", "192.168.1.20"},
+			kept:     []string{`"name":"default"`, `"rx_bytes":123456789012`, `"msg":"User `, " roamed"},
+		},
+		{
+			name:     "dropped",
+			redactor: "dropped",
+			gone:     []string{"aa:bb:cc:dd:ee:ff", "11:22:33:44:55:66", "Kitchen AP", "192.168.1.20"},
+			kept:     []string{`"name":"default"`, `"mac":""`, `"msg":"User [redacted] roamed"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			out, err := redactors[test.redactor].rawJSON([]byte(raw))
+			require.NoError(t, err)
+
+			for _, pii := range test.gone {
+				assert.NotContains(t, string(out), pii)
+			}
+
+			for _, kept := range test.kept {
+				assert.Contains(t, string(out), kept)
+			}
+		})
+	}
+
+	out, err := (&Controller{HashPII: new(bool), DropPII: new(bool)}).redactor().rawJSON([]byte(raw))
+	require.NoError(t, err)
+	assert.Equal(t, raw, string(out), "without hash_pii or drop_pii, raw output is not changed")
+
+	_, err = (&Controller{HashPII: &on}).redactor().rawJSON([]byte(`{"mac":`))
+	assert.Error(t, err, "output that cannot be parsed cannot be redacted")
+}
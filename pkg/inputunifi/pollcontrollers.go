@@ -0,0 +1,146 @@
+package inputunifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+var (
+	// ErrPollDeadline is returned for a controller that did not finish within its poll_timeout.
+	ErrPollDeadline = errors.New("controller poll deadline exceeded")
+	// ErrPollInProgress is returned when a controller's previous poll is still running,
+	// usually because it blew through its poll_timeout on an earlier interval.
+	ErrPollInProgress = errors.New("previous poll of this controller is still running")
+)
+
+// pollResult is the outcome of collecting from one controller.
+type pollResult[T any] struct {
+	c   *Controller
+	val T
+	err error
+}
+
+// inflightKey identifies one running collection. Metrics and events are tracked separately.
+// Controllers are known by URL, as a reload or rediscovery may replace the *Controller.
+type inflightKey struct {
+	url  string
+	kind string
+}
+
+// maxConcurrency returns how many controllers may be polled at the same time.
+func (u *InputUnifi) maxConcurrency() int {
+	if u.MaxConcurrency > 0 {
+		return u.MaxConcurrency
+	}
+
+	return defaultMaxConcurrency
+}
+
+// pollControllers runs poll against every configured controller that matches the filter,
+// no more than max_concurrency at a time. Results are returned in controller order, so
+// merging them produces the same output the old one-at-a-time loop did. Controllers
+// still waiting for a slot when ctx is canceled are returned with the context error.
+func pollControllers[T any](ctx context.Context, u *InputUnifi, kind string, filter *poller.Filter,
+	poll func(context.Context, *Controller) (T, error),
+) []pollResult[T] {
+	// A config reload may swap the controller list; poll the one in place right now.
+	u.Lock()
+	configured, maxConcurrency := u.Controllers, u.maxConcurrency()
+	u.Unlock()
+
+	controllers := make([]*Controller, 0, len(configured))
+
+	for _, c := range configured {
+		if filter.Path != "" && !strings.EqualFold(c.URL, filter.Path) {
+			continue
+		}
+
+		controllers = append(controllers, c)
+	}
+
+	results := make([]pollResult[T], len(controllers))
+	limit := make(chan struct{}, maxConcurrency)
+	wg := sync.WaitGroup{}
+
+	for idx, c := range controllers {
+		select {
+		case limit <- struct{}{}:
+		case <-ctx.Done():
+			results[idx] = pollResult[T]{c: c, err: fmt.Errorf("polling %s: %w", kind, ctx.Err())}
+
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+
+			val, err := pollWithDeadline(ctx, u, kind, c, poll)
+			results[idx] = pollResult[T]{c: c, val: val, err: err}
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// pollWithDeadline runs poll for one controller and gives up waiting on it after the
+// controller's poll_timeout, or when ctx is canceled. The unifi library cannot cancel
+// a request in flight, so a collection that misses its deadline keeps running in the
+// background until its current request returns; the controller is skipped with
+// ErrPollInProgress until then, so slow polls never pile up.
+func pollWithDeadline[T any](ctx context.Context, u *InputUnifi, kind string, c *Controller,
+	poll func(context.Context, *Controller) (T, error),
+) (T, error) {
+	var zero T
+
+	key := inflightKey{url: c.URL, kind: kind}
+	if _, busy := u.inflight.LoadOrStore(key, struct{}{}); busy {
+		return zero, ErrPollInProgress
+	}
+
+	pctx, cancel := ctx, context.CancelFunc(func() {})
+	if c.PollTimeout.Duration > 0 {
+		pctx, cancel = context.WithTimeout(ctx, c.PollTimeout.Duration)
+	}
+	defer cancel()
+
+	done := make(chan pollResult[T], 1)
+
+	go func() {
+		defer u.inflight.Delete(key)
+		defer func() {
+			// This goroutine is ours, so the core's panic recovery cannot see it.
+			if r := recover(); r != nil {
+				done <- pollResult[T]{c: c, err: fmt.Errorf("panic polling %s %s: %v", kind, c.URL, r)} //nolint:err113
+			}
+		}()
+
+		val, err := poll(pctx, c)
+		done <- pollResult[T]{c: c, val: val, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.val, r.err
+	case <-pctx.Done():
+		if err := ctx.Err(); err != nil {
+			return zero, fmt.Errorf("polling %s: %w", kind, err)
+		}
+
+		u.logWith("controller", c.URL).LogErrorf("Controller %s did not finish collecting %s within %v; skipping it until it does",
+			c.URL, kind, c.PollTimeout)
+
+		return zero, fmt.Errorf("%w after %v", ErrPollDeadline, c.PollTimeout)
+	}
+}
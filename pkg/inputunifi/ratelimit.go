@@ -0,0 +1,194 @@
+package inputunifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/unpoller/unifi/v5"
+)
+
+/* Rate limiting. Every request to a controller takes a token from that controller's
+   bucket, so with rate_limit set a poll's requests are spread out instead of bursting.
+   A 429 Too Many Requests on any endpoint pauses the controller for its Retry-After;
+   requests during the pause are skipped with ErrThrottled instead of being sent, and
+   only the collections they belong to are left out of the poll. After a 429, the
+   requests of a poll are spread over the poll interval, even without rate_limit. */
+
+// ErrThrottled is returned for a request that was not sent because the controller
+// asked us to back off. Collections that get it are skipped until the next poll.
+var ErrThrottled = errors.New("controller is throttled")
+
+const (
+	// defaultRateBurst is how many requests may go out back to back with rate_limit set.
+	defaultRateBurst = 10
+	// defaultRetryAfter is how long to back off after a 429 without a Retry-After.
+	defaultRetryAfter = 30 * time.Second
+)
+
+// limiters holds a rate limiter per controller URL. They are kept here, and not on
+// the Controller, so a reload or rediscovery does not forget a Retry-After.
+var limiters = struct { // nolint: gochecknoglobals
+	sync.Mutex
+	byURL map[string]*rateLimiter
+}{byURL: make(map[string]*rateLimiter)}
+
+// rateLimiter is a token bucket for one controller.
+type rateLimiter struct {
+	sync.Mutex
+	rate    float64 // tokens added per second, from rate_limit; 0 for no limit.
+	spread  float64 // tokens added per second after a 429: a poll's requests per interval.
+	burst   float64
+	tokens  float64
+	updated time.Time
+	until   time.Time // requests are skipped until this time, after a 429.
+	skipped []string  // endpoints skipped since takeSkipped was last called.
+	polled  time.Time // when the last poll started.
+	every   time.Duration
+	sent    int // requests since the last poll started.
+	prev    int // requests in the poll before.
+}
+
+// limiter returns the controller's rate limiter, updated with its current settings.
+func limiter(c *Controller) *rateLimiter {
+	limiters.Lock()
+	defer limiters.Unlock()
+
+	l := limiters.byURL[c.URL]
+	fresh := l == nil
+
+	if fresh {
+		l = &rateLimiter{updated: time.Now()}
+		limiters.byURL[c.URL] = l
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	l.rate, l.burst = c.RateLimit, float64(c.RateBurst)
+	if l.burst < 1 {
+		l.burst = defaultRateBurst
+	}
+
+	if fresh || l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+
+	return l
+}
+
+// startPoll counts the requests of each poll, and the time between polls, so they
+// can be spread over the interval after a 429.
+func (l *rateLimiter) startPoll(now time.Time) {
+	l.Lock()
+	defer l.Unlock()
+
+	if !l.polled.IsZero() {
+		l.every, l.prev = now.Sub(l.polled), l.sent
+	}
+
+	l.polled, l.sent = now, 0
+}
+
+// limit returns how many requests per second may be sent; 0 for no limit.
+func (l *rateLimiter) limit() float64 {
+	if l.spread > 0 && (l.rate <= 0 || l.spread < l.rate) {
+		return l.spread
+	}
+
+	return l.rate
+}
+
+// wait blocks until the next request to an endpoint may be sent, or ctx is done. It
+// returns ErrThrottled without waiting while the controller is backing off.
+func (l *rateLimiter) wait(ctx context.Context, endpoint string) error {
+	l.Lock()
+
+	now := time.Now()
+	if now.Before(l.until) {
+		l.skipped = append(l.skipped, endpoint)
+		until := l.until
+		l.Unlock()
+
+		return fmt.Errorf("%w for another %v", ErrThrottled, until.Sub(now).Round(time.Second))
+	}
+
+	l.sent++
+
+	rate := l.limit()
+	if rate <= 0 {
+		l.Unlock()
+		return nil
+	}
+
+	// Take a token, even one that has not been added yet, and wait for it.
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.updated).Seconds()*rate) - 1
+	l.updated = now
+	delay := time.Duration(-l.tokens / rate * float64(time.Second))
+	l.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.Lock()
+		l.tokens++ // not sent; give the token back.
+		l.sent--
+		l.Unlock()
+
+		return fmt.Errorf("waiting to send %s: %w", endpoint, ctx.Err())
+	}
+}
+
+// backOff pauses the controller when err is a 429, for the Retry-After it sent or
+// defaultRetryAfter, and counts the endpoint as skipped. From then on, a poll's
+// requests are spread over the poll interval. Returns how long, or 0 if err is
+// not a 429.
+func (l *rateLimiter) backOff(err error, endpoint string) time.Duration {
+	if !errors.Is(err, unifi.ErrTooManyRequests) {
+		return 0
+	}
+
+	wait := defaultRetryAfter
+
+	var rl *unifi.RateLimitError
+	if errors.As(err, &rl) && rl.RetryAfter > 0 {
+		wait = rl.RetryAfter
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if until := time.Now().Add(wait); until.After(l.until) {
+		l.until = until
+	}
+
+	if l.every > 0 {
+		l.spread = float64(max(l.sent, l.prev)) / l.every.Seconds()
+	}
+
+	l.skipped = append(l.skipped, endpoint)
+
+	return wait
+}
+
+// takeSkipped returns the endpoints skipped since the last call, once each.
+func (l *rateLimiter) takeSkipped() []string {
+	l.Lock()
+	defer l.Unlock()
+
+	skipped := slices.Compact(slices.Sorted(slices.Values(l.skipped)))
+	l.skipped = nil
+
+	return skipped
+}
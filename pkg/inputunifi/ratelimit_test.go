@@ -0,0 +1,86 @@
+//nolint:testpackage // white-box: exercises the unexported rate limiter.
+package inputunifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unifi/v5"
+)
+
+func newTestLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), updated: time.Now()}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	t.Parallel()
+
+	l := newTestLimiter(50, 1)
+	require.NoError(t, l.wait(t.Context(), "GetSites"), "the burst goes out at once")
+
+	start := time.Now()
+	require.NoError(t, l.wait(t.Context(), "GetClients"))
+	assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond, "the next request waits for its token")
+
+	slow := newTestLimiter(0.01, 1)
+	require.NoError(t, slow.wait(t.Context(), "GetSites"))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	err := slow.wait(ctx, "GetClients")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "a done context stops the wait")
+	assert.InDelta(t, 0, slow.tokens, 0.01, "the token of a request not sent is given back")
+	assert.Equal(t, 1, slow.sent)
+}
+
+func TestRateLimiterBackOff(t *testing.T) {
+	t.Parallel()
+
+	l := newTestLimiter(0, 0)
+	assert.Zero(t, l.backOff(errors.New("500 Internal Server Error"), "GetDevices"))
+	assert.Zero(t, l.backOff(nil, "GetDevices"))
+
+	tooMany := fmt.Errorf("%w: %w", unifi.ErrTooManyRequests, &unifi.RateLimitError{RetryAfter: time.Minute})
+	assert.Equal(t, time.Minute, l.backOff(tooMany, "GetDevices"))
+	assert.Equal(t, defaultRetryAfter, l.backOff(unifi.ErrTooManyRequests, "GetDevices"),
+		"a 429 without a Retry-After backs off for the default, without shortening the pause")
+
+	require.ErrorIs(t, l.wait(t.Context(), "GetClients"), ErrThrottled, "requests are skipped while backing off")
+	require.ErrorIs(t, l.wait(t.Context(), "GetClients"), ErrThrottled)
+
+	assert.Equal(t, []string{"GetClients", "GetDevices"}, l.takeSkipped(), "each skipped endpoint is named once")
+	assert.Empty(t, l.takeSkipped())
+}
+
+func TestRateLimiterSpreadsAfterBackOff(t *testing.T) {
+	t.Parallel()
+
+	l := newTestLimiter(0, 0)
+	start := time.Now()
+
+	l.startPoll(start)
+
+	for range 6 {
+		require.NoError(t, l.wait(t.Context(), "GetClients"))
+	}
+
+	l.startPoll(start.Add(time.Minute))
+	assert.Zero(t, l.limit(), "no limit until the controller throttles")
+
+	l.backOff(unifi.ErrTooManyRequests, "GetDevices")
+	assert.InDelta(t, 0.1, l.limit(), 0.001, "six requests a poll, spread over a minute")
+
+	l.rate = 0.05
+	assert.InDelta(t, 0.05, l.limit(), 0.001, "a lower rate_limit still applies")
+
+	l.rate = 1
+	assert.InDelta(t, 0.1, l.limit(), 0.001)
+}
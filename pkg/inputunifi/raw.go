@@ -0,0 +1,108 @@
+package inputunifi
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/unpoller/unifi/v5"
+)
+
+// rawEndpoint is an API path RawMetrics serves. Paths with a %s are fetched for every
+// site: by name, or by ID from the integration API's site list for integration paths.
+type rawEndpoint struct {
+	path        string
+	integration bool
+}
+
+// rawPaths are the filter kinds RawMetrics serves, for --dumpjson and --support-bundle.
+var rawPaths = map[string]rawEndpoint{ // nolint: gochecknoglobals
+	"devices":   {path: unifi.APIDevicePath},
+	"clients":   {path: unifi.APIClientPath},
+	"sites":     {path: "/api/self/sites"},
+	"health":    {path: "/api/s/%s/stat/health"},
+	"sysinfo":   {path: "/api/s/%s/stat/sysinfo"},
+	"events":    {path: "/api/s/%s/stat/event"},
+	"alarms":    {path: "/api/s/%s/list/alarm"},
+	"anomalies": {path: "/api/s/%s/stat/anomalies"},
+	"ids":       {path: "/api/s/%s/stat/ips/event"},
+	"rogueaps":  {path: "/api/s/%s/stat/rogueap"},
+	// The integration API needs an API key.
+	"integration-sites":   {path: integrationSitesPath, integration: true},
+	"integration-devices": {path: "/proxy/network/integration/v1/sites/%s/devices", integration: true},
+	"integration-clients": {path: "/proxy/network/integration/v1/sites/%s/clients", integration: true},
+}
+
+const integrationSitesPath = "/proxy/network/integration/v1/sites"
+
+// rawKind returns the filter kind an alias stands for.
+func rawKind(kind string) string {
+	switch kind {
+	case "d", "device":
+		return "devices"
+	case "c", "client":
+		return "clients"
+	default:
+		return kind
+	}
+}
+
+// RawPath returns the API path a filter kind dumps, with a %s where the site goes, or
+// an empty string for an unknown kind. The replay input uses it to serve support bundles.
+func RawPath(kind string) string {
+	return rawPaths[rawKind(kind)].path
+}
+
+func rawKinds() []string {
+	return slices.Sorted(maps.Keys(rawPaths))
+}
+
+// RawKinds returns the filter kinds RawMetrics serves. Satisfies poller.RawDumper.
+func (u *InputUnifi) RawKinds() []string {
+	return rawKinds()
+}
+
+// RawUnits returns how many controllers are configured. Satisfies poller.RawDumper.
+func (u *InputUnifi) RawUnits() int {
+	u.Lock()
+	defer u.Unlock()
+
+	return len(u.Controllers)
+}
+
+// getIntegrationJSON returns an integration API path, for every site if it has a %s.
+func (u *InputUnifi) getIntegrationJSON(c *Controller, path string) ([]byte, error) {
+	body, err := c.Unifi.GetJSON(integrationSitesPath)
+	if err != nil || !strings.Contains(path, "%s") {
+		return body, err //nolint:wrapcheck
+	}
+
+	var sites struct {
+		Data []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(body, &sites); err != nil {
+		return nil, fmt.Errorf("decoding integration sites: %w", err)
+	}
+
+	allJSON := []byte{}
+
+	for _, s := range sites.Data {
+		apiPath := fmt.Sprintf(path, s.ID)
+		u.LogDebugf("Returning Path '%s' for site: %s (%s):\n", apiPath, s.Name, s.ID)
+
+		body, err := c.Unifi.GetJSON(apiPath)
+		if err != nil {
+			return allJSON, fmt.Errorf("controller: %w", err)
+		}
+
+		allJSON = append(allJSON, body...)
+	}
+
+	return allJSON, nil
+}
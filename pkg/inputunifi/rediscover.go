@@ -0,0 +1,149 @@
+package inputunifi
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/unpoller/unifi/v5"
+)
+
+/* Rediscovery. With rediscover_interval, the site list of every controller is refreshed,
+   and remote consoles are discovered again, so new sites and consoles are polled without
+   a restart. It works like a reload of the running config. */
+
+// rediscovery tracks when the controllers were last rediscovered.
+type rediscovery struct {
+	reload     sync.Mutex // one Reload or rediscovery at a time.
+	sync.Mutex            // locks the fields below.
+	running    bool
+	last       time.Time
+	sites      map[string][]string // site names last found, keyed by controller URL.
+}
+
+// rediscoverIfDue starts a rediscovery in the background when rediscover_interval
+// has passed since the last one. Polls call this, so it stops when polling stops.
+func (u *InputUnifi) rediscoverIfDue() {
+	u.Lock()
+	interval := u.Rediscover.Duration
+	u.Unlock()
+
+	if interval <= 0 {
+		return
+	}
+
+	d := &u.discovery
+	d.Lock()
+	defer d.Unlock()
+
+	if d.running || time.Since(d.last) < interval {
+		return
+	}
+
+	d.running = true
+
+	go func() {
+		defer func() {
+			d.Lock()
+			d.running, d.last = false, time.Now()
+			d.Unlock()
+		}()
+
+		u.rediscover()
+	}()
+}
+
+// rediscover builds the controller list again from the configured controllers, and
+// swaps it in like Reload does: new consoles are logged into, retired ones are logged
+// out of, and every controller's sites are checked again. When remote discovery fails,
+// the current controllers are kept until the next try.
+func (u *InputUnifi) rediscover() {
+	u.discovery.reload.Lock()
+	defer u.discovery.reload.Unlock()
+
+	u.Lock()
+	defaults := u.Default
+	defaults.results = nil // it may be polled, and gets its own like the others.
+	n := &InputUnifi{Logger: u.Logger, Config: &Config{
+		Default:         defaults,
+		Disable:         u.Disable,
+		Dynamic:         u.Dynamic,
+		Remote:          u.Remote,
+		RemoteAPIKey:    u.RemoteAPIKey,
+		MaxConcurrency:  u.MaxConcurrency,
+		Rediscover:      u.Rediscover,
+		InventoryMaxAge: u.InventoryMaxAge,
+		Controllers:     cloneControllers(u.configured),
+	}}
+	u.Unlock()
+
+	u.LogDebugf("Rediscovering UniFi sites and remote consoles")
+
+	if err := n.configureControllers(); err != nil {
+		u.LogErrorf("Rediscovery failed, keeping the current controllers: %v", err)
+		return
+	}
+
+	u.applyConfig(n)
+}
+
+// siteChanges records the sites found on a controller, and logs the sites that were
+// added or removed since they were last found. Returns true if this is the first
+// time the controller's sites were found, or if they changed.
+func (u *InputUnifi) siteChanges(c *Controller, sites []*unifi.Site) bool {
+	names := make([]string, 0, len(sites))
+	for _, site := range sites {
+		names = append(names, site.Name)
+	}
+
+	slices.Sort(names)
+
+	d := &u.discovery
+	d.Lock()
+
+	if d.sites == nil {
+		d.sites = make(map[string][]string)
+	}
+
+	prev, found := d.sites[c.URL]
+	d.sites[c.URL] = names
+	d.Unlock()
+
+	if !found {
+		return true
+	}
+
+	changed := false
+
+	for _, name := range names {
+		if !slices.Contains(prev, name) {
+			u.Logf("New site %s found on controller %s", name, c.URL)
+			changed = true
+		}
+	}
+
+	for _, name := range prev {
+		if !slices.Contains(names, name) {
+			u.Logf("Site %s is gone from controller %s", name, c.URL)
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// cloneControllers copies a list of controllers, so defaults and discovery applied to
+// the copies do not change the originals.
+func cloneControllers(list []*Controller) []*Controller {
+	clones := make([]*Controller, len(list))
+
+	for i, c := range list {
+		clone := *c
+		clone.results = nil // the copy gets its own.
+		clone.Sites = slices.Clone(c.Sites)
+		clone.CertPaths = slices.Clone(c.CertPaths)
+		clones[i] = &clone
+	}
+
+	return clones
+}
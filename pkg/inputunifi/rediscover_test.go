@@ -0,0 +1,94 @@
+//nolint:testpackage // white-box: exercises the unexported rediscovery helpers.
+package inputunifi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unpoller/unifi/v5"
+	"golift.io/cnfg"
+)
+
+func testSites(names ...string) []*unifi.Site {
+	sites := make([]*unifi.Site, len(names))
+	for i, name := range names {
+		sites[i] = &unifi.Site{Name: name}
+	}
+
+	return sites
+}
+
+func TestSiteChanges(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		before  []string // nil: never found before.
+		after   []string
+		changed bool
+	}{
+		{name: "first time", after: []string{"default"}, changed: true},
+		{name: "unchanged", before: []string{"default", "guest"}, after: []string{"guest", "default"}},
+		{name: "added", before: []string{"default"}, after: []string{"default", "guest"}, changed: true},
+		{name: "removed", before: []string{"default", "guest"}, after: []string{"default"}, changed: true},
+		{name: "replaced", before: []string{"guest"}, after: []string{"lab"}, changed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			u := &InputUnifi{}
+			c := &Controller{URL: "https://unifi"}
+
+			if test.before != nil {
+				u.siteChanges(c, testSites(test.before...))
+			}
+
+			assert.Equal(t, test.changed, u.siteChanges(c, testSites(test.after...)))
+			assert.False(t, u.siteChanges(c, testSites(test.after...)), "nothing changed since the last call")
+		})
+	}
+}
+
+func TestSameController(t *testing.T) {
+	t.Parallel()
+
+	session := &unifi.Unifi{}
+	on, off := true, false
+
+	configured := func() *Controller {
+		return &Controller{
+			URL: "https://unifi", ConsoleID: "console", Sites: []string{"all"}, VerifySSL: &off,
+			SaveDPI: &off, Intervals: map[string]cnfg.Duration{"dpi": {Duration: time.Hour}},
+			Unifi: session,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Controller)
+		same   bool
+	}{
+		{name: "unchanged", change: func(*Controller) {}, same: true},
+		{name: "new flag pointer, same value", change: func(c *Controller) { f := false; c.SaveDPI = &f }, same: true},
+		{name: "site added", change: func(c *Controller) { c.Sites = []string{"default", "guest"} }},
+		{name: "setting changed", change: func(c *Controller) { c.SaveDPI = &on }},
+		{name: "interval changed", change: func(c *Controller) { c.Intervals["dpi"] = cnfg.Duration{Duration: time.Minute} }},
+		{name: "new session", change: func(c *Controller) { c.Unifi = &unifi.Unifi{} }},
+		{name: "other console", change: func(c *Controller) { c.ConsoleID = "other" }},
+		{name: "results saved", change: func(c *Controller) { c.results = &resultCache{} }, same: true},
+		{name: "pii key rotated", change: func(c *Controller) { c.piiKey = "rotated" }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := configured()
+			test.change(c)
+			assert.Equal(t, test.same, sameController(configured(), c))
+		})
+	}
+}
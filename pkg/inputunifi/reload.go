@@ -0,0 +1,150 @@
+package inputunifi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/webserver"
+)
+
+// ErrReloadDisable is returned when a reload would enable or disable the whole plugin.
+var ErrReloadDisable = errors.New("enabling or disabling the unifi input requires a restart")
+
+var _ poller.Reloader = &InputUnifi{}
+
+// Reload applies a new unifi config without a restart. Controllers that were added,
+// or whose URL or credentials changed, are logged into; removed controllers are logged
+// out of. A controller that still logs in the same way keeps its session and picks up
+// its other new settings. The pii_key is read again, so a rotated key is used from the
+// next poll. Satisfies poller.Reloader.
+func (u *InputUnifi) Reload(config any) error {
+	n, ok := config.(*InputUnifi)
+	if !ok {
+		return fmt.Errorf("%w: %T", poller.ErrReloadConfigType, config)
+	}
+
+	if n.Config == nil {
+		n.Config = &Config{Disable: true}
+	}
+
+	if u.Disable != n.Disable {
+		return ErrReloadDisable
+	} else if u.Disable {
+		return nil
+	}
+
+	u.discovery.reload.Lock()
+	defer u.discovery.reload.Unlock()
+
+	// Build the new controller list with the new defaults, without touching ours.
+	n.Logger = u.Logger
+	_ = n.configureControllers() // errors are logged; the configured controllers are polled.
+
+	if err := n.checkPIIKeys(); err != nil {
+		return err
+	}
+
+	u.applyConfig(n)
+
+	return nil
+}
+
+// applyConfig swaps in the controllers, and the settings, of n; which has had its
+// controllers configured. Call with the reload lock held.
+func (u *InputUnifi) applyConfig(n *InputUnifi) {
+	u.Lock()
+	current := u.Controllers
+	u.Unlock()
+
+	controllers := make([]*Controller, len(n.Controllers))
+	kept := make(map[*Controller]bool)
+
+	for i, c := range n.Controllers {
+		c = n.setControllerDefaults(c)
+		controllers[i] = c
+		old := findLogin(current, c)
+
+		if old != nil && !kept[old] {
+			kept[old] = true
+
+			u.Lock()
+			c.Unifi = old.Unifi // keep the session.
+			u.Unlock()
+		} else if err := u.getUnifi(c); err != nil {
+			u.LogErrorf("Controller %s Auth or Connection Error, retrying: %v", c.URL, err)
+
+			continue
+		}
+
+		if c.Unifi != nil {
+			// Without a session, the old one had already expired; it re-authenticates on the next poll.
+			if err := u.checkSites(c); err != nil {
+				u.LogErrorf("checking sites on %s: %v", c.URL, err)
+			}
+		}
+
+		switch {
+		case old == nil:
+			u.Logf("Added UniFi Controller %s:", c.URL)
+			u.logController(c)
+		case sameController(old, c):
+			controllers[i] = old // unchanged; polls and streams carry on with it.
+			old.results.clear()  // but its sites may have changed.
+		}
+	}
+
+	u.Lock()
+	u.Controllers = controllers
+	u.Default = n.Default
+	u.Dynamic = n.Dynamic
+	u.Remote = n.Remote
+	u.RemoteAPIKey = n.RemoteAPIKey
+	u.MaxConcurrency = n.MaxConcurrency
+	u.Rediscover = n.Rediscover
+	u.InventoryMaxAge = n.InventoryMaxAge
+	u.configured = n.configured
+	u.Unlock()
+
+	for _, c := range current {
+		if kept[c] {
+			continue
+		}
+
+		u.Logf("Removing UniFi Controller: %s", c.URL)
+
+		if err := u.logout(c); err != nil {
+			u.LogErrorf("%v", err)
+		}
+	}
+
+	u.restreamEvents()
+	webserver.UpdateInput(&webserver.Input{Name: PluginName, Config: formatConfig(u.Config)})
+}
+
+// sameController returns true if c, configured again, is set up just like old, and
+// the same session would keep polling it the same way.
+func sameController(old, c *Controller) bool {
+	a, b := *old, *c
+	a.piiKeyErr, b.piiKeyErr = nil, nil // the key is compared; errors are not comparable.
+	a.results, b.results = nil, nil
+
+	return a.Unifi == b.Unifi && reflect.DeepEqual(a, b)
+}
+
+// findLogin returns the controller in list that logs in to the same URL with the same
+// credentials and connection settings as c, or nil if there is none.
+func findLogin(list []*Controller, c *Controller) *Controller {
+	for _, old := range list {
+		if old.URL == c.URL && old.ConsoleID == c.ConsoleID &&
+			old.User == c.User && old.Pass == c.Pass && old.APIKey == c.APIKey &&
+			old.Timeout == c.Timeout && *old.VerifySSL == *c.VerifySSL &&
+			slices.Equal(old.CertPaths, c.CertPaths) {
+			return old
+		}
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
 )
 
 const discoverMaxAttempts = 3
@@ -13,17 +14,18 @@ const discoverRetryDelay = 5 * time.Second
 
 // discoverRemoteControllers discovers all controllers via remote API and creates Controller entries.
 func (u *InputUnifi) discoverRemoteControllers(apiKey string) ([]*Controller, error) {
-	// Handle file:// prefix for API key
-	if strings.HasPrefix(apiKey, "file://") {
-		apiKey = u.getPassFromFile(strings.TrimPrefix(apiKey, "file://"))
+	// The discovered controllers keep the reference, and resolve it when they log in.
+	key, err := poller.ResolveSecret(apiKey)
+	if err != nil {
+		return nil, fmt.Errorf("remote API key: %w", err)
 	}
 
-	if apiKey == "" {
+	if key == "" {
 		return nil, fmt.Errorf("remote API key not provided")
 	}
 
 	// Use library client
-	client := unifi.NewRemoteAPIClient(apiKey, u.LogErrorf, u.LogDebugf, u.Logf)
+	client := unifi.NewRemoteAPIClient(key, u.LogErrorf, u.LogDebugf, u.Logf)
 
 	u.Logf("Discovering remote UniFi consoles...")
 
@@ -171,6 +173,22 @@ func (u *InputUnifi) discoverRemoteControllers(apiKey string) ([]*Controller, er
 			controller.ProtectThumbnails = &f
 		}
 
+		if controller.EventStream == nil {
+			controller.EventStream = &f // api.ui.com has no event stream.
+		}
+
+		if controller.SaveClientEvents == nil {
+			controller.SaveClientEvents = &f
+		}
+
+		if controller.SaveDeviceEvents == nil {
+			controller.SaveDeviceEvents = &f
+		}
+
+		if controller.ClientInventory == nil {
+			controller.ClientInventory = &f
+		}
+
 		// Extract site names
 		siteNames := make([]string, 0, len(sites))
 		for _, site := range sites {
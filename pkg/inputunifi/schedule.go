@@ -0,0 +1,222 @@
+package inputunifi
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unpoller/unifi/v5"
+	"golift.io/cnfg"
+)
+
+/* Collection schedules. A controller's intervals config sets how often a collection is
+   fetched; between fetches its last result is reused, so every poll still returns all
+   of the data. Collections without an interval are fetched on every poll. */
+
+// scheduleSlack is how early a collection may be fetched again. Polls do not land on
+// exactly the same tick, and a collection should not skip a poll over a few milliseconds.
+const scheduleSlack = time.Second
+
+// collections maps each controller API method to the collection whose interval applies
+// to it. Methods not listed, like events and sites, are never reused.
+var collections = map[string]string{ // nolint: gochecknoglobals
+	"GetClients":                          "clients",
+	"GetDevices":                          "devices",
+	"GetSiteDPI":                          "dpi",
+	"GetClientsDPI":                       "dpi",
+	"GetClientTraffic":                    "dpi",
+	"GetCountryTraffic":                   "country_traffic",
+	"GetRogueAPs":                         "rogue_aps",
+	"GetSpeedTests":                       "speedtests",
+	"GetActiveDHCPLeasesWithAssociations": "dhcp_leases",
+	"GetWANEnrichedConfiguration":         "wan",
+	"GetWANStatus":                        "wan",
+	"GetFirewallPolicies":                 "firewall",
+	"GetFirewallZones":                    "firewall",
+	"GetACLRules":                         "firewall",
+	"GetSysinfo":                          "sysinfo",
+	"GetTopology":                         "topology",
+	"GetPortAnomalies":                    "port_anomalies",
+	"GetMagicSiteToSiteVPN":               "vpn",
+	"GetVPNServers":                       "vpn",
+	"GetSiteToSiteTunnels":                "vpn",
+	"GetPortForwards":                     "port_forwards",
+	"GetSSLCertificate":                   "ssl_certificates",
+	"GetUPSDeviceList":                    "ups",
+	"GetAllIntegrationDeviceStats":        "integration_device_stats",
+	"GetWifiBroadcasts":                   "wifi_broadcasts",
+	"GetLAGs":                             "switching",
+	"GetMCLAGDomains":                     "switching",
+	"GetSwitchStacks":                     "switching",
+	"GetDNSPolicies":                      "dns_policies",
+	"GetRADIUSProfiles":                   "radius_profiles",
+	"GetTrafficMatchingLists":             "traffic_matching_lists",
+	"GetHotspotVouchers":                  "hotspot_vouchers",
+	"GetPendingDevices":                   "pending_devices",
+	"GetDPIApplications":                  "catalogues",
+	"GetDPICategories":                    "catalogues",
+	"GetCountries":                        "catalogues",
+}
+
+// resultCache holds the last result of every scheduled request to one controller. Each
+// controller has its own, so results are not shared by controllers with the same URL,
+// and a reload or rediscovery starts it again: the sites or settings may have changed.
+type resultCache struct {
+	sync.Mutex
+	byKey map[string]lastResult
+}
+
+// lastResult is a copy of what a request returned, and when it was sent.
+type lastResult struct {
+	at  time.Time
+	val any
+}
+
+// collectionNames returns the names that may be used in the intervals config.
+func collectionNames() []string {
+	return slices.Compact(slices.Sorted(maps.Values(collections)))
+}
+
+// interval returns how often the collection an API method belongs to is fetched.
+func (c *Controller) interval(endpoint string) time.Duration {
+	collection, ok := collections[endpoint]
+	if !ok {
+		return 0
+	}
+
+	return c.Intervals[collection].Duration
+}
+
+// scheduled returns a copy of the last result of a request if its collection's interval
+// has not passed, and otherwise sends it and saves a copy of what it returns. key tells
+// apart the calls to one method, usually the site.
+func scheduled[T any](c *Controller, endpoint, key string, fetch func() (T, error)) (T, error) {
+	interval := c.interval(endpoint)
+	if interval <= 0 || c.results == nil {
+		return fetch()
+	}
+
+	key = endpoint + "/" + key
+
+	c.results.Lock()
+	last, ok := c.results.byKey[key]
+	c.results.Unlock()
+
+	if v, isT := last.val.(T); ok && isT && time.Since(last.at)+scheduleSlack < interval {
+		return cloneResult(v), nil
+	}
+
+	start := time.Now()
+
+	v, err := fetch()
+	if err != nil {
+		return v, err
+	}
+
+	c.results.Lock()
+	defer c.results.Unlock()
+
+	if c.results.byKey == nil {
+		c.results.byKey = make(map[string]lastResult)
+	}
+
+	c.results.byKey[key] = lastResult{at: start, val: cloneResult(v)}
+
+	return v, nil
+}
+
+// clear forgets every saved result, so each collection is fetched on the next poll.
+func (r *resultCache) clear() {
+	if r == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.byKey = nil
+}
+
+// argKey returns the part of a request's key that comes from its first argument. The
+// other arguments are time windows and such, which do not change what is collected.
+func argKey(arg any) string {
+	switch v := arg.(type) {
+	case *unifi.Site:
+		return v.Name
+	case []*unifi.Site:
+		names := make([]string, len(v))
+		for i, site := range v {
+			names[i] = site.Name
+		}
+
+		return strings.Join(names, ",")
+	case *unifi.IntegrationSite:
+		return v.InternalReference
+	default:
+		return fmt.Sprint(arg)
+	}
+}
+
+// cloneResult copies the structs in a result, so the copy can be redacted, renamed and
+// relabeled without changing the saved one. Maps and strings are shared; they are
+// replaced, not changed, on the way to the outputs.
+func cloneResult[T any](v T) T {
+	cloned, _ := cloneValue(reflect.ValueOf(&v).Elem()).Interface().(T)
+
+	return cloned
+}
+
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() { // nolint: exhaustive
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		clone := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			clone.Index(i).Set(cloneValue(v.Index(i)))
+		}
+
+		return clone
+	case reflect.Pointer:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return v
+		}
+
+		clone := reflect.New(v.Elem().Type())
+		clone.Elem().Set(cloneValue(v.Elem()))
+
+		return clone
+	case reflect.Struct:
+		clone := reflect.New(v.Type()).Elem()
+		clone.Set(v)
+
+		for i := range clone.NumField() {
+			if field := clone.Field(i); field.CanSet() {
+				field.Set(cloneValue(field))
+			}
+		}
+
+		return clone
+	default:
+		return v
+	}
+}
+
+// mergeIntervals returns the intervals with those from the defaults added, for the
+// collections the controller does not set itself.
+func mergeIntervals(intervals, defaults map[string]cnfg.Duration) map[string]cnfg.Duration {
+	if len(defaults) == 0 {
+		return intervals
+	}
+
+	merged := maps.Clone(defaults)
+	maps.Copy(merged, intervals)
+
+	return merged
+}
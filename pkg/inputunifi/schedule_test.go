@@ -0,0 +1,46 @@
+//nolint:testpackage // white-box: exercises the unexported result cache.
+package inputunifi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golift.io/cnfg"
+)
+
+func TestScheduledPerController(t *testing.T) {
+	t.Parallel()
+
+	newController := func() *Controller {
+		return &Controller{
+			URL:       "https://unifi",
+			Intervals: map[string]cnfg.Duration{"dpi": {Duration: time.Hour}},
+			results:   &resultCache{},
+		}
+	}
+
+	fetches := 0
+	fetch := func() ([]int, error) { fetches++; return []int{fetches}, nil }
+
+	a, b := newController(), newController()
+
+	got, err := scheduled(a, "GetSiteDPI", "default", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, got)
+
+	got, _ = scheduled(a, "GetSiteDPI", "default", fetch)
+	assert.Equal(t, []int{1}, got, "the last result is reused within the interval")
+
+	got, _ = scheduled(b, "GetSiteDPI", "default", fetch)
+	assert.Equal(t, []int{2}, got, "a controller with the same URL has its own results")
+
+	got, _ = scheduled(a, "GetClients", "default", fetch)
+	assert.Equal(t, []int{3}, got, "collections without an interval are fetched every time")
+
+	a.results.clear()
+
+	got, _ = scheduled(a, "GetSiteDPI", "default", fetch)
+	assert.Equal(t, []int{4}, got, "a reload or rediscovery clears the results")
+}
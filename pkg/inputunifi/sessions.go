@@ -0,0 +1,164 @@
+package inputunifi
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/webserver"
+)
+
+/* Client session events. With save_client_events, each controller's client list is
+   compared to the one from its previous poll, and the differences become events. */
+
+// sessionKey identifies a client on a controller.
+type sessionKey struct {
+	site, mac string
+}
+
+// clientSessions holds each controller's client list from its last poll.
+// Keys are controller URLs.
+type clientSessions struct {
+	sync.Mutex
+	last  map[string]map[sessionKey]*unifi.Client
+	since map[string]map[sessionKey]time.Time // when each client was first seen.
+}
+
+// diffClients compares a controller's clients to those from its previous poll, and
+// holds the connects, disconnects, roams and IP changes for the next events collection.
+// The clients have had PII redacted, so with drop_pii there are no MACs to compare
+// and no events. The first poll only records the clients.
+func (u *InputUnifi) diffClients(c *Controller, clients []any) {
+	if c.SaveClientEvents == nil || !*c.SaveClientEvents {
+		return
+	}
+
+	now := time.Now()
+	current := make(map[sessionKey]*unifi.Client, len(clients))
+
+	for _, v := range clients {
+		if client, ok := v.(*unifi.Client); ok && client.Mac != "" {
+			current[sessionKey{site: client.SiteName, mac: client.Mac}] = client
+		}
+	}
+
+	s := &u.sessions
+	s.Lock()
+	defer s.Unlock()
+
+	if s.last == nil {
+		s.last = make(map[string]map[sessionKey]*unifi.Client)
+		s.since = make(map[string]map[sessionKey]time.Time)
+	}
+
+	last, polled := s.last[c.URL]
+	since := make(map[sessionKey]time.Time, len(current))
+	events := []*poller.ClientEvent{}
+
+	for key, client := range current {
+		if since[key] = s.since[c.URL][key]; since[key].IsZero() {
+			since[key] = now.Add(-time.Duration(client.Uptime.Int64()) * time.Second)
+		}
+
+		if !polled {
+			continue
+		}
+
+		events = append(events, sessionChanges(c, last[key], client, now)...)
+	}
+
+	for key, client := range last {
+		if _, ok := current[key]; !ok && polled {
+			e := clientEvent(c, poller.ClientDisconnected, client, now)
+			e.Duration = int64(now.Sub(s.since[c.URL][key]).Seconds())
+			events = append(events, e)
+		}
+	}
+
+	s.last[c.URL], s.since[c.URL] = current, since
+
+	slices.SortFunc(events, func(a, b *poller.ClientEvent) int {
+		return cmp.Or(cmp.Compare(a.SiteName, b.SiteName), cmp.Compare(a.Mac, b.Mac), cmp.Compare(a.Type, b.Type))
+	})
+
+	held := make([]any, len(events))
+
+	for i, e := range events {
+		held[i] = e
+
+		webserver.NewInputEvent(PluginName, e.SiteName+"_client_events", &webserver.Event{
+			Msg: e.Msg(), Ts: e.Time, Tags: map[string]string{
+				"type": e.Type, "mac": e.Mac, "site_name": e.SiteName, "source": e.SourceName,
+			},
+		})
+	}
+
+	u.held.hold(c.URL, held)
+}
+
+// sessionChanges returns the events between two sightings of a client. prev is nil
+// for a client that was not connected on the previous poll.
+func sessionChanges(c *Controller, prev, client *unifi.Client, now time.Time) []*poller.ClientEvent {
+	if prev == nil {
+		return []*poller.ClientEvent{clientEvent(c, poller.ClientConnected, client, now)}
+	}
+
+	events := []*poller.ClientEvent{}
+
+	// A client that was wired, or on no access point, did not roam from one.
+	roamed := !prev.IsWired.Val && prev.ApMac != "" && (prev.ApMac != client.ApMac || prev.Radio != client.Radio)
+	if !client.IsWired.Val && roamed {
+		e := clientEvent(c, poller.ClientRoamed, client, now)
+		e.FromApName, e.FromBand = prev.ApName, radioBand(prev.Radio)
+		events = append(events, e)
+	}
+
+	if prev.IP != client.IP && prev.IP != "" && client.IP != "" {
+		e := clientEvent(c, poller.ClientIPChanged, client, now)
+		e.FromIP = prev.IP
+		events = append(events, e)
+	}
+
+	return events
+}
+
+func clientEvent(c *Controller, kind string, client *unifi.Client, now time.Time) *poller.ClientEvent {
+	e := &poller.ClientEvent{
+		Time:       now,
+		Type:       kind,
+		SourceName: client.SourceName,
+		SiteName:   client.SiteName,
+		Mac:        client.Mac,
+		Name:       client.Name,
+		Hostname:   client.Hostname,
+		IP:         client.IP,
+		Wired:      client.IsWired.Val,
+	}
+
+	if e.SourceName == "" {
+		e.SourceName = c.URL
+	}
+
+	if !e.Wired {
+		e.SSID, e.ApName, e.Band = client.Essid, client.ApName, radioBand(client.Radio)
+	}
+
+	return e
+}
+
+// radioBand maps a UniFi radio, ng, na or 6e, to its band in GHz.
+func radioBand(radio string) string {
+	switch radio {
+	case "ng":
+		return "2.4"
+	case "na":
+		return "5"
+	case "6e":
+		return "6"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,168 @@
+//nolint:testpackage // white-box: exercises the unexported client list comparison.
+package inputunifi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+func testClient(change func(*unifi.Client)) *unifi.Client {
+	client := &unifi.Client{
+		SiteName: "default", Mac: "aa:bb:cc:dd:ee:ff", Name: "laptop", Hostname: "laptop.lan",
+		IP: "10.0.0.2", ApMac: "11:11:11:11:11:11", ApName: "office", Radio: "na", Essid: "home",
+	}
+
+	if change != nil {
+		change(client)
+	}
+
+	return client
+}
+
+func TestSessionChanges(t *testing.T) {
+	t.Parallel()
+
+	c := &Controller{URL: "https://unifi"}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	event := func(kind string, change func(*poller.ClientEvent)) *poller.ClientEvent {
+		e := &poller.ClientEvent{
+			Time: now, Type: kind, SourceName: c.URL, SiteName: "default", Mac: "aa:bb:cc:dd:ee:ff",
+			Name: "laptop", Hostname: "laptop.lan", IP: "10.0.0.2", SSID: "home", ApName: "office", Band: "5",
+		}
+
+		if change != nil {
+			change(e)
+		}
+
+		return e
+	}
+
+	tests := []struct {
+		name   string
+		prev   *unifi.Client
+		client *unifi.Client
+		want   []*poller.ClientEvent
+	}{
+		{
+			name:   "connected",
+			client: testClient(nil),
+			want:   []*poller.ClientEvent{event(poller.ClientConnected, nil)},
+		},
+		{
+			name:   "unchanged",
+			prev:   testClient(nil),
+			client: testClient(nil),
+			want:   []*poller.ClientEvent{},
+		},
+		{
+			name:   "roamed to another access point",
+			prev:   testClient(func(c *unifi.Client) { c.ApMac, c.ApName, c.Radio = "22:22:22:22:22:22", "hall", "ng" }),
+			client: testClient(nil),
+			want: []*poller.ClientEvent{event(poller.ClientRoamed, func(e *poller.ClientEvent) {
+				e.FromApName, e.FromBand = "hall", "2.4"
+			})},
+		},
+		{
+			name:   "roamed to another band",
+			prev:   testClient(func(c *unifi.Client) { c.Radio = "6e" }),
+			client: testClient(nil),
+			want: []*poller.ClientEvent{event(poller.ClientRoamed, func(e *poller.ClientEvent) {
+				e.FromApName, e.FromBand = "office", "6"
+			})},
+		},
+		{
+			name:   "roamed with a new address",
+			prev:   testClient(func(c *unifi.Client) { c.ApMac, c.ApName, c.IP = "22:22:22:22:22:22", "hall", "10.0.0.9" }),
+			client: testClient(nil),
+			want: []*poller.ClientEvent{
+				event(poller.ClientRoamed, func(e *poller.ClientEvent) { e.FromApName, e.FromBand = "hall", "5" }),
+				event(poller.ClientIPChanged, func(e *poller.ClientEvent) { e.FromIP = "10.0.0.9" }),
+			},
+		},
+		{
+			name:   "address lost",
+			prev:   testClient(nil),
+			client: testClient(func(c *unifi.Client) { c.IP = "" }),
+			want:   []*poller.ClientEvent{},
+		},
+		{
+			name:   "wired client on another switch",
+			prev:   testClient(func(c *unifi.Client) { c.IsWired.Val, c.ApMac = true, "" }),
+			client: testClient(func(c *unifi.Client) { c.IsWired.Val, c.ApMac = true, "33:33:33:33:33:33" }),
+			want:   []*poller.ClientEvent{},
+		},
+		{
+			name:   "wired client now wireless",
+			prev:   testClient(func(c *unifi.Client) { c.IsWired.Val, c.ApMac, c.ApName, c.Radio = true, "", "", "" }),
+			client: testClient(nil),
+			want:   []*poller.ClientEvent{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.want, sessionChanges(c, test.prev, test.client, now))
+		})
+	}
+}
+
+func TestDiffClientsDisconnected(t *testing.T) {
+	t.Parallel()
+
+	on := true
+	u := &InputUnifi{}
+	c := &Controller{URL: "https://unifi", SaveClientEvents: &on}
+	client := testClient(func(c *unifi.Client) { c.Uptime = unifi.FlexInt{Val: 600, Txt: "600"} })
+
+	u.diffClients(c, []any{client})
+	assert.Empty(t, u.held.take(c.URL), "the first poll only records the clients")
+
+	u.diffClients(c, []any{client})
+	assert.Empty(t, u.held.take(c.URL), "nothing changed")
+
+	u.diffClients(c, []any{})
+
+	held := u.held.take(c.URL)
+	require.Len(t, held, 1)
+
+	e, ok := held[0].(*poller.ClientEvent)
+	require.True(t, ok)
+	assert.Equal(t, poller.ClientDisconnected, e.Type)
+	assert.Equal(t, client.Mac, e.Mac)
+	assert.Equal(t, int64(600), e.Duration, "the session started when the client's uptime began")
+}
+
+func TestThrottledPollKeepsClientsAndDevices(t *testing.T) {
+	t.Parallel()
+
+	on := true
+	u := &InputUnifi{Config: &Config{}}
+	c := &Controller{URL: "https://unifi", SaveClientEvents: &on, SaveDeviceEvents: &on}
+	poll := func(throttled bool) {
+		m := &Metrics{Devices: &unifi.Devices{UAPs: []*unifi.UAP{{SiteName: "default", Mac: "11:11:11:11:11:11", Name: "office"}}}}
+		m.Clients = []*unifi.Client{testClient(nil)}
+
+		if throttled {
+			m = &Metrics{Devices: &unifi.Devices{}}
+			m.skipped("GetClients")
+			m.skipped("GetDevices")
+		}
+
+		u.augmentMetrics(c, m)
+	}
+
+	poll(false)
+	u.held.take(c.URL)
+
+	poll(true)
+	assert.Empty(t, u.held.take(c.URL), "a throttled poll does not disconnect every client and device")
+
+	poll(false)
+	assert.Empty(t, u.held.take(c.URL), "nor reconnect them on the next poll")
+}
@@ -0,0 +1,158 @@
+package inputunifi
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+/* Site overrides. A controller's site_overrides block changes its collection and PII
+   settings for single sites, and adds static labels to everything from those sites.
+   Keys are a site's name (like "default") or its description. Settings an override
+   leaves out are the controller's. Client and device events, Protect logs and the
+   client inventory are not per-site, and always use the controller's settings. */
+
+// SiteOverride holds the settings that replace a controller's for one site.
+type SiteOverride struct {
+	SaveAnomal    *bool             `json:"save_anomalies" toml:"save_anomalies" xml:"save_anomalies" yaml:"save_anomalies"`
+	SaveAlarms    *bool             `json:"save_alarms"    toml:"save_alarms"    xml:"save_alarms"    yaml:"save_alarms"`
+	SaveEvents    *bool             `json:"save_events"    toml:"save_events"    xml:"save_events"    yaml:"save_events"`
+	SaveSyslog    *bool             `json:"save_syslog"    toml:"save_syslog"    xml:"save_syslog"    yaml:"save_syslog"`
+	SaveIDs       *bool             `json:"save_ids"       toml:"save_ids"       xml:"save_ids"       yaml:"save_ids"`
+	SaveDPI       *bool             `json:"save_dpi"       toml:"save_dpi"       xml:"save_dpi"       yaml:"save_dpi"`
+	SaveTraffic   *bool             `json:"save_traffic"   toml:"save_traffic"   xml:"save_traffic"   yaml:"save_traffic"`
+	SaveRogue     *bool             `json:"save_rogue"     toml:"save_rogue"     xml:"save_rogue"     yaml:"save_rogue"`
+	SaveSpeedTest *bool             `json:"save_speedtest" toml:"save_speedtest" xml:"save_speedtest" yaml:"save_speedtest"`
+	SaveSites     *bool             `json:"save_sites"     toml:"save_sites"     xml:"save_sites"     yaml:"save_sites"`
+	HashPII       *bool             `json:"hash_pii"       toml:"hash_pii"       xml:"hash_pii"       yaml:"hash_pii"`
+	DropPII       *bool             `json:"drop_pii"       toml:"drop_pii"       xml:"drop_pii"       yaml:"drop_pii"`
+	Labels        map[string]string `json:"labels"         toml:"labels"         xml:"labels"         yaml:"labels"`
+}
+
+// siteOverride returns the override for the first of a site's names that has one.
+func (c *Controller) siteOverride(names ...string) *SiteOverride {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+
+		for key, override := range c.SiteOverrides {
+			if strings.EqualFold(key, name) {
+				return override
+			}
+		}
+	}
+
+	return nil
+}
+
+// forSite returns the controller with a site's overrides applied. The controller
+// itself is returned for sites without one; it is never changed.
+func (c *Controller) forSite(names ...string) *Controller {
+	override := c.siteOverride(names...)
+	if override == nil {
+		return c
+	}
+
+	site := *c
+
+	for _, flag := range []struct {
+		dst **bool
+		src *bool
+	}{
+		{&site.SaveAnomal, override.SaveAnomal},
+		{&site.SaveAlarms, override.SaveAlarms},
+		{&site.SaveEvents, override.SaveEvents},
+		{&site.SaveSyslog, override.SaveSyslog},
+		{&site.SaveIDs, override.SaveIDs},
+		{&site.SaveDPI, override.SaveDPI},
+		{&site.SaveTraffic, override.SaveTraffic},
+		{&site.SaveRogue, override.SaveRogue},
+		{&site.SaveSpeedTest, override.SaveSpeedTest},
+		{&site.SaveSites, override.SaveSites},
+		{&site.HashPII, override.HashPII},
+		{&site.DropPII, override.DropPII},
+	} {
+		if flag.src != nil {
+			*flag.dst = flag.src
+		}
+	}
+
+	return &site
+}
+
+// sitesWith returns the sites that have a setting turned on, after their overrides.
+func (c *Controller) sitesWith(sites []*unifi.Site, setting func(*Controller) *bool) []*unifi.Site {
+	on := make([]*unifi.Site, 0, len(sites))
+
+	for _, site := range sites {
+		if flag := setting(c.forSite(siteNames(site)...)); flag != nil && *flag {
+			on = append(on, site)
+		}
+	}
+
+	return on
+}
+
+// siteLabels returns the static labels from the site overrides, scoped to each name
+// the sites' data may carry. Call it before the default site name override is applied.
+func (c *Controller) siteLabels(sites []*unifi.Site) poller.Labels {
+	labels := poller.Labels{}
+
+	for _, site := range sites {
+		override := c.siteOverride(siteNames(site)...)
+		if override == nil || len(override.Labels) == 0 {
+			continue
+		}
+
+		names := siteNames(site)
+		if c.DefaultSiteNameOverride != "" && isDefaultSiteName(site.SiteName) {
+			names = append(names, c.DefaultSiteNameOverride)
+		}
+
+		for _, name := range names {
+			if name != "" {
+				labels.Merge(poller.Labels{{Source: c.URL, Site: name}: override.Labels})
+			}
+		}
+	}
+
+	return labels
+}
+
+// siteNames returns the names a site override may be keyed by, and that the site's
+// data may carry as its site name.
+func siteNames(site *unifi.Site) []string {
+	return []string{site.Name, site.Desc, site.SiteName}
+}
+
+// objectSiteNames returns the names in the site name of a client or event, which
+// is usually the site's description with its name in parentheses.
+func objectSiteNames(siteName string) []string {
+	names := []string{siteName}
+
+	if i := strings.LastIndex(siteName, " ("); i > 0 && strings.HasSuffix(siteName, ")") {
+		names = append(names, siteName[i+2:len(siteName)-1], siteName[:i])
+	}
+
+	return names
+}
+
+// unknownOverrides returns the site override keys that match none of the sites.
+func (c *Controller) unknownOverrides(sites []*unifi.Site) []string {
+	unknown := []string{}
+
+	for key := range c.SiteOverrides {
+		if !slices.ContainsFunc(sites, func(site *unifi.Site) bool {
+			return slices.ContainsFunc(siteNames(site), func(name string) bool { return strings.EqualFold(key, name) })
+		}) {
+			unknown = append(unknown, key)
+		}
+	}
+
+	slices.Sort(unknown)
+
+	return unknown
+}
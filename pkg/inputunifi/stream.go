@@ -0,0 +1,395 @@
+package inputunifi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/webserver"
+	"golang.org/x/net/websocket"
+)
+
+/* Event streaming. Controllers with event_stream enabled push events over a websocket.
+   The streams follow the controllers: after a reload or rediscovery, and every
+   streamResync, streams are opened for new controllers and sites, and closed for those
+   that are gone. Each connection, and each message, uses the current controller, so
+   new credentials and settings apply without a restart. */
+
+// ErrStreamController is returned by a stream whose controller is no longer polled.
+var ErrStreamController = errors.New("controller is no longer polled")
+
+const (
+	streamMinBackoff = time.Second
+	streamMaxBackoff = time.Minute
+	// streamResync is how often the site lists are checked for streams to open or close.
+	streamResync = 5 * time.Minute
+)
+
+// streamPaths are where a site's event stream lives: first on UniFi OS consoles, then
+// on standalone Network controllers. %s is the site name.
+var streamPaths = []string{"/proxy/network/wss/s/%s/events", "/wss/s/%s/events"} // nolint: gochecknoglobals
+
+// streamKey identifies one site's event stream.
+type streamKey struct {
+	url, site string
+}
+
+// streamMessage is one message from an event stream.
+type streamMessage struct {
+	Meta struct {
+		RC      string `json:"rc"`
+		Message string `json:"message"`
+	} `json:"meta"`
+	Data json.RawMessage `json:"data"`
+}
+
+// StreamEvents keeps an event stream open to every site on the controllers with
+// event_stream enabled, and pushes their events and alarms as they arrive. While a
+// site's stream is connected, polling skips its events and alarms; when the stream
+// drops, polling collects them until it reconnects. Satisfies poller.EventStreamer.
+func (u *InputUnifi) StreamEvents(ctx context.Context, push func(*poller.Events)) {
+	var wg sync.WaitGroup
+
+	running := make(map[streamKey]context.CancelFunc)
+
+	defer func() {
+		for _, cancel := range running {
+			cancel()
+		}
+
+		wg.Wait()
+	}()
+
+	backoff := streamMinBackoff
+
+	for {
+		want, ok := u.streamTargets(ctx, running)
+
+		for key, cancel := range running {
+			if _, keep := want[key]; !keep {
+				u.LogDebugf("[%s] Closing the event stream for site %s", key.url, key.site)
+				cancel()
+				delete(running, key)
+			}
+		}
+
+		for key, site := range want {
+			if running[key] != nil {
+				continue
+			}
+
+			streamCtx, cancel := context.WithCancel(ctx)
+			running[key] = cancel
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				u.streamSite(streamCtx, key.url, site, push)
+			}()
+		}
+
+		wait := streamResync
+		if ok {
+			backoff = streamMinBackoff
+		} else {
+			// A controller was not ready; try it again soon.
+			wait, backoff = backoff, min(backoff*2, streamMaxBackoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-u.restream:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// restreamEvents makes StreamEvents check the controllers and sites for streams to
+// open or close. Called after the controllers change.
+func (u *InputUnifi) restreamEvents() {
+	select {
+	case u.restream <- struct{}{}:
+	default: // one is already waiting, or nothing streams.
+	}
+}
+
+// streamTargets returns the sites that should have an event stream, on the current
+// controllers. A controller whose sites cannot be listed keeps its running streams,
+// and ok is false so it is tried again soon.
+func (u *InputUnifi) streamTargets(ctx context.Context, running map[streamKey]context.CancelFunc) (map[streamKey]*unifi.Site, bool) {
+	u.Lock()
+	controllers := u.Controllers
+	u.Unlock()
+
+	want := make(map[streamKey]*unifi.Site)
+	ok := true
+
+	for _, c := range controllers {
+		if c.EventStream == nil || !*c.EventStream || c.Remote {
+			continue
+		}
+
+		sites, err := u.streamSites(ctx, c)
+		if err != nil {
+			u.LogDebugf("[%s] Event stream waiting for the controller: %v", c.URL, err)
+
+			for key := range running {
+				if key.url == c.URL {
+					want[key] = &unifi.Site{Name: key.site}
+				}
+			}
+
+			ok = false
+
+			continue
+		}
+
+		for _, site := range sites {
+			want[streamKey{url: c.URL, site: site.Name}] = site
+		}
+	}
+
+	return want, ok
+}
+
+// streaming returns true if a site's event stream is connected.
+func (u *InputUnifi) streaming(c *Controller, site *unifi.Site) bool {
+	_, ok := u.streams.Load(streamKey{url: c.URL, site: site.Name})
+
+	return ok
+}
+
+// currentController returns the controller polled at a URL now. A reload or
+// rediscovery may have replaced the one a stream was opened with.
+func (u *InputUnifi) currentController(url string) *Controller {
+	u.Lock()
+	defer u.Unlock()
+
+	for _, c := range u.Controllers {
+		if c.URL == url {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (u *InputUnifi) streamSites(ctx context.Context, c *Controller) ([]*unifi.Site, error) {
+	u.RLock()
+	ready := c.Unifi != nil
+	u.RUnlock()
+
+	if !ready {
+		return nil, unifi.ErrNilUnifi
+	}
+
+	return u.getFilteredSites(ctx, c)
+}
+
+// streamSite keeps one site's stream open, reconnecting with backoff until ctx is canceled.
+func (u *InputUnifi) streamSite(ctx context.Context, url string, site *unifi.Site, push func(*poller.Events)) {
+	backoff := streamMinBackoff
+
+	for {
+		start := time.Now()
+		err := u.readStream(ctx, url, site, push)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(start) > streamMaxBackoff {
+			backoff = streamMinBackoff // it was up for a while; reconnect quickly.
+		}
+
+		u.Logf("[%s] Event stream for site %s closed, polling until it reconnects in %v: %v",
+			url, site.Name, backoff, err)
+
+		if !sleepContext(ctx, backoff) {
+			return
+		}
+
+		backoff = min(backoff*2, streamMaxBackoff)
+	}
+}
+
+// readStream connects to a site's stream and pushes what it reads until it fails.
+func (u *InputUnifi) readStream(ctx context.Context, url string, site *unifi.Site, push func(*poller.Events)) error {
+	c := u.currentController(url)
+	if c == nil {
+		return ErrStreamController
+	}
+
+	conn, err := u.dialStream(ctx, c, site)
+	if err != nil {
+		return err
+	}
+
+	key := streamKey{url: c.URL, site: site.Name}
+	u.streams.Store(key, struct{}{})
+	u.LogDebugf("[%s] Event stream connected for site %s", c.URL, site.Name)
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	defer func() {
+		stop()
+		conn.Close()
+		u.streams.Delete(key)
+	}()
+
+	for {
+		var msg streamMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			return fmt.Errorf("reading event stream: %w", err)
+		}
+
+		if c = u.currentController(url); c == nil {
+			return ErrStreamController
+		}
+
+		logs, err := u.streamLogs(c, site, &msg)
+		if err != nil {
+			u.LogDebugf("[%s] Event stream for site %s: %v", c.URL, site.Name, err)
+			continue
+		}
+
+		if logs, cursors := newEvents(c, logs, &poller.Filter{}); len(logs) > 0 {
+			push(&poller.Events{Logs: logs, Labels: c.siteLabels([]*unifi.Site{site}), Cursors: cursors})
+		}
+	}
+}
+
+// dialStream opens a site's stream with the controller session's cookies or API key.
+func (u *InputUnifi) dialStream(ctx context.Context, c *Controller, site *unifi.Site) (*websocket.Conn, error) {
+	base, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing controller url: %w", err)
+	}
+
+	header := http.Header{}
+
+	u.RLock()
+	if c.Unifi != nil && c.Unifi.Jar != nil {
+		for _, cookie := range c.Unifi.Jar.Cookies(base) {
+			header.Add("Cookie", cookie.String())
+		}
+	}
+	u.RUnlock()
+
+	if c.APIKey != "" {
+		apiKey, err := poller.ResolveSecret(c.APIKey)
+		if err != nil {
+			return nil, fmt.Errorf("event stream api_key: %w", err)
+		}
+
+		header.Set("X-API-Key", apiKey)
+	}
+
+	scheme := "wss"
+	if base.Scheme == "http" {
+		scheme = "ws"
+	}
+
+	origin := base.Scheme + "://" + base.Host
+	errs := []error{}
+
+	for _, path := range streamPaths {
+		config, err := websocket.NewConfig(scheme+"://"+base.Host+
+			strings.TrimRight(base.Path, "/")+fmt.Sprintf(path, url.PathEscape(site.Name)), origin)
+		if err != nil {
+			return nil, fmt.Errorf("event stream url: %w", err)
+		}
+
+		config.Header = header
+		config.TlsConfig = &tls.Config{InsecureSkipVerify: !*c.VerifySSL} // nolint: gosec
+
+		conn, err := config.DialContext(ctx)
+		if err == nil {
+			return conn, nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	return nil, fmt.Errorf("connecting event stream: %w", errors.Join(errs...))
+}
+
+// streamLogs decodes the events and alarms in a stream message. Other messages,
+// like client and device syncs, are ignored.
+func (u *InputUnifi) streamLogs(c *Controller, site *unifi.Site, msg *streamMessage) ([]any, error) {
+	logs := []any{}
+	c = c.forSite(siteNames(site)...)
+
+	switch msg.Meta.Message {
+	case "events":
+		if !*c.SaveEvents {
+			return logs, nil
+		}
+
+		var events []*unifi.Event
+		if err := json.Unmarshal(msg.Data, &events); err != nil {
+			return nil, fmt.Errorf("decoding events: %w", err)
+		}
+
+		for _, e := range events {
+			e.SourceName, e.SiteName = c.URL, site.SiteName
+			e = redactEvent(e, c.redactor())
+			logs = append(logs, e)
+
+			webserver.NewInputEvent(PluginName, site.ID+"_events", &webserver.Event{
+				Msg: e.Msg, Ts: e.Datetime, Tags: map[string]string{
+					"type": "event", "key": e.Key, "site_id": e.SiteID,
+					"site_name": e.SiteName, "source": e.SourceName,
+				},
+			})
+		}
+	case "alarm", "alarms":
+		if !*c.SaveAlarms {
+			return logs, nil
+		}
+
+		var alarms []*unifi.Alarm
+		if err := json.Unmarshal(msg.Data, &alarms); err != nil {
+			return nil, fmt.Errorf("decoding alarms: %w", err)
+		}
+
+		for _, e := range alarms {
+			e.SourceName, e.SiteName = c.URL, site.SiteName
+			e = redactAlarm(e, c.redactor())
+			logs = append(logs, e)
+
+			webserver.NewInputEvent(PluginName, site.ID+"_alarms", &webserver.Event{
+				Ts: e.Datetime, Msg: e.Msg, Tags: map[string]string{
+					"type": "alarm", "key": e.Key, "site_id": e.SiteID,
+					"site_name": e.SiteName, "source": e.SourceName,
+				},
+			})
+		}
+	}
+
+	return logs, nil
+}
+
+// sleepContext waits for d, and returns false if ctx is canceled first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,50 @@
+package inputunifi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+/* These wrap calls to the controller API, to reuse the last result while the collection's
+   interval has not passed, to hold them to the controller's rate limit, and to record how
+   long each took and whether it failed in the poller's telemetry. The endpoint is the name
+   of the unifi method. */
+
+func timed0[T any](ctx context.Context, c *Controller, endpoint string, method func() (T, error)) (T, error) {
+	return timedKey(ctx, c, endpoint, "", method)
+}
+
+func timed[A, T any](ctx context.Context, c *Controller, endpoint string, method func(A) (T, error), a A) (T, error) {
+	return timedKey(ctx, c, endpoint, argKey(a), func() (T, error) { return method(a) })
+}
+
+func timed2[A, B, T any](ctx context.Context, c *Controller, endpoint string, method func(A, B) (T, error), a A, b B) (T, error) {
+	return timedKey(ctx, c, endpoint, argKey(a), func() (T, error) { return method(a, b) })
+}
+
+func timed3[A, B, C, T any](ctx context.Context, c *Controller, endpoint string, method func(A, B, C) (T, error), a A, b B, cc C) (T, error) {
+	return timedKey(ctx, c, endpoint, argKey(a), func() (T, error) { return method(a, b, cc) })
+}
+
+func timedKey[T any](ctx context.Context, c *Controller, endpoint, key string, method func() (T, error)) (T, error) {
+	return scheduled(c, endpoint, key, func() (T, error) {
+		l := limiter(c)
+		if err := l.wait(ctx, endpoint); err != nil {
+			var zero T
+			return zero, err
+		}
+
+		start := time.Now()
+		v, err := method()
+		poller.RecordRequest(PluginName, c.URL, endpoint, time.Since(start), err)
+
+		if l.backOff(err, endpoint) > 0 {
+			return v, fmt.Errorf("%w: %w", ErrThrottled, err)
+		}
+
+		return v, err
+	})
+}
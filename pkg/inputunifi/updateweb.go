@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/poller"
 	"github.com/unpoller/unpoller/pkg/webserver"
 )
 
@@ -53,10 +54,15 @@ func updateWeb(c *Controller, metrics *Metrics) {
 
 func formatConfig(config *Config) *Config {
 	return &Config{
-		Default:     *formatControllers([]*Controller{&config.Default})[0],
-		Disable:     config.Disable,
-		Dynamic:     config.Dynamic,
-		Controllers: formatControllers(config.Controllers),
+		Default:         *formatControllers([]*Controller{&config.Default})[0],
+		Disable:         config.Disable,
+		Dynamic:         config.Dynamic,
+		Remote:          config.Remote,
+		RemoteAPIKey:    strconv.FormatBool(config.RemoteAPIKey != ""),
+		MaxConcurrency:  config.MaxConcurrency,
+		Rediscover:      config.Rediscover,
+		InventoryMaxAge: config.InventoryMaxAge,
+		Controllers:     formatControllers(config.Controllers),
 	}
 }
 
@@ -70,26 +76,45 @@ func formatControllers(controllers []*Controller) []*Controller {
 		}
 
 		fixed = append(fixed, &Controller{
-			VerifySSL:       c.VerifySSL,
-			SaveAnomal:      c.SaveAnomal,
-			SaveAlarms:      c.SaveAlarms,
-			SaveRogue:       c.SaveRogue,
-			SaveSpeedTest:   c.SaveSpeedTest,
-			SaveEvents:      c.SaveEvents,
-			SaveSyslog:      c.SaveSyslog,
-			SaveProtectLogs: c.SaveProtectLogs,
-			SaveIDs:         c.SaveIDs,
-			SaveDPI:         c.SaveDPI,
-			HashPII:         c.HashPII,
-			DropPII:         c.DropPII,
-			SaveSites:       c.SaveSites,
-			SaveTraffic:     c.SaveTraffic,
-			User:            c.User,
-			Pass:            strconv.FormatBool(c.Pass != ""),
-			APIKey:          strconv.FormatBool(c.APIKey != ""),
-			URL:             c.URL,
-			Sites:           c.Sites,
-			ID:              id,
+			VerifySSL:               c.VerifySSL,
+			SaveAnomal:              c.SaveAnomal,
+			SaveAlarms:              c.SaveAlarms,
+			SaveRogue:               c.SaveRogue,
+			SaveSpeedTest:           c.SaveSpeedTest,
+			SaveEvents:              c.SaveEvents,
+			SaveSyslog:              c.SaveSyslog,
+			SaveProtectLogs:         c.SaveProtectLogs,
+			ProtectThumbnails:       c.ProtectThumbnails,
+			EventStream:             c.EventStream,
+			SaveClientEvents:        c.SaveClientEvents,
+			SaveDeviceEvents:        c.SaveDeviceEvents,
+			ClientInventory:         c.ClientInventory,
+			SaveIDs:                 c.SaveIDs,
+			SaveDPI:                 c.SaveDPI,
+			HashPII:                 c.HashPII,
+			DropPII:                 c.DropPII,
+			SaveSites:               c.SaveSites,
+			SaveTraffic:             c.SaveTraffic,
+			Timeout:                 c.Timeout,
+			PollTimeout:             c.PollTimeout,
+			RateLimit:               c.RateLimit,
+			RateBurst:               c.RateBurst,
+			Intervals:               c.Intervals,
+			SiteOverrides:           c.SiteOverrides,
+			CertPaths:               c.CertPaths,
+			User:                    c.User,
+			Pass:                    strconv.FormatBool(c.Pass != ""),
+			APIKey:                  strconv.FormatBool(c.APIKey != ""),
+			PIIHash:                 c.PIIHash,
+			PIIKey:                  strconv.FormatBool(c.PIIKey != ""),
+			URL:                     c.URL,
+			Sites:                   c.Sites,
+			DefaultSiteNameOverride: c.DefaultSiteNameOverride,
+			Remote:                  c.Remote,
+			ConsoleID:               c.ConsoleID,
+			CaptureDir:              c.CaptureDir,
+			CaptureMaxAge:           c.CaptureMaxAge,
+			ID:                      id,
 		})
 	}
 
@@ -251,39 +276,60 @@ func formatDevices(c *Controller, devices *unifi.Devices) (d webserver.Devices)
 
 // Logf logs a message.
 func (u *InputUnifi) Logf(msg string, v ...any) {
-	webserver.NewInputEvent(PluginName, PluginName, &webserver.Event{
-		Ts:   time.Now(),
-		Msg:  fmt.Sprintf(msg, v...),
-		Tags: map[string]string{"type": "info"},
-	})
-
-	if u.Logger != nil {
-		u.Logger.Logf(msg, v...)
-	}
+	logf(u.Logger, "info", msg, v...)
 }
 
 // LogErrorf logs an error message.
 func (u *InputUnifi) LogErrorf(msg string, v ...any) {
-	webserver.NewInputEvent(PluginName, PluginName, &webserver.Event{
-		Ts:   time.Now(),
-		Msg:  fmt.Sprintf(msg, v...),
-		Tags: map[string]string{"type": "error"},
-	})
-
-	if u.Logger != nil {
-		u.Logger.LogErrorf(msg, v...)
-	}
+	logf(u.Logger, "error", msg, v...)
 }
 
 // LogDebugf logs a debug message.
 func (u *InputUnifi) LogDebugf(msg string, v ...any) {
+	logf(u.Logger, "debug", msg, v...)
+}
+
+// logWith returns a logger that adds fields, like the controller and site, to the
+// entries it passes to the poller. The web interface gets the same messages as Logf.
+func (u *InputUnifi) logWith(args ...any) poller.Logger {
+	return &fieldLogger{log: poller.With(u.Logger, args...)}
+}
+
+// fieldLogger is returned by logWith.
+type fieldLogger struct {
+	log poller.Logger
+}
+
+func (f *fieldLogger) Logf(msg string, v ...any) {
+	logf(f.log, "info", msg, v...)
+}
+
+func (f *fieldLogger) LogErrorf(msg string, v ...any) {
+	logf(f.log, "error", msg, v...)
+}
+
+func (f *fieldLogger) LogDebugf(msg string, v ...any) {
+	logf(f.log, "debug", msg, v...)
+}
+
+// logf sends a message to the web interface, and to l at the level, if there is one.
+func logf(l poller.Logger, level, msg string, v ...any) {
 	webserver.NewInputEvent(PluginName, PluginName, &webserver.Event{
 		Ts:   time.Now(),
 		Msg:  fmt.Sprintf(msg, v...),
-		Tags: map[string]string{"type": "debug"},
+		Tags: map[string]string{"type": level},
 	})
 
-	if u.Logger != nil {
-		u.Logger.LogDebugf(msg, v...)
+	if l == nil {
+		return
+	}
+
+	switch level {
+	case "error":
+		l.LogErrorf(msg, v...)
+	case "debug":
+		l.LogDebugf(msg, v...)
+	default:
+		l.Logf(msg, v...)
 	}
 }
@@ -0,0 +1,154 @@
+package inputunifi
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+var (
+	ErrAPIKeyAndUser     = errors.New("api_key and user/pass are both set; user and pass are ignored")
+	ErrRemoteNoAPIKey    = errors.New("remote mode requires an api_key")
+	ErrControllerURL     = errors.New("url must be an http:// or https:// URL")
+	ErrNegativeSetting   = errors.New("must not be negative")
+	ErrUnknownCollection = errors.New("unknown collection")
+	ErrPIIHash           = errors.New("pii_hash must be hmac or md5")
+	ErrPIIKeyMissing     = errors.New("pii_hash hmac requires a pii_key")
+	ErrPIIKeyRead        = errors.New("reading pii_key")
+)
+
+var (
+	_ poller.ConfigChecker = &InputUnifi{}
+	_ poller.ConfigPrinter = &InputUnifi{}
+)
+
+// CheckConfig returns a problem for each configured value that cannot work, or that
+// setControllerDefaults would quietly override. Satisfies poller.ConfigChecker.
+func (u *InputUnifi) CheckConfig() []error {
+	if u.Config == nil || u.Disable {
+		return nil
+	}
+
+	var errs []error
+
+	if u.MaxConcurrency < 0 {
+		errs = append(errs, fmt.Errorf("max_concurrency %w: %d", ErrNegativeSetting, u.MaxConcurrency))
+	}
+
+	if u.Rediscover.Duration < 0 {
+		errs = append(errs, fmt.Errorf("rediscover_interval %w: %v", ErrNegativeSetting, u.Rediscover))
+	}
+
+	if u.InventoryMaxAge.Duration < 0 {
+		errs = append(errs, fmt.Errorf("inventory_max_age %w: %v", ErrNegativeSetting, u.InventoryMaxAge))
+	}
+
+	if u.Remote && u.RemoteAPIKey == "" {
+		errs = append(errs, fmt.Errorf("remote_api_key: %w", ErrRemoteNoAPIKey))
+	}
+
+	if err := poller.CheckSecret(u.RemoteAPIKey); err != nil {
+		errs = append(errs, fmt.Errorf("remote_api_key: %w", err))
+	}
+
+	errs = append(errs, checkController("defaults", &u.Default, nil)...)
+
+	for i, c := range u.Controllers {
+		errs = append(errs, checkController(fmt.Sprintf("controller %d", i+1), c, &u.Default)...)
+	}
+
+	return errs
+}
+
+// checkController checks one controller. defaults is nil when checking the defaults.
+func checkController(name string, c, defaults *Controller) []error {
+	var errs []error
+
+	if c.APIKey != "" && (c.User != "" || c.Pass != "") {
+		errs = append(errs, fmt.Errorf("%s: %w", name, ErrAPIKeyAndUser))
+	}
+
+	if c.Remote && c.APIKey == "" && (defaults == nil || defaults.APIKey == "") {
+		errs = append(errs, fmt.Errorf("%s: %w", name, ErrRemoteNoAPIKey))
+	}
+
+	for _, secret := range []struct{ key, value string }{{"pass", c.Pass}, {"api_key", c.APIKey}, {"pii_key", c.PIIKey}} {
+		if err := poller.CheckSecret(secret.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", name, secret.key, err))
+		}
+	}
+
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s: %w: %s", name, ErrControllerURL, c.URL))
+		}
+	}
+
+	if c.Timeout.Duration < 0 {
+		errs = append(errs, fmt.Errorf("%s: timeout %w: %v", name, ErrNegativeSetting, c.Timeout))
+	}
+
+	if c.PollTimeout.Duration < 0 {
+		errs = append(errs, fmt.Errorf("%s: poll_timeout %w: %v", name, ErrNegativeSetting, c.PollTimeout))
+	}
+
+	if c.CaptureMaxAge.Duration < 0 {
+		errs = append(errs, fmt.Errorf("%s: capture_max_age %w: %v", name, ErrNegativeSetting, c.CaptureMaxAge))
+	}
+
+	if c.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("%s: rate_limit %w: %v", name, ErrNegativeSetting, c.RateLimit))
+	}
+
+	for collection, interval := range c.Intervals {
+		if !slices.Contains(collectionNames(), collection) {
+			errs = append(errs, fmt.Errorf("%s: intervals: %w: %s (one of: %s)", name, ErrUnknownCollection,
+				collection, strings.Join(collectionNames(), ", ")))
+		} else if interval.Duration < 0 {
+			errs = append(errs, fmt.Errorf("%s: intervals: %s %w: %v", name, collection, ErrNegativeSetting, interval))
+		}
+	}
+
+	piiHash, piiKey := c.PIIHash, c.PIIKey
+	if defaults != nil {
+		piiHash, piiKey = cmp.Or(piiHash, defaults.PIIHash), cmp.Or(piiKey, defaults.PIIKey)
+	}
+
+	switch piiHash {
+	case "", PIIHashMD5:
+	case PIIHashHMAC:
+		if piiKey == "" {
+			errs = append(errs, fmt.Errorf("%s: %w", name, ErrPIIKeyMissing))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s: %w, not %q", name, ErrPIIHash, piiHash))
+	}
+
+	if c.RateBurst < 0 {
+		errs = append(errs, fmt.Errorf("%s: rate_burst %w: %v", name, ErrNegativeSetting, c.RateBurst))
+	}
+
+	return errs
+}
+
+// EffectiveConfig applies the defaults to the configured controllers and returns the
+// config as the web interface shows it. Remote consoles are not discovered.
+// Satisfies poller.ConfigPrinter.
+func (u *InputUnifi) EffectiveConfig() any {
+	if u.Config == nil {
+		return formatConfig(&Config{Disable: true})
+	}
+
+	u.setDefaults(&u.Default)
+
+	for _, c := range u.Controllers {
+		u.setControllerDefaults(c)
+	}
+
+	return formatConfig(u.Config)
+}
@@ -8,6 +8,8 @@ import (
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/unpoller/unpoller/pkg/poller"
 )
 
 const (
@@ -20,11 +22,13 @@ var errStatusCode = fmt.Errorf("unexpected HTTP status code")
 type Client struct {
 	*Config
 	*http.Client
+	password string // resolved from Config.Password.
 }
 
-func (l *Loki) httpClient() *Client {
+func (l *Loki) httpClient(password string) *Client {
 	return &Client{
-		Config: l.Config,
+		Config:   l.Config,
+		password: password,
 		Client: &http.Client{
 			Timeout: l.Timeout.Duration,
 			Transport: &http.Transport{
@@ -53,6 +57,10 @@ func (c *Client) Post(logs any) error {
 	if code, body, err := c.Do(req); err != nil {
 		return err
 	} else if code != http.StatusNoContent {
+		if code == http.StatusUnauthorized {
+			c.renewPassword()
+		}
+
 		m := fmt.Sprintf("%s (%d/%s) %s, msg: %s", u, code, http.StatusText(code),
 			strings.TrimSpace(strings.ReplaceAll(string(body), "\n", " ")), msg)
 
@@ -62,6 +70,21 @@ func (c *Client) Post(logs any) error {
 	return nil
 }
 
+// renewPassword resolves the password again after Loki rejected it, so a rotated
+// password in a file or credential is used on the next push.
+func (c *Client) renewPassword() {
+	if !poller.IsSecretRef(c.Password) {
+		return
+	}
+
+	// A cached exec:// secret is resolved again, as it may have been rotated.
+	poller.ForgetSecret(c.Password)
+
+	if password, err := poller.ResolveSecret(c.Password); err == nil {
+		c.password = password
+	}
+}
+
 // NewRequest creates the http request based on input data.
 func (c *Client) NewRequest(url, method, cType string, msg []byte) (*http.Request, error) {
 	req, err := http.NewRequest(method, url, bytes.NewBuffer(msg)) //nolint:noctx
@@ -73,8 +96,8 @@ func (c *Client) NewRequest(url, method, cType string, msg []byte) (*http.Reques
 		req.Header.Set("Content-Type", cType)
 	}
 
-	if c.Username != "" || c.Password != "" {
-		req.SetBasicAuth(c.Username, c.Password)
+	if c.Username != "" || c.password != "" {
+		req.SetBasicAuth(c.Username, c.password)
 	}
 
 	if c.TenantID != "" {
@@ -1,8 +1,8 @@
 package lokiunifi
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -45,9 +45,16 @@ type Loki struct {
 	*Config `json:"loki" toml:"loki" xml:"loki" yaml:"loki"`
 	client  *Client
 	last    time.Time
+	reload  chan *Config // configs from Reload, applied by the poll loop.
 }
 
-var _ poller.OutputPlugin = &Loki{}
+var (
+	_ poller.OutputPlugin        = &Loki{}
+	_ poller.ContextOutputPlugin = &Loki{}
+	_ poller.Reloader            = &Loki{}
+	_ poller.OnceOutput          = &Loki{}
+	_ poller.InputFilter         = &Loki{}
+)
 
 // init is how this modular code is initialized by the main app.
 // This module adds itself as an output module to the poller core.
@@ -55,7 +62,7 @@ func init() { // nolint: gochecknoinits
 	l := &Loki{Config: &Config{
 		Interval: cnfg.Duration{Duration: defaultInterval},
 		Timeout:  cnfg.Duration{Duration: defaultTimeout},
-	}}
+	}, reload: make(chan *Config, 1)}
 
 	poller.NewOutput(&poller.Output{
 		Name:         PluginName,
@@ -98,6 +105,12 @@ func (l *Loki) DebugOutput() (bool, error) {
 
 // Run is fired from the poller library after the Config is unmarshalled.
 func (l *Loki) Run(collect poller.Collect) error {
+	return l.RunContext(context.Background(), collect)
+}
+
+// RunContext is Run, but it returns once the context is canceled. A batch already
+// being sent to Loki is allowed to finish first.
+func (l *Loki) RunContext(ctx context.Context, collect poller.Collect) error {
 	l.Collect = collect
 	if !l.Enabled() {
 		l.LogDebugf("Loki config missing (or disabled), Loki output disabled!")
@@ -113,16 +126,107 @@ func (l *Loki) Run(collect poller.Collect) error {
 		return err
 	}
 
+	l.updateWeb()
+	l.PollController(ctx)
+
+	if ctx.Err() != nil {
+		l.Logf("Loki Output Plugin Stopped")
+
+		return nil
+	}
+
+	l.LogErrorf("Loki Output Plugin Stopped!")
+
+	return nil
+}
+
+// InputName returns the input this output writes. Satisfies poller.InputFilter.
+func (l *Loki) InputName() string {
+	return InputName
+}
+
+// WriteOnce sends the events in one snapshot to Loki, for --once. The poller collected
+// them from the --once-window or the event cursors, so none are too old to send.
+// Satisfies poller.OnceOutput.
+func (l *Loki) WriteOnce(_ context.Context, collect poller.Collect, snap *poller.Snapshot) error {
+	l.Collect = collect
+
+	if err := l.ValidateConfig(); err != nil {
+		return err
+	}
+
+	report := l.NewReport(snap.Start)
+	report.Oldest = time.Time{}
+
+	return l.ProcessEvents(report, snap.Events)
+}
+
+// updateWeb sends the current config, minus the password, to the web interface.
+func (l *Loki) updateWeb() {
 	fake := *l.Config
 	fake.Password = strconv.FormatBool(fake.Password != "")
 
 	webserver.UpdateOutput(&webserver.Output{Name: PluginName, Config: fake})
-	l.PollController()
-	l.LogErrorf("Loki Output Plugin Stopped!")
+}
+
+// Reload hands a new config to the poll loop, which applies it between polls.
+// The time of the last event sent survives a reload, so events are not sent twice.
+// Satisfies poller.Reloader.
+func (l *Loki) Reload(config any) error {
+	n, ok := config.(*Loki)
+	if !ok {
+		return fmt.Errorf("%w: %T", poller.ErrReloadConfigType, config)
+	}
+
+	if n.Config == nil {
+		n.Config = &Config{Disable: true}
+	}
+
+	if n.Interval.Duration == 0 {
+		n.Interval = cnfg.Duration{Duration: defaultInterval}
+	}
+
+	if n.Timeout.Duration == 0 {
+		n.Timeout = cnfg.Duration{Duration: defaultTimeout}
+	}
+
+	// Replace a config the poll loop has not picked up yet.
+	select {
+	case <-l.reload:
+	default:
+	}
+
+	l.reload <- n.Config
 
 	return nil
 }
 
+// applyConfig swaps in a reloaded config. It runs on the poll loop, between polls.
+// Returns false if the reloaded config disables the output.
+func (l *Loki) applyConfig(config *Config) bool {
+	if config.Disable || config.URL == "" {
+		l.Logf("Loki output disabled by a config reload")
+
+		return false
+	}
+
+	prev, client, last := l.Config, l.client, l.last
+	l.Config = config
+
+	if err := l.ValidateConfig(); err != nil {
+		l.LogErrorf("Reloading Loki config, keeping the current one: %v", err)
+		l.Config, l.client, l.last = prev, client, last
+
+		return true
+	}
+
+	l.last = last // ValidateConfig resets it.
+	l.updateWeb()
+	l.Logf("Loki config reloaded, interval: %v, URL: %s", l.Interval.Round(time.Second), l.URL)
+
+	return true
+}
+
 // ValidateConfig sets initial "last" update time. Also creates an http client,
 // makes sure URL is sane, and sets interval within min/max limits.
 func (l *Loki) ValidateConfig() error {
@@ -132,43 +236,93 @@ func (l *Loki) ValidateConfig() error {
 		l.Interval.Duration = minInterval
 	}
 
-	if strings.HasPrefix(l.Password, "file://") {
-		pass, err := os.ReadFile(strings.TrimPrefix(l.Password, "file://"))
-		if err != nil {
-			l.LogErrorf("Reading Loki Password File: %v", err)
-
-			return fmt.Errorf("error reading password file")
-		}
-
-		l.Password = strings.TrimSpace(string(pass))
+	password, err := poller.ResolveSecret(l.Password)
+	if err != nil {
+		return fmt.Errorf("loki pass: %w", err)
 	}
 
 	l.last = time.Now().Add(-l.Interval.Duration)
-	l.client = l.httpClient()
+	l.client = l.httpClient(password)
 	l.URL = strings.TrimRight(l.URL, "/") // gets a path appended to it later.
 
 	return nil
 }
 
-// PollController runs forever, polling UniFi for events and pushing them to Loki.
+// PollController polls UniFi for events and pushes them to Loki until the context is canceled.
 // This is started by Run().
-func (l *Loki) PollController() {
+func (l *Loki) PollController(ctx context.Context) {
 	interval := l.Interval.Round(time.Second)
 
 	l.Logf("Loki Event collection started, interval: %v, URL: %s", interval, l.URL)
 
-	ticker := time.NewTicker(interval)
-	for start := range ticker.C {
-		events, err := l.Collect.Events(&poller.Filter{Name: InputName})
-		if err != nil {
-			l.LogErrorf("event fetch for Loki failed: %v", err)
-
-			continue
+	if s, ok := l.Collect.(poller.Scheduler); ok {
+		sub := &poller.Subscription{Name: PluginName, Input: InputName, Interval: interval, Events: true, Stream: true, Ack: true}
+		snaps := s.Subscribe(sub)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case config := <-l.reload:
+				if !l.applyConfig(config) {
+					return
+				}
+
+				if r, ok := s.(poller.Rescheduler); ok {
+					r.Reschedule(sub, l.Interval.Round(time.Second))
+				}
+			case snap, ok := <-snaps:
+				if !ok {
+					return
+				}
+
+				if snap.EventsErr != nil {
+					l.LogErrorf("event fetch for Loki failed: %v", snap.EventsErr)
+					snap.Done(snap.EventsErr)
+
+					continue
+				}
+
+				report := l.NewReport(snap.Start)
+				if snap.Streamed {
+					report.Oldest, report.Streamed = time.Time{}, true
+				}
+
+				err := l.ProcessEvents(report, snap.Events)
+				if err != nil {
+					l.LogErrorf("%v", err)
+				}
+
+				snap.Done(err)
+			}
 		}
+	}
 
-		err = l.ProcessEvents(l.NewReport(start), events)
-		if err != nil {
-			l.LogErrorf("%v", err)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case config := <-l.reload:
+			if !l.applyConfig(config) {
+				return
+			}
+
+			ticker.Reset(l.Interval.Round(time.Second))
+		case start := <-ticker.C:
+			events, err := l.Collect.Events(&poller.Filter{Name: InputName})
+			if err != nil {
+				l.LogErrorf("event fetch for Loki failed: %v", err)
+
+				continue
+			}
+
+			err = l.ProcessEvents(l.NewReport(start), events)
+			if err != nil {
+				l.LogErrorf("%v", err)
+			}
 		}
 	}
 }
@@ -187,11 +341,22 @@ func (l *Loki) ProcessEvents(report *Report, events *poller.Events) error {
 		return nil
 	}
 
-	if err := l.client.Post(logs); err != nil {
+	entries := 0
+	for _, stream := range logs.Streams {
+		entries += len(stream.Entries)
+	}
+
+	start := time.Now()
+	err := l.client.Post(logs)
+	poller.RecordWrite(PluginName, time.Since(start), entries, err)
+
+	if err != nil {
 		return fmt.Errorf("sending to Loki failed: %w", err)
 	}
 
-	l.last = report.Start
+	if !report.Streamed {
+		l.last = report.Start
+	}
 
 	l.Logf("Events sent to Loki. %v", report)
 
@@ -29,13 +29,22 @@ type Report struct {
 	ExtraLabels map[string]string
 	poller.Logger
 	Counts map[string]int
+	// Streamed reports hold events an input pushed as they arrived. They are all
+	// new, and do not move the window the next poll is read from.
+	Streamed bool
 }
 
-// NewReport makes a new report.
+// NewReport makes a new report. With event cursors on, the input returns each
+// event once, so no event is too old to send.
 func (l *Loki) NewReport(start time.Time) *Report {
+	oldest := l.last
+	if poller.EventCursors() {
+		oldest = time.Time{}
+	}
+
 	return &Report{
 		Start:       start,
-		Oldest:      l.last,
+		Oldest:      oldest,
 		Collect:     l.Collect,
 		ExtraLabels: l.ExtraLabels,
 		Logger:      l,
@@ -63,6 +72,10 @@ func (r *Report) ProcessEventLogs(events *poller.Events) *Logs {
 			r.SystemLogEvent(event, logs)
 		case *unifi.ProtectLogEntry:
 			r.ProtectLogEvent(event, logs)
+		case *poller.ClientEvent:
+			r.ClientEvent(event, logs)
+		case *poller.DeviceEvent:
+			r.DeviceEvent(event, logs)
 		default: // unlikely.
 			if r.Collect != nil && r.Collect.Poller().LogUnknownTypes {
 				r.LogDebugf("unknown event type: %T", e)
@@ -70,6 +83,11 @@ func (r *Report) ProcessEventLogs(events *poller.Events) *Logs {
 		}
 	}
 
+	// Add the static labels from the poller's relabel rules to each stream.
+	for _, stream := range logs.Streams {
+		MergeLabels(stream.Labels, events.Labels.For(stream.Labels["source"], stream.Labels["site_name"]))
+	}
+
 	return logs
 }
 
@@ -83,6 +101,14 @@ func (r *Report) String() string {
 		s += fmt.Sprintf(" (thumbs: %d)", r.Counts[typeProtectThumbnail])
 	}
 
+	if r.Counts[typeClientEvent] > 0 {
+		s += fmt.Sprintf(", %s: %d", typeClientEvent, r.Counts[typeClientEvent])
+	}
+
+	if r.Counts[typeDeviceEvent] > 0 {
+		s += fmt.Sprintf(", %s: %d", typeDeviceEvent, r.Counts[typeDeviceEvent])
+	}
+
 	s += fmt.Sprintf(", Dur: %v", time.Since(r.Start).Round(time.Millisecond))
 
 	return s
@@ -0,0 +1,37 @@
+package lokiunifi
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+const typeDeviceEvent = "DeviceEvent"
+
+// DeviceEvent stores a device lifecycle event for batch sending to Loki.
+// Logs the raw JSON for parsing with Loki's `| json` pipeline.
+func (r *Report) DeviceEvent(event *poller.DeviceEvent, logs *Logs) {
+	if event.Time.Before(r.Oldest) {
+		return
+	}
+
+	r.Counts[typeDeviceEvent]++ // increase counter and append new log line.
+
+	msg, err := json.Marshal(event)
+	if err != nil {
+		msg = []byte(event.Msg())
+	}
+
+	logs.Streams = append(logs.Streams, LogStream{
+		Entries: [][]string{{strconv.FormatInt(event.Time.UnixNano(), 10), string(msg)}},
+		Labels: CleanLabels(MergeLabels(map[string]string{
+			"application": "unifi_device_event",
+			"job":         "unpoller",
+			"source":      event.SourceName,
+			"site_name":   event.SiteName,
+			"event_type":  event.Type,
+			"device_type": event.DeviceType,
+		}, r.ExtraLabels)),
+	})
+}
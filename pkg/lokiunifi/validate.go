@@ -0,0 +1,65 @@
+package lokiunifi
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+var (
+	ErrLokiURL       = errors.New("url must be an http:// or https:// URL")
+	ErrIntervalRange = fmt.Errorf("interval is outside %v to %v, and is moved into that range", minInterval, maxInterval)
+	ErrNegativeTime  = errors.New("timeout must not be negative")
+)
+
+var (
+	_ poller.ConfigChecker = &Loki{}
+	_ poller.ConfigPrinter = &Loki{}
+)
+
+// CheckConfig returns a problem for each configured value that cannot work, or that
+// ValidateConfig would quietly change. Satisfies poller.ConfigChecker.
+func (l *Loki) CheckConfig() []error {
+	if !l.Enabled() {
+		return nil
+	}
+
+	var errs []error
+
+	if parsed, err := url.Parse(l.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrLokiURL, l.URL))
+	}
+
+	if l.Interval.Duration < minInterval || l.Interval.Duration > maxInterval {
+		errs = append(errs, fmt.Errorf("%w: %v", ErrIntervalRange, l.Interval))
+	}
+
+	if l.Timeout.Duration < 0 {
+		errs = append(errs, fmt.Errorf("%w: %v", ErrNegativeTime, l.Timeout))
+	}
+
+	if err := poller.CheckSecret(l.Password); err != nil {
+		errs = append(errs, fmt.Errorf("pass: %w", err))
+	}
+
+	return errs
+}
+
+// EffectiveConfig returns the config with the interval limits applied and the
+// password redacted. Satisfies poller.ConfigPrinter.
+func (l *Loki) EffectiveConfig() any {
+	if l.Config == nil {
+		return Config{Disable: true}
+	}
+
+	fake := *l.Config
+	fake.Interval.Duration = min(max(fake.Interval.Duration, minInterval), maxInterval)
+	fake.URL = strings.TrimRight(fake.URL, "/")
+	fake.Password = strconv.FormatBool(fake.Password != "")
+
+	return fake
+}
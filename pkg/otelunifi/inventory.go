@@ -0,0 +1,32 @@
+package otelunifi
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// exportClientInventory emits the known and new connected clients per site,
+// published by inputs with a client inventory.
+func (u *OtelOutput) exportClientInventory(ctx context.Context, meter metric.Meter, m *poller.Metrics, r *Report) {
+	for _, item := range m.Extra[poller.KindOf(&poller.ClientInventory{})] {
+		s, ok := item.(*poller.ClientInventory)
+		if !ok {
+			continue
+		}
+
+		for state, count := range map[string]int{"known": s.Known, "new": s.New} {
+			attrs := attribute.NewSet(
+				attribute.String("site_name", s.SiteName),
+				attribute.String("source", s.SourceName),
+				attribute.String("state", state),
+			)
+
+			u.recordGauge(ctx, meter, r, "unifi_client_inventory",
+				"Connected clients by inventory state: known, or new (first seen in the last day)", float64(count), attrs)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package otelunifi
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -69,12 +70,20 @@ type OtelOutput struct {
 	LastCheck time.Time
 	provider  *sdkmetric.MeterProvider
 	*OtelUnifi
+	reload chan *Config // configs from Reload, applied by the poll loop.
 }
 
-var _ poller.OutputPlugin = &OtelOutput{}
+var (
+	_ poller.OutputPlugin        = &OtelOutput{}
+	_ poller.ContextOutputPlugin = &OtelOutput{}
+	_ poller.Reloader            = &OtelOutput{}
+	_ poller.KindExporter        = &OtelOutput{}
+	_ poller.OnceOutput          = &OtelOutput{}
+	_ poller.InputFilter         = &OtelOutput{}
+)
 
 func init() { //nolint:gochecknoinits
-	u := &OtelOutput{OtelUnifi: &OtelUnifi{Config: &Config{}}, LastCheck: time.Now()}
+	u := &OtelOutput{OtelUnifi: &OtelUnifi{Config: &Config{}}, LastCheck: time.Now(), reload: make(chan *Config, 1)}
 
 	poller.NewOutput(&poller.Output{
 		Name:         PluginName,
@@ -122,6 +131,12 @@ func (u *OtelOutput) DebugOutput() (bool, error) {
 
 // Run is the main loop called by the poller core.
 func (u *OtelOutput) Run(c poller.Collect) error {
+	return u.RunContext(context.Background(), c)
+}
+
+// RunContext is Run, but it returns once the context is canceled, after shutting
+// down the MeterProvider. Shutdown exports anything still buffered.
+func (u *OtelOutput) RunContext(ctx context.Context, c poller.Collect) error {
 	u.Collector = c
 
 	if !u.Enabled() {
@@ -138,33 +153,167 @@ func (u *OtelOutput) Run(c poller.Collect) error {
 	}
 
 	defer func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		flushCtx, cancel := poller.FlushContext(c)
 		defer cancel()
 
-		if err := u.provider.Shutdown(ctx); err != nil {
+		if err := u.provider.Shutdown(flushCtx); err != nil {
 			u.LogErrorf("otel: shutdown provider: %v", err)
 		}
 	}()
 
-	webserver.UpdateOutput(&webserver.Output{Name: PluginName, Config: u.Config})
-	u.pollController()
+	webserver.UpdateOutput(&webserver.Output{Name: PluginName, Config: u.redacted()})
+	u.pollController(ctx)
+
+	return nil
+}
+
+// InputName returns the input this output writes. Satisfies poller.InputFilter.
+func (u *OtelOutput) InputName() string {
+	return "unifi"
+}
+
+// WriteOnce records one snapshot and exports it before shutting down the MeterProvider,
+// for --once. Satisfies poller.OnceOutput.
+func (u *OtelOutput) WriteOnce(ctx context.Context, c poller.Collect, snap *poller.Snapshot) error {
+	u.Collector = c
+	u.setConfigDefaults()
+
+	if err := u.setupProvider(); err != nil {
+		return fmt.Errorf("otel: setup provider: %w", err)
+	}
+
+	report, err := u.reportMetrics(snap.Metrics, snap.Events)
+	if err == nil {
+		// The periodic reader would export on its next tick; export now instead.
+		err = u.provider.ForceFlush(ctx)
+	}
+
+	err = errors.Join(err, u.provider.Shutdown(ctx))
+	poller.RecordWrite(PluginName, report.Elapsed, report.Total, err)
+
+	if err != nil {
+		return fmt.Errorf("otel export: %w", err)
+	}
+
+	u.Logf("OTel Metrics Exported. %v", report)
 
 	return nil
 }
 
-// pollController runs the ticker loop, pushing metrics on each tick.
-func (u *OtelOutput) pollController() {
+// pollController runs the ticker loop, pushing metrics on each tick, until the context is canceled.
+func (u *OtelOutput) pollController(ctx context.Context) {
 	interval := u.Interval.Round(time.Second)
+
+	u.Logf("OTel->OTLP started, protocol: %s, interval: %v, url: %s",
+		u.Protocol, interval, u.URL)
+
+	if s, ok := u.Collector.(poller.Scheduler); ok {
+		sub := &poller.Subscription{Name: PluginName, Input: u.InputName(), Interval: interval, Metrics: true, Events: true}
+		snaps := s.Subscribe(sub)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case config := <-u.reload:
+				if !u.applyConfig(config) {
+					return
+				}
+
+				if r, ok := s.(poller.Rescheduler); ok {
+					r.Reschedule(sub, u.Interval.Duration)
+				}
+			case snap, ok := <-snaps:
+				if !ok {
+					return
+				}
+
+				u.LastCheck = snap.Start
+				u.pollSnapshot(snap)
+			}
+		}
+	}
+
 	ticker := time.NewTicker(interval)
 
 	defer ticker.Stop()
 
-	u.Logf("OTel->OTLP started, protocol: %s, interval: %v, url: %s",
-		u.Protocol, interval, u.URL)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case config := <-u.reload:
+			if !u.applyConfig(config) {
+				return
+			}
+
+			interval = u.Interval.Duration
+			ticker.Reset(interval)
+		case u.LastCheck = <-ticker.C:
+			u.poll(interval)
+		}
+	}
+}
+
+// Reload hands a new config to the poll loop, which applies it between polls.
+// Satisfies poller.Reloader.
+func (u *OtelOutput) Reload(config any) error {
+	c, ok := config.(*OtelUnifi)
+	if !ok {
+		return fmt.Errorf("%w: %T", poller.ErrReloadConfigType, config)
+	}
+
+	if c.Config == nil {
+		c.Config = &Config{}
+	}
+
+	if c.Protocol != "" && c.Protocol != protoHTTP && c.Protocol != protoGRPC {
+		return fmt.Errorf("otel: protocol must be %q or %q, got %q", protoHTTP, protoGRPC, c.Protocol)
+	}
+
+	// Replace a config the poll loop has not picked up yet.
+	select {
+	case <-u.reload:
+	default:
+	}
+
+	u.reload <- c.Config
+
+	return nil
+}
+
+// applyConfig swaps in a reloaded config and a MeterProvider built from it, then
+// shuts the old provider down, which exports what it still buffers. It runs on the
+// poll loop, between polls. Returns false if the reloaded config disables the output.
+func (u *OtelOutput) applyConfig(config *Config) bool {
+	if !config.Enable {
+		u.Logf("OTel output disabled by a config reload")
+
+		return false
+	}
+
+	prev, provider := u.OtelUnifi, u.provider
+	u.OtelUnifi = &OtelUnifi{Config: config}
+	u.setConfigDefaults()
+
+	if err := u.setupProvider(); err != nil {
+		u.LogErrorf("Reloading OTel config, keeping the current one: %v", err)
+		u.OtelUnifi, u.provider = prev, provider
+
+		return true
+	}
 
-	for u.LastCheck = range ticker.C {
-		u.poll(interval)
+	flushCtx, cancel := poller.FlushContext(u.Collector)
+	defer cancel()
+
+	if err := provider.Shutdown(flushCtx); err != nil {
+		u.LogErrorf("otel: shutdown provider: %v", err)
 	}
+
+	webserver.UpdateOutput(&webserver.Output{Name: PluginName, Config: u.redacted()})
+	u.Logf("OTel config reloaded, protocol: %s, interval: %v, url: %s", u.Protocol, u.Interval.Duration, u.URL)
+
+	return true
 }
 
 // poll fetches metrics once and sends them to the OTLP endpoint.
@@ -183,7 +332,30 @@ func (u *OtelOutput) poll(interval time.Duration) {
 		return
 	}
 
+	u.export(metrics, events)
+}
+
+// pollSnapshot sends a snapshot from the shared poll scheduler to the OTLP endpoint.
+func (u *OtelOutput) pollSnapshot(snap *poller.Snapshot) {
+	if snap.MetricsErr != nil {
+		u.LogErrorf("metric fetch for OTel failed: %v", snap.MetricsErr)
+
+		return
+	}
+
+	if snap.EventsErr != nil {
+		u.LogErrorf("event fetch for OTel failed: %v", snap.EventsErr)
+
+		return
+	}
+
+	u.export(snap.Metrics, snap.Events)
+}
+
+func (u *OtelOutput) export(metrics *poller.Metrics, events *poller.Events) {
 	report, err := u.reportMetrics(metrics, events)
+	poller.RecordWrite(PluginName, report.Elapsed, report.Total, err)
+
 	if err != nil {
 		u.LogErrorf("otel report: %v", err)
 
@@ -228,6 +400,11 @@ func (u *OtelOutput) setupProvider() error {
 
 // buildExporter creates either an HTTP or gRPC OTLP exporter.
 func (u *OtelOutput) buildExporter(ctx context.Context) (sdkmetric.Exporter, error) {
+	apiKey, err := poller.ResolveSecret(u.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("otel api_key: %w", err)
+	}
+
 	switch u.Protocol {
 	case protoGRPC:
 		opts := []otlpmetricgrpc.Option{
@@ -235,9 +412,9 @@ func (u *OtelOutput) buildExporter(ctx context.Context) (sdkmetric.Exporter, err
 			otlpmetricgrpc.WithInsecure(),
 		}
 
-		if u.APIKey != "" {
+		if apiKey != "" {
 			opts = append(opts, otlpmetricgrpc.WithHeaders(map[string]string{
-				"Authorization": "Bearer " + u.APIKey,
+				"Authorization": "Bearer " + apiKey,
 			}))
 		}
 
@@ -254,9 +431,9 @@ func (u *OtelOutput) buildExporter(ctx context.Context) (sdkmetric.Exporter, err
 			otlpmetrichttp.WithInsecure(),
 		}
 
-		if u.APIKey != "" {
+		if apiKey != "" {
 			opts = append(opts, otlpmetrichttp.WithHeaders(map[string]string{
-				"Authorization": "Bearer " + u.APIKey,
+				"Authorization": "Bearer " + apiKey,
 			}))
 		}
 
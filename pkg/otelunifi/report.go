@@ -25,6 +25,8 @@ type Report struct {
 	UDM     int           // Total count of UDM devices exported.
 	UXG     int           // Total count of UXG devices exported.
 	Elapsed time.Duration // Duration elapsed collecting and exporting.
+
+	labels poller.Labels // Static labels from the poller's relabel rules.
 }
 
 func (r *Report) String() string {
@@ -35,9 +37,31 @@ func (r *Report) String() string {
 	)
 }
 
+// withLabels adds the static labels the poller's relabel rules added for the
+// controller and site in attrs. Attributes already in the set win.
+func (r *Report) withLabels(attrs attribute.Set) attribute.Set {
+	source, _ := attrs.Value("source")
+	site, _ := attrs.Value("site_name")
+
+	labels := r.labels.For(source.AsString(), site.AsString())
+	if len(labels) == 0 {
+		return attrs
+	}
+
+	kvs := attrs.ToSlice()
+
+	for k, v := range labels {
+		if !attrs.HasValue(attribute.Key(k)) {
+			kvs = append(kvs, attribute.String(k, v))
+		}
+	}
+
+	return attribute.NewSet(kvs...)
+}
+
 // reportMetrics converts poller.Metrics to OTel measurements.
 func (u *OtelOutput) reportMetrics(m *poller.Metrics, _ *poller.Events) (*Report, error) {
-	r := &Report{}
+	r := &Report{labels: m.Labels}
 	start := time.Now()
 
 	meter := otel.GetMeterProvider().Meter(PluginName)
@@ -51,6 +75,8 @@ func (u *OtelOutput) reportMetrics(m *poller.Metrics, _ *poller.Events) (*Report
 	u.exportTopology(ctx, meter, m, r)
 	u.exportPortAnomalies(ctx, meter, m, r)
 	u.exportVPNMeshes(ctx, meter, m, r)
+	u.exportClientInventory(ctx, meter, m, r)
+	u.exportTelemetry(ctx, meter, poller.GetTelemetry(), r)
 
 	r.Elapsed = time.Since(start)
 
@@ -175,36 +201,62 @@ func (u *OtelOutput) exportClients(ctx context.Context, meter metric.Meter, m *p
 
 // exportDevices routes each device to its type-specific exporter.
 func (u *OtelOutput) exportDevices(ctx context.Context, meter metric.Meter, m *poller.Metrics, r *Report) {
-	for _, item := range m.Devices {
-		switch d := item.(type) {
-		case *unifi.UAP:
-			r.UAP++
-			u.exportUAP(ctx, meter, r, d)
-
-		case *unifi.USW:
-			r.USW++
-			u.exportUSW(ctx, meter, r, d)
-
-		case *unifi.USG:
-			r.USG++
-			u.exportUSG(ctx, meter, r, d)
-
-		case *unifi.UDM:
-			r.UDM++
-			u.exportUDM(ctx, meter, r, d)
-
-		case *unifi.UXG:
-			r.UXG++
-			u.exportUXG(ctx, meter, r, d)
-
-		default:
-			if u.Collector.Poller().LogUnknownTypes {
-				u.LogDebugf("otel: unknown device type: %T", item)
-			}
+	for _, item := range u.deviceHandlers().Export(device{ctx: ctx, meter: meter, r: r}, m.Devices) {
+		if u.Collector.Poller().LogUnknownTypes {
+			u.LogDebugf("otel: unknown device type: %T", item)
 		}
 	}
 }
 
+// device is passed to each device exporter by poller.Handlers.
+type device struct {
+	ctx   context.Context //nolint:containedctx
+	meter metric.Meter
+	r     *Report
+}
+
+// deviceHandlers returns the exporter for each device type, counting devices for the report.
+func (u *OtelOutput) deviceHandlers() *poller.Handlers[device] {
+	h := &poller.Handlers[device]{}
+
+	poller.Handle(h, func(e device, d *unifi.UAP) {
+		e.r.UAP++
+		u.exportUAP(e.ctx, e.meter, e.r, d)
+	})
+	poller.Handle(h, func(e device, d *unifi.USW) {
+		e.r.USW++
+		u.exportUSW(e.ctx, e.meter, e.r, d)
+	})
+	poller.Handle(h, func(e device, d *unifi.USG) {
+		e.r.USG++
+		u.exportUSG(e.ctx, e.meter, e.r, d)
+	})
+	poller.Handle(h, func(e device, d *unifi.UDM) {
+		e.r.UDM++
+		u.exportUDM(e.ctx, e.meter, e.r, d)
+	})
+	poller.Handle(h, func(e device, d *unifi.UXG) {
+		e.r.UXG++
+		u.exportUXG(e.ctx, e.meter, e.r, d)
+	})
+
+	return h
+}
+
+// ExportedKinds satisfies poller.KindExporter: the device types above,
+// and the collections reportMetrics reads directly.
+func (u *OtelOutput) ExportedKinds() []poller.MetricKind {
+	return append(u.deviceHandlers().Kinds(),
+		poller.KindOf(&unifi.Site{}),
+		poller.KindOf(&unifi.Client{}),
+		poller.KindOf(&unifi.FirewallPolicy{}),
+		poller.KindOf(&unifi.Topology{}),
+		poller.KindOf(&unifi.PortAnomaly{}),
+		poller.KindOf(&unifi.MagicSiteToSiteVPN{}),
+		poller.KindOf(&poller.ClientInventory{}),
+	)
+}
+
 // recordGauge is a helper that records a single float64 gauge observation.
 func (u *OtelOutput) recordGauge(
 	_ context.Context,
@@ -214,6 +266,8 @@ func (u *OtelOutput) recordGauge(
 	value float64,
 	attrs attribute.Set,
 ) {
+	attrs = r.withLabels(attrs)
+
 	g, err := meter.Float64ObservableGauge(name, metric.WithDescription(description))
 	if err != nil {
 		r.Errors++
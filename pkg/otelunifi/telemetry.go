@@ -0,0 +1,55 @@
+package otelunifi
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// exportTelemetry emits the poller's own poll, request and write statistics.
+func (u *OtelOutput) exportTelemetry(ctx context.Context, meter metric.Meter, t *poller.Telemetry, r *Report) {
+	for _, p := range t.Polls {
+		attrs := attribute.NewSet(
+			attribute.String("input", p.Input),
+			attribute.String("kind", p.Kind),
+		)
+
+		u.recordCallStats(ctx, meter, r, "unifipoller_poll", "input collections", &p.CallStats, attrs)
+	}
+
+	for _, q := range t.Requests {
+		attrs := attribute.NewSet(
+			attribute.String("input", q.Input),
+			attribute.String("controller", q.Controller),
+			attribute.String("endpoint", q.Endpoint),
+		)
+
+		u.recordCallStats(ctx, meter, r, "unifipoller_request", "controller API requests", &q.CallStats, attrs)
+	}
+
+	for _, w := range t.Writes {
+		attrs := attribute.NewSet(attribute.String("output", w.Output))
+
+		u.recordCallStats(ctx, meter, r, "unifipoller_write", "output writes", &w.CallStats, attrs)
+		u.recordGauge(ctx, meter, r, "unifipoller_write_points_total",
+			"Points written by the output", float64(w.Points), attrs)
+	}
+}
+
+func (u *OtelOutput) recordCallStats(
+	ctx context.Context,
+	meter metric.Meter,
+	r *Report,
+	prefix, what string,
+	s *poller.CallStats,
+	attrs attribute.Set,
+) {
+	u.recordGauge(ctx, meter, r, prefix+"_total", "Count of "+what, float64(s.Count), attrs)
+	u.recordGauge(ctx, meter, r, prefix+"_errors_total", "Count of failed "+what, float64(s.Errors), attrs)
+	u.recordGauge(ctx, meter, r, prefix+"_seconds_total", "Time spent on "+what, s.Total.Seconds(), attrs)
+	u.recordGauge(ctx, meter, r, prefix+"_last_seconds", "Duration of the last of the "+what, s.Last.Seconds(), attrs)
+	u.recordGauge(ctx, meter, r, prefix+"_max_seconds", "Longest of the "+what, s.Max.Seconds(), attrs)
+}
@@ -0,0 +1,68 @@
+package otelunifi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+var (
+	ErrProtocol        = fmt.Errorf("protocol must be %q or %q", protoHTTP, protoGRPC)
+	ErrIntervalLow     = fmt.Errorf("interval is below the minimum of %v, and is raised to it", minimumInterval)
+	ErrNegativeTimeout = errors.New("timeout must not be negative")
+)
+
+var (
+	_ poller.ConfigChecker = &OtelOutput{}
+	_ poller.ConfigPrinter = &OtelOutput{}
+)
+
+// CheckConfig returns a problem for each configured value that cannot work, or that
+// setConfigDefaults would quietly change. Satisfies poller.ConfigChecker.
+func (u *OtelOutput) CheckConfig() []error {
+	if !u.Enabled() {
+		return nil
+	}
+
+	var errs []error
+
+	if u.Protocol != "" && u.Protocol != protoHTTP && u.Protocol != protoGRPC {
+		errs = append(errs, fmt.Errorf("%w, got %q", ErrProtocol, u.Protocol))
+	}
+
+	if u.Interval.Duration < 0 || (u.Interval.Duration > 0 && u.Interval.Duration < minimumInterval) {
+		errs = append(errs, fmt.Errorf("%w: %v", ErrIntervalLow, u.Interval))
+	}
+
+	if u.Timeout.Duration < 0 {
+		errs = append(errs, fmt.Errorf("%w: %v", ErrNegativeTimeout, u.Timeout))
+	}
+
+	if err := poller.CheckSecret(u.APIKey); err != nil {
+		errs = append(errs, fmt.Errorf("api_key: %w", err))
+	}
+
+	return errs
+}
+
+// EffectiveConfig returns the config with defaults applied and the API key redacted.
+// Satisfies poller.ConfigPrinter.
+func (u *OtelOutput) EffectiveConfig() any {
+	if u.Config == nil {
+		return Config{}
+	}
+
+	u.setConfigDefaults()
+
+	return u.redacted()
+}
+
+// redacted returns a copy of the config that only shows whether the API key is set.
+func (c *Config) redacted() Config {
+	fake := *c
+	fake.APIKey = strconv.FormatBool(fake.APIKey != "")
+
+	return fake
+}
@@ -0,0 +1,339 @@
+package poller
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golift.io/version"
+)
+
+// DefaultSupportBundle is the file --support-bundle writes when it is not given one.
+const DefaultSupportBundle = "unpoller-support.tar.gz"
+
+// Redacted replaces the value of each secret in a support bundle's raw JSON.
+const Redacted = "[redacted]"
+
+// ErrNoStateDir is recorded in a support bundle made without a state_dir, where the
+// service keeps its recent log lines.
+var ErrNoStateDir = errors.New("no state_dir is set, so the service keeps no recent log lines")
+
+// secretKeys are parts of the JSON keys whose values are left out of a support bundle.
+// UniFi prefixes most of its secrets, like x_passphrase, with x_.
+var secretKeys = []string{"pass", "secret", "token", "psk", "apikey", "api_key", "private"} // nolint: gochecknoglobals
+
+// WriteSupportBundle writes a tar.gz for bug reports to the --support-bundle path. It holds:
+//
+//	version.txt      the version, platform and plugins.
+//	config.json      the effective config, as --print-config prints it.
+//	debugio.txt      the --debugio checks.
+//	discovery.md     the --discover report.
+//	raw/<input>/<unit>/<filter>.json
+//	                 the JSON of every endpoint of every controller, from inputs
+//	                 implementing RawDumper, with secrets redacted, and PII hashed
+//	                 or dropped like in metrics with hash_pii or drop_pii.
+//	errors.txt       what could not be collected, if anything.
+//	recent.log       the service's last log lines, kept in the state_dir.
+//	log.txt          the log lines written while the bundle was made.
+//
+// A problem collecting one of these is written to errors.txt, and is not returned.
+func (u *UnifiPoller) WriteSupportBundle() error {
+	logs := &syncBuffer{}
+
+	log.SetOutput(io.MultiWriter(log.Writer(), logs))
+
+	if err := u.SetupLogging(log.Writer()); err != nil {
+		return err
+	}
+
+	if err := u.SetupRelabel(); err != nil {
+		return err
+	}
+
+	path := u.Flags.SupportBundle
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) //nolint:mnd
+	if err != nil {
+		return fmt.Errorf("creating support bundle: %w", err)
+	}
+	defer file.Close()
+
+	u.Logf("Writing support bundle to %s", path)
+
+	b := newBundle(file)
+	u.collectBundle(b, logs)
+
+	if err := b.close(); err != nil {
+		return fmt.Errorf("writing support bundle %s: %w", path, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("writing support bundle %s: %w", path, err)
+	}
+
+	if len(b.problems) > 0 {
+		u.LogErrorf("%d problems collecting the support bundle are listed in its errors.txt", len(b.problems))
+	}
+
+	u.Logf("Support bundle written to %s. It holds data from your network, like client names and "+
+		"addresses, unless hash_pii or drop_pii is set; secrets are redacted. Review it before you share it.", path)
+
+	return nil
+}
+
+// collectBundle adds every file to the bundle. log.txt is last, to have every line.
+func (u *UnifiPoller) collectBundle(b *bundle, logs *syncBuffer) {
+	b.add("version.txt", []byte(u.bundleVersion()))
+
+	config, err := u.effectiveConfig()
+	b.addOrFail("config.json", config, err)
+
+	start := logs.Len()
+	err = u.DebugIO()
+	checks := logs.String()[start:]
+
+	if err != nil {
+		checks += fmt.Sprintf("\nFailed: %v\n", err)
+	}
+
+	b.add("debugio.txt", []byte(checks))
+
+	if err := u.InitializeInputs(); err != nil {
+		b.fail("initializing inputs", err)
+	}
+
+	report, err := bundleDiscovery()
+	b.addOrFail("discovery.md", report, err)
+	u.bundleRaw(b)
+	u.closeInputs()
+	u.bundleRecentLog(b)
+
+	if len(b.problems) > 0 {
+		b.add("errors.txt", []byte(strings.Join(b.problems, "\n")+"\n"))
+	}
+
+	b.add("log.txt", []byte(logs.String()))
+}
+
+// bundleRecentLog adds the service's last log lines, which it keeps in the state_dir.
+func (u *UnifiPoller) bundleRecentLog(b *bundle) {
+	if u.StateDir == "" {
+		b.fail("recent.log", ErrNoStateDir)
+
+		return
+	}
+
+	recent, err := os.ReadFile(filepath.Join(u.StateDir, RecentLogFile))
+	b.addOrFail("recent.log", recent, err)
+}
+
+func (u *UnifiPoller) bundleVersion() string {
+	return fmt.Sprintf("%s\nplatform: %s/%s\nconfig file: %s\ninputs: %s\noutputs: %s\n",
+		version.Print(AppName), runtime.GOOS, runtime.GOARCH, u.Flags.ConfigFile,
+		strings.Join(u.Inputs(), ", "), strings.Join(u.Outputs(), ", "))
+}
+
+// bundleDiscovery returns the --discover report. The input writes it to a file.
+func bundleDiscovery() ([]byte, error) {
+	dir, err := os.MkdirTemp("", "unpoller-discover")
+	if err != nil {
+		return nil, fmt.Errorf("discover: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "discovery.md")
+	if err := discover(path); err != nil {
+		return nil, err
+	}
+
+	report, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("discover: %w", err)
+	}
+
+	return report, nil
+}
+
+// bundleRaw adds the JSON of every filter of every unit of each RawDumper input.
+func (u *UnifiPoller) bundleRaw(b *bundle) {
+	inputSync.RLock()
+	defer inputSync.RUnlock()
+
+	for _, input := range inputs {
+		d, ok := input.Input.(RawDumper)
+		if !ok {
+			continue
+		}
+
+		for unit := range d.RawUnits() {
+			for _, kind := range d.RawKinds() {
+				name := fmt.Sprintf("raw/%s/%d/%s.json", input.Name, unit, kind)
+				u.LogDebugf("Support bundle: fetching %s", name)
+
+				raw, err := input.RawMetrics(&Filter{Kind: kind, Unit: unit})
+				if err == nil {
+					raw, err = RedactSecrets(raw)
+				}
+
+				b.addOrFail(name, raw, err)
+			}
+		}
+	}
+}
+
+// RedactSecrets replaces the values of secret keys in one or more JSON documents, like
+// the concatenated responses of several sites, and indents them. JSON that cannot be
+// parsed returns an error, as it cannot be redacted.
+func RedactSecrets(raw []byte) ([]byte, error) {
+	var out bytes.Buffer
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	enc := json.NewEncoder(&out)
+	enc.SetIndent("", "  ")
+
+	for {
+		var v any
+
+		if err := dec.Decode(&v); err == io.EOF { //nolint:errorlint // Decode returns it unwrapped.
+			return out.Bytes(), nil
+		} else if err != nil {
+			return nil, fmt.Errorf("redacting: %w", err) // not valid json, so it cannot be redacted.
+		}
+
+		if err := enc.Encode(redactValue(v)); err != nil {
+			return nil, fmt.Errorf("redacting: %w", err)
+		}
+	}
+}
+
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, val := range v {
+			if isSecretKey(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = redactValue(val)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+
+	return v
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasPrefix(key, "x_") {
+		return true
+	}
+
+	for _, part := range secretKeys {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bundle writes files into a tar.gz, and keeps the problems collecting them.
+type bundle struct {
+	gz       *gzip.Writer
+	tar      *tar.Writer
+	now      time.Time
+	err      error // the first write error; nothing is written after it.
+	problems []string
+}
+
+func newBundle(w io.Writer) *bundle {
+	gz := gzip.NewWriter(w)
+
+	return &bundle{gz: gz, tar: tar.NewWriter(gz), now: time.Now()}
+}
+
+func (b *bundle) add(name string, data []byte) {
+	if b.err != nil {
+		return
+	}
+
+	header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: b.now} //nolint:mnd
+
+	if b.err = b.tar.WriteHeader(header); b.err == nil {
+		_, b.err = b.tar.Write(data)
+	}
+}
+
+// addOrFail adds a file, or records why it could not be collected.
+func (b *bundle) addOrFail(name string, data []byte, err error) {
+	if err != nil {
+		b.fail(name, err)
+
+		return
+	}
+
+	b.add(name, data)
+}
+
+func (b *bundle) fail(what string, err error) {
+	b.problems = append(b.problems, what+": "+err.Error())
+}
+
+func (b *bundle) close() error {
+	if b.err != nil {
+		return b.err
+	}
+
+	if err := b.tar.Close(); err != nil {
+		return fmt.Errorf("closing tar: %w", err)
+	}
+
+	if err := b.gz.Close(); err != nil {
+		return fmt.Errorf("closing gzip: %w", err)
+	}
+
+	return nil
+}
+
+// syncBuffer is a bytes.Buffer that plugins may log to from several goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buf.Write(p) //nolint:wrapcheck
+}
+
+func (s *syncBuffer) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buf.Len()
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buf.String()
+}
@@ -0,0 +1,76 @@
+package poller
+
+import (
+	"fmt"
+	"time"
+)
+
+// Client event types. See ClientEvent.
+const (
+	ClientConnected    = "client_connected"
+	ClientDisconnected = "client_disconnected"
+	ClientRoamed       = "client_roamed"
+	ClientIPChanged    = "ip_changed"
+	ClientNew          = "client_new" // The first time a MAC is seen, ever.
+)
+
+// ClientEvent is a client session change that an input found by comparing two client
+// lists, so outputs get connects, disconnects and roams even when the controller's own
+// events are off or incomplete. Inputs put these into Events.Logs with native events.
+// The From fields hold the previous values on roams and IP changes.
+type ClientEvent struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	SourceName string    `json:"source"`
+	SiteName   string    `json:"site_name"`
+	Mac        string    `json:"mac"`
+	Name       string    `json:"name,omitempty"`
+	Hostname   string    `json:"hostname,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	FromIP     string    `json:"from_ip,omitempty"`
+	Wired      bool      `json:"wired"`
+	SSID       string    `json:"ssid,omitempty"`
+	ApName     string    `json:"ap_name,omitempty"`
+	FromApName string    `json:"from_ap_name,omitempty"`
+	Band       string    `json:"band,omitempty"` // 2.4, 5 or 6 (GHz).
+	FromBand   string    `json:"from_band,omitempty"`
+	Duration   int64     `json:"duration,omitempty"` // Session length in seconds, on disconnect.
+}
+
+// Msg returns a one line description of the event, for outputs that want one.
+func (e *ClientEvent) Msg() string {
+	name := e.Name
+	if name == "" {
+		name = e.Mac
+	}
+
+	switch e.Type {
+	case ClientConnected:
+		if e.Wired {
+			return fmt.Sprintf("Client %s connected (wired)", name)
+		}
+
+		return fmt.Sprintf("Client %s connected to %s on %s", name, e.ApName, e.SSID)
+	case ClientDisconnected:
+		return fmt.Sprintf("Client %s disconnected after %v", name, time.Duration(e.Duration)*time.Second)
+	case ClientRoamed:
+		return fmt.Sprintf("Client %s roamed from %s (%sGHz) to %s (%sGHz)",
+			name, e.FromApName, e.FromBand, e.ApName, e.Band)
+	case ClientIPChanged:
+		return fmt.Sprintf("Client %s changed IP from %s to %s", name, e.FromIP, e.IP)
+	case ClientNew:
+		return fmt.Sprintf("New client %s (%s) seen for the first time", name, e.Mac)
+	default:
+		return fmt.Sprintf("Client %s: %s", name, e.Type)
+	}
+}
+
+// ClientInventory counts the clients connected to a site, split by whether the input's
+// client inventory has known them for a while or first saw them recently. Inputs add
+// these to Metrics with Publish.
+type ClientInventory struct {
+	SourceName string `json:"source"`
+	SiteName   string `json:"site_name"`
+	Known      int    `json:"known"`
+	New        int    `json:"new"`
+}
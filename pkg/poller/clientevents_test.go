@@ -0,0 +1,31 @@
+package poller_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+func TestClientEventMsg(t *testing.T) {
+	t.Parallel()
+
+	roam := &poller.ClientEvent{
+		Type: poller.ClientRoamed, Mac: "aa:bb", Name: "laptop",
+		FromApName: "hall", FromBand: "2.4", ApName: "office", Band: "5",
+	}
+	assert.Equal(t, "Client laptop roamed from hall (2.4GHz) to office (5GHz)", roam.Msg())
+
+	gone := &poller.ClientEvent{Type: poller.ClientDisconnected, Mac: "aa:bb", Duration: 3720}
+	assert.Equal(t, "Client aa:bb disconnected after 1h2m0s", gone.Msg())
+
+	wired := &poller.ClientEvent{Type: poller.ClientConnected, Name: "nas", Wired: true}
+	assert.Equal(t, "Client nas connected (wired)", wired.Msg())
+}
+
+func TestClientEventMsgNew(t *testing.T) {
+	t.Parallel()
+
+	e := &poller.ClientEvent{Type: poller.ClientNew, Mac: "aa:bb", Name: "phone"}
+	assert.Equal(t, "New client phone (aa:bb) seen for the first time", e.Msg())
+}
@@ -3,35 +3,16 @@ package poller
 import (
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/term"
 )
 
-// PrintRawMetrics prints raw json from the UniFi Controller. This is currently
-// tied into the -j CLI arg, and is probably not very useful outside that context.
+// PrintRawMetrics prints raw json from an input, usually the UniFi Controller, or the
+// values of one metric kind. This is tied into the -j CLI arg. See DumpJSON.
 func (u *UnifiPoller) PrintRawMetrics() (err error) {
-	split := strings.SplitN(u.Flags.DumpJSON, " ", 2)
-	filter := &Filter{Kind: split[0]}
-
-	// Allows you to grab a controller other than 0 from config.
-	if split2 := strings.Split(filter.Kind, ":"); len(split2) > 1 {
-		filter.Kind = split2[0]
-		filter.Unit, _ = strconv.Atoi(split2[1])
-	}
-
-	// Used with "other"
-	if len(split) > 1 {
-		filter.Path = split[1]
-	}
-
-	// As of now we only have one input plugin, so target that [0].
-	m, err := inputs[0].RawMetrics(filter)
-	fmt.Println(string(m))
-
-	return err
+	return u.DumpJSON(os.Stdout, u.Flags.DumpJSON)
 }
 
 // PrintPasswordHash prints a bcrypt'd password. Useful for the web server.
@@ -217,6 +198,17 @@ func (u *UnifiPoller) RunDiscover() error {
 		outputPath = "api_endpoints_discovery.md"
 	}
 
+	if err := discover(outputPath); err != nil {
+		return err
+	}
+
+	u.Logf("Discovery report written to %s (share with maintainers for API/404 issues).", outputPath)
+
+	return nil
+}
+
+// discover writes a discovery report from the first input that implements Discoverer.
+func discover(outputPath string) error {
 	inputSync.RLock()
 	defer inputSync.RUnlock()
 
@@ -226,8 +218,6 @@ func (u *UnifiPoller) RunDiscover() error {
 				return fmt.Errorf("discover: %w", err)
 			}
 
-			u.Logf("Discovery report written to %s (share with maintainers for API/404 issues).", outputPath)
-
 			return nil
 		}
 	}
@@ -2,11 +2,13 @@ package poller
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path"
 	"plugin"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/pflag"
@@ -64,6 +66,12 @@ func DefaultObjPath() string {
 type UnifiPoller struct {
 	Flags *Flags
 	*Config
+	sched     *scheduler
+	schedOnce sync.Once
+	slog      *slog.Logger          // nil for the text log format.
+	logLevels map[string]slog.Level // from log_levels, by lowercase plugin name.
+	relabel   []*relabeler          // compiled from relabel.
+	recent    *recentLog            // the last log lines, with a state_dir.
 }
 
 // Flags represents the CLI args available and their settings.
@@ -76,6 +84,11 @@ type Flags struct {
 	Health         bool
 	Discover       bool
 	DiscoverOutput string
+	ValidateConfig bool
+	PrintConfig    bool
+	Once           bool
+	OnceWindow     time.Duration
+	SupportBundle  string
 	*pflag.FlagSet
 }
 
@@ -86,9 +99,14 @@ type ControllerStatus struct {
 	Source string
 	// Up is true when the last poll of this controller succeeded.
 	Up bool
+	// Throttled is true when the controller asked the input to back off, and some or
+	// all of its collections were skipped on the last poll.
+	Throttled bool
 }
 
 // Metrics is a type shared by the exporting and reporting packages.
+// Its []any fields are the collections that predate the kind registry; outputs still
+// read them by name. New collections are added to Extra with Publish instead.
 type Metrics struct {
 	TS                 time.Time
 	Sites              []any
@@ -131,11 +149,21 @@ type Metrics struct {
 	Countries            []any // *unifi.Country — country list for geo-filters (global)
 	// Added by the inputunas plugin:
 	UNASDevices []any // *unifi.UNASDevice — UNAS Pro storage console (one per configured device)
+	// Extra holds collections without a field of their own, by kind. See Publish.
+	Extra map[MetricKind][]any
+	// Labels are static labels by controller and site, added by inputs and relabel rules.
+	Labels Labels
 }
 
 // Events defines the type for log entries.
 type Events struct {
-	Logs []any
+	Logs   []any
+	Labels Labels // Static labels by controller and site, from inputs and relabel rules.
+	// Cursors are where event collection resumes once these logs are written. Inputs
+	// add the cursors AdvanceEvent returns; the core saves them when outputs wrote the logs.
+	Cursors []*EventCursor `json:"-"`
+	pending []*pendingCursors
+	inputs  []string // the name of the input each of Logs came from.
 }
 
 // Config represents the core library input data.
@@ -145,14 +173,22 @@ type Config struct {
 
 // Poller is the global config values.
 type Poller struct {
-	Plugins         []string `json:"plugins"           toml:"plugins"           xml:"plugin"            yaml:"plugins"`
-	Debug           bool     `json:"debug"             toml:"debug"             xml:"debug,attr"        yaml:"debug"`
-	Quiet           bool     `json:"quiet"             toml:"quiet"             xml:"quiet,attr"        yaml:"quiet"`
-	LogUnknownTypes bool     `json:"log_unknown_types" toml:"log_unknown_types" xml:"log_unknown_types" yaml:"log_unknown_types"`
+	Plugins         []string          `json:"plugins"           toml:"plugins"           xml:"plugin"            yaml:"plugins"           deprecated:"use the [exec] plugin, which runs outputs and inputs as programs"`
+	Debug           bool              `json:"debug"             toml:"debug"             xml:"debug,attr"        yaml:"debug"`
+	Quiet           bool              `json:"quiet"             toml:"quiet"             xml:"quiet,attr"        yaml:"quiet"`
+	LogUnknownTypes bool              `json:"log_unknown_types" toml:"log_unknown_types" xml:"log_unknown_types" yaml:"log_unknown_types"`
+	Interval        cnfg.Duration     `json:"interval"          toml:"interval"          xml:"interval"          yaml:"interval"`
+	ShutdownTimeout cnfg.Duration     `json:"shutdown_timeout"  toml:"shutdown_timeout"  xml:"shutdown_timeout"  yaml:"shutdown_timeout"`
+	WatchConfig     bool              `json:"watch_config"      toml:"watch_config"      xml:"watch_config"      yaml:"watch_config"`
+	LogFormat       string            `json:"log_format"        toml:"log_format"        xml:"log_format"        yaml:"log_format"`
+	LogLevels       map[string]string `json:"log_levels"        toml:"log_levels"        xml:"log_levels"        yaml:"log_levels"`
+	Relabel         []*RelabelRule    `json:"relabel"           toml:"relabel"           xml:"relabel"           yaml:"relabel"`
+	StateDir        string            `json:"state_dir"         toml:"state_dir"         xml:"state_dir"         yaml:"state_dir"`
 }
 
 // LoadPlugins reads-in dynamic shared libraries.
-// Not used very often, if at all.
+// Not used very often, if at all. Outputs run as programs by the exec output plugin
+// do not need to be built with the poller's toolchain and source, like these do.
 func (u *UnifiPoller) LoadPlugins() error {
 	for _, p := range u.Plugins {
 		name := strings.TrimSuffix(p, ".so") + ".so"
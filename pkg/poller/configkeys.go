@@ -0,0 +1,334 @@
+package poller
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// maxKeyDepth stops keyTree from recursing forever on self-referencing config types.
+const maxKeyDepth = 16
+
+// configKey is one key found in a config file. Array indexes are not part of the path,
+// so every [[unifi.controller]] has keys under unifi.controller.
+type configKey struct {
+	path []string
+	line int // zero if unknown.
+}
+
+func (k configKey) String() string {
+	return strings.Join(k.path, ".")
+}
+
+// keyTree is the set of keys the config structs accept, built from their struct tags.
+type keyTree struct {
+	keys map[string]*keyTree // lowercase; the decoders match keys without case.
+	// any is set for maps and interfaces, which accept keys we cannot know about.
+	any bool
+	// deprecated is the value of a `deprecated:"..."` struct tag, explaining what to use instead.
+	deprecated string
+}
+
+var textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// add merges the keys of a config type into the tree. tag selects the struct
+// tag (json, toml, xml or yaml) matching the format of the config file.
+func (k *keyTree) add(t reflect.Type, tag string, depth int) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+
+	switch {
+	case depth > maxKeyDepth:
+		k.any = true
+	case reflect.PointerTo(t).Implements(textUnmarshaler):
+		// Decoded from a single value, like cnfg.Duration.
+	case t.Kind() == reflect.Map, t.Kind() == reflect.Interface:
+		k.any = true
+	case t.Kind() == reflect.Struct:
+		for i := range t.NumField() {
+			k.addField(t.Field(i), tag, depth)
+		}
+	}
+}
+
+func (k *keyTree) addField(field reflect.StructField, tag string, depth int) {
+	name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+
+	switch {
+	case name == "-":
+		return
+	case field.Anonymous && name == "":
+		if field.Type.Kind() != reflect.Interface {
+			k.add(field.Type, tag, depth) // embedded struct fields are promoted.
+		}
+
+		return
+	case !field.IsExported():
+		return
+	case name == "" && tag == "yaml":
+		name = strings.ToLower(field.Name)
+	case name == "":
+		name = field.Name
+	}
+
+	if k.keys == nil {
+		k.keys = make(map[string]*keyTree)
+	}
+
+	name = strings.ToLower(name)
+	if k.keys[name] == nil {
+		k.keys[name] = &keyTree{}
+	}
+
+	if note := field.Tag.Get("deprecated"); note != "" {
+		k.keys[name].deprecated = note
+	}
+
+	k.keys[name].add(field.Type, tag, depth+1)
+}
+
+// find returns the node for a key path, and whether it is known. Keys below a
+// map or interface are known, but return the map's node.
+func (k *keyTree) find(path []string) (*keyTree, bool) {
+	node := k
+
+	for _, name := range path {
+		if node.any {
+			return node, true
+		}
+
+		next, ok := node.keys[strings.ToLower(name)]
+		if !ok {
+			return nil, false
+		}
+
+		node = next
+	}
+
+	return node, true
+}
+
+// configFormat returns the struct tag for a config file name. It picks the format
+// the same way cnfgfile.Unmarshal does, and defaults to TOML.
+func configFormat(fileName string) string {
+	switch lower := strings.ToLower(fileName); {
+	case strings.Contains(lower, ".json"):
+		return "json"
+	case strings.Contains(lower, ".xml"):
+		return "xml"
+	case strings.Contains(lower, ".yaml"), strings.Contains(lower, ".yml"):
+		return "yaml"
+	default:
+		return "toml"
+	}
+}
+
+// readConfigFile reads a config file, decompressing it if it is gzip or bzip2 compressed.
+func readConfigFile(fileName string) ([]byte, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	var reader io.Reader
+
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		if reader, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("decompressing config file: %w", err)
+		}
+	case bytes.HasPrefix(data, []byte("BZh")):
+		reader = bzip2.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+
+	if data, err = io.ReadAll(reader); err != nil {
+		return nil, fmt.Errorf("decompressing config file: %w", err)
+	}
+
+	return data, nil
+}
+
+// fileKeys returns every key in a config file, in the order they appear.
+func fileKeys(format string, data []byte) ([]configKey, error) {
+	switch format {
+	case "json":
+		return jsonKeys(data)
+	case "xml":
+		return xmlKeys(data)
+	case "yaml":
+		return yamlKeys(data)
+	default:
+		return tomlKeys(data)
+	}
+}
+
+// tomlKeys uses the toml decoder for the list of keys, which it returns in file order,
+// then finds the line each is on. The decoder does not expose key positions.
+func tomlKeys(data []byte) ([]configKey, error) {
+	var discard map[string]any
+
+	meta, err := toml.Decode(string(data), &discard)
+	if err != nil {
+		return nil, fmt.Errorf("parsing toml: %w", err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	keys := make([]configKey, 0, len(meta.Keys()))
+	cursor := 0
+
+	for _, key := range meta.Keys() {
+		name := regexp.QuoteMeta(key[len(key)-1])
+		// The key's last component, bare or quoted, followed by = or the ] of a table header.
+		re := regexp.MustCompile(`(^|[\s.\[])["']?` + name + `["']?\s*(=|\])`)
+		line := 0
+
+		for i := cursor; i < len(lines); i++ {
+			if text := strings.TrimSpace(lines[i]); !strings.HasPrefix(text, "#") && re.MatchString(text) {
+				line, cursor = i+1, i // keys of inline tables share a line.
+
+				break
+			}
+		}
+
+		keys = append(keys, configKey{path: slices.Clone(key), line: line})
+	}
+
+	return keys, nil
+}
+
+// jsonWalker collects the keys of a json document.
+type jsonWalker struct {
+	data []byte
+	dec  *json.Decoder
+	keys []configKey
+}
+
+func jsonKeys(data []byte) ([]configKey, error) {
+	w := &jsonWalker{data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+	if err := w.value(nil); err != nil {
+		return nil, fmt.Errorf("parsing json: %w", err)
+	}
+
+	return w.keys, nil
+}
+
+func (w *jsonWalker) line() int {
+	return bytes.Count(w.data[:w.dec.InputOffset()], []byte("\n")) + 1
+}
+
+func (w *jsonWalker) value(path []string) error {
+	tok, err := w.dec.Token()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for w.dec.More() {
+			if tok, err = w.dec.Token(); err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			key := append(slices.Clone(path), fmt.Sprint(tok))
+			w.keys = append(w.keys, configKey{path: key, line: w.line()})
+
+			if err := w.value(key); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for w.dec.More() {
+			if err := w.value(path); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+
+	_, err = w.dec.Token() // closing delimiter.
+
+	return err //nolint:wrapcheck
+}
+
+// xmlKeys returns the elements and attributes below the document's root element.
+func xmlKeys(data []byte) ([]configKey, error) {
+	var (
+		dec   = xml.NewDecoder(bytes.NewReader(data))
+		keys  []configKey
+		stack []string
+	)
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return keys, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("parsing xml: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			line, _ := dec.InputPos()
+
+			if len(stack) > 0 {
+				keys = append(keys, configKey{path: append(slices.Clone(stack[1:]), tok.Name.Local), line: line})
+			}
+
+			stack = append(stack, tok.Name.Local)
+
+			for _, attr := range tok.Attr {
+				keys = append(keys, configKey{path: append(slices.Clone(stack[1:]), attr.Name.Local), line: line})
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+func yamlKeys(data []byte) ([]configKey, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing yaml: %w", err)
+	}
+
+	var keys []configKey
+
+	var walk func(node *yaml.Node, path []string)
+
+	walk = func(node *yaml.Node, path []string) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, n := range node.Content {
+				walk(n, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := append(slices.Clone(path), node.Content[i].Value)
+				keys = append(keys, configKey{path: key, line: node.Content[i].Line})
+				walk(node.Content[i+1], key)
+			}
+		}
+	}
+
+	walk(&doc, nil)
+
+	return keys, nil
+}
@@ -0,0 +1,65 @@
+package poller
+
+import (
+	"fmt"
+	"time"
+)
+
+// Device event types. See DeviceEvent.
+const (
+	DeviceAdded         = "device_added"
+	DeviceRemoved       = "device_removed"
+	DeviceRebooted      = "device_rebooted"
+	DeviceUpgraded      = "device_upgraded"
+	DeviceStateChanged  = "device_state_changed"
+	DeviceUplinkChanged = "device_uplink_changed"
+)
+
+// DeviceEvent is a device lifecycle change that an input found by comparing two device
+// lists: a reboot (uptime went backwards), a firmware upgrade, a state change, a device
+// that appeared or disappeared, or a new uplink parent. Inputs put these into Events.Logs
+// with native events. The From fields hold the previous values.
+type DeviceEvent struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`
+	SourceName  string    `json:"source"`
+	SiteName    string    `json:"site_name"`
+	Mac         string    `json:"mac"`
+	Name        string    `json:"name,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	DeviceType  string    `json:"device_type,omitempty"` // uap, usw, ugw, udm...
+	Version     string    `json:"version,omitempty"`
+	FromVersion string    `json:"from_version,omitempty"`
+	State       string    `json:"state,omitempty"`
+	FromState   string    `json:"from_state,omitempty"`
+	Uplink      string    `json:"uplink,omitempty"` // Name, or MAC, of the uplink parent.
+	FromUplink  string    `json:"from_uplink,omitempty"`
+	Uptime      int64     `json:"uptime,omitempty"` // Seconds.
+	FromUptime  int64     `json:"from_uptime,omitempty"`
+}
+
+// Msg returns a one line description of the event, for outputs that want one.
+func (e *DeviceEvent) Msg() string {
+	name := e.Name
+	if name == "" {
+		name = e.Mac
+	}
+
+	switch e.Type {
+	case DeviceAdded:
+		return fmt.Sprintf("Device %s (%s) appeared", name, e.Model)
+	case DeviceRemoved:
+		return fmt.Sprintf("Device %s (%s) disappeared", name, e.Model)
+	case DeviceRebooted:
+		return fmt.Sprintf("Device %s rebooted; up %v, was up %v", name,
+			time.Duration(e.Uptime)*time.Second, time.Duration(e.FromUptime)*time.Second)
+	case DeviceUpgraded:
+		return fmt.Sprintf("Device %s upgraded from %s to %s", name, e.FromVersion, e.Version)
+	case DeviceStateChanged:
+		return fmt.Sprintf("Device %s changed state from %s to %s", name, e.FromState, e.State)
+	case DeviceUplinkChanged:
+		return fmt.Sprintf("Device %s changed uplink from %s to %s", name, e.FromUplink, e.Uplink)
+	default:
+		return fmt.Sprintf("Device %s: %s", name, e.Type)
+	}
+}
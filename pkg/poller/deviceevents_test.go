@@ -0,0 +1,18 @@
+package poller_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+func TestDeviceEventMsg(t *testing.T) {
+	t.Parallel()
+
+	reboot := &poller.DeviceEvent{Type: poller.DeviceRebooted, Name: "core", Uptime: 90, FromUptime: 86400}
+	assert.Equal(t, "Device core rebooted; up 1m30s, was up 24h0m0s", reboot.Msg())
+
+	upgrade := &poller.DeviceEvent{Type: poller.DeviceUpgraded, Mac: "aa:bb", FromVersion: "6.6.55", Version: "6.6.77"}
+	assert.Equal(t, "Device aa:bb upgraded from 6.6.55 to 6.6.77", upgrade.Msg())
+}
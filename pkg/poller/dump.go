@@ -0,0 +1,173 @@
+package poller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dumpEventWindow is how far back `--dumpjson "kind <name>"` collects events from inputs.
+const dumpEventWindow = time.Hour
+
+var (
+	// ErrDumpFilter is returned by DumpJSON for a filter it cannot serve.
+	ErrDumpFilter = errors.New("invalid dumpjson filter")
+	errNoInputs   = errors.New("no input plugins imported")
+)
+
+/* The --dumpjson filter is [input/]filter[:unit] [path]. The input is picked by name,
+   or else it is the first input whose RawKinds include the filter, or else the first
+   input. Two filters are handled by the core, for every input:
+     list         prints the inputs, the filters each serves, and every metric kind.
+     kind <name>  prints every collected value of a metric kind, ie. "kind alarm". */
+
+// DumpJSON writes the JSON that a --dumpjson filter selects to w. See PrintRawMetrics.
+func (u *UnifiPoller) DumpJSON(w io.Writer, arg string) error {
+	head, path, _ := strings.Cut(arg, " ")
+
+	name, head, ok := strings.Cut(head, "/")
+	if !ok {
+		head, name = name, ""
+	}
+
+	filter := &Filter{Kind: head, Path: path}
+
+	// Allows you to grab a controller other than 0 from config.
+	if kind, unit, ok := strings.Cut(head, ":"); ok {
+		filter.Kind = kind
+		filter.Unit, _ = strconv.Atoi(unit)
+	}
+
+	switch filter.Kind {
+	case "list":
+		return u.dumpList(w)
+	case "kind":
+		return u.dumpKind(w, name, MetricKind(path))
+	}
+
+	input, err := rawInput(name, filter.Kind)
+	if err != nil {
+		return err
+	}
+
+	m, err := input.RawMetrics(filter)
+	fmt.Fprintln(w, string(m))
+
+	return err //nolint:wrapcheck
+}
+
+// rawInput returns the input named name, or the first one that serves the filter kind.
+func rawInput(name, kind string) (*InputPlugin, error) {
+	inputSync.RLock()
+	defer inputSync.RUnlock()
+
+	if len(inputs) == 0 {
+		return nil, errNoInputs
+	}
+
+	for _, input := range inputs {
+		if name != "" && strings.EqualFold(input.Name, name) {
+			return input, nil
+		}
+	}
+
+	if name != "" {
+		return nil, fmt.Errorf("%w: no input named %q, see: --dumpjson list", ErrDumpFilter, name)
+	}
+
+	for _, input := range inputs {
+		if d, ok := input.Input.(RawDumper); ok && slices.Contains(d.RawKinds(), kind) {
+			return input, nil
+		}
+	}
+
+	return inputs[0], nil
+}
+
+// dumpInput describes what one input can dump, for --dumpjson list.
+type dumpInput struct {
+	Units   int      `json:"units,omitempty"`
+	Filters []string `json:"filters,omitempty"`
+}
+
+func (u *UnifiPoller) dumpList(w io.Writer) error {
+	list := struct {
+		Inputs map[string]dumpInput `json:"inputs"`
+		Kinds  []MetricKind         `json:"kinds"`
+	}{Inputs: make(map[string]dumpInput), Kinds: MetricKinds()}
+
+	inputSync.RLock()
+
+	for _, input := range inputs {
+		list.Inputs[input.Name] = dumpInput{}
+
+		if d, ok := input.Input.(RawDumper); ok {
+			list.Inputs[input.Name] = dumpInput{Units: d.RawUnits(), Filters: d.RawKinds()}
+		}
+	}
+
+	inputSync.RUnlock()
+
+	return writeJSON(w, list)
+}
+
+// dumpKind collects metrics and events from every input, or the one named, and
+// writes the values of one kind.
+func (u *UnifiPoller) dumpKind(w io.Writer, name string, kind MetricKind) error {
+	if !slices.Contains(MetricKinds(), kind) {
+		return fmt.Errorf("%w: unknown metric kind %q, see: --dumpjson list", ErrDumpFilter, kind)
+	}
+
+	metrics, metricsErr := u.Metrics(&Filter{Name: name})
+	events, eventsErr := u.Events(&Filter{Name: name, Dur: dumpEventWindow})
+
+	if err := writeJSON(w, kindValues(metrics, events, kind)); err != nil {
+		return err
+	}
+
+	return errors.Join(metricsErr, eventsErr)
+}
+
+// kindValues returns the values of one kind in metrics and events. Either may be nil.
+func kindValues(metrics *Metrics, events *Events, kind MetricKind) []any {
+	values := []any{}
+	collections := [][]any{}
+
+	if metrics != nil {
+		for _, f := range metrics.fields() {
+			collections = append(collections, *f)
+		}
+
+		collections = append(collections, metrics.Extra[kind])
+	}
+
+	if events != nil {
+		collections = append(collections, events.Logs)
+	}
+
+	for _, collection := range collections {
+		for _, v := range collection {
+			if KindOf(v) == kind {
+				values = append(values, v)
+			}
+		}
+	}
+
+	return values
+}
+
+func writeJSON(w io.Writer, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding json: %w", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(b))
+
+	return err //nolint:wrapcheck
+}
@@ -0,0 +1,173 @@
+package poller_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errDumpTest = errors.New("endpoint not found")
+
+type dumpWidget struct{ Name string }
+
+// dumpInput serves two raw filters for two controllers, and collects widgets.
+type dumpInput struct{ countingInput }
+
+func (*dumpInput) RawKinds() []string { return []string{"gadgets", "broken"} }
+
+func (*dumpInput) RawUnits() int { return 2 }
+
+func (*dumpInput) RawMetrics(filter *poller.Filter) ([]byte, error) {
+	if filter.Kind == "broken" {
+		return nil, errDumpTest
+	}
+
+	// One document per site, like the unifi input returns.
+	return []byte(`{"name":"ap","x_passphrase":"hunter2"}{"data":[{"api_key":"abc","mac":"00:01"}]}`), nil
+}
+
+func (d *dumpInput) Metrics(f *poller.Filter) (*poller.Metrics, error) {
+	m, _ := d.countingInput.Metrics(f)
+	poller.Publish(m, &dumpWidget{Name: "one"}, &dumpWidget{Name: "two"})
+
+	return m, nil
+}
+
+var dumpInputOnce sync.Once // nolint: gochecknoglobals
+
+// registerDumpInput registers the dump-test input, once for every test that uses it.
+func registerDumpInput() {
+	dumpInputOnce.Do(func() {
+		poller.RegisterKind[*dumpWidget]("dump_widget")
+		poller.NewInput(&poller.InputPlugin{Name: "dump-test", Input: &dumpInput{}, Config: &struct{}{}})
+	})
+}
+
+// Not parallel: registers an input with the global plugin list.
+func TestDumpJSON(t *testing.T) {
+	registerDumpInput()
+
+	u := poller.New()
+	u.Quiet = true
+
+	var buf bytes.Buffer
+
+	require.NoError(t, u.DumpJSON(&buf, "gadgets:1"))
+	assert.Contains(t, buf.String(), "hunter2", "the input serving a filter is found by its RawKinds")
+
+	buf.Reset()
+	require.NoError(t, u.DumpJSON(&buf, "dump-test/kind dump_widget"))
+	assert.JSONEq(t, `[{"Name":"one"},{"Name":"two"}]`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, u.DumpJSON(&buf, "list"))
+	assert.Contains(t, buf.String(), `"dump-test": {`)
+	assert.Contains(t, buf.String(), `"dump_widget"`)
+
+	require.ErrorIs(t, u.DumpJSON(&buf, "kind nothing"), poller.ErrDumpFilter)
+	require.ErrorIs(t, u.DumpJSON(&buf, "nothing/devices"), poller.ErrDumpFilter)
+	require.ErrorIs(t, u.DumpJSON(&buf, "dump-test/broken"), errDumpTest)
+}
+
+// Not parallel: registers an input, and replaces the log package's output.
+func TestWriteSupportBundle(t *testing.T) {
+	registerDumpInput()
+
+	writer, flags := log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+
+	log.SetOutput(io.Discard)
+
+	u := poller.New()
+	u.Flags.SupportBundle = filepath.Join(t.TempDir(), "bundle.tar.gz")
+
+	require.NoError(t, u.WriteSupportBundle())
+
+	files := readBundle(t, u.Flags.SupportBundle)
+
+	for _, name := range []string{"version.txt", "config.json", "debugio.txt", "log.txt", "errors.txt"} {
+		assert.Contains(t, files, name)
+	}
+
+	raw := files["raw/dump-test/1/gadgets.json"]
+	assert.Contains(t, raw, `"mac": "00:01"`, "every document of every controller is kept")
+	assert.NotContains(t, raw, "hunter2", "secrets are redacted")
+	assert.NotContains(t, raw, "abc", "secrets are redacted")
+
+	assert.NotContains(t, files, "raw/dump-test/0/broken.json")
+	assert.Contains(t, files["errors.txt"], "raw/dump-test/0/broken.json: "+errDumpTest.Error())
+	assert.Contains(t, files["errors.txt"], "discovery.md: discover: no input plugin supports discovery")
+	assert.Contains(t, files["errors.txt"], "recent.log: "+poller.ErrNoStateDir.Error())
+	assert.Contains(t, files["log.txt"], "Checking inputs...", "the --debugio checks are logged")
+	assert.Contains(t, files["debugio.txt"], "Checking inputs...")
+}
+
+// Not parallel: registers an input, and replaces the log package's output.
+func TestWriteSupportBundleRecentLog(t *testing.T) {
+	registerDumpInput()
+
+	writer, flags := log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+
+	log.SetOutput(io.Discard)
+
+	u := poller.New()
+	u.StateDir = t.TempDir()
+	u.Flags.SupportBundle = filepath.Join(t.TempDir(), "bundle.tar.gz")
+
+	recent := "[INFO] UniFi Poller Starting Up!\n[ERROR] Controller https://unifi Auth or Connection Error\n"
+	require.NoError(t, os.WriteFile(filepath.Join(u.StateDir, poller.RecentLogFile), []byte(recent), 0o600))
+	require.NoError(t, u.WriteSupportBundle())
+
+	files := readBundle(t, u.Flags.SupportBundle)
+	assert.Equal(t, recent, files["recent.log"], "the service's log lines are bundled")
+	assert.NotContains(t, files["errors.txt"], "recent.log")
+}
+
+// readBundle returns the files in a tar.gz, by name.
+func readBundle(t *testing.T, path string) map[string]string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err)
+
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files
+		}
+
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+
+		files[header.Name] = string(data)
+	}
+}
@@ -1,7 +1,9 @@
 package poller
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -24,11 +26,35 @@ type Input interface {
 	DebugInput() (bool, error)
 }
 
+// ContextInput is an optional interface for inputs that can stop collecting when
+// the context is canceled, ie. on shutdown. When an input implements it, the core
+// calls these methods instead of Metrics and Events.
+type ContextInput interface {
+	MetricsContext(context.Context, *Filter) (*Metrics, error)
+	EventsContext(context.Context, *Filter) (*Events, error)
+}
+
+// EventStreamer is an optional interface for inputs that receive events as they happen,
+// ie. from a controller websocket. When the outputs start, the core runs StreamEvents in
+// its own goroutine until the context is canceled. The input hands each batch of events
+// to push; they go through relabeling, then to every output that subscribed to events.
+type EventStreamer interface {
+	StreamEvents(ctx context.Context, push func(*Events))
+}
+
 // Discoverer is an optional interface for inputs that can discover API endpoints.
 type Discoverer interface {
 	Discover(outputPath string) error
 }
 
+// RawDumper is an optional interface for inputs whose RawMetrics serves a fixed set of
+// endpoints. --dumpjson uses it to find the input that serves a filter, and
+// --support-bundle uses it to dump every endpoint of every controller.
+type RawDumper interface {
+	RawKinds() []string // The filter kinds RawMetrics serves, without aliases.
+	RawUnits() int      // How many controllers (or consoles) Filter.Unit selects from.
+}
+
 // InputPlugin describes an input plugin's consumable interface.
 type InputPlugin struct {
 	Name   string
@@ -50,6 +76,9 @@ type Filter struct {
 	Skip bool
 	Time time.Time
 	Dur  time.Duration
+	// ack is set by callers that save the event cursors once outputs wrote the events.
+	// Without it, they are saved when the events are collected.
+	ack bool
 }
 
 // NewInput creates a metric input. This should be called by input plugins
@@ -100,7 +129,7 @@ func (u *UnifiPoller) InitializeInputs() error {
 			// This must return, or the app locks up here.
 			u.LogDebugf("inititalizing input... %s", input.Name)
 
-			if err := recoverInitialize(input, u); err != nil {
+			if err := recoverInitialize(input, u.PluginLogger(input.Name)); err != nil {
 				u.LogDebugf("error initializing input ... %s", input.Name)
 
 				errChan <- err
@@ -136,29 +165,36 @@ func (u *UnifiPoller) InitializeInputs() error {
 		}
 	}
 
-	u.LogDebugf("returning error: %w", err)
+	u.LogDebugf("returning error: %v", err)
 
 	return err
 }
 
 type eventInputResult struct {
-	logs []any
-	err  error
+	input   string
+	logs    []any
+	labels  Labels
+	cursors []*EventCursor
+	err     error
 }
 
 // recoverEvents runs input.Events and converts a panic into an error. See
 // recoverInitialize for why this is necessary.
-func recoverEvents(input *InputPlugin, filter *Filter) (e *Events, err error) {
+func recoverEvents(ctx context.Context, input *InputPlugin, filter *Filter) (e *Events, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("input plugin %s panicked collecting events: %v", input.Name, r) //nolint:err113
 		}
 	}()
 
+	if i, ok := input.Input.(ContextInput); ok {
+		return i.EventsContext(ctx, filter)
+	}
+
 	return input.Events(filter)
 }
 
-func collectEvents(filter *Filter, inputs []*InputPlugin) (*Events, error) {
+func collectEvents(ctx context.Context, filter *Filter, inputs []*InputPlugin) (*Events, error) {
 	resultChan := make(chan eventInputResult, len(inputs))
 	wg := &sync.WaitGroup{}
 
@@ -176,7 +212,10 @@ func collectEvents(filter *Filter, inputs []*InputPlugin) (*Events, error) {
 				return
 			}
 
-			e, err := recoverEvents(input, filter)
+			start := time.Now()
+			e, err := recoverEvents(ctx, input, filter)
+			RecordPoll(input.Name, "events", time.Since(start), err)
+
 			if err != nil {
 				resultChan <- eventInputResult{err: err}
 
@@ -189,7 +228,7 @@ func collectEvents(filter *Filter, inputs []*InputPlugin) (*Events, error) {
 				return
 			}
 
-			resultChan <- eventInputResult{logs: e.Logs}
+			resultChan <- eventInputResult{input: input.Name, logs: e.Logs, labels: e.Labels, cursors: e.Cursors}
 		}(input)
 	}
 
@@ -204,9 +243,12 @@ func collectEvents(filter *Filter, inputs []*InputPlugin) (*Events, error) {
 		if result.err != nil {
 			errs = append(errs, result.err)
 		} else if result.logs != nil {
-			// Logs is the only member to extend at this time.
 			events.Logs = append(events.Logs, result.logs...)
+			events.inputs = append(events.inputs, slices.Repeat([]string{result.input}, len(result.logs))...)
+			events.Labels = events.Labels.Merge(result.labels)
 		}
+
+		events.Cursors = append(events.Cursors, result.cursors...)
 	}
 
 	var err error
@@ -222,10 +264,24 @@ func collectEvents(filter *Filter, inputs []*InputPlugin) (*Events, error) {
 
 // Events aggregates log messages (events) from one or more sources.
 func (u *UnifiPoller) Events(filter *Filter) (*Events, error) {
+	return u.EventsContext(context.Background(), filter)
+}
+
+// EventsContext is Events with a context that is passed to inputs implementing ContextInput.
+func (u *UnifiPoller) EventsContext(ctx context.Context, filter *Filter) (*Events, error) {
 	inputSync.RLock()
 	defer inputSync.RUnlock()
 
-	return collectEvents(filter, inputs)
+	events, err := collectEvents(ctx, filter, inputs)
+	relabelEvents(u.relabel, events)
+
+	if filter == nil || !filter.ack {
+		if err := trackEvents(events, 0); err != nil {
+			u.LogErrorf("Saving event cursors: %v", err)
+		}
+	}
+
+	return events, err
 }
 
 type metricInputResult struct {
@@ -237,17 +293,21 @@ type metricInputResult struct {
 // from a malformed controller response, such as an unexpected Site Speed
 // Test aggregated-dashboard payload). See recoverInitialize for why this is
 // necessary.
-func recoverMetrics(input *InputPlugin, filter *Filter) (m *Metrics, err error) {
+func recoverMetrics(ctx context.Context, input *InputPlugin, filter *Filter) (m *Metrics, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("input plugin %s panicked collecting metrics: %v", input.Name, r) //nolint:err113
 		}
 	}()
 
+	if i, ok := input.Input.(ContextInput); ok {
+		return i.MetricsContext(ctx, filter)
+	}
+
 	return input.Metrics(filter)
 }
 
-func collectMetrics(filter *Filter, inputs []*InputPlugin) (*Metrics, error) {
+func collectMetrics(ctx context.Context, filter *Filter, inputs []*InputPlugin) (*Metrics, error) {
 	resultChan := make(chan metricInputResult, len(inputs))
 	wg := &sync.WaitGroup{}
 
@@ -265,7 +325,9 @@ func collectMetrics(filter *Filter, inputs []*InputPlugin) (*Metrics, error) {
 				return
 			}
 
-			m, err := recoverMetrics(input, filter)
+			start := time.Now()
+			m, err := recoverMetrics(ctx, input, filter)
+			RecordPoll(input.Name, "metrics", time.Since(start), err)
 			resultChan <- metricInputResult{metric: m, err: err}
 		}(input)
 	}
@@ -299,10 +361,18 @@ func collectMetrics(filter *Filter, inputs []*InputPlugin) (*Metrics, error) {
 // Metrics aggregates all the measurements from filtered inputs and returns them.
 // Passing a null filter returns everything!
 func (u *UnifiPoller) Metrics(filter *Filter) (*Metrics, error) {
+	return u.MetricsContext(context.Background(), filter)
+}
+
+// MetricsContext is Metrics with a context that is passed to inputs implementing ContextInput.
+func (u *UnifiPoller) MetricsContext(ctx context.Context, filter *Filter) (*Metrics, error) {
 	inputSync.RLock()
 	defer inputSync.RUnlock()
 
-	return collectMetrics(filter, inputs)
+	metrics, err := collectMetrics(ctx, filter, inputs)
+	relabelMetrics(u.relabel, metrics)
+
+	return metrics, err
 }
 
 // AppendMetrics combines the metrics from two sources.
@@ -328,44 +398,24 @@ func AppendMetrics(existing *Metrics, m *Metrics) *Metrics {
 		existing.TS = m.TS
 	}
 
-	existing.SitesDPI = append(existing.SitesDPI, m.SitesDPI...)
-	existing.Sites = append(existing.Sites, m.Sites...)
-	existing.ClientsDPI = append(existing.ClientsDPI, m.ClientsDPI...)
-	existing.RogueAPs = append(existing.RogueAPs, m.RogueAPs...)
-	existing.SpeedTests = append(existing.SpeedTests, m.SpeedTests...)
-	existing.Clients = append(existing.Clients, m.Clients...)
-	existing.Devices = append(existing.Devices, m.Devices...)
-	existing.CountryTraffic = append(existing.CountryTraffic, m.CountryTraffic...)
-	existing.DHCPLeases = append(existing.DHCPLeases, m.DHCPLeases...)
-	existing.WANConfigs = append(existing.WANConfigs, m.WANConfigs...)
-	existing.Sysinfos = append(existing.Sysinfos, m.Sysinfos...)
-	existing.FirewallPolicies = append(existing.FirewallPolicies, m.FirewallPolicies...)
-	existing.Topologies = append(existing.Topologies, m.Topologies...)
-	existing.PortAnomalies = append(existing.PortAnomalies, m.PortAnomalies...)
-	existing.VPNMeshes = append(existing.VPNMeshes, m.VPNMeshes...)
+	dst, src := existing.fields(), m.fields()
+	for i := range dst {
+		*dst[i] = append(*dst[i], *src[i]...)
+	}
+
 	existing.ControllerStatuses = append(existing.ControllerStatuses, m.ControllerStatuses...)
-	existing.WANStatuses = append(existing.WANStatuses, m.WANStatuses...)
-	existing.PortForwards = append(existing.PortForwards, m.PortForwards...)
-	existing.SSLCertificates = append(existing.SSLCertificates, m.SSLCertificates...)
-	existing.UPSDevices = append(existing.UPSDevices, m.UPSDevices...)
-	existing.IntegrationDevStats = append(existing.IntegrationDevStats, m.IntegrationDevStats...)
-	existing.WifiBroadcasts = append(existing.WifiBroadcasts, m.WifiBroadcasts...)
-	existing.FirewallZones = append(existing.FirewallZones, m.FirewallZones...)
-	existing.ACLRules = append(existing.ACLRules, m.ACLRules...)
-	existing.VPNServers = append(existing.VPNServers, m.VPNServers...)
-	existing.SiteToSiteTunnels = append(existing.SiteToSiteTunnels, m.SiteToSiteTunnels...)
-	existing.LAGs = append(existing.LAGs, m.LAGs...)
-	existing.MCLAGDomains = append(existing.MCLAGDomains, m.MCLAGDomains...)
-	existing.SwitchStacks = append(existing.SwitchStacks, m.SwitchStacks...)
-	existing.DNSPolicies = append(existing.DNSPolicies, m.DNSPolicies...)
-	existing.RADIUSProfiles = append(existing.RADIUSProfiles, m.RADIUSProfiles...)
-	existing.TrafficMatchingLists = append(existing.TrafficMatchingLists, m.TrafficMatchingLists...)
-	existing.HotspotVouchers = append(existing.HotspotVouchers, m.HotspotVouchers...)
-	existing.DPIApplications = append(existing.DPIApplications, m.DPIApplications...)
-	existing.DPICategories = append(existing.DPICategories, m.DPICategories...)
-	existing.PendingDevices = append(existing.PendingDevices, m.PendingDevices...)
-	existing.Countries = append(existing.Countries, m.Countries...)
-	existing.UNASDevices = append(existing.UNASDevices, m.UNASDevices...)
+
+	if len(m.Labels) > 0 {
+		existing.Labels = existing.Labels.Merge(m.Labels)
+	}
+
+	for kind, items := range m.Extra {
+		if existing.Extra == nil {
+			existing.Extra = make(map[MetricKind][]any)
+		}
+
+		existing.Extra[kind] = append(existing.Extra[kind], items...)
+	}
 
 	return existing
 }
@@ -1,6 +1,7 @@
 package poller_test
 
 import (
+	"context"
 	"reflect"
 	"testing"
 	"time"
@@ -155,3 +156,65 @@ func TestAppendMetricsPropagatesTS(t *testing.T) {
 	got = poller.AppendMetrics(&poller.Metrics{TS: first}, &poller.Metrics{TS: second})
 	assert.Equal(t, first, got.TS)
 }
+
+type ctxKey struct{}
+
+// contextInput implements poller.ContextInput and records the context it was handed.
+// Its plain Metrics and Events fail, so a test passes only if the core prefers the
+// context-aware methods.
+type contextInput struct {
+	nilEventsInput
+	got chan any
+}
+
+func (contextInput) Metrics(*poller.Filter) (*poller.Metrics, error) {
+	panic("Metrics called on a ContextInput")
+}
+
+func (contextInput) Events(*poller.Filter) (*poller.Events, error) {
+	panic("Events called on a ContextInput")
+}
+
+func (c contextInput) MetricsContext(ctx context.Context, _ *poller.Filter) (*poller.Metrics, error) {
+	c.got <- ctx.Value(ctxKey{})
+
+	return &poller.Metrics{}, nil
+}
+
+func (c contextInput) EventsContext(ctx context.Context, _ *poller.Filter) (*poller.Events, error) {
+	c.got <- ctx.Value(ctxKey{})
+
+	return &poller.Events{}, nil
+}
+
+func TestCollectPrefersContextInput(t *testing.T) {
+	t.Parallel()
+
+	input := contextInput{got: make(chan any, 2)}
+	collector := poller.NewTestCollector(t)
+	collector.AddInput(&poller.InputPlugin{Name: "context-input", Input: input})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	_, err := collector.MetricsContext(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "value", <-input.got)
+
+	// Plain Metrics/Events still reach the context method, with a background context.
+	_, err = collector.Events(nil)
+	require.NoError(t, err)
+	assert.Nil(t, <-input.got)
+}
+
+func TestAppendMetricsMergesLabels(t *testing.T) {
+	t.Parallel()
+
+	hq := poller.LabelScope{Source: "https://10.1.1.1", Site: "HQ"}
+	guest := poller.LabelScope{Source: "https://10.1.1.2", Site: "guest"}
+
+	got := poller.AppendMetrics(&poller.Metrics{}, &poller.Metrics{Labels: poller.Labels{hq: {"env": "prod"}}})
+	got = poller.AppendMetrics(got, &poller.Metrics{Labels: poller.Labels{guest: {"pii": "dropped"}}})
+
+	assert.Equal(t, map[string]string{"env": "prod"}, got.Labels.For(hq.Source, hq.Site))
+	assert.Equal(t, map[string]string{"pii": "dropped"}, got.Labels.For(guest.Source, guest.Site))
+}
@@ -0,0 +1,315 @@
+package poller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrUnknownKind is returned when decoding a kind that no input registered.
+	ErrUnknownKind = errors.New("no input registered this metric kind")
+	// ErrUnknownField is returned when adding values to a field Metrics does not have.
+	ErrUnknownField = errors.New("metrics have no collection field")
+)
+
+// MetricKind names one type of data an input collects, ie. "uap", "client" or "event".
+// Inputs register a kind for each Go type they put into Metrics or Events, and outputs
+// register a handler for each type they export. The core compares the two at startup.
+type MetricKind string
+
+// metricKinds maps each registered type to its kind.
+var metricKinds = struct { // nolint: gochecknoglobals
+	sync.RWMutex
+	byType map[reflect.Type]MetricKind
+}{byType: make(map[reflect.Type]MetricKind)}
+
+// RegisterKind declares that an input's values of type T are the metric kind named kind.
+// Inputs should call this in init() for every type they collect. Registering a type
+// again replaces its kind.
+func RegisterKind[T any](kind MetricKind) {
+	metricKinds.Lock()
+	defer metricKinds.Unlock()
+
+	metricKinds.byType[reflect.TypeFor[T]()] = kind
+}
+
+// KindOf returns the metric kind of a value. Values of a type no input registered
+// are named by their Go type, ie. "*unifi.Widget".
+func KindOf(v any) MetricKind {
+	return kindOfType(reflect.TypeOf(v))
+}
+
+func kindOfType(t reflect.Type) MetricKind {
+	metricKinds.RLock()
+	defer metricKinds.RUnlock()
+
+	if kind, ok := metricKinds.byType[t]; ok {
+		return kind
+	}
+
+	return MetricKind(fmt.Sprint(t))
+}
+
+// MetricKinds returns every registered kind, sorted.
+func MetricKinds() []MetricKind {
+	metricKinds.RLock()
+	defer metricKinds.RUnlock()
+
+	kinds := slices.Collect(maps.Values(metricKinds.byType))
+	slices.Sort(kinds)
+
+	return slices.Compact(kinds)
+}
+
+// DecodeKind decodes JSON into a new value of the type an input registered for kind,
+// like a *unifi.Client for "client". Inputs that get their values from elsewhere, like
+// the exec input, use it to make values that outputs export like any other.
+func DecodeKind(kind MetricKind, data []byte) (any, error) {
+	t, ok := typeOfKind(kind)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKind, kind)
+	}
+
+	v := reflect.New(t)
+	if t.Kind() == reflect.Pointer {
+		v = reflect.New(t.Elem())
+	}
+
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", kind, err)
+	}
+
+	if t.Kind() == reflect.Pointer {
+		return v.Interface(), nil
+	}
+
+	return v.Elem().Interface(), nil
+}
+
+// typeOfKind returns the type registered for a kind. With more than one, the first by
+// name is returned, so the choice does not change from run to run.
+func typeOfKind(kind MetricKind) (reflect.Type, bool) {
+	metricKinds.RLock()
+	defer metricKinds.RUnlock()
+
+	var found reflect.Type
+
+	for t, k := range metricKinds.byType {
+		if k == kind && (found == nil || t.String() < found.String()) {
+			found = t
+		}
+	}
+
+	return found, found != nil
+}
+
+// Add appends values to the collection field named as it is in Go, like "Clients". With
+// no field, values are added to Extra by their kind, like Publish does.
+func (m *Metrics) Add(field string, items ...any) error {
+	if field == "" {
+		if m.Extra == nil && len(items) > 0 {
+			m.Extra = make(map[MetricKind][]any)
+		}
+
+		for _, item := range items {
+			kind := KindOf(item)
+			m.Extra[kind] = append(m.Extra[kind], item)
+		}
+
+		return nil
+	}
+
+	f := reflect.ValueOf(m).Elem().FieldByName(field)
+	if !f.IsValid() || f.Type() != reflect.TypeFor[[]any]() {
+		return fmt.Errorf("%w: %s", ErrUnknownField, field)
+	}
+
+	f.Set(reflect.AppendSlice(f, reflect.ValueOf(items)))
+
+	return nil
+}
+
+// Publish adds values to a Metrics collection by kind. Use it for collections that
+// have no field in Metrics; they are kept in Extra, merged by AppendMetrics, and
+// included in Collections, so the core and outputs need no changes to carry them.
+func Publish[T any](m *Metrics, items ...T) {
+	if len(items) == 0 {
+		return
+	}
+
+	kind := kindOfType(reflect.TypeFor[T]())
+
+	if m.Extra == nil {
+		m.Extra = make(map[MetricKind][]any)
+	}
+
+	for _, item := range items {
+		m.Extra[kind] = append(m.Extra[kind], item)
+	}
+}
+
+// KindExporter is an optional interface for output plugins. When an enabled output
+// implements it, the core logs at startup which registered kinds it does not export,
+// instead of the output dropping them silently. Handlers.Kinds returns this list.
+type KindExporter interface {
+	ExportedKinds() []MetricKind
+}
+
+// unexportedKinds returns the registered kinds missing from exported.
+func unexportedKinds(exported []MetricKind) []MetricKind {
+	missing := []MetricKind{}
+
+	for _, kind := range MetricKinds() {
+		if !slices.Contains(exported, kind) {
+			missing = append(missing, kind)
+		}
+	}
+
+	return missing
+}
+
+// Handlers routes collected values to an output's exporters by type. R is whatever
+// the output passes along with each value, usually its report. The zero value is
+// ready to use; add handlers with Handle and HandleAll.
+type Handlers[R any] struct {
+	byType map[reflect.Type]func(R, []any)
+	always []reflect.Type // HandleAll types, called even when none were collected.
+}
+
+// Handle registers fn to export each value of type T.
+func Handle[R, T any](h *Handlers[R], fn func(R, T)) {
+	h.set(reflect.TypeFor[T](), func(r R, items []any) {
+		for _, item := range items {
+			fn(r, item.(T)) //nolint:forcetypeassert // Export groups items by this type.
+		}
+	})
+}
+
+// HandleAll registers fn to export every value of type T in one call,
+// for exporters that need the whole collection, ie. to compute totals.
+// fn is called on every export, with no values when none were collected,
+// so totals go back to zero instead of going stale.
+func HandleAll[R, T any](h *Handlers[R], fn func(R, []T)) {
+	h.always = append(h.always, reflect.TypeFor[T]())
+	h.set(reflect.TypeFor[T](), func(r R, items []any) {
+		all := make([]T, len(items))
+		for i, item := range items {
+			all[i] = item.(T) //nolint:forcetypeassert // Export groups items by this type.
+		}
+
+		fn(r, all)
+	})
+}
+
+func (h *Handlers[R]) set(t reflect.Type, fn func(R, []any)) {
+	if h.byType == nil {
+		h.byType = make(map[reflect.Type]func(R, []any))
+	}
+
+	h.byType[t] = fn
+}
+
+// Export passes every value in the collections to its type's handler. Handlers are
+// called once per type, in the order each type first appears, then HandleAll handlers
+// without values. Values without a handler are returned, so the output may log them.
+func (h *Handlers[R]) Export(r R, collections ...[]any) []any {
+	var (
+		order   []reflect.Type
+		grouped = make(map[reflect.Type][]any)
+		unknown []any
+	)
+
+	for _, items := range collections {
+		for _, item := range items {
+			t := reflect.TypeOf(item)
+			if _, ok := h.byType[t]; !ok {
+				unknown = append(unknown, item)
+				continue
+			}
+
+			if _, ok := grouped[t]; !ok {
+				order = append(order, t)
+			}
+
+			grouped[t] = append(grouped[t], item)
+		}
+	}
+
+	for _, t := range h.always {
+		if _, ok := grouped[t]; !ok {
+			order = append(order, t)
+		}
+	}
+
+	for _, t := range order {
+		h.byType[t](r, grouped[t])
+	}
+
+	return unknown
+}
+
+// Kinds returns the kinds these handlers export, sorted. Use it to implement KindExporter.
+func (h *Handlers[R]) Kinds() []MetricKind {
+	kinds := make([]MetricKind, 0, len(h.byType))
+	for t := range h.byType {
+		kinds = append(kinds, kindOfType(t))
+	}
+
+	slices.Sort(kinds)
+
+	return slices.Compact(kinds)
+}
+
+// metricsFields are the indexes of the []any fields of Metrics: the collections that
+// predate the kind registry. They are found once, so a new field needs no other change.
+var metricsFields = func() []int { // nolint: gochecknoglobals
+	var (
+		t      = reflect.TypeFor[Metrics]()
+		fields = []int{}
+	)
+
+	for i := range t.NumField() {
+		if f := t.Field(i); f.IsExported() && f.Type == reflect.TypeFor[[]any]() {
+			fields = append(fields, i)
+		}
+	}
+
+	return fields
+}()
+
+// fields returns every []any field in m, in the order they are declared.
+// AppendMetrics merges these, and Collections returns them.
+func (m *Metrics) fields() []*[]any {
+	v := reflect.ValueOf(m).Elem()
+	fields := make([]*[]any, len(metricsFields))
+
+	for i, index := range metricsFields {
+		fields[i] = v.Field(index).Addr().Interface().(*[]any) //nolint:forcetypeassert // picked by type.
+	}
+
+	return fields
+}
+
+// Collections returns every collection in m for Handlers.Export, Extra last in kind
+// order. SitesDPI and ClientsDPI are left out: both hold the same type, and outputs
+// export them differently, so they read those two fields directly.
+func (m *Metrics) Collections() [][]any {
+	collections := make([][]any, 0, len(m.Extra)+len(m.fields()))
+
+	for _, f := range m.fields() {
+		if f != &m.SitesDPI && f != &m.ClientsDPI {
+			collections = append(collections, *f)
+		}
+	}
+
+	for _, kind := range slices.Sorted(maps.Keys(m.Extra)) {
+		collections = append(collections, m.Extra[kind])
+	}
+
+	return collections
+}
@@ -0,0 +1,132 @@
+package poller_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	testGauge   struct{ v int }
+	testCounter struct{ v int }
+	testWidget  struct{}
+	testUnknown struct{}
+)
+
+func TestHandlersExportByType(t *testing.T) {
+	t.Parallel()
+
+	poller.RegisterKind[*testGauge]("test_gauge")
+	poller.RegisterKind[*testCounter]("test_counter")
+
+	var (
+		h        poller.Handlers[*[]int]
+		counters int
+	)
+
+	poller.Handle(&h, func(got *[]int, g *testGauge) { *got = append(*got, g.v) })
+	poller.HandleAll(&h, func(got *[]int, c []*testCounter) { counters = len(c) })
+
+	got := []int{}
+	other := &testUnknown{}
+	unknown := h.Export(&got,
+		[]any{&testGauge{1}, &testCounter{}, other},
+		[]any{&testGauge{2}, &testCounter{}},
+	)
+
+	assert.Equal(t, []int{1, 2}, got)
+	assert.Equal(t, 2, counters, "HandleAll gets every value of its type in one call")
+	assert.Equal(t, []any{other}, unknown)
+	assert.Equal(t, []poller.MetricKind{"test_counter", "test_gauge"}, h.Kinds())
+	assert.Equal(t, poller.MetricKind("*poller_test.testUnknown"), poller.KindOf(other))
+	assert.Subset(t, poller.MetricKinds(), h.Kinds())
+
+	counters = -1
+	h.Export(&got, []any{&testGauge{3}})
+	assert.Zero(t, counters, "HandleAll is called without values, so totals go back to zero")
+}
+
+func TestPublishKeepsExtraCollections(t *testing.T) {
+	t.Parallel()
+
+	poller.RegisterKind[*testWidget]("test_widget")
+
+	existing, incoming := &poller.Metrics{}, &poller.Metrics{}
+	poller.Publish(existing, &testWidget{})
+	poller.Publish(incoming, &testWidget{}, &testWidget{})
+
+	got := poller.AppendMetrics(existing, incoming)
+	assert.Len(t, got.Extra["test_widget"], 3)
+
+	got.SitesDPI = []any{"dpi"}
+	got.Sites = []any{"site"}
+
+	var all []any
+	for _, c := range got.Collections() {
+		all = append(all, c...)
+	}
+
+	assert.Len(t, all, 4, "Collections has Sites and Extra, but not SitesDPI")
+	assert.Equal(t, "site", all[0])
+}
+
+type testDecoded struct {
+	Name string `json:"name"`
+}
+
+func TestDecodeKindAndAdd(t *testing.T) {
+	t.Parallel()
+
+	poller.RegisterKind[*testDecoded]("test_decoded")
+
+	v, err := poller.DecodeKind("test_decoded", []byte(`{"name":"ap"}`))
+	require.NoError(t, err)
+	assert.Equal(t, &testDecoded{Name: "ap"}, v, "values decode into the registered type")
+
+	_, err = poller.DecodeKind("test_never_registered", []byte(`{}`))
+	require.ErrorIs(t, err, poller.ErrUnknownKind)
+
+	_, err = poller.DecodeKind("test_decoded", []byte(`[`))
+	require.Error(t, err)
+
+	m := &poller.Metrics{}
+	require.NoError(t, m.Add("Clients", v, v))
+	require.NoError(t, m.Add("", v))
+	assert.Len(t, m.Clients, 2)
+	assert.Equal(t, []any{v}, m.Extra["test_decoded"], "without a field, values go in Extra by kind")
+
+	require.ErrorIs(t, m.Add("TS", v), poller.ErrUnknownField, "only collections can be added to")
+	require.ErrorIs(t, m.Add("Widgets", v), poller.ErrUnknownField)
+}
+
+func TestAppendMetricsMergesEveryField(t *testing.T) {
+	t.Parallel()
+
+	var (
+		m      = &poller.Metrics{}
+		fields = 0
+	)
+
+	for _, f := range reflect.VisibleFields(reflect.TypeFor[poller.Metrics]()) {
+		if f.Type == reflect.TypeFor[[]any]() {
+			require.NoError(t, m.Add(f.Name, f.Name))
+
+			fields++
+		}
+	}
+
+	merged := poller.AppendMetrics(&poller.Metrics{}, m)
+	got := 0
+
+	for _, c := range merged.Collections() {
+		got += len(c)
+	}
+
+	// SitesDPI and ClientsDPI are read directly, not through Collections.
+	assert.Equal(t, fields-2, got, "every []any field is merged, and returned by Collections")
+	assert.Equal(t, []any{"SitesDPI"}, merged.SitesDPI)
+}
@@ -1,13 +1,32 @@
 package poller
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"slices"
+	"strings"
 )
 
 // Log the command that called these commands.
 const callDepth = 2
 
+// Log formats for log_format. Text is the original [LEVEL] message format, which
+// goes through the log package. Logfmt and JSON are structured, through log/slog.
+const (
+	LogFormatText   = "text"
+	LogFormatLogfmt = "logfmt"
+	LogFormatJSON   = "json"
+)
+
+var (
+	ErrLogFormat = fmt.Errorf("log_format must be %q, %q or %q", LogFormatText, LogFormatLogfmt, LogFormatJSON)
+	ErrLogLevel  = errors.New("log level must be debug, info, warn or error")
+)
+
 // Logger is passed into input packages so they may write logs.
 type Logger interface {
 	Logf(m string, v ...any)
@@ -15,21 +34,210 @@ type Logger interface {
 	LogDebugf(m string, v ...any)
 }
 
+// FieldLogger is a Logger that can attach fields, like controller or site, to its
+// entries. The Logger and Collect the core passes to plugins implement it, and
+// already carry the plugin's name. Use With to add fields to any Logger.
+type FieldLogger interface {
+	Logger
+	With(args ...any) Logger
+}
+
+// With returns a Logger that adds args, as key/value pairs like slog.Logger.With,
+// to every entry. If l cannot carry fields, l is returned and the fields are dropped.
+func With(l Logger, args ...any) Logger {
+	if f, ok := l.(FieldLogger); ok {
+		return f.With(args...)
+	}
+
+	return l
+}
+
+// SetupLogging applies log_format and log_levels from the poller config. Structured
+// entries are written to w, as is the output of the log package's default logger.
+// The text format keeps writing through the log package, without touching it.
+func (u *UnifiPoller) SetupLogging(w io.Writer) error {
+	levels, err := parseLogLevels(u.LogLevels)
+	if err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug} // levels are checked before the handler.
+
+	switch strings.ToLower(u.LogFormat) {
+	case "", LogFormatText:
+		u.slog = nil
+	case LogFormatLogfmt:
+		u.slog = slog.New(slog.NewTextHandler(w, opts))
+	case LogFormatJSON:
+		u.slog = slog.New(slog.NewJSONHandler(w, opts))
+	default:
+		return fmt.Errorf("%w, not %q", ErrLogFormat, u.LogFormat)
+	}
+
+	u.logLevels = levels
+
+	if u.slog != nil {
+		slog.SetDefault(u.slog) // picks up log.Printf from anywhere, including libraries.
+	}
+
+	return nil
+}
+
+// parseLogLevels parses log_levels, whose keys are plugin names.
+func parseLogLevels(config map[string]string) (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level, len(config))
+
+	for plugin, name := range config {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			return nil, fmt.Errorf("log_levels.%s: %w, not %q", plugin, ErrLogLevel, name)
+		}
+
+		levels[strings.ToLower(plugin)] = level
+	}
+
+	return levels, nil
+}
+
+// logLevel returns the lowest level logged for a plugin. A plugin's entry in log_levels
+// wins; otherwise quiet logs only errors, and debug logs everything.
+func (u *UnifiPoller) logLevel(plugin string) slog.Level {
+	if level, ok := u.logLevels[strings.ToLower(plugin)]; ok && plugin != "" {
+		return level
+	}
+
+	switch {
+	case u.Quiet:
+		return slog.LevelError
+	case u.Debug:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// output writes one log entry. It must be called directly by a Log*f method, so the
+// text format's file:line (in debug mode) points at that method's caller.
+func (u *UnifiPoller) output(level slog.Level, plugin string, attrs []any, m string, v ...any) {
+	if level < u.logLevel(plugin) {
+		return
+	}
+
+	if u.slog != nil {
+		if plugin != "" {
+			attrs = append([]any{"plugin", plugin}, attrs...)
+		}
+
+		u.slog.Log(context.Background(), level, fmt.Sprintf(m, v...), attrs...)
+
+		return
+	}
+
+	prefix := "[INFO] "
+
+	switch level {
+	case slog.LevelDebug:
+		prefix = "[DEBUG] "
+	case slog.LevelError:
+		prefix = "[ERROR] "
+	}
+
+	_ = log.Output(callDepth+1, prefix+fmt.Sprintf(m, v...)+textFields(attrs))
+}
+
+// textFields formats fields for the text format, the way logfmt would.
+func textFields(attrs []any) string {
+	var buf strings.Builder
+
+	for _, attr := range slog.Group("", attrs...).Value.Group() {
+		value := attr.Value.String()
+		if strings.ContainsAny(value, " =\"") || value == "" {
+			value = fmt.Sprintf("%q", value)
+		}
+
+		buf.WriteString(" " + attr.Key + "=" + value)
+	}
+
+	return buf.String()
+}
+
 // Logf prints a log entry if quiet is false.
 func (u *UnifiPoller) Logf(m string, v ...any) {
-	if !u.Quiet {
-		_ = log.Output(callDepth, fmt.Sprintf("[INFO] "+m, v...))
-	}
+	u.output(slog.LevelInfo, "", nil, m, v...)
 }
 
 // LogDebugf prints a debug log entry if debug is true and quite is false.
 func (u *UnifiPoller) LogDebugf(m string, v ...any) {
-	if u.Debug && !u.Quiet {
-		_ = log.Output(callDepth, fmt.Sprintf("[DEBUG] "+m, v...))
-	}
+	u.output(slog.LevelDebug, "", nil, m, v...)
 }
 
 // LogErrorf prints an error log entry.
 func (u *UnifiPoller) LogErrorf(m string, v ...any) {
-	_ = log.Output(callDepth, fmt.Sprintf("[ERROR] "+m, v...))
+	u.output(slog.LevelError, "", nil, m, v...)
+}
+
+// With returns a Logger for the core that adds fields to every entry.
+func (u *UnifiPoller) With(args ...any) Logger {
+	return &pluginLogger{u: u, attrs: args}
+}
+
+// PluginLogger returns the Logger the core hands to a plugin. Its entries carry the
+// plugin's name, and are filtered by the plugin's entry in log_levels.
+func (u *UnifiPoller) PluginLogger(name string) FieldLogger {
+	return &pluginLogger{u: u, plugin: name}
+}
+
+// pluginLogger logs for one plugin, with optional fields.
+type pluginLogger struct {
+	u      *UnifiPoller
+	plugin string
+	attrs  []any
+}
+
+func (l *pluginLogger) Logf(m string, v ...any) {
+	l.u.output(slog.LevelInfo, l.plugin, l.attrs, m, v...)
+}
+
+func (l *pluginLogger) LogErrorf(m string, v ...any) {
+	l.u.output(slog.LevelError, l.plugin, l.attrs, m, v...)
+}
+
+func (l *pluginLogger) LogDebugf(m string, v ...any) {
+	l.u.output(slog.LevelDebug, l.plugin, l.attrs, m, v...)
+}
+
+func (l *pluginLogger) With(args ...any) Logger {
+	return &pluginLogger{u: l.u, plugin: l.plugin, attrs: append(slices.Clip(l.attrs), args...)}
+}
+
+// pluginCollect is the Collect handed to an output plugin. Logging goes through the
+// plugin's logger; everything else, including the optional interfaces, to the poller.
+type pluginCollect struct {
+	*UnifiPoller
+	log *pluginLogger
+}
+
+// collectFor returns the Collect to hand to the named output plugin.
+func collectFor(c Collect, name string) Collect {
+	if u, ok := c.(*UnifiPoller); ok {
+		return &pluginCollect{UnifiPoller: u, log: &pluginLogger{u: u, plugin: name}}
+	}
+
+	return c
+}
+
+func (p *pluginCollect) Logf(m string, v ...any) {
+	p.UnifiPoller.output(slog.LevelInfo, p.log.plugin, nil, m, v...)
+}
+
+func (p *pluginCollect) LogErrorf(m string, v ...any) {
+	p.UnifiPoller.output(slog.LevelError, p.log.plugin, nil, m, v...)
+}
+
+func (p *pluginCollect) LogDebugf(m string, v ...any) {
+	p.UnifiPoller.output(slog.LevelDebug, p.log.plugin, nil, m, v...)
+}
+
+func (p *pluginCollect) With(args ...any) Logger {
+	return p.log.With(args...)
 }
@@ -0,0 +1,93 @@
+package poller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupLogging runs SetupLogging into a buffer, and puts the log package back after the test.
+func setupLogging(t *testing.T, u *poller.UnifiPoller) *bytes.Buffer {
+	t.Helper()
+
+	defaultLogger, writer, flags := slog.Default(), log.Writer(), log.Flags()
+
+	t.Cleanup(func() {
+		slog.SetDefault(defaultLogger)
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, u.SetupLogging(buf))
+
+	return buf
+}
+
+// Not parallel: replaces the default logger.
+func TestJSONLogsCarryPluginAndFields(t *testing.T) {
+	u := poller.New()
+	u.LogFormat = poller.LogFormatJSON
+	u.LogLevels = map[string]string{"Noisy": "error", "unifi": "debug"}
+	buf := setupLogging(t, u)
+
+	poller.With(u.PluginLogger("unifi"), "controller", "https://udm", "site", "default").LogDebugf("polled %d devices", 3)
+	u.PluginLogger("noisy").Logf("not logged: below the plugin's level")
+	u.PluginLogger("noisy").LogErrorf("logged")
+	u.LogDebugf("not logged: debug is off")
+	log.Printf("from the log package")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3, buf.String())
+
+	var entry map[string]any
+
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "DEBUG", entry["level"])
+	assert.Equal(t, "polled 3 devices", entry["msg"])
+	assert.Equal(t, "unifi", entry["plugin"])
+	assert.Equal(t, "https://udm", entry["controller"])
+	assert.Equal(t, "default", entry["site"])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.Equal(t, "noisy", entry["plugin"])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &entry))
+	assert.Equal(t, "from the log package", entry["msg"])
+}
+
+// Not parallel: replaces the default logger.
+func TestTextLogsKeepTheirFormat(t *testing.T) {
+	u := poller.New()
+	setupLogging(t, u)
+
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	log.SetFlags(0)
+
+	u.PluginLogger("unifi").Logf("plain")
+	poller.With(u.PluginLogger("unifi"), "site", "Main Office").LogErrorf("with a field")
+
+	assert.Equal(t, "[INFO] plain\n[ERROR] with a field site=\"Main Office\"\n", buf.String())
+}
+
+func TestSetupLoggingRejectsBadConfig(t *testing.T) {
+	t.Parallel()
+
+	u := poller.New()
+	u.LogFormat = "xml"
+	require.ErrorIs(t, u.SetupLogging(&bytes.Buffer{}), poller.ErrLogFormat)
+
+	u = poller.New()
+	u.LogLevels = map[string]string{"unifi": "loud"}
+	require.ErrorIs(t, u.SetupLogging(&bytes.Buffer{}), poller.ErrLogLevel)
+}
@@ -0,0 +1,217 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultOnceWindow is how far back --once collects events from inputs without an
+// event cursor. Run from cron, set it to the cron interval, or set a state_dir.
+const DefaultOnceWindow = 5 * time.Minute
+
+var (
+	errNoOnceOutputs = errors.New("no enabled output can write once; --once needs a push output like influxdb")
+	errOnceInputs    = errors.New("polling inputs")
+)
+
+// OnceOutput is an optional interface for push outputs that can write a single snapshot
+// and return, for --once. WriteOnce writes snap, flushes, closes its clients and returns
+// any error from doing so. ctx expires after the shutdown_timeout. Pull outputs, like
+// prometheus, and outputs without it are skipped in --once mode.
+type OnceOutput interface {
+	WriteOnce(ctx context.Context, c Collect, snap *Snapshot) error
+}
+
+// InputFilter is an optional interface for outputs that only write what one input
+// collects, like the Input of their Subscription. --once polls that input for them.
+type InputFilter interface {
+	InputName() string
+}
+
+// RunOnce polls the inputs once, hands the snapshot to every enabled output that
+// implements OnceOutput, waits for them to flush and returns. The error joins every
+// input and output error, so the process exits non-zero if anything failed.
+func (u *UnifiPoller) RunOnce() error {
+	if err := u.LoadState(); err != nil {
+		return err
+	}
+
+	if err := u.InitializeInputs(); err != nil {
+		return err
+	}
+
+	defer u.closeInputs()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	window := u.Flags.OnceWindow
+	if window <= 0 {
+		window = DefaultOnceWindow
+	}
+
+	// Each input is polled once, and outputs reading one input are handed its part.
+	// Polling again for them would get no events from inputs with cursors on.
+	parts := make(map[string]*Snapshot)
+	poll := func(input string) *Snapshot {
+		if parts[input] == nil {
+			snap := &Snapshot{Start: time.Now()}
+			snap.Metrics, snap.MetricsErr = u.MetricsContext(ctx, &Filter{Name: input})
+			snap.Events, snap.EventsErr = u.EventsContext(ctx, &Filter{Name: input, Dur: window, ack: true})
+			parts[input] = snap
+		}
+
+		return parts[input]
+	}
+
+	names := u.Inputs()
+
+	outputSync.RLock()
+	defer outputSync.RUnlock()
+
+	err := writeOnce(ctx, outputs, u, u, names, poll)
+	if err == nil {
+		u.Logf("Poll and write complete")
+	}
+
+	return err
+}
+
+// writeOnce hands each enabled output implementing OnceOutput a snapshot of the
+// inputs it reads, one at a time. poll returns the snapshot of one of the named
+// inputs, and is called once for each input an output reads. The event cursors of an
+// input are saved if every output given its events wrote them, so a run that failed to
+// write events collects them again when re-run.
+func writeOnce(ctx context.Context, outputs []*Output, l Logger, c Collect,
+	names []string, poll func(input string) *Snapshot,
+) error {
+	var (
+		errs    []error
+		failed  = make(map[*Snapshot]error)
+		order   = []*Snapshot{}
+		written = 0
+	)
+
+	part := func(input string) *Snapshot {
+		snap := poll(input)
+		if _, ok := failed[snap]; ok {
+			return snap
+		}
+
+		failed[snap], order = nil, append(order, snap)
+
+		if snap.MetricsErr != nil {
+			errs = append(errs, fmt.Errorf("%w: %s metrics: %w", errOnceInputs, input, snap.MetricsErr))
+		}
+
+		if snap.EventsErr != nil {
+			errs = append(errs, fmt.Errorf("%w: %s events: %w", errOnceInputs, input, snap.EventsErr))
+		}
+
+		// Write what was collected even when some inputs failed, like the poll loops do
+		// when one of several controllers is down. Outputs range over these.
+		if snap.Metrics == nil {
+			snap.Metrics = &Metrics{TS: snap.Start}
+		}
+
+		if snap.Events == nil {
+			snap.Events = &Events{}
+		}
+
+		snap.MetricsErr, snap.EventsErr = nil, nil
+
+		return snap
+	}
+
+	for _, o := range outputs {
+		if o == nil || !o.Enabled() {
+			continue
+		}
+
+		w, ok := o.OutputPlugin.(OnceOutput)
+		if !ok {
+			l.Logf("Output %s cannot write once, skipped", o.Name)
+
+			continue
+		}
+
+		written++
+
+		input := ""
+		if f, ok := o.OutputPlugin.(InputFilter); ok {
+			input = f.InputName()
+		}
+
+		read := []*Snapshot{}
+
+		for _, name := range names {
+			if input == "" || strings.EqualFold(input, name) {
+				read = append(read, part(name))
+			}
+		}
+
+		if err := writeOutputOnce(ctx, w, collectFor(c, o.Name), joinSnapshots(read)); err != nil {
+			l.LogErrorf("Output %s: %v", o.Name, err)
+			errs = append(errs, fmt.Errorf("output %s: %w", o.Name, err))
+
+			for _, snap := range read {
+				failed[snap] = err
+			}
+		}
+	}
+
+	for _, snap := range order {
+		if err := trackEvents(snap.Events, 1); err != nil {
+			errs = append(errs, fmt.Errorf("saving event cursors: %w", err))
+		}
+
+		if err := wroteEvents(snap.Events, failed[snap]); err != nil {
+			errs = append(errs, fmt.Errorf("saving event cursors: %w", err))
+		}
+	}
+
+	if written == 0 {
+		errs = append(errs, errNoOnceOutputs)
+	}
+
+	return errors.Join(errs...)
+}
+
+// joinSnapshots returns one snapshot of the inputs in parts. It is the only part
+// itself when there is one, so outputs reading the same inputs share it.
+func joinSnapshots(parts []*Snapshot) *Snapshot {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	joined := &Snapshot{Start: time.Now(), Metrics: &Metrics{}, Events: &Events{}}
+
+	for _, snap := range parts {
+		if snap.Start.Before(joined.Start) {
+			joined.Start = snap.Start
+		}
+
+		joined.Metrics = AppendMetrics(joined.Metrics, snap.Metrics)
+		joined.Events = mergeEvents(joined.Events, snap.Events)
+	}
+
+	if joined.Metrics.TS.IsZero() {
+		joined.Metrics.TS = joined.Start
+	}
+
+	return joined
+}
+
+// writeOutputOnce gives one output the shutdown_timeout to write and flush.
+func writeOutputOnce(ctx context.Context, w OnceOutput, c Collect, snap *Snapshot) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Poller().shutdownTimeout())
+	defer cancel()
+
+	return w.WriteOnce(ctx, c, snap)
+}
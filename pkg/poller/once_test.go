@@ -0,0 +1,171 @@
+package poller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errOnceTest = errors.New("once test failure")
+
+// onceInput fails its events poll when fail is set.
+type onceInput struct {
+	countingInput
+	fail bool
+}
+
+func (o *onceInput) Events(f *poller.Filter) (*poller.Events, error) {
+	if o.fail {
+		return nil, errOnceTest
+	}
+
+	return o.countingInput.Events(f)
+}
+
+// pullOutput is an output without WriteOnce, like prometheus.
+type pullOutput struct{ enabled bool }
+
+func (*pullOutput) Run(poller.Collect) error   { return nil }
+func (p *pullOutput) Enabled() bool            { return p.enabled }
+func (*pullOutput) DebugOutput() (bool, error) { return true, nil }
+
+// onceOutput records the snapshots it is handed, and returns err for each.
+type onceOutput struct {
+	pullOutput
+	snaps []*poller.Snapshot
+	err   error
+}
+
+func (o *onceOutput) WriteOnce(ctx context.Context, _ poller.Collect, snap *poller.Snapshot) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("WriteOnce needs a deadline to flush by") //nolint:err113
+	}
+
+	o.snaps = append(o.snaps, snap)
+
+	return o.err
+}
+
+// Not parallel: registers an input and outputs with the global plugin lists.
+func TestRunOnce(t *testing.T) {
+	input := &onceInput{}
+	pull := &pullOutput{enabled: true}
+	push := &onceOutput{pullOutput: pullOutput{enabled: true}}
+
+	poller.NewInput(&poller.InputPlugin{Name: "once-test", Input: input, Config: &struct{}{}})
+	poller.NewOutput(&poller.Output{Name: "once-pull", OutputPlugin: pull, Config: &struct{}{}})
+	poller.NewOutput(&poller.Output{Name: "once-push", OutputPlugin: push, Config: &struct{}{}})
+
+	t.Cleanup(func() { pull.enabled, push.enabled, input.fail = false, false, false })
+
+	u := poller.New()
+	u.Quiet = true
+	u.Flags.Once = true
+
+	require.NoError(t, u.RunOnce())
+	require.Len(t, push.snaps, 1, "each push output writes once")
+	assert.Equal(t, int64(1), input.metrics.Load(), "the inputs are polled once")
+	assert.NotNil(t, push.snaps[0].Metrics)
+	assert.WithinDuration(t, time.Now(), push.snaps[0].Start, time.Minute)
+
+	push.err = errOnceTest
+	require.ErrorIs(t, u.RunOnce(), errOnceTest, "an output failure is returned")
+
+	push.err, input.fail = nil, true
+	err := u.RunOnce()
+	require.ErrorContains(t, err, errOnceTest.Error(), "an input failure is returned")
+	require.Len(t, push.snaps, 3, "what was collected is still written")
+	assert.NotNil(t, push.snaps[2].Events, "outputs are not handed nil events")
+
+	input.fail, push.enabled = false, false
+	require.Error(t, u.RunOnce(), "--once fails without an output that can write once")
+}
+
+// onceCursorInput returns one event, with its cursor, each poll.
+type onceCursorInput struct {
+	countingInput
+	at time.Time
+}
+
+func (o *onceCursorInput) Events(*poller.Filter) (*poller.Events, error) {
+	if o.at.IsZero() {
+		return &poller.Events{}, nil
+	}
+
+	cursor := poller.AdvanceEvent("https://once", "default", "event", o.at)
+
+	return &poller.Events{Logs: []any{o.at}, Cursors: []*poller.EventCursor{cursor}}, nil
+}
+
+// Not parallel: registers an input and an output with the global plugin lists.
+func TestRunOnceSavesCursorsOnceWritten(t *testing.T) {
+	input := &onceCursorInput{at: time.Now().UTC().Round(time.Second)}
+	push := &onceOutput{pullOutput: pullOutput{enabled: true}, err: errOnceTest}
+
+	poller.NewInput(&poller.InputPlugin{Name: "once-cursor", Input: input, Config: &struct{}{}})
+	poller.NewOutput(&poller.Output{Name: "once-cursor-push", OutputPlugin: push, Config: &struct{}{}})
+
+	t.Cleanup(func() {
+		push.enabled, input.at = false, time.Time{}
+		_ = poller.New().LoadState()
+	})
+
+	dir := t.TempDir()
+	u := poller.New()
+	u.Quiet = true
+	u.Flags.Once = true
+	u.StateDir = dir
+
+	require.ErrorIs(t, u.RunOnce(), errOnceTest)
+	assert.Zero(t, savedEvent(t, dir, "default"), "a failed run does not save its cursors, so a re-run collects the events")
+
+	push.err = nil
+	require.NoError(t, u.RunOnce())
+	require.Len(t, push.snaps, 2)
+	assert.Equal(t, []any{input.at}, push.snaps[1].Events.Logs, "the re-run collects the events again")
+	assert.Equal(t, input.at, savedEvent(t, dir, "default"))
+}
+
+// inputOnceOutput is a onceOutput that writes what one input collects.
+type inputOnceOutput struct {
+	onceOutput
+	input string
+}
+
+func (o *inputOnceOutput) InputName() string { return o.input }
+
+// Not parallel: registers an input and outputs with the global plugin lists.
+func TestRunOncePollsEachInputOnce(t *testing.T) {
+	input := &onceCursorInput{at: time.Now().UTC().Round(time.Second)}
+	every := &onceOutput{pullOutput: pullOutput{enabled: true}}
+	one := &inputOnceOutput{onceOutput: onceOutput{pullOutput: pullOutput{enabled: true}}, input: "once-shared"}
+
+	poller.NewInput(&poller.InputPlugin{Name: "once-shared", Input: input, Config: &struct{}{}})
+	poller.NewOutput(&poller.Output{Name: "once-every", OutputPlugin: every, Config: &struct{}{}})
+	poller.NewOutput(&poller.Output{Name: "once-one", OutputPlugin: one, Config: &struct{}{}})
+
+	t.Cleanup(func() {
+		every.enabled, one.enabled, input.at = false, false, time.Time{}
+		_ = poller.New().LoadState()
+	})
+
+	dir := t.TempDir()
+	u := poller.New()
+	u.Quiet = true
+	u.Flags.Once = true
+	u.StateDir = dir
+
+	require.NoError(t, u.RunOnce())
+	assert.Equal(t, int64(1), input.metrics.Load(), "outputs of every input and of one input share its poll")
+	require.Len(t, one.snaps, 1)
+	require.Len(t, every.snaps, 1)
+	assert.Equal(t, []any{input.at}, one.snaps[0].Events.Logs, "the output of one input gets its events")
+	assert.Contains(t, every.snaps[0].Events.Logs, input.at, "and so does the output of every input")
+	assert.Equal(t, input.at, savedEvent(t, dir, "default"))
+}
@@ -1,8 +1,10 @@
 package poller
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 )
 
 var (
@@ -32,12 +34,27 @@ type OutputPlugin interface {
 	DebugOutput() (bool, error)
 }
 
+// ContextOutputPlugin is an optional interface for outputs that return when the
+// context is canceled, ie. on shutdown. When an output implements it, the core
+// calls RunContext instead of Run.
+type ContextOutputPlugin interface {
+	RunContext(context.Context, Collect) error
+}
+
+// CollectContext is implemented by the Collect passed into output plugins.
+// Outputs use it to hand their context down to inputs implementing ContextInput.
+type CollectContext interface {
+	MetricsContext(context.Context, *Filter) (*Metrics, error)
+	EventsContext(context.Context, *Filter) (*Events, error)
+}
+
 // Output defines the output data for a metric exporter like influx or prometheus.
 // Output packages should call NewOutput with this struct in init().
 type Output struct {
 	Name   string
 	Config any // Each config is passed into an unmarshaller later.
 	OutputPlugin
+	started atomic.Bool // set once Run is called; only running outputs are reloaded.
 }
 
 // NewOutput should be called by each output package's init function.
@@ -71,51 +88,87 @@ func (u *UnifiPoller) Poller() Poller {
 // InitializeOutputs runs all the configured output plugins.
 // If none exist, or they all exit an error is returned.
 func (u *UnifiPoller) InitializeOutputs() error {
-	count, errChan := u.runOutputMethods()
-	defer close(errChan)
+	return u.InitializeOutputsContext(context.Background())
+}
+
+// InitializeOutputsContext is InitializeOutputs with a context. Canceling it stops the
+// poll scheduler and every output that implements ContextOutputPlugin.
+func (u *UnifiPoller) InitializeOutputsContext(ctx context.Context) error {
+	u.startScheduler(ctx)
+	u.startStreams(ctx)
 
+	count, running, errChan := u.runOutputMethods(ctx)
 	if count == 0 {
 		return errNoOutputPlugins
 	}
 
 	// Wait for and return an error from any output plugin.
-	for err := range errChan {
-		if err != nil {
-			return err
-		}
-
-		if count--; count == 0 {
-			return errAllOutputStopped
+	for {
+		select {
+		case err := <-errChan:
+			if err != nil {
+				return err
+			}
+
+			if count--; count == 0 {
+				return errAllOutputStopped
+			}
+		case <-ctx.Done():
+			u.waitOutputs(running)
+
+			return nil
 		}
 	}
-
-	return nil
 }
 
-func (u *UnifiPoller) runOutputMethods() (int, chan error) {
+func (u *UnifiPoller) runOutputMethods(ctx context.Context) (int, *sync.WaitGroup, chan error) {
 	outputSync.RLock()
 	defer outputSync.RUnlock()
 
-	return runOutputMethods(outputs, u, u)
+	return runOutputMethods(ctx, outputs, u, u)
 }
 
-func runOutputMethods(outputs []*Output, l Logger, c Collect) (int, chan error) {
-	// Output plugin errors go into this channel.
-	err := make(chan error)
+// runOutputMethods starts every enabled output. The WaitGroup tracks the outputs
+// that implement ContextOutputPlugin, the ones that return when ctx is canceled.
+func runOutputMethods(ctx context.Context, outputs []*Output, l Logger, c Collect) (int, *sync.WaitGroup, chan error) {
+	// Output plugin errors go into this channel. It is buffered and never closed,
+	// so outputs that return after we stopped listening do not block or panic.
+	err := make(chan error, len(outputs))
+	running := &sync.WaitGroup{}
 
 	for _, o := range outputs {
 		if o != nil && o.Enabled() {
 			l.LogDebugf("output plugin enabled, starting run loop for %s", o.Name)
-			
+			o.started.Store(true)
+
+			if k, ok := o.OutputPlugin.(KindExporter); ok {
+				if missing := unexportedKinds(k.ExportedKinds()); len(missing) > 0 {
+					l.Logf("%s output does not export these metric kinds: %v", o.Name, missing)
+				}
+			}
+
+			p, ok := o.OutputPlugin.(ContextOutputPlugin)
+			if ok {
+				running.Add(1)
+			}
+
 			go func(o *Output) {
-				err <- o.Run(c) // Run each output plugin
+				if ok {
+					defer running.Done()
+
+					err <- p.RunContext(ctx, collectFor(c, o.Name))
+
+					return
+				}
+
+				err <- o.Run(collectFor(c, o.Name)) // Run each output plugin
 			}(o)
 		} else {
 			l.LogDebugf("output plugin disabled for %s", o.Name)
 		}
 	}
 
-	return len(outputs), err
+	return len(outputs), running, err
 }
 
 // Outputs allows other output plugins to see the list of loaded output plugins.
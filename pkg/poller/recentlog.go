@@ -0,0 +1,118 @@
+package poller
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecentLogFile is the file in the [poller] state_dir that holds the service's last
+// log lines. --support-bundle adds it to the bundle, as the bundle is made by another
+// process that cannot see the service's log.
+const RecentLogFile = "unpoller-recent.log"
+
+const (
+	// maxRecentLogLines is how many log lines RecentLogFile keeps.
+	maxRecentLogLines = 1000
+	// recentLogInterval is how often RecentLogFile is written, when there are new lines.
+	recentLogInterval = 30 * time.Second
+)
+
+// recentLog keeps the last log lines, and writes them to a file in the state_dir.
+type recentLog struct {
+	mu      sync.Mutex
+	path    string
+	lines   []string
+	partial []byte // a line without its newline yet.
+	changed bool
+}
+
+// keepRecentLog copies the log to a recentLog when there is a state_dir, and returns
+// the writer to log to. It replaces the log package's output, like SetupLogging.
+func (u *UnifiPoller) keepRecentLog(w io.Writer) io.Writer {
+	if u.StateDir == "" {
+		return w
+	}
+
+	u.recent = &recentLog{path: filepath.Join(u.StateDir, RecentLogFile)}
+	w = io.MultiWriter(w, u.recent)
+	log.SetOutput(w)
+
+	return w
+}
+
+func (r *recentLog) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data := append(r.partial, p...) //nolint:gocritic
+	r.partial = nil
+
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			r.partial = append([]byte(nil), data...)
+			break
+		}
+
+		r.lines = append(r.lines, string(data[:i]))
+		data = data[i+1:]
+		r.changed = true
+	}
+
+	if over := len(r.lines) - maxRecentLogLines; over > 0 {
+		r.lines = append(r.lines[:0], r.lines[over:]...)
+	}
+
+	return len(p), nil
+}
+
+// keep writes the recent log lines to the state_dir every recentLogInterval while
+// there are new ones, until ctx is canceled.
+func (r *recentLog) keep(ctx context.Context) {
+	if r == nil {
+		return
+	}
+
+	ticker := time.NewTicker(recentLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.save()
+		}
+	}
+}
+
+// save writes the recent log lines to the state_dir, if there are new ones.
+func (r *recentLog) save() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+
+	if !r.changed {
+		r.mu.Unlock()
+		return
+	}
+
+	data := []byte(strings.Join(r.lines, "\n") + "\n")
+	r.changed = false
+	r.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o700); err != nil { //nolint:mnd
+		return // logging this would add a line to save.
+	}
+
+	_ = writeFile(r.path, data)
+}
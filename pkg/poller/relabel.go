@@ -0,0 +1,411 @@
+package poller
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+)
+
+// Relabel actions. See RelabelRule.
+const (
+	RelabelReplace = "replace"
+	RelabelDrop    = "drop"
+	RelabelKeep    = "keep"
+	RelabelLabel   = "label"
+)
+
+// ErrRelabel is returned for a relabel rule that cannot be used.
+var ErrRelabel = errors.New("invalid relabel rule")
+
+// relabelFields maps the label names relabel rules use to the struct fields they read
+// and write. A value without the field is left alone by the rule.
+var relabelFields = map[string]string{ // nolint: gochecknoglobals
+	"site_name": "SiteName",
+	"source":    "SourceName",
+	"name":      "Name",
+	"mac":       "Mac",
+	"hostname":  "Hostname",
+	"ip":        "IP",
+}
+
+// RelabelRule is one step of the relabel stage. The rules run in order on every
+// collection of metrics and events, before any output sees it, so every output gets
+// the same result. Like Prometheus relabel_configs, Regex must match a whole value.
+//
+//   - replace: set Label to Replacement ($1 expands to the first group) when it matches.
+//   - drop: remove values whose Label matches.
+//   - keep: remove values whose Label does not match.
+//   - label: add Labels to every value from a matching controller or site. Label
+//     must be "source" or "site_name". These run after the other rules.
+//
+// Kinds limits the rule to those metric kinds, ie. ["client"]; empty means every kind.
+type RelabelRule struct {
+	Action      string            `json:"action"      toml:"action"      xml:"action,attr" yaml:"action"`
+	Label       string            `json:"label"       toml:"label"       xml:"label"       yaml:"label"`
+	Regex       string            `json:"regex"       toml:"regex"       xml:"regex"       yaml:"regex"`
+	Replacement string            `json:"replacement" toml:"replacement" xml:"replacement" yaml:"replacement"`
+	Kinds       []MetricKind      `json:"kinds"       toml:"kinds"       xml:"kind"        yaml:"kinds"`
+	Labels      map[string]string `json:"labels"      toml:"labels"      xml:"labels"      yaml:"labels"`
+}
+
+// relabeler is a RelabelRule with its defaults applied and its regex compiled.
+type relabeler struct {
+	*RelabelRule
+	action string
+	field  string
+	re     *regexp.Regexp
+}
+
+// LabelScope is a controller (source) and site that static labels apply to.
+type LabelScope struct {
+	Source string
+	Site   string
+}
+
+// Labels are static labels by controller and site, added by inputs and relabel rules.
+type Labels map[LabelScope]map[string]string
+
+// For returns the labels for a controller and site. Safe to call on nil Labels.
+func (l Labels) For(source, site string) map[string]string {
+	return l[LabelScope{Source: source, Site: site}]
+}
+
+// Merge adds the labels in other to l, and returns l; a nil l is made first.
+// Labels in other replace those in l with the same name and scope.
+func (l Labels) Merge(other Labels) Labels {
+	if l == nil {
+		l = Labels{}
+	}
+
+	for scope, labels := range other {
+		if l[scope] == nil {
+			l[scope] = make(map[string]string, len(labels))
+		}
+
+		maps.Copy(l[scope], labels)
+	}
+
+	return l
+}
+
+// compileRelabel checks the rules and compiles their regular expressions.
+func compileRelabel(rules []*RelabelRule) ([]*relabeler, error) {
+	compiled := make([]*relabeler, 0, len(rules))
+
+	for i, rule := range rules {
+		r := &relabeler{RelabelRule: rule, action: rule.Action, field: relabelFields[rule.Label]}
+		if r.action == "" {
+			r.action = RelabelReplace
+		}
+
+		regex := rule.Regex
+		if regex == "" {
+			regex = "(.*)"
+		}
+
+		var err error
+		if r.re, err = regexp.Compile("^(?:" + regex + ")$"); err != nil {
+			return nil, fmt.Errorf("%w %d: regex: %w", ErrRelabel, i+1, err)
+		}
+
+		switch {
+		case !slices.Contains([]string{RelabelReplace, RelabelDrop, RelabelKeep, RelabelLabel}, r.action):
+			return nil, fmt.Errorf("%w %d: unknown action %q", ErrRelabel, i+1, rule.Action)
+		case r.field == "":
+			return nil, fmt.Errorf("%w %d: label must be one of %v, not %q",
+				ErrRelabel, i+1, slices.Sorted(maps.Keys(relabelFields)), rule.Label)
+		case r.action == RelabelLabel && rule.Label != "source" && rule.Label != "site_name":
+			return nil, fmt.Errorf("%w %d: the label action matches source or site_name, not %q",
+				ErrRelabel, i+1, rule.Label)
+		case r.action == RelabelLabel && len(rule.Labels) == 0:
+			return nil, fmt.Errorf("%w %d: the label action needs labels to add", ErrRelabel, i+1)
+		}
+
+		compiled = append(compiled, r)
+	}
+
+	return compiled, nil
+}
+
+// SetupRelabel compiles the relabel rules from the config.
+// Called by Start() after the config is parsed.
+func (u *UnifiPoller) SetupRelabel() error {
+	rules, err := compileRelabel(u.Relabel)
+	if err != nil {
+		return err
+	}
+
+	u.relabel = rules
+
+	return nil
+}
+
+// relabeled is the result of the rules for one value from an input: the value to
+// pass on, which is a copy if a rule rewrote it, and whether it was kept.
+type relabeled struct {
+	value any
+	keep  bool
+}
+
+// relabelMetrics runs the relabel rules on m. The collections in m are replaced; the
+// values in them belong to the inputs, which may keep them between polls, so values a
+// rule rewrites are copied first.
+func relabelMetrics(rules []*relabeler, m *Metrics) {
+	if len(rules) == 0 || m == nil {
+		return
+	}
+
+	all := [][]any{}
+	for _, f := range m.fields() {
+		all = append(all, *f)
+	}
+
+	for _, items := range m.Extra {
+		all = append(all, items)
+	}
+
+	scopes := inputScopes(m.Labels, all...)
+	done := make(map[any]relabeled)
+	all = all[:0]
+
+	for _, f := range m.fields() {
+		*f = relabelValues(rules, *f, done)
+		all = append(all, *f)
+	}
+
+	for kind, items := range m.Extra {
+		m.Extra[kind] = relabelValues(rules, items, done)
+		all = append(all, m.Extra[kind])
+	}
+
+	m.Labels = followLabels(m.Labels, scopes, done).Merge(relabelLabels(rules, all...))
+}
+
+// relabelEvents runs the relabel rules on e, like relabelMetrics.
+func relabelEvents(rules []*relabeler, e *Events) {
+	if len(rules) == 0 || e == nil {
+		return
+	}
+
+	var (
+		scopes = inputScopes(e.Labels, e.Logs)
+		done   = make(map[any]relabeled)
+		from   = sources(e)
+		logs   = make([]any, 0, len(e.Logs))
+	)
+
+	e.inputs = make([]string, 0, len(e.Logs))
+
+	// One at a time, so the input each kept log came from stays beside it.
+	for i, log := range e.Logs {
+		kept := relabelValues(rules, []any{log}, done)
+		logs = append(logs, kept...)
+
+		if len(kept) > 0 {
+			e.inputs = append(e.inputs, from[i])
+		}
+	}
+
+	e.Logs = logs
+	e.Labels = followLabels(e.Labels, scopes, done).Merge(relabelLabels(rules, e.Logs))
+}
+
+// relabelValues runs the replace, drop and keep rules on each value, and returns a new
+// slice of the values that were not dropped. Done keeps the result for each value, so
+// a value in two collections is relabeled once, and both get the same copy.
+func relabelValues(rules []*relabeler, values []any, done map[any]relabeled) []any {
+	kept := make([]any, 0, len(values))
+
+	for _, v := range values {
+		var (
+			result  relabeled
+			ok      bool
+			pointer = reflect.ValueOf(v).Kind() == reflect.Pointer
+		)
+
+		if pointer {
+			result, ok = done[v]
+		}
+
+		if !ok {
+			result = relabelValue(rules, v)
+		}
+
+		if pointer {
+			done[v] = result
+		}
+
+		if result.keep {
+			kept = append(kept, result.value)
+		}
+	}
+
+	return kept
+}
+
+func relabelValue(rules []*relabeler, v any) relabeled {
+	out := v
+
+	for _, r := range rules {
+		if r.action == RelabelLabel || (len(r.Kinds) > 0 && !slices.Contains(r.Kinds, KindOf(v))) {
+			continue
+		}
+
+		field, ok := relabelField(out, r.field)
+		if !ok {
+			continue
+		}
+
+		value := field.String()
+		match := r.re.FindStringSubmatchIndex(value)
+
+		switch r.action {
+		case RelabelDrop:
+			if match != nil {
+				return relabeled{}
+			}
+		case RelabelKeep:
+			if match == nil {
+				return relabeled{}
+			}
+		case RelabelReplace:
+			if match == nil {
+				continue
+			}
+
+			replaced := string(r.re.ExpandString(nil, r.Replacement, value, match))
+			if replaced == value {
+				continue
+			}
+
+			if out == v {
+				out = copyValue(v)
+				field, _ = relabelField(out, r.field)
+			}
+
+			field.SetString(replaced)
+		}
+	}
+
+	return relabeled{value: out, keep: true}
+}
+
+// copyValue returns a pointer to a shallow copy of the struct v points to.
+func copyValue(v any) any {
+	rv := reflect.ValueOf(v)
+	c := reflect.New(rv.Elem().Type())
+	c.Elem().Set(rv.Elem())
+
+	return c.Interface()
+}
+
+// relabelLabels runs the label rules on each controller and site in the values.
+func relabelLabels(rules []*relabeler, collections ...[]any) Labels {
+	labels := Labels{}
+	done := make(map[LabelScope]bool)
+
+	for _, values := range collections {
+		for _, v := range values {
+			scope := valueScope(v)
+			if done[scope] {
+				continue
+			}
+
+			done[scope] = true
+
+			for _, r := range rules {
+				value := scope.Site
+				if r.Label == "source" {
+					value = scope.Source
+				}
+
+				if r.action != RelabelLabel || !r.re.MatchString(value) {
+					continue
+				}
+
+				if labels[scope] == nil {
+					labels[scope] = make(map[string]string)
+				}
+
+				maps.Copy(labels[scope], r.Labels)
+			}
+		}
+	}
+
+	return labels
+}
+
+// valueScope returns the controller and site a value belongs to.
+func valueScope(v any) LabelScope {
+	scope := LabelScope{}
+	if f, ok := relabelField(v, relabelFields["source"]); ok {
+		scope.Source = f.String()
+	}
+
+	if f, ok := relabelField(v, relabelFields["site_name"]); ok {
+		scope.Site = f.String()
+	}
+
+	return scope
+}
+
+// inputScopes returns the scope of each value that has labels from its input, so
+// those labels can follow the value when a rule renames its controller or site.
+func inputScopes(labels Labels, collections ...[]any) map[any]LabelScope {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	scopes := make(map[any]LabelScope)
+
+	for _, values := range collections {
+		for _, v := range values {
+			if reflect.ValueOf(v).Kind() != reflect.Pointer {
+				continue
+			}
+
+			if scope := valueScope(v); labels[scope] != nil {
+				scopes[v] = scope
+			}
+		}
+	}
+
+	return scopes
+}
+
+// followLabels returns a copy of the labels from an input, with the labels of each
+// renamed value also added under its new scope.
+func followLabels(labels Labels, scopes map[any]LabelScope, done map[any]relabeled) Labels {
+	moved := Labels{}.Merge(labels)
+
+	for v, scope := range scopes {
+		result := done[v]
+		if !result.keep {
+			continue
+		}
+
+		if renamed := valueScope(result.value); renamed != scope {
+			moved.Merge(Labels{renamed: labels[scope]})
+		}
+	}
+
+	return moved
+}
+
+// relabelField returns the settable string field of a struct pointer, if it has one.
+func relabelField(v any, name string) (reflect.Value, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	field := rv.Elem().FieldByName(name)
+	if !field.IsValid() || field.Kind() != reflect.String || !field.CanSet() {
+		return reflect.Value{}, false
+	}
+
+	return field, true
+}
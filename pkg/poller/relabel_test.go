@@ -0,0 +1,138 @@
+package poller_test
+
+import (
+	"testing"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	relabelClient struct{ SiteName, SourceName, Name, Mac string }
+	relabelDevice struct{ SiteName, SourceName, Name string }
+	relabelEvent  struct{ SiteName, SourceName, Msg string }
+)
+
+// relabelInput returns the same values every poll.
+type relabelInput struct {
+	countingInput
+	metrics *poller.Metrics
+	events  *poller.Events
+}
+
+func (r *relabelInput) Metrics(*poller.Filter) (*poller.Metrics, error) { return r.metrics, nil }
+func (r *relabelInput) Events(*poller.Filter) (*poller.Events, error)   { return r.events, nil }
+
+func TestRelabelRunsBeforeOutputs(t *testing.T) {
+	t.Parallel()
+
+	poller.RegisterKind[*relabelClient]("relabel_client")
+
+	phone := &relabelClient{SiteName: "default", SourceName: "https://10.1.1.1", Name: "phone"}
+	printer := &relabelClient{SiteName: "default", SourceName: "https://10.1.1.1", Name: "printer-2"}
+	guest := &relabelClient{SiteName: "guest", SourceName: "https://10.1.1.1", Name: "laptop"}
+	device := &relabelDevice{SiteName: "default", SourceName: "https://10.1.1.1", Name: "printer-ap"}
+	event := &relabelEvent{SiteName: "default", SourceName: "https://10.1.1.1", Msg: "hi"}
+
+	collector := poller.NewTestCollector(t)
+	collector.SetPoller(&poller.Poller{Relabel: []*poller.RelabelRule{
+		{Label: "site_name", Regex: "default", Replacement: "HQ"},
+		{Label: "source", Regex: `https://10\.1\.1\.1`, Replacement: "main-udm"},
+		{Action: poller.RelabelDrop, Label: "name", Regex: "printer.*", Kinds: []poller.MetricKind{"relabel_client"}},
+		{Action: poller.RelabelKeep, Label: "site_name", Regex: "HQ"},
+		{Action: poller.RelabelLabel, Label: "source", Regex: "main-.*", Labels: map[string]string{"env": "prod"}},
+		{Action: poller.RelabelLabel, Label: "site_name", Regex: "HQ", Labels: map[string]string{"region": "eu"}},
+	}})
+	collector.AddInput(&poller.InputPlugin{Name: "relabel", Input: &relabelInput{
+		metrics: &poller.Metrics{Clients: []any{phone, printer, guest}, Devices: []any{device}},
+		events:  &poller.Events{Logs: []any{event}},
+	}})
+
+	metrics, err := collector.Metrics(nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []any{&relabelClient{SiteName: "HQ", SourceName: "main-udm", Name: "phone"}}, metrics.Clients,
+		"printer is dropped, guest is not kept")
+	assert.Equal(t, []any{&relabelDevice{SiteName: "HQ", SourceName: "main-udm", Name: "printer-ap"}}, metrics.Devices,
+		"the drop rule is limited to clients")
+	assert.Equal(t, map[string]string{"env": "prod", "region": "eu"}, metrics.Labels.For("main-udm", "HQ"))
+	assert.Nil(t, metrics.Labels.For("main-udm", "guest"))
+
+	assert.Equal(t, "default", phone.SiteName, "rewritten values are copies; the input's are left alone")
+	assert.Equal(t, "https://10.1.1.1", device.SourceName)
+
+	again, err := collector.Metrics(nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Clients, again.Clients, "the next poll of the same values gets the same result")
+
+	events, err := collector.Events(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "HQ", events.Logs[0].(*relabelEvent).SiteName)
+	assert.Equal(t, "default", event.SiteName)
+	assert.Equal(t, "prod", events.Labels.For("main-udm", "HQ")["env"])
+}
+
+func TestRelabelCopiesValuesInTwoCollectionsOnce(t *testing.T) {
+	t.Parallel()
+
+	phone := &relabelClient{SiteName: "default", Name: "phone"}
+
+	collector := poller.NewTestCollector(t)
+	collector.SetPoller(&poller.Poller{Relabel: []*poller.RelabelRule{
+		{Label: "site_name", Regex: "default", Replacement: "HQ"},
+	}})
+	collector.AddInput(&poller.InputPlugin{Name: "relabel", Input: &relabelInput{
+		metrics: &poller.Metrics{Clients: []any{phone}, Devices: []any{phone}},
+	}})
+
+	metrics, err := collector.Metrics(nil)
+	require.NoError(t, err)
+	require.Len(t, metrics.Clients, 1)
+	assert.Same(t, metrics.Clients[0], metrics.Devices[0])
+	assert.Equal(t, "HQ", metrics.Clients[0].(*relabelClient).SiteName)
+}
+
+func TestRelabelKeepsInputLabels(t *testing.T) {
+	t.Parallel()
+
+	phone := &relabelClient{SiteName: "default", SourceName: "https://10.1.1.1", Name: "phone"}
+	event := &relabelEvent{SiteName: "default", SourceName: "https://10.1.1.1", Msg: "hi"}
+	labels := poller.Labels{{Source: "https://10.1.1.1", Site: "default"}: {"role": "corp", "region": "us"}}
+
+	collector := poller.NewTestCollector(t)
+	collector.SetPoller(&poller.Poller{Relabel: []*poller.RelabelRule{
+		{Label: "site_name", Regex: "default", Replacement: "HQ"},
+		{Action: poller.RelabelLabel, Label: "site_name", Regex: "HQ", Labels: map[string]string{"region": "eu"}},
+	}})
+	collector.AddInput(&poller.InputPlugin{Name: "relabel", Input: &relabelInput{
+		metrics: &poller.Metrics{Clients: []any{phone}, Labels: labels},
+		events:  &poller.Events{Logs: []any{event}, Labels: labels},
+	}})
+
+	metrics, err := collector.Metrics(nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"role": "corp", "region": "eu"}, metrics.Labels.For("https://10.1.1.1", "HQ"),
+		"input labels follow the renamed site, and rule labels win")
+
+	events, err := collector.Events(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "corp", events.Labels.For("https://10.1.1.1", "HQ")["role"])
+}
+
+func TestSetupRelabelRejectsBadRules(t *testing.T) {
+	t.Parallel()
+
+	for _, rule := range []*poller.RelabelRule{
+		{Action: "rename", Label: "site_name"},
+		{Label: "model"},
+		{Label: "name", Regex: "("},
+		{Action: poller.RelabelLabel, Label: "name", Labels: map[string]string{"a": "b"}},
+		{Action: poller.RelabelLabel, Label: "site_name"},
+	} {
+		u := poller.New()
+		u.Relabel = []*poller.RelabelRule{rule}
+		require.ErrorIs(t, u.SetupRelabel(), poller.ErrRelabel, "%+v", rule)
+	}
+}
@@ -0,0 +1,182 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// ErrReloadConfigType is returned when a plugin is handed a config it did not register.
+var ErrReloadConfigType = fmt.Errorf("unexpected config type")
+
+// configWatchInterval is how often the config file is checked for changes when
+// the [poller] watch_config option is enabled.
+const configWatchInterval = 5 * time.Second
+
+// Reloader is an optional interface for inputs and outputs that can apply a new
+// config without restarting the process. Reload is passed a freshly parsed value
+// of the same type the plugin registered as its Config. Defaults from the plugin's
+// init() are not carried over, so the plugin must apply its own. A plugin that
+// returns an error keeps running with its old config.
+type Reloader interface {
+	Reload(config any) error
+}
+
+// reloadedConfig is a plugin config parsed during a reload, and the plugin it belongs to.
+type reloadedConfig struct {
+	name   string
+	plugin any
+	config any
+}
+
+// watchReload reloads the configs on SIGHUP, and when the config file changes if
+// watch_config is enabled, until ctx is canceled.
+func (u *UnifiPoller) watchReload(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	defer signal.Stop(hup)
+
+	var changed <-chan time.Time
+
+	modTime := u.configModTime()
+
+	if u.WatchConfig {
+		ticker := time.NewTicker(configWatchInterval)
+		defer ticker.Stop()
+
+		changed = ticker.C
+
+		u.Logf("Watching %s for changes", u.Flags.ConfigFile)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			u.Logf("Caught SIGHUP, reloading configuration from %s", u.Flags.ConfigFile)
+		case <-changed:
+			mod := u.configModTime()
+			if mod.Equal(modTime) {
+				continue
+			}
+
+			modTime = mod
+
+			u.Logf("Config file %s changed, reloading configuration", u.Flags.ConfigFile)
+		}
+
+		if err := u.ReloadConfigs(); err != nil {
+			u.LogErrorf("Reloading configuration, keeping the current one: %v", err)
+		}
+	}
+}
+
+// configModTime returns the modification time of the config file, or the zero time.
+func (u *UnifiPoller) configModTime() time.Time {
+	info, err := os.Stat(u.Flags.ConfigFile)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+// ReloadConfigs parses the config file and environment variables again and hands
+// the result to every running input and output that implements Reloader.
+// All the configs are parsed before any are applied; if one fails to parse, the
+// error is returned and every plugin keeps its current config.
+// Changes to the [poller] section itself are only applied on restart.
+func (u *UnifiPoller) ReloadConfigs() error {
+	ForgetSecrets() // plugins resolve their secrets again, like the pii_key, as they reload.
+
+	core := &Config{Poller: &Poller{}}
+	if err := u.parseInterface(core); err != nil {
+		return err
+	}
+
+	configs, err := u.parseReloads()
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(core.Poller, u.Config.Poller) {
+		u.Logf("Changes to the [poller] section take effect after a restart")
+	}
+
+	for _, r := range configs {
+		reloader, ok := r.plugin.(Reloader)
+		if !ok {
+			u.LogDebugf("%s does not support reloading; config changes take effect after a restart", r.name)
+
+			continue
+		}
+
+		if err := reloader.Reload(r.config); err != nil {
+			u.LogErrorf("Reloading %s, keeping its current config: %v", r.name, err)
+
+			continue
+		}
+
+		u.Logf("Reloaded %s configuration", r.name)
+	}
+
+	return nil
+}
+
+// parseReloads parses a fresh config for every input, and every output that is running.
+func (u *UnifiPoller) parseReloads() ([]*reloadedConfig, error) {
+	inputSync.RLock()
+	defer inputSync.RUnlock()
+
+	outputSync.RLock()
+	defer outputSync.RUnlock()
+
+	configs := make([]*reloadedConfig, 0, len(inputs)+len(outputs))
+
+	for _, i := range inputs {
+		config, err := u.parseFresh(i.Config)
+		if err != nil {
+			return nil, fmt.Errorf("input %s: %w", i.Name, err)
+		}
+
+		configs = append(configs, &reloadedConfig{name: "input " + i.Name, plugin: i.Input, config: config})
+	}
+
+	for _, o := range outputs {
+		if !o.started.Load() {
+			// A new config cannot start an output that was disabled at startup.
+			continue
+		}
+
+		config, err := u.parseFresh(o.Config)
+		if err != nil {
+			return nil, fmt.Errorf("output %s: %w", o.Name, err)
+		}
+
+		configs = append(configs, &reloadedConfig{name: "output " + o.Name, plugin: o.OutputPlugin, config: config})
+	}
+
+	return configs, nil
+}
+
+// parseFresh parses the config file and environment into a new zero value of the
+// same type as config, leaving config untouched.
+func (u *UnifiPoller) parseFresh(config any) (any, error) {
+	t := reflect.TypeOf(config)
+	if t == nil || t.Kind() != reflect.Pointer {
+		return nil, fmt.Errorf("%w: %T", ErrReloadConfigType, config)
+	}
+
+	fresh := reflect.New(t.Elem()).Interface()
+	if err := u.parseInterface(fresh); err != nil {
+		return nil, err
+	}
+
+	return fresh, nil
+}
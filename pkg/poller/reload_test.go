@@ -0,0 +1,64 @@
+package poller_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ReloadSection struct {
+	Value int `json:"value" toml:"value" xml:"value" yaml:"value"`
+}
+
+type reloadConfig struct {
+	*ReloadSection `json:"reload_test" toml:"reload_test" xml:"reload_test" yaml:"reload_test"`
+}
+
+// reloadInput records every config it is handed by a reload.
+type reloadInput struct {
+	countingInput
+	reloads []*reloadConfig
+}
+
+func (r *reloadInput) Reload(config any) error {
+	r.reloads = append(r.reloads, config.(*reloadConfig))
+
+	return nil
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+// Not parallel: registers an input with the global plugin list.
+func TestReloadConfigsIsAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "up.conf")
+	writeConfig(t, path, "[reload_test]\nvalue = 1\n")
+
+	input := &reloadInput{}
+	config := &reloadConfig{}
+	poller.NewInput(&poller.InputPlugin{Name: "reload-test", Input: input, Config: config})
+
+	u := poller.New()
+	u.Flags.ConfigFile = path
+	u.Quiet = true
+
+	require.NoError(t, u.ParseConfigs())
+	require.Equal(t, 1, config.Value)
+
+	writeConfig(t, path, "[reload_test]\nvalue = 2\n")
+	require.NoError(t, u.ReloadConfigs())
+	require.Len(t, input.reloads, 1)
+	assert.Equal(t, 2, input.reloads[0].Value, "the plugin must be handed the new config")
+	assert.Equal(t, 1, config.Value, "the registered config must not be modified")
+
+	writeConfig(t, path, "[reload_test\nvalue = 3\n")
+	require.Error(t, u.ReloadConfigs())
+	assert.Len(t, input.reloads, 1, "a config that fails to parse must not be applied")
+}
@@ -0,0 +1,574 @@
+package poller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is the base tick of the shared poll scheduler when the
+// [poller] interval is not configured. Output intervals are rounded up to a
+// multiple of the base tick, so keep this small.
+const DefaultPollInterval = 5 * time.Second
+
+// Scheduler is implemented by the Collect passed into output plugins.
+// Outputs that subscribe receive a shared Snapshot instead of polling the inputs
+// on their own ticker, so two outputs with the same (or aligned) intervals cost
+// one controller poll, not two. Outputs should fall back to their own ticker when
+// the Collect they were handed does not implement this interface.
+type Scheduler interface {
+	Subscribe(*Subscription) <-chan *Snapshot
+}
+
+// Rescheduler is an optional interface for a Scheduler that can change the interval
+// of a subscription, ie. after a config reload. Outputs handed a Scheduler without
+// it keep their first interval until a restart.
+type Rescheduler interface {
+	Reschedule(sub *Subscription, interval time.Duration)
+}
+
+// Subscription describes how often an output wants data, and which data it wants.
+type Subscription struct {
+	// Name is used in log messages; usually the output plugin name.
+	Name string
+	// Input is the name of the only input polled for this subscriber, like the Name
+	// of a Filter. Empty polls every input. Subscribers with the same Input due on
+	// the same tick share a snapshot.
+	Input string
+	// Interval is rounded up to a multiple of the scheduler's base interval.
+	Interval time.Duration
+	// Metrics and Events select what is polled for this subscriber.
+	// Inputs are only asked for events when at least one due subscriber wants them.
+	Metrics bool
+	Events  bool
+	// Stream sends events from inputs implementing EventStreamer as they arrive, in
+	// snapshots without metrics. Without it, they come with the next due snapshot.
+	Stream bool
+	// Ack is set by outputs that call Done on each snapshot once its events are written.
+	// Event cursors are saved when every such output wrote the events, so events that
+	// failed to write are collected again after a restart.
+	Ack bool
+}
+
+// Snapshot is the result of polling every input once. The same Metrics and Events
+// are handed to every output due on a tick, so outputs must treat them as read-only.
+type Snapshot struct {
+	Start      time.Time
+	Metrics    *Metrics // nil if no due subscriber asked for metrics.
+	Events     *Events  // nil if no due subscriber asked for events.
+	MetricsErr error
+	EventsErr  error
+	// Streamed is true for the snapshots of events pushed by an EventStreamer input,
+	// sent to subscribers with Stream set. They hold no metrics.
+	Streamed bool
+	done     func(error)
+}
+
+// Done tells the scheduler an output finished writing the snapshot's events, with the
+// error from writing them, if any. Outputs that set Ack in their Subscription call it
+// once for every snapshot. It does nothing on other snapshots, or when called again.
+func (s *Snapshot) Done(err error) {
+	if s != nil && s.done != nil {
+		s.done(err)
+	}
+}
+
+// subscriber is one registered Subscription and its place in the schedule.
+// next is the base tick number this subscriber is next due on.
+type subscriber struct {
+	*Subscription
+	every int64
+	next  int64
+	ch    chan *Snapshot
+	held  *Events // events collected while this subscriber was not due.
+}
+
+// scheduler polls the inputs on a base interval and fans the result out to any
+// subscriber that is due on that tick. Subscribers are aligned to multiples of
+// the base tick counted from the scheduler start, so a 30s and a 2m output share
+// the poll every 2 minutes.
+type scheduler struct {
+	Collect
+	base    time.Duration
+	start   time.Time
+	mu      sync.Mutex
+	sending sync.Mutex // polls and pushed events both send to subscribers.
+	subs    []*subscriber
+	stopped bool
+}
+
+func newScheduler(c Collect, base time.Duration) *scheduler {
+	if base <= 0 {
+		base = DefaultPollInterval
+	}
+
+	return &scheduler{Collect: c, base: base, start: time.Now()}
+}
+
+// tick returns the base tick number for a point in time.
+// Computing it from elapsed time keeps the schedule aligned when a slow poll
+// causes the ticker to drop ticks.
+func (s *scheduler) tick(now time.Time) int64 {
+	return int64(now.Sub(s.start) / s.base)
+}
+
+// every returns how many base ticks apart a subscriber with this interval is polled.
+func (s *scheduler) every(name string, interval time.Duration) int64 {
+	every := int64((interval + s.base - 1) / s.base)
+	if every < 1 {
+		every = 1
+	}
+
+	if rounded := time.Duration(every) * s.base; rounded != interval {
+		s.Logf("%s: interval %v rounded up to %v (a multiple of the poller interval, %v)",
+			name, interval, rounded, s.base)
+	}
+
+	return every
+}
+
+func (s *scheduler) subscribe(sub *Subscription) <-chan *Snapshot {
+	every := s.every(sub.Name, sub.Interval)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		ch := make(chan *Snapshot)
+		close(ch)
+
+		return ch
+	}
+
+	n := &subscriber{
+		Subscription: sub,
+		every:        every,
+		next:         (s.tick(time.Now())/every + 1) * every,
+		ch:           make(chan *Snapshot, 1),
+	}
+	s.subs = append(s.subs, n)
+
+	s.LogDebugf("%s subscribed to the poll scheduler, every %d tick(s) of %v", sub.Name, every, s.base)
+
+	return n.ch
+}
+
+// reschedule changes the interval of an existing subscriber. The new schedule
+// starts from the current tick, so the subscriber is next due one new interval from now.
+func (s *scheduler) reschedule(sub *Subscription, interval time.Duration) {
+	every := s.every(sub.Name, interval)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, n := range s.subs {
+		if n.Subscription != sub || n.every == every {
+			continue
+		}
+
+		n.every = every
+		n.next = (s.tick(time.Now())/every + 1) * every
+
+		s.LogDebugf("%s rescheduled, every %d tick(s) of %v", sub.Name, every, s.base)
+	}
+}
+
+// run ticks until the context is canceled, polling the inputs whenever a subscriber
+// is due. Subscriber channels are closed when it returns.
+func (s *scheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(s.base)
+	defer ticker.Stop()
+	defer s.stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.poll(ctx, now)
+		}
+	}
+}
+
+// stop closes every subscriber channel so the outputs ranging over them return.
+func (s *scheduler) stop() {
+	s.sending.Lock()
+	defer s.sending.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+
+	for _, sub := range s.subs {
+		close(sub.ch)
+	}
+
+	s.subs = nil
+}
+
+// due returns the subscribers due on tick n, grouped by their Input, and advances
+// their schedule.
+func (s *scheduler) due(n int64) [][]*subscriber {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups := [][]*subscriber{}
+
+NEXT:
+	for _, sub := range s.subs {
+		if n < sub.next {
+			continue
+		}
+
+		sub.next = (n/sub.every + 1) * sub.every
+
+		for i, group := range groups {
+			if strings.EqualFold(group[0].Input, sub.Input) {
+				groups[i] = append(group, sub)
+				continue NEXT
+			}
+		}
+
+		groups = append(groups, []*subscriber{sub})
+	}
+
+	return groups
+}
+
+// wants returns what a group of subscribers asked for. events is the longest
+// interval of the subscribers that want events, or zero.
+func (s *scheduler) wants(group []*subscriber) (metrics bool, events time.Duration) {
+	for _, sub := range group {
+		metrics = metrics || sub.Metrics
+
+		if interval := time.Duration(sub.every) * s.base; sub.Events && interval > events {
+			events = interval
+		}
+	}
+
+	return metrics, events
+}
+
+// poll collects a Snapshot for each group of due subscribers, and sends it to them.
+func (s *scheduler) poll(ctx context.Context, now time.Time) {
+	for _, group := range s.due(s.tick(now)) {
+		s.pollGroup(ctx, now, group)
+	}
+}
+
+// pollGroup polls the input the subscribers in a group read, or every input.
+func (s *scheduler) pollGroup(ctx context.Context, now time.Time, due []*subscriber) {
+	var (
+		input           = due[0].Input
+		metrics, events = s.wants(due)
+		snap            = &Snapshot{Start: now}
+	)
+
+	if metrics {
+		snap.Metrics, snap.MetricsErr = s.metrics(ctx, &Filter{Name: input})
+	}
+
+	if events > 0 {
+		snap.Events, snap.EventsErr = s.events(ctx, &Filter{Name: input, Dur: events, ack: true})
+	}
+
+	if ctx.Err() != nil {
+		return // shutting down; a partial snapshot is not worth sending.
+	}
+
+	// Inputs may not return the same events again, like those with cursors on or those
+	// they made up between polls, so keep them for the subscribers that are not due.
+	if snap.Events != nil {
+		s.hold(due, input, snap.Events, acks(due))
+	}
+
+	s.sending.Lock()
+	defer s.sending.Unlock()
+
+	for _, sub := range due {
+		send := snap
+		if held := s.take(sub); held != nil {
+			send = &Snapshot{Start: snap.Start, Metrics: snap.Metrics, MetricsErr: snap.MetricsErr, EventsErr: snap.EventsErr}
+			send.Events = mergeEvents(held, snap.Events)
+		}
+
+		s.send(sub, send)
+	}
+}
+
+// push sends streamed events from an input to the subscribers of it with Stream set
+// right away, and holds them for its other event subscribers until they are next due.
+func (s *scheduler) push(input string, events *Events) {
+	if events == nil || len(events.Logs) == 0 {
+		return
+	}
+
+	// Lock sending first, so stop cannot close the channels while this sends.
+	s.sending.Lock()
+	defer s.sending.Unlock()
+
+	s.mu.Lock()
+
+	streams := []*subscriber{}
+
+	for _, sub := range s.subs {
+		if sub.Events && sub.Stream && reads(sub, input) {
+			streams = append(streams, sub)
+		}
+	}
+
+	stopped := s.stopped
+	s.mu.Unlock()
+
+	if stopped {
+		return
+	}
+
+	s.hold(streams, input, events, acks(streams))
+
+	snap := &Snapshot{Start: time.Now(), Events: events, Streamed: true}
+
+	for _, sub := range streams {
+		s.send(sub, snap)
+	}
+}
+
+// send hands a snapshot to a subscriber without blocking. If the subscriber has not
+// picked up the previous snapshot yet, it is replaced, and the events in the replaced
+// snapshot are carried into the new one.
+func (s *scheduler) send(sub *subscriber, snap *Snapshot) {
+	select {
+	case sub.ch <- s.acked(sub, snap):
+		return
+	default:
+	}
+
+	// This is the only sender, so the channel has room after the drain.
+	select {
+	case stale := <-sub.ch:
+		if stale.Events != nil {
+			snap = &Snapshot{Start: snap.Start, Metrics: snap.Metrics, MetricsErr: snap.MetricsErr,
+				Events: mergeEvents(stale.Events, snap.Events), EventsErr: snap.EventsErr}
+		}
+	default:
+	}
+
+	sub.ch <- s.acked(sub, snap)
+
+	s.LogDebugf("%s is still busy with a previous poll; dropped a stale snapshot", sub.Name)
+}
+
+// acked returns the snapshot to send a subscriber. For subscribers with Ack set, it is
+// a copy whose Done records that they wrote its events.
+func (s *scheduler) acked(sub *subscriber, snap *Snapshot) *Snapshot {
+	if !sub.Events || !sub.Ack || snap.Events == nil || len(snap.Events.pending) == 0 {
+		return snap
+	}
+
+	var (
+		once   sync.Once
+		events = snap.Events
+		acked  = *snap
+	)
+
+	acked.done = func(err error) {
+		once.Do(func() {
+			if err := wroteEvents(events, err); err != nil {
+				s.LogErrorf("Saving event cursors: %v", err)
+			}
+		})
+	}
+
+	return &acked
+}
+
+// acks returns how many subscribers will call Done on the events sent to them.
+func acks(subs []*subscriber) (n int) {
+	for _, sub := range subs {
+		if sub.Events && sub.Ack {
+			n++
+		}
+	}
+
+	return n
+}
+
+// hold keeps events from input, or from every input when it is empty, for the
+// subscribers that read them and want events but are not in skip. Each is sent the
+// logs from the inputs it reads with its next snapshot. The cursors of the events are
+// saved once the subscribers in skip that ack, waiting, and those holding them wrote them.
+func (s *scheduler) hold(skip []*subscriber, input string, events *Events, waiting int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tagEvents(events, input)
+
+	var (
+		holders = []*subscriber{}
+		held    = []*Events{}
+	)
+
+	for _, sub := range s.subs {
+		if !sub.Events || slices.Contains(skip, sub) {
+			continue
+		}
+
+		if read := readBy(sub, events); reads(sub, input) || len(read.Logs) > 0 {
+			holders = append(holders, sub)
+			held = append(held, read)
+		}
+	}
+
+	if err := trackEvents(events, waiting+acks(holders)); err != nil {
+		s.LogErrorf("Saving event cursors: %v", err)
+	}
+
+	if len(events.Logs) == 0 {
+		return
+	}
+
+	for i, sub := range holders {
+		sub.held = mergeEvents(sub.held, held[i])
+	}
+}
+
+// reads is true if a subscriber reads everything collected from input. Events collected
+// from every input, with an empty input, are read in full only by subscribers of every
+// input; readBy picks out the logs the others read.
+func reads(sub *subscriber, input string) bool {
+	return sub.Input == "" || strings.EqualFold(sub.Input, input)
+}
+
+// readBy returns the events with only the logs from the input a subscriber reads.
+// The labels and cursors are shared, so the subscriber acks them like the others.
+func readBy(sub *subscriber, events *Events) *Events {
+	if sub.Input == "" {
+		return events
+	}
+
+	read := &Events{Labels: events.Labels, Cursors: events.Cursors, pending: events.pending}
+
+	for i, log := range events.Logs {
+		if strings.EqualFold(events.inputs[i], sub.Input) {
+			read.Logs = append(read.Logs, log)
+			read.inputs = append(read.inputs, events.inputs[i])
+		}
+	}
+
+	return read
+}
+
+// tagEvents records input as where the logs in events came from, for those without one.
+func tagEvents(events *Events, input string) {
+	events.inputs = sources(events)
+
+	for i, from := range events.inputs {
+		if from == "" {
+			events.inputs[i] = input
+		}
+	}
+}
+
+// sources returns the input each of the logs in events came from, one for each log.
+// It is empty for logs from an unknown input, like those an output appended.
+func sources(events *Events) []string {
+	if len(events.inputs) == len(events.Logs) {
+		return events.inputs
+	}
+
+	inputs := make([]string, len(events.Logs))
+	copy(inputs, events.inputs)
+
+	return inputs
+}
+
+// take returns and clears the events held for a subscriber.
+func (s *scheduler) take(sub *subscriber) *Events {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	held := sub.held
+	sub.held = nil
+
+	return held
+}
+
+// mergeEvents returns a new Events with the logs and labels of both.
+func mergeEvents(a, b *Events) *Events {
+	merged := &Events{Labels: Labels{}}
+
+	for _, e := range []*Events{a, b} {
+		if e == nil {
+			continue
+		}
+
+		merged.Logs = append(merged.Logs, e.Logs...)
+		merged.inputs = append(merged.inputs, sources(e)...)
+		merged.Labels.Merge(e.Labels)
+		merged.Cursors = append(merged.Cursors, e.Cursors...)
+		merged.pending = append(merged.pending, e.pending...)
+	}
+
+	return merged
+}
+
+func (s *scheduler) metrics(ctx context.Context, filter *Filter) (*Metrics, error) {
+	if c, ok := s.Collect.(CollectContext); ok {
+		return c.MetricsContext(ctx, filter)
+	}
+
+	return s.Metrics(filter)
+}
+
+func (s *scheduler) events(ctx context.Context, filter *Filter) (*Events, error) {
+	if c, ok := s.Collect.(CollectContext); ok {
+		return c.EventsContext(ctx, filter)
+	}
+
+	return s.Events(filter)
+}
+
+// startScheduler starts the shared poll scheduler once. It runs until ctx is canceled.
+func (u *UnifiPoller) startScheduler(ctx context.Context) {
+	u.schedOnce.Do(func() {
+		u.sched = newScheduler(u, u.Interval.Duration)
+		go u.sched.run(ctx)
+	})
+}
+
+// startStreams starts every input that implements EventStreamer.
+func (u *UnifiPoller) startStreams(ctx context.Context) {
+	inputSync.RLock()
+	defer inputSync.RUnlock()
+
+	for _, input := range inputs {
+		if streamer, ok := input.Input.(EventStreamer); ok {
+			name := input.Name
+			go streamer.StreamEvents(ctx, func(events *Events) { u.pushEvents(name, events) })
+		}
+	}
+}
+
+// pushEvents runs streamed events through the same steps as polled events,
+// then hands them to the scheduler.
+func (u *UnifiPoller) pushEvents(input string, events *Events) {
+	relabelEvents(u.relabel, events)
+	u.sched.push(input, events)
+}
+
+// Subscribe registers an output with the shared poll scheduler.
+// The returned channel is closed when the scheduler stops.
+func (u *UnifiPoller) Subscribe(sub *Subscription) <-chan *Snapshot {
+	u.startScheduler(context.Background())
+
+	return u.sched.subscribe(sub)
+}
+
+// Reschedule changes the interval of a subscription made with Subscribe.
+func (u *UnifiPoller) Reschedule(sub *Subscription, interval time.Duration) {
+	u.startScheduler(context.Background())
+	u.sched.reschedule(sub, interval)
+}
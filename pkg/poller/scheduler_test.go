@@ -0,0 +1,200 @@
+package poller_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+	"golift.io/cnfg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingInput counts how often the scheduler polls it.
+type countingInput struct {
+	metrics atomic.Int64
+	events  atomic.Int64
+}
+
+func (*countingInput) Initialize(poller.Logger) error { return nil }
+
+func (c *countingInput) Metrics(*poller.Filter) (*poller.Metrics, error) {
+	c.metrics.Add(1)
+
+	return &poller.Metrics{TS: time.Now()}, nil
+}
+
+func (c *countingInput) Events(*poller.Filter) (*poller.Events, error) {
+	c.events.Add(1)
+
+	return &poller.Events{}, nil
+}
+
+func (*countingInput) RawMetrics(*poller.Filter) ([]byte, error) { return nil, nil }
+
+func (*countingInput) DebugInput() (bool, error) { return false, nil }
+
+// discardLog keeps the scheduler goroutine, which outlives the test, from
+// logging through a finished *testing.T.
+type discardLog struct{}
+
+func (discardLog) Log(...any)          {}
+func (discardLog) Logf(string, ...any) {}
+
+func newSchedulerCollector(base time.Duration) (*poller.TestCollector, *countingInput) {
+	input := &countingInput{}
+	collector := poller.NewTestCollector(discardLog{})
+	collector.SetPoller(&poller.Poller{Interval: cnfg.Duration{Duration: base}})
+	collector.AddInput(&poller.InputPlugin{Name: "counting-input", Input: input})
+
+	return collector, input
+}
+
+func receive(t *testing.T, ch <-chan *poller.Snapshot) *poller.Snapshot {
+	t.Helper()
+
+	select {
+	case snap := <-ch:
+		return snap
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for a snapshot")
+
+		return nil
+	}
+}
+
+func TestSchedulerSharesSnapshotBetweenAlignedSubscribers(t *testing.T) {
+	t.Parallel()
+
+	collector, input := newSchedulerCollector(50 * time.Millisecond)
+	first := collector.Subscribe(&poller.Subscription{Name: "first", Interval: 100 * time.Millisecond, Metrics: true})
+	second := collector.Subscribe(&poller.Subscription{Name: "second", Interval: 100 * time.Millisecond, Metrics: true})
+
+	a, b := receive(t, first), receive(t, second)
+
+	assert.Same(t, a, b, "subscribers due on the same tick must share one snapshot")
+	require.NoError(t, a.MetricsErr)
+	require.NotNil(t, a.Metrics)
+	assert.Nil(t, a.Events, "events were not requested")
+	assert.Equal(t, int64(1), input.metrics.Load(), "one tick must poll the inputs once")
+	assert.Zero(t, input.events.Load())
+}
+
+func TestSchedulerPollsEventsOnlyWhenRequested(t *testing.T) {
+	t.Parallel()
+
+	collector, input := newSchedulerCollector(50 * time.Millisecond)
+	ch := collector.Subscribe(&poller.Subscription{Name: "events", Interval: 50 * time.Millisecond, Events: true})
+
+	snap := receive(t, ch)
+
+	require.NoError(t, snap.EventsErr)
+	assert.NotNil(t, snap.Events)
+	assert.Nil(t, snap.Metrics, "metrics were not requested")
+	assert.Zero(t, input.metrics.Load())
+}
+
+func TestSchedulerPollsOnlyTheSubscribedInput(t *testing.T) {
+	t.Parallel()
+
+	collector, input := newSchedulerCollector(50 * time.Millisecond)
+	other := &countingInput{}
+	collector.AddInput(&poller.InputPlugin{Name: "other-input", Input: other})
+
+	one := collector.Subscribe(&poller.Subscription{
+		Name: "one", Input: "counting-input", Interval: 500 * time.Millisecond, Metrics: true,
+	})
+	every := collector.Subscribe(&poller.Subscription{Name: "every", Interval: 500 * time.Millisecond, Metrics: true})
+
+	a, b := receive(t, one), receive(t, every)
+
+	assert.NotSame(t, a, b, "subscribers of other inputs do not share a snapshot")
+	assert.Equal(t, int64(2), input.metrics.Load(), "each subscriber's poll reads the input")
+	assert.Equal(t, int64(1), other.metrics.Load(), "only the subscriber of every input reads the other")
+}
+
+func TestSchedulerRoundsIntervalUpToBaseMultiple(t *testing.T) {
+	t.Parallel()
+
+	const base = 100 * time.Millisecond
+
+	collector, _ := newSchedulerCollector(base)
+	start := time.Now()
+	ch := collector.Subscribe(&poller.Subscription{Name: "odd", Interval: 150 * time.Millisecond, Metrics: true})
+
+	snap := receive(t, ch)
+
+	// 150ms rounds up to 2 ticks of 100ms.
+	assert.GreaterOrEqual(t, snap.Start.Sub(start), 2*base-10*time.Millisecond)
+}
+
+func TestSchedulerRescheduleChangesInterval(t *testing.T) {
+	t.Parallel()
+
+	collector, _ := newSchedulerCollector(50 * time.Millisecond)
+	sub := &poller.Subscription{Name: "slow", Interval: time.Hour, Metrics: true}
+	ch := collector.Subscribe(sub)
+
+	collector.Reschedule(sub, 50*time.Millisecond)
+
+	snap := receive(t, ch)
+
+	require.NoError(t, snap.MetricsErr)
+	assert.NotNil(t, snap.Metrics)
+}
+
+func TestSchedulerPushesStreamedEvents(t *testing.T) {
+	t.Parallel()
+
+	collector, _ := newSchedulerCollector(50 * time.Millisecond)
+	stream := collector.Subscribe(&poller.Subscription{Name: "stream", Interval: time.Hour, Events: true, Stream: true})
+	polled := collector.Subscribe(&poller.Subscription{Name: "polled", Interval: 50 * time.Millisecond, Events: true})
+
+	collector.PushEvents(&poller.Events{Logs: []any{"alert"}})
+
+	snap := receive(t, stream)
+	assert.Equal(t, []any{"alert"}, snap.Events.Logs, "stream subscribers get pushed events right away")
+	assert.True(t, snap.Streamed)
+	assert.Nil(t, snap.Metrics)
+
+	snap = receive(t, polled)
+	assert.Equal(t, []any{"alert"}, snap.Events.Logs, "other subscribers get them with their next snapshot")
+	assert.False(t, snap.Streamed)
+}
+
+// firstPollInput returns its logs from the first poll only, like an input with cursors on, or
+// one handing over events it made up between polls.
+type firstPollInput struct {
+	countingInput
+	logs []any
+}
+
+func (o *firstPollInput) Events(filter *poller.Filter) (*poller.Events, error) {
+	if _, err := o.countingInput.Events(filter); err != nil || o.events.Load() > 1 {
+		return &poller.Events{}, err
+	}
+
+	return &poller.Events{Logs: o.logs}, nil
+}
+
+func TestSchedulerHoldsEventsForSubscribersOfTheirInput(t *testing.T) {
+	t.Parallel()
+
+	collector, _ := newSchedulerCollector(50 * time.Millisecond)
+	collector.AddInput(&poller.InputPlugin{Name: "first-input", Input: &firstPollInput{logs: []any{"made up"}}})
+	collector.AddInput(&poller.InputPlugin{Name: "other-input", Input: &firstPollInput{logs: []any{"other"}}})
+
+	every := collector.Subscribe(&poller.Subscription{Name: "every", Interval: 50 * time.Millisecond, Events: true})
+	one := collector.Subscribe(&poller.Subscription{
+		Name: "one", Input: "first-input", Interval: 100 * time.Millisecond, Events: true,
+	})
+
+	snap := receive(t, every)
+	assert.ElementsMatch(t, []any{"made up", "other"}, snap.Events.Logs, "the first poll collects both inputs")
+
+	snap = receive(t, one)
+	assert.Equal(t, []any{"made up"}, snap.Events.Logs,
+		"a subscriber of one input gets its events collected for every input, without a state_dir")
+}
@@ -0,0 +1,250 @@
+package poller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Secrets. Any password, API key or token in the config may be a reference to the
+   secret instead of the secret itself:
+
+     file:///path/to/file  reads the file.
+     env://NAME            reads an environment variable.
+     exec://command args   runs a command, split on spaces without a shell, and reads
+                           what it prints.
+     credential://name     reads a systemd credential from $CREDENTIALS_DIRECTORY; a
+                           $CREDENTIALS_DIRECTORY/name path works the same.
+
+   Leading and trailing whitespace is trimmed from every secret. Plugins resolve their
+   references when they log in, so rotated secrets are picked up on the next login.
+
+   The output of an exec:// command is reused for secretCacheTTL, as logins happen on
+   every session expiry and a password manager's CLI may be slow, or ask for a touch of a
+   hardware key. A reload runs them all again, and so does the next login after a plugin
+   calls ForgetSecret, ie. when its login failed. The other schemes are read every time. */
+
+// ErrSecret is returned when a secret reference cannot be resolved.
+var ErrSecret = errors.New("resolving secret")
+
+const (
+	// secretExecTimeout is how long an exec:// command may run.
+	secretExecTimeout = 30 * time.Second
+	// secretCacheTTL is how long the output of an exec:// command is reused.
+	secretCacheTTL = time.Hour
+)
+
+// SecretResolver returns the secret a reference points to. It is passed the reference
+// without its scheme, ie. "NAME" for "env://NAME".
+type SecretResolver func(ref string) (string, error)
+
+// secretResolvers holds the resolver for each scheme.
+var secretResolvers = struct { // nolint: gochecknoglobals
+	sync.RWMutex
+	byScheme map[string]SecretResolver
+}{byScheme: map[string]SecretResolver{
+	"file":       resolveFileSecret,
+	"env":        resolveEnvSecret,
+	"exec":       resolveExecSecret,
+	"credential": resolveCredentialSecret,
+}}
+
+// secretCache holds the secrets exec:// commands printed, by reference.
+var secretCache = struct { // nolint: gochecknoglobals
+	sync.Mutex
+	byRef map[string]cachedSecret
+}{byRef: make(map[string]cachedSecret)}
+
+// cachedSecret is a resolved secret, and when it was resolved.
+type cachedSecret struct {
+	value string
+	at    time.Time
+}
+
+// RegisterSecretResolver adds a scheme, like "vault", for secret references. Plugins
+// should call this in init(). Registering a scheme again replaces its resolver.
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolvers.Lock()
+	defer secretResolvers.Unlock()
+
+	secretResolvers.byScheme[scheme] = resolver
+}
+
+// IsSecretRef returns true if value is a reference to a secret, and not the secret.
+func IsSecretRef(value string) bool {
+	_, _, _, ok := secretResolver(value)
+
+	return ok
+}
+
+// ResolveSecret returns the secret a reference points to. Values that are not
+// references are returned as they are.
+func ResolveSecret(value string) (string, error) {
+	scheme, resolver, ref, ok := secretResolver(value)
+	if !ok {
+		return value, nil
+	}
+
+	cache := scheme == "exec"
+	if cache {
+		secretCache.Lock()
+		cached, found := secretCache.byRef[value]
+		secretCache.Unlock()
+
+		if found && time.Since(cached.at) < secretCacheTTL {
+			return cached.value, nil
+		}
+	}
+
+	secret, err := resolver(ref)
+	if err != nil {
+		return "", fmt.Errorf("%w %s: %w", ErrSecret, value, err)
+	}
+
+	secret = strings.TrimSpace(secret)
+
+	if cache {
+		secretCache.Lock()
+		secretCache.byRef[value] = cachedSecret{value: secret, at: time.Now()}
+		secretCache.Unlock()
+	}
+
+	return secret, nil
+}
+
+// ForgetSecret makes the next ResolveSecret of a reference resolve it again. Call it
+// when a secret did not work, ie. a login with it failed, as it may have been rotated.
+func ForgetSecret(value string) {
+	secretCache.Lock()
+	defer secretCache.Unlock()
+
+	delete(secretCache.byRef, value)
+}
+
+// ForgetSecrets makes every reference resolve again. Reloads call it.
+func ForgetSecrets() {
+	secretCache.Lock()
+	defer secretCache.Unlock()
+
+	clear(secretCache.byRef)
+}
+
+// CheckSecret returns an error if a reference points to a file, credential, variable
+// or command that does not exist. Nothing is read or run. Values that are not
+// references, and schemes added by plugins, are not checked.
+func CheckSecret(value string) error {
+	scheme, _, ref, ok := secretResolver(value)
+	if !ok {
+		return nil
+	}
+
+	var err error
+
+	switch scheme {
+	case "file":
+		_, err = os.Stat(ref)
+	case "env":
+		if _, ok := os.LookupEnv(ref); !ok {
+			err = fmt.Errorf("environment variable %s is not set", ref)
+		}
+	case "exec":
+		if args := strings.Fields(ref); len(args) == 0 {
+			err = errors.New("no command")
+		} else {
+			_, err = exec.LookPath(args[0])
+		}
+	case "credential":
+		if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+			_, err = os.Stat(filepath.Join(dir, ref))
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrSecret, value, err)
+	}
+
+	return nil
+}
+
+// secretResolver returns the scheme and resolver for a reference, and the reference
+// without its scheme.
+func secretResolver(value string) (string, SecretResolver, string, bool) {
+	for _, prefix := range []string{"$CREDENTIALS_DIRECTORY/", "${CREDENTIALS_DIRECTORY}/"} {
+		if name, ok := strings.CutPrefix(value, prefix); ok {
+			return "credential", resolveCredentialSecret, name, true
+		}
+	}
+
+	scheme, ref, ok := strings.Cut(value, "://")
+	if !ok {
+		return "", nil, "", false
+	}
+
+	secretResolvers.RLock()
+	defer secretResolvers.RUnlock()
+
+	resolver, ok := secretResolvers.byScheme[scheme]
+
+	return scheme, resolver, ref, ok
+}
+
+func resolveFileSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading file: %w", err)
+	}
+
+	return string(b), nil
+}
+
+func resolveEnvSecret(name string) (string, error) {
+	secret, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return secret, nil
+}
+
+func resolveExecSecret(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("no command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretExecTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).Output() // nolint: gosec
+
+	var exitErr *exec.ExitError
+
+	switch {
+	case errors.As(err, &exitErr) && len(exitErr.Stderr) > 0:
+		return "", fmt.Errorf("running %s: %w: %s", args[0], err, bytes.TrimSpace(exitErr.Stderr))
+	case err != nil:
+		return "", fmt.Errorf("running %s: %w", args[0], err)
+	}
+
+	return string(out), nil
+}
+
+func resolveCredentialSecret(name string) (string, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return "", errors.New("CREDENTIALS_DIRECTORY is not set; is this running under systemd with LoadCredential?")
+	}
+
+	if name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("invalid credential name %q", name)
+	}
+
+	return resolveFileSecret(filepath.Join(dir, name))
+}
@@ -0,0 +1,87 @@
+package poller_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// These tests set environment variables, so they do not run in parallel.
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pass"), []byte("from-file\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "unifi"), []byte("from-systemd\n"), 0o600))
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	t.Setenv("UP_TEST_SECRET", " from-env ")
+
+	for ref, want := range map[string]string{
+		"plain-password":                 "plain-password",
+		"https://not.a/secret":           "https://not.a/secret",
+		"file://" + dir + "/pass":        "from-file",
+		"env://UP_TEST_SECRET":           "from-env",
+		"exec://echo from-exec":          "from-exec",
+		"credential://unifi":             "from-systemd",
+		"$CREDENTIALS_DIRECTORY/unifi":   "from-systemd",
+		"${CREDENTIALS_DIRECTORY}/unifi": "from-systemd",
+	} {
+		got, err := poller.ResolveSecret(ref)
+		require.NoError(t, err, ref)
+		assert.Equal(t, want, got, ref)
+		assert.Equal(t, ref != want, poller.IsSecretRef(ref), ref)
+		assert.NoError(t, poller.CheckSecret(ref), ref)
+	}
+
+	for _, ref := range []string{"file://" + dir + "/missing", "env://UP_TEST_UNSET", "exec://false", "credential://../pass"} {
+		_, err := poller.ResolveSecret(ref)
+		require.ErrorIs(t, err, poller.ErrSecret, ref)
+	}
+
+	require.ErrorIs(t, poller.CheckSecret("file://"+dir+"/missing"), poller.ErrSecret)
+	require.ErrorIs(t, poller.CheckSecret("exec://no-such-command-here"), poller.ErrSecret)
+}
+
+func TestRegisterSecretResolver(t *testing.T) {
+	poller.RegisterSecretResolver("test-vault", func(ref string) (string, error) { return "vault:" + ref, nil })
+
+	got, err := poller.ResolveSecret("test-vault://unifi/pass")
+	require.NoError(t, err)
+	assert.Equal(t, "vault:unifi/pass", got)
+}
+
+func TestExecSecretsAreCached(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "count")
+	ref := "exec://sh " + writeScript(t, `echo x >> `+counter+`; wc -l < `+counter)
+
+	resolve := func() string {
+		got, err := poller.ResolveSecret(ref)
+		require.NoError(t, err)
+
+		return got
+	}
+
+	assert.Equal(t, "1", resolve())
+	assert.Equal(t, "1", resolve(), "the command's output is reused")
+
+	poller.ForgetSecret(ref)
+	assert.Equal(t, "2", resolve(), "a forgotten secret is resolved again")
+
+	poller.ForgetSecrets()
+	assert.Equal(t, "3", resolve(), "a reload resolves every secret again")
+}
+
+// writeScript writes a shell script, for exec:// commands that cannot take a shell's
+// quoting, and returns its path.
+func writeScript(t *testing.T, script string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "script.sh")
+	require.NoError(t, os.WriteFile(path, []byte(script+"\n"), 0o600))
+
+	return path
+}
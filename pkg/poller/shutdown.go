@@ -0,0 +1,72 @@
+package poller
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownTimeout is how long outputs get to flush, and inputs get to log out,
+// after a SIGTERM or SIGINT when the [poller] shutdown_timeout is not set.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Closer is an optional interface for inputs that hold sessions open, like a controller
+// login. Close is called once on shutdown, after the outputs have stopped.
+type Closer interface {
+	Close(context.Context) error
+}
+
+// FlushContext returns the context an output should flush and close its clients with
+// after its RunContext context is canceled. It expires after the shutdown_timeout.
+func FlushContext(c Collect) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.Poller().shutdownTimeout())
+}
+
+func (p Poller) shutdownTimeout() time.Duration {
+	if p.ShutdownTimeout.Duration <= 0 {
+		return DefaultShutdownTimeout
+	}
+
+	return p.ShutdownTimeout.Duration
+}
+
+// waitOutputs waits for the outputs implementing ContextOutputPlugin to return, for no
+// longer than the shutdown timeout. Outputs that only implement Run cannot be stopped.
+func (u *UnifiPoller) waitOutputs(running *sync.WaitGroup) {
+	timeout := u.Poller().shutdownTimeout()
+	done := make(chan struct{})
+
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+
+	u.Logf("Shutting down; waiting up to %v for outputs to flush", timeout)
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		u.LogErrorf("Outputs did not stop within %v; exiting anyway", timeout)
+	}
+}
+
+// closeInputs calls Close on every input that implements Closer.
+// They share one shutdown timeout between them.
+func (u *UnifiPoller) closeInputs() {
+	ctx, cancel := context.WithTimeout(context.Background(), u.Poller().shutdownTimeout())
+	defer cancel()
+
+	inputSync.RLock()
+	defer inputSync.RUnlock()
+
+	for _, input := range inputs {
+		closer, ok := input.Input.(Closer)
+		if !ok {
+			continue
+		}
+
+		if err := closer.Close(ctx); err != nil {
+			u.LogErrorf("Closing input %s: %v", input.Name, err)
+		}
+	}
+}
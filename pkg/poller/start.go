@@ -2,10 +2,13 @@
 package poller
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/pflag"
 	"golift.io/version"
@@ -48,7 +51,13 @@ func (u *UnifiPoller) Start() error {
 	}
 
 	u.Flags.ConfigFile = cfile
-	if u.Flags.DumpJSON == "" { // do not print this when dumping JSON.
+
+	switch {
+	case u.Flags.ValidateConfig:
+		return u.ValidateConfig()
+	case u.Flags.PrintConfig:
+		return u.PrintConfig()
+	case u.Flags.DumpJSON == "": // do not print this when dumping JSON.
 		u.Logf("Loading Configuration File: %s", u.Flags.ConfigFile)
 	}
 
@@ -57,6 +66,18 @@ func (u *UnifiPoller) Start() error {
 		return err
 	}
 
+	if u.Flags.SupportBundle != "" {
+		return u.WriteSupportBundle() // sets up logging itself, to keep a copy.
+	}
+
+	if err := u.SetupLogging(u.keepRecentLog(log.Writer())); err != nil {
+		return err
+	}
+
+	if err := u.SetupRelabel(); err != nil {
+		return err
+	}
+
 	if u.Flags.DebugIO {
 		err = u.DebugIO()
 		if err != nil {
@@ -82,12 +103,23 @@ func (f *Flags) Parse(args []string) {
 	f.StringVarP(&f.HashPW, "encrypt", "e", "",
 		"This option bcrypts a provided string. Useful for the webserver password. Use - to be prompted.")
 	f.StringVarP(&f.DumpJSON, "dumpjson", "j", "",
-		"This debug option prints a json payload and exits. See man page for more info.")
+		"This debug option prints a json payload and exits. Use \"list\" to see what it can print. See man page for more info.")
+	f.StringVarP(&f.SupportBundle, "support-bundle", "", "",
+		"Write a tar.gz with the redacted config, logs, checks and raw controller JSON for a bug report, then exit.")
+	f.Lookup("support-bundle").NoOptDefVal = DefaultSupportBundle
 	f.BoolVarP(&f.DebugIO, "debugio", "d", false, "Debug the Inputs and Outputs configured and exit.")
 	f.BoolVarP(&f.Health, "health", "", false, "Run health check and exit with status 0 (healthy) or 1 (unhealthy).")
 	f.BoolVarP(&f.Discover, "discover", "", false, "Discover API endpoints on the controller and write a shareable report, then exit.")
 	f.StringVarP(&f.DiscoverOutput, "discover-output", "", "api_endpoints_discovery.md",
 		"Path for the discovery report when using --discover.")
+	f.BoolVarP(&f.ValidateConfig, "validate-config", "", false,
+		"Check the config file for unknown keys and invalid values, then exit.")
+	f.BoolVarP(&f.PrintConfig, "print-config", "", false,
+		"Print the effective config with defaults applied and secrets redacted, then exit.")
+	f.BoolVarP(&f.Once, "once", "", false,
+		"Poll the inputs once, write to every push output, and exit. Exits 1 if any input or output failed.")
+	f.DurationVarP(&f.OnceWindow, "once-window", "", DefaultOnceWindow,
+		"With --once, collect events from this far back. Inputs with a state_dir cursor resume from it instead.")
 	f.StringVarP(&f.ConfigFile, "config", "c", DefaultConfFile(),
 		"Poller config file path. Separating multiple paths with a comma will load the first config file found.")
 	f.BoolVarP(&f.ShowVer, "version", "v", false, "Print the version and exit.")
@@ -95,12 +127,13 @@ func (f *Flags) Parse(args []string) {
 }
 
 // Run picks a mode and executes the associated functions. This will do one of three things:
-// 1. Start the collector routine that polls unifi and reports to influx on an interval. (default)
-// 2. Run the collector one time and report the metrics to influxdb. (lambda)
-// 3. Start a web server and wait for Prometheus to poll the application for metrics.
+// 1. Start every output; push outputs poll the inputs on an interval. (default)
+// 2. Poll the inputs once, write to every push output and return. (--once, for cron)
+// 3. Print one collection as json and return. (--dumpjson)
 func (u *UnifiPoller) Run() error {
 	if u.Flags.DumpJSON != "" {
 		u.Quiet = true
+		u.logLevels = nil // only errors, so they do not mix into the json.
 		if err := u.InitializeInputs(); err != nil {
 			return err
 		}
@@ -115,11 +148,37 @@ func (u *UnifiPoller) Run() error {
 
 	log.Printf("[INFO] UniFi Poller v%v Starting Up! PID: %d", version.Version, os.Getpid())
 
+	if u.Flags.Once {
+		return u.RunOnce()
+	}
+
+	if err := u.LoadState(); err != nil {
+		return err
+	}
+
 	if err := u.InitializeInputs(); err != nil {
 		return err
 	}
 
+	// SIGTERM and SIGINT stop the outputs, give them the shutdown_timeout to flush,
+	// then log out of the inputs. A clean shutdown returns nil and exits 0.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// SIGHUP, and a config file change with watch_config, reload the plugin configs.
+	go u.watchReload(ctx)
+	go u.recent.keep(ctx)
+
 	u.LogDebugf("staring outputs")
 
-	return u.InitializeOutputs()
+	err := u.InitializeOutputsContext(ctx)
+	u.closeInputs()
+
+	if err == nil {
+		u.Logf("Shutdown complete")
+	}
+
+	u.recent.save()
+
+	return err
 }
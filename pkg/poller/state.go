@@ -0,0 +1,392 @@
+package poller
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// StateFile is the file in the [poller] state_dir that holds the poller's state.
+const StateFile = "unpoller-state.json"
+
+// EventCursor is a high-water mark: the time of the newest event of one kind that
+// was collected from one site on one controller. Event collection resumes from it.
+type EventCursor struct {
+	Source string     `json:"source"`
+	Site   string     `json:"site"`
+	Kind   MetricKind `json:"kind"`
+	Time   time.Time  `json:"time"`
+	// IDs are the events at Time that were collected. Events at the cursor's time are
+	// collected again unless their ID is here, so none in the same second are lost.
+	IDs []string `json:"ids,omitempty"`
+}
+
+// savedState is the format of the StateFile.
+type savedState struct {
+	Events []*EventCursor `json:"events"`
+}
+
+type cursorKey struct {
+	source, site string
+	kind         MetricKind
+}
+
+func (c *EventCursor) key() cursorKey {
+	return cursorKey{source: c.Source, site: c.Site, kind: c.Kind}
+}
+
+// pendingCursors are the cursors of one collection of events. They are saved once
+// every output the events were handed to has written them.
+type pendingCursors struct {
+	cursors []*EventCursor
+	waiting int
+	failed  bool
+}
+
+// state is the poller's persistent state. Like telemetry, there is one per process.
+// It is off, and holds nothing, until LoadState finds a state_dir in the config.
+// Inputs read from the events cursors. The saved cursors only move once outputs wrote
+// the events, so a restart collects again what was not written.
+var state = struct { // nolint: gochecknoglobals
+	sync.Mutex
+	path    string
+	dirty   bool
+	events  map[cursorKey]*EventCursor
+	saved   map[cursorKey]*EventCursor
+	pending []*pendingCursors // in the order they were collected.
+}{
+	events: make(map[cursorKey]*EventCursor),
+	saved:  make(map[cursorKey]*EventCursor),
+}
+
+// LoadState reads the event cursors from the state_dir, and turns them on.
+// Without a state_dir the cursors are off and event collection works by time window.
+// Called by Run() before the inputs are initialized.
+func (u *UnifiPoller) LoadState() error {
+	state.Lock()
+	defer state.Unlock()
+
+	state.path, state.dirty, state.pending = "", false, nil
+	clear(state.events)
+	clear(state.saved)
+
+	if u.StateDir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(u.StateDir, 0o700); err != nil {
+		return fmt.Errorf("creating state_dir: %w", err)
+	}
+
+	path := filepath.Join(u.StateDir, StateFile)
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading state: %w", err)
+	}
+
+	if len(data) > 0 {
+		var saved savedState
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("reading state %s: %w", path, err)
+		}
+
+		for _, c := range saved.Events {
+			moveCursor(state.events, c)
+			moveCursor(state.saved, c)
+		}
+	}
+
+	state.path = path
+	u.Logf("Loaded %d event cursors from %s", len(state.saved), path)
+
+	return nil
+}
+
+// EventCursors returns true when event cursors are on. Inputs then return each event
+// once, across restarts, so outputs must not drop events for being older than their
+// interval; they may have arrived while the poller was down.
+func EventCursors() bool {
+	state.Lock()
+	defer state.Unlock()
+
+	return state.path != ""
+}
+
+// LastEvent returns the cursor for one kind of event from a site on a controller.
+// The zero time means there is none, or cursors are off.
+func LastEvent(source, site string, kind MetricKind) time.Time {
+	state.Lock()
+	defer state.Unlock()
+
+	if c := state.events[cursorKey{source: source, site: site, kind: kind}]; c != nil {
+		return c.Time
+	}
+
+	return time.Time{}
+}
+
+// SeenEvent returns true if an event at t, with an ID, was already collected: it is
+// older than its cursor, or at the cursor's time with an ID the cursor holds.
+func SeenEvent(source, site string, kind MetricKind, t time.Time, id string) bool {
+	state.Lock()
+	defer state.Unlock()
+
+	c := state.events[cursorKey{source: source, site: site, kind: kind}]
+
+	return c != nil && (t.Before(c.Time) || t.Equal(c.Time) && slices.Contains(c.IDs, id))
+}
+
+// OldestEvent returns the oldest cursor for one kind of event on a controller, across
+// its sites. Inputs use it to decide how far back to ask the controller for events.
+func OldestEvent(source string, kind MetricKind) time.Time {
+	state.Lock()
+	defer state.Unlock()
+
+	var oldest time.Time
+
+	for key, c := range state.events {
+		if key.source == source && key.kind == kind && (oldest.IsZero() || c.Time.Before(oldest)) {
+			oldest = c.Time
+		}
+	}
+
+	return oldest
+}
+
+// AdvanceEvent moves a cursor forward to t, and adds the IDs of the events at t to it.
+// It never moves back. It returns the cursor for inputs to put in the Events they
+// return, so it is saved once those are written; or nil when nothing moved, or
+// cursors are off.
+func AdvanceEvent(source, site string, kind MetricKind, t time.Time, ids ...string) *EventCursor {
+	state.Lock()
+	defer state.Unlock()
+
+	c := &EventCursor{Source: source, Site: site, Kind: kind, Time: t, IDs: ids}
+	if state.path == "" || !moveCursor(state.events, c) {
+		return nil
+	}
+
+	moved := *state.events[c.key()]
+	moved.IDs = slices.Clone(moved.IDs)
+
+	return &moved
+}
+
+// moveCursor moves the cursor in m forward to c. At the same time, the IDs of both are
+// kept. It returns false if that changed nothing.
+func moveCursor(m map[cursorKey]*EventCursor, c *EventCursor) bool {
+	key := c.key()
+
+	switch cur := m[key]; {
+	case cur == nil || c.Time.After(cur.Time):
+		m[key] = &EventCursor{Source: c.Source, Site: c.Site, Kind: c.Kind, Time: c.Time, IDs: slices.Clone(c.IDs)}
+	case c.Time.Equal(cur.Time):
+		moved := false
+
+		for _, id := range c.IDs {
+			if !slices.Contains(cur.IDs, id) {
+				cur.IDs, moved = append(cur.IDs, id), true
+			}
+		}
+
+		return moved
+	default:
+		return false
+	}
+
+	return true
+}
+
+// trackEvents takes the cursors out of events, to save them once waiting outputs
+// wrote the events. With none waiting, they are saved now, after the cursors of
+// earlier events that are still being written.
+func trackEvents(events *Events, waiting int) error {
+	if events == nil || len(events.Cursors) == 0 {
+		return nil
+	}
+
+	state.Lock()
+	defer state.Unlock()
+
+	if state.path == "" {
+		return nil
+	}
+
+	cursors := slices.DeleteFunc(events.Cursors, func(c *EventCursor) bool { return c == nil })
+	p := &pendingCursors{cursors: cursors, waiting: waiting}
+	events.Cursors, events.pending = nil, append(events.pending, p)
+	state.pending = append(state.pending, p)
+
+	return settleCursors()
+}
+
+// wroteEvents records that an output wrote events, or failed to with err. It saves
+// the cursors of the events every output has now written. After a failed write, the
+// cursors of those events are rewound to the saved ones, so inputs collect them again.
+func wroteEvents(events *Events, err error) error {
+	if events == nil || len(events.pending) == 0 {
+		return nil
+	}
+
+	state.Lock()
+	defer state.Unlock()
+
+	for _, p := range events.pending {
+		p.waiting--
+		p.failed = p.failed || err != nil
+	}
+
+	return settleCursors()
+}
+
+// settleCursors moves the saved cursors up to the oldest events that are not written
+// yet, and writes them to the state_dir. Call it with state locked.
+func settleCursors() error {
+	for len(state.pending) > 0 && state.pending[0].waiting <= 0 {
+		p := state.pending[0]
+		state.pending = state.pending[1:]
+
+		if p.failed {
+			rewindCursors(p.cursors)
+
+			continue
+		}
+
+		for _, c := range p.cursors {
+			if moveCursor(state.saved, c) {
+				state.dirty = true
+			}
+		}
+	}
+
+	return writeState()
+}
+
+// rewindCursors moves the cursors of events that failed to write back to the saved
+// ones, so inputs collect those events again on their next poll. Later events of the
+// same kinds that are still being written are collected again too, so their cursors
+// are dropped; the cursors of the events collected again are saved once written.
+// Call it with state locked.
+func rewindCursors(failed []*EventCursor) {
+	keys := make(map[cursorKey]bool)
+
+	for _, c := range failed {
+		key := c.key()
+		keys[key] = true
+
+		if saved := state.saved[key]; saved != nil {
+			rewound := *saved
+			rewound.IDs = slices.Clone(saved.IDs)
+			state.events[key] = &rewound
+		} else {
+			delete(state.events, key)
+		}
+	}
+
+	for _, p := range state.pending {
+		p.cursors = slices.DeleteFunc(p.cursors, func(c *EventCursor) bool { return keys[c.key()] })
+	}
+}
+
+// writeState writes the saved cursors to the state_dir if they changed.
+// Call it with state locked.
+func writeState() error {
+	if state.path == "" || !state.dirty {
+		return nil
+	}
+
+	saved := savedState{Events: make([]*EventCursor, 0, len(state.saved))}
+	for _, c := range state.saved {
+		saved.Events = append(saved.Events, c)
+	}
+
+	slices.SortFunc(saved.Events, func(a, b *EventCursor) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Site, b.Site), cmp.Compare(a.Kind, b.Kind))
+	})
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+
+	if err := writeFile(state.path, data); err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+
+	state.dirty = false
+
+	return nil
+}
+
+// StatePath returns the path to the file named name in the state_dir, or an empty
+// string when there is no state_dir. Plugins keep their own state in these files.
+func StatePath(name string) string {
+	state.Lock()
+	defer state.Unlock()
+
+	if state.path == "" {
+		return ""
+	}
+
+	return filepath.Join(filepath.Dir(state.path), name)
+}
+
+// LoadStateFile decodes the JSON file named name in the state_dir into v. It returns
+// false, and leaves v alone, when there is no state_dir or the file does not exist yet.
+func LoadStateFile(name string, v any) (bool, error) {
+	path := StatePath(name)
+	if path == "" {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("reading state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("reading state %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// SaveStateFile encodes v as JSON to the file named name in the state_dir, replacing
+// the file like the event cursors are. It does nothing when there is no state_dir.
+func SaveStateFile(name string, v any) error {
+	path := StatePath(name)
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+
+	if err := writeFile(path, data); err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+
+	return nil
+}
+
+// writeFile replaces the file at path with data. The data is written to a temporary
+// file first, so a crash leaves the previous file in place.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return os.Rename(tmp, path) //nolint:wrapcheck
+}
@@ -0,0 +1,242 @@
+package poller_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+	"golift.io/cnfg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Event cursors are global, so these tests do not run in parallel.
+
+func loadState(t *testing.T, dir string) {
+	t.Helper()
+
+	u := poller.New()
+	u.StateDir = dir
+	require.NoError(t, u.LoadState())
+	t.Cleanup(func() { _ = poller.New().LoadState() })
+}
+
+func TestEventCursorsPersistAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Round(0)
+
+	loadState(t, dir)
+	require.True(t, poller.EventCursors())
+
+	input := &cursorInput{cursors: []*poller.EventCursor{
+		poller.AdvanceEvent("https://udm", "default", "event", now, "a"),
+		poller.AdvanceEvent("https://udm", "default", "event", now.Add(-time.Hour)),
+		poller.AdvanceEvent("https://udm", "guest", "event", now.Add(-time.Minute)),
+		poller.AdvanceEvent("https://udm", "default", "event", now, "b"),
+	}}
+	assert.Nil(t, input.cursors[1], "a cursor never moves back")
+	assert.Equal(t, now, poller.LastEvent("https://udm", "default", "event"))
+	assert.Equal(t, now.Add(-time.Minute), poller.OldestEvent("https://udm", "event"))
+
+	assert.True(t, poller.SeenEvent("https://udm", "default", "event", now, "b"))
+	assert.False(t, poller.SeenEvent("https://udm", "default", "event", now, "c"), "another event in the same second is new")
+	assert.True(t, poller.SeenEvent("https://udm", "default", "event", now.Add(-time.Second), "c"))
+
+	collector := poller.NewTestCollector(t)
+	collector.AddInput(&poller.InputPlugin{Name: "cursor-input", Input: input})
+	_, err := collector.Events(nil) // saves the cursors; nothing waits to write them.
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, poller.StateFile))
+
+	loadState(t, dir)
+	assert.True(t, now.Equal(poller.LastEvent("https://udm", "default", "event")))
+	assert.True(t, poller.SeenEvent("https://udm", "default", "event", now, "a"), "the IDs at the cursor are saved")
+	assert.Zero(t, poller.LastEvent("https://udm", "default", "alarm"))
+
+	require.NoError(t, poller.New().LoadState())
+	assert.False(t, poller.EventCursors())
+	assert.Zero(t, poller.LastEvent("https://udm", "default", "event"))
+	assert.Nil(t, poller.AdvanceEvent("https://udm", "default", "event", now))
+	assert.Zero(t, poller.LastEvent("https://udm", "default", "event"), "cursors are off without a state_dir")
+}
+
+// cursorInput returns events with the cursors it was given, once.
+type cursorInput struct {
+	countingInput
+	cursors []*poller.EventCursor
+}
+
+func (c *cursorInput) Events(*poller.Filter) (*poller.Events, error) {
+	cursors := c.cursors
+	c.cursors = nil
+
+	return &poller.Events{Logs: []any{len(cursors)}, Cursors: cursors}, nil
+}
+
+// savedEvent returns the time of a cursor in the state file.
+func savedEvent(t *testing.T, dir, site string) time.Time {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(dir, poller.StateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}
+	}
+
+	require.NoError(t, err)
+
+	var saved struct {
+		Events []*poller.EventCursor `json:"events"`
+	}
+
+	require.NoError(t, json.Unmarshal(data, &saved))
+
+	for _, c := range saved.Events {
+		if c.Site == site {
+			return c.Time
+		}
+	}
+
+	return time.Time{}
+}
+
+func TestEventCursorsSavedOnceWritten(t *testing.T) {
+	dir := t.TempDir()
+	loadState(t, dir)
+
+	collector := poller.NewTestCollector(discardLog{})
+	collector.SetPoller(&poller.Poller{Interval: cnfg.Duration{Duration: time.Hour}})
+
+	sub := poller.Subscription{Interval: time.Hour, Events: true, Stream: true, Ack: true}
+	one, two := sub, sub
+	one.Name, two.Name = "one", "two"
+	first, second := collector.Subscribe(&one), collector.Subscribe(&two)
+	start := time.Now().UTC().Round(time.Second)
+
+	push := func(n int) (*poller.Snapshot, *poller.Snapshot) {
+		t.Helper()
+
+		cursor := poller.AdvanceEvent("https://udm", "default", "event", start.Add(time.Duration(n)*time.Second))
+		collector.PushEvents(&poller.Events{Logs: []any{n}, Cursors: []*poller.EventCursor{cursor}})
+
+		return receive(t, first), receive(t, second)
+	}
+
+	a, b := push(1)
+	a.Done(nil)
+	assert.Zero(t, savedEvent(t, dir, "default"), "cursors wait for every output to write the events")
+	b.Done(nil)
+	b.Done(errors.New("done twice")) //nolint:err113
+	assert.Equal(t, start.Add(time.Second), savedEvent(t, dir, "default"))
+
+	a, b = push(2)
+	a.Done(errors.New("write failed")) //nolint:err113
+	b.Done(nil)
+	assert.Equal(t, start.Add(time.Second), savedEvent(t, dir, "default"), "failed writes are not saved")
+	assert.Equal(t, start.Add(time.Second), poller.LastEvent("https://udm", "default", "event"),
+		"after a failed write, the cursor is rewound so the next poll collects the events again")
+
+	a, b = push(3)
+	a.Done(nil)
+	b.Done(nil)
+	assert.Equal(t, start.Add(3*time.Second), savedEvent(t, dir, "default"),
+		"writes after a failed one move the saved cursors again")
+}
+
+func TestFailedWriteDropsCursorsOfLaterEvents(t *testing.T) {
+	dir := t.TempDir()
+	loadState(t, dir)
+
+	collector := poller.NewTestCollector(discardLog{})
+	collector.SetPoller(&poller.Poller{Interval: cnfg.Duration{Duration: time.Hour}})
+
+	ch := collector.Subscribe(&poller.Subscription{Name: "one", Interval: time.Hour, Events: true, Stream: true, Ack: true})
+	start := time.Now().UTC().Round(time.Second)
+
+	push := func(n int) *poller.Snapshot {
+		t.Helper()
+
+		cursor := poller.AdvanceEvent("https://udm", "default", "event", start.Add(time.Duration(n)*time.Second))
+		collector.PushEvents(&poller.Events{Logs: []any{n}, Cursors: []*poller.EventCursor{cursor}})
+
+		return receive(t, ch)
+	}
+
+	failed, later := push(1), push(2)
+	failed.Done(errors.New("write failed")) //nolint:err113
+	later.Done(nil)
+
+	assert.Zero(t, savedEvent(t, dir, "default"),
+		"events collected before the rewind do not save past the events that failed to write")
+	assert.Zero(t, poller.LastEvent("https://udm", "default", "event"), "both are collected again")
+
+	push(2).Done(nil)
+	assert.Equal(t, start.Add(2*time.Second), savedEvent(t, dir, "default"))
+}
+
+func TestLoadStateRejectsCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, poller.StateFile), []byte("{"), 0o600))
+
+	u := poller.New()
+	u.StateDir = dir
+	require.Error(t, u.LoadState())
+}
+
+// eventInput returns one new event each poll, like an input with cursors on.
+type eventInput struct {
+	countingInput
+	next atomic.Int64
+}
+
+func (e *eventInput) Events(*poller.Filter) (*poller.Events, error) {
+	return &poller.Events{Logs: []any{e.next.Add(1)}}, nil
+}
+
+func TestSchedulerHoldsEventsForSubscribersNotDue(t *testing.T) {
+	loadState(t, t.TempDir())
+
+	collector := poller.NewTestCollector(discardLog{})
+	collector.SetPoller(&poller.Poller{Interval: cnfg.Duration{Duration: 50 * time.Millisecond}})
+	collector.AddInput(&poller.InputPlugin{Name: "event-input", Input: &eventInput{}})
+
+	fast := collector.Subscribe(&poller.Subscription{Name: "fast", Interval: 50 * time.Millisecond, Events: true})
+	slow := collector.Subscribe(&poller.Subscription{Name: "slow", Interval: 100 * time.Millisecond, Events: true})
+
+	assert.Equal(t, []any{int64(1)}, receive(t, fast).Events.Logs)
+	assert.Equal(t, []any{int64(1), int64(2)}, receive(t, slow).Events.Logs,
+		"events polled while slow was not due must be held for it")
+}
+
+func TestStateFiles(t *testing.T) {
+	type inventory struct {
+		Clients []string `json:"clients"`
+	}
+
+	var got inventory
+
+	require.NoError(t, poller.New().LoadState())
+	require.NoError(t, poller.SaveStateFile("inventory.json", &inventory{Clients: []string{"a"}}))
+	ok, err := poller.LoadStateFile("inventory.json", &got)
+	require.NoError(t, err)
+	assert.False(t, ok, "state files are off without a state_dir")
+
+	dir := t.TempDir()
+	loadState(t, dir)
+	assert.Equal(t, filepath.Join(dir, "inventory.json"), poller.StatePath("inventory.json"))
+
+	ok, err = poller.LoadStateFile("inventory.json", &got)
+	require.NoError(t, err)
+	assert.False(t, ok, "there is no file yet")
+
+	require.NoError(t, poller.SaveStateFile("inventory.json", &inventory{Clients: []string{"a", "b"}}))
+	ok, err = poller.LoadStateFile("inventory.json", &got)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b"}, got.Clients)
+}
@@ -0,0 +1,158 @@
+package poller
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Telemetry is what the poller records about its own work: how long each input took to
+// collect, how long each API call to a controller took, and how each output's writes
+// went. Outputs may export it along with the UniFi data; get it with GetTelemetry.
+type Telemetry struct {
+	Polls    []*PollStats    `json:"polls"`
+	Requests []*RequestStats `json:"requests"`
+	Writes   []*WriteStats   `json:"writes"`
+}
+
+// CallStats counts calls of one kind, and how long they took.
+type CallStats struct {
+	Count     uint64        `json:"count"`
+	Errors    uint64        `json:"errors"`
+	Total     time.Duration `json:"total"`
+	Last      time.Duration `json:"last"`
+	Max       time.Duration `json:"max"`
+	LastTime  time.Time     `json:"last_time"`
+	LastError string        `json:"last_error,omitempty"`
+}
+
+// PollStats is one input's metrics or events collections. Kind is "metrics" or "events".
+type PollStats struct {
+	Input string `json:"input"`
+	Kind  string `json:"kind"`
+	CallStats
+}
+
+// RequestStats is one input's requests to one endpoint on one controller.
+type RequestStats struct {
+	Input      string `json:"input"`
+	Controller string `json:"controller"`
+	Endpoint   string `json:"endpoint"`
+	CallStats
+}
+
+// WriteStats is one output's writes, ie. a batch sent to a database.
+type WriteStats struct {
+	Output string `json:"output"`
+	Points uint64 `json:"points"`
+	CallStats
+}
+
+// telemetry is the poller's registry. Like the plugin lists, there is one per process.
+var telemetry = struct { // nolint: gochecknoglobals
+	sync.Mutex
+	polls    map[[2]string]*PollStats
+	requests map[[3]string]*RequestStats
+	writes   map[string]*WriteStats
+}{
+	polls:    make(map[[2]string]*PollStats),
+	requests: make(map[[3]string]*RequestStats),
+	writes:   make(map[string]*WriteStats),
+}
+
+func (s *CallStats) record(elapsed time.Duration, err error) {
+	s.Count++
+	s.Total += elapsed
+	s.Last = elapsed
+	s.Max = max(s.Max, elapsed)
+	s.LastTime = time.Now()
+
+	if err != nil {
+		s.Errors++
+		s.LastError = err.Error()
+	}
+}
+
+// RecordPoll records one collection of metrics or events from an input. The core calls
+// this for every input; inputs do not need to.
+func RecordPoll(input, kind string, elapsed time.Duration, err error) {
+	telemetry.Lock()
+	defer telemetry.Unlock()
+
+	key := [2]string{input, kind}
+	if telemetry.polls[key] == nil {
+		telemetry.polls[key] = &PollStats{Input: input, Kind: kind}
+	}
+
+	telemetry.polls[key].record(elapsed, err)
+}
+
+// RecordRequest records one API call an input made to a controller. Endpoint names
+// the call, ie. GetClients.
+func RecordRequest(input, controller, endpoint string, elapsed time.Duration, err error) {
+	telemetry.Lock()
+	defer telemetry.Unlock()
+
+	key := [3]string{input, controller, endpoint}
+	if telemetry.requests[key] == nil {
+		telemetry.requests[key] = &RequestStats{Input: input, Controller: controller, Endpoint: endpoint}
+	}
+
+	telemetry.requests[key].record(elapsed, err)
+}
+
+// RecordWrite records one write by an output, and how many points it wrote.
+// Points are only counted when the write succeeds.
+func RecordWrite(output string, elapsed time.Duration, points int, err error) {
+	telemetry.Lock()
+	defer telemetry.Unlock()
+
+	if telemetry.writes[output] == nil {
+		telemetry.writes[output] = &WriteStats{Output: output}
+	}
+
+	telemetry.writes[output].record(elapsed, err)
+
+	if err == nil && points > 0 {
+		telemetry.writes[output].Points += uint64(points)
+	}
+}
+
+// GetTelemetry returns a copy of everything recorded so far, sorted by name.
+func GetTelemetry() *Telemetry {
+	telemetry.Lock()
+	defer telemetry.Unlock()
+
+	t := &Telemetry{
+		Polls:    make([]*PollStats, 0, len(telemetry.polls)),
+		Requests: make([]*RequestStats, 0, len(telemetry.requests)),
+		Writes:   make([]*WriteStats, 0, len(telemetry.writes)),
+	}
+
+	for _, s := range telemetry.polls {
+		c := *s
+		t.Polls = append(t.Polls, &c)
+	}
+
+	for _, s := range telemetry.requests {
+		c := *s
+		t.Requests = append(t.Requests, &c)
+	}
+
+	for _, s := range telemetry.writes {
+		c := *s
+		t.Writes = append(t.Writes, &c)
+	}
+
+	slices.SortFunc(t.Polls, func(a, b *PollStats) int {
+		return cmp.Or(cmp.Compare(a.Input, b.Input), cmp.Compare(a.Kind, b.Kind))
+	})
+	slices.SortFunc(t.Requests, func(a, b *RequestStats) int {
+		return cmp.Or(cmp.Compare(a.Input, b.Input), cmp.Compare(a.Controller, b.Controller),
+			cmp.Compare(a.Endpoint, b.Endpoint))
+	})
+	slices.SortFunc(t.Writes, func(a, b *WriteStats) int { return cmp.Compare(a.Output, b.Output) })
+
+	return t
+}
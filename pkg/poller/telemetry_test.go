@@ -0,0 +1,73 @@
+package poller_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTelemetryTest = errors.New("timeout")
+
+func TestTelemetryRecordsRequestsAndWrites(t *testing.T) {
+	t.Parallel()
+
+	poller.RecordRequest("telemetry-test", "https://b", "GetDevices", 2*time.Second, nil)
+	poller.RecordRequest("telemetry-test", "https://a", "GetClients", time.Second, nil)
+	poller.RecordRequest("telemetry-test", "https://a", "GetClients", 3*time.Second, errTelemetryTest)
+	poller.RecordWrite("telemetry-test-output", time.Second, 100, nil)
+	poller.RecordWrite("telemetry-test-output", time.Second, 50, errTelemetryTest)
+
+	var requests []*poller.RequestStats
+
+	for _, r := range poller.GetTelemetry().Requests {
+		if r.Input == "telemetry-test" {
+			requests = append(requests, r)
+		}
+	}
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "https://a", requests[0].Controller, "sorted by controller")
+	assert.Equal(t, "GetClients", requests[0].Endpoint)
+	assert.EqualValues(t, 2, requests[0].Count)
+	assert.EqualValues(t, 1, requests[0].Errors)
+	assert.Equal(t, 4*time.Second, requests[0].Total)
+	assert.Equal(t, 3*time.Second, requests[0].Max)
+	assert.Equal(t, "timeout", requests[0].LastError)
+
+	for _, w := range poller.GetTelemetry().Writes {
+		if w.Output == "telemetry-test-output" {
+			assert.EqualValues(t, 2, w.Count)
+			assert.EqualValues(t, 1, w.Errors)
+			assert.EqualValues(t, 100, w.Points, "failed writes do not count their points")
+
+			return
+		}
+	}
+
+	t.Fatal("write stats missing")
+}
+
+func TestTelemetryRecordsInputPolls(t *testing.T) {
+	t.Parallel()
+
+	collector := poller.NewTestCollector(t)
+	collector.AddInput(&poller.InputPlugin{Name: "telemetry-poll-input", Input: nilEventsInput{}})
+
+	_, err := collector.Metrics(nil)
+	require.NoError(t, err)
+
+	for _, p := range poller.GetTelemetry().Polls {
+		if p.Input == "telemetry-poll-input" && p.Kind == "metrics" {
+			assert.EqualValues(t, 1, p.Count)
+
+			return
+		}
+	}
+
+	t.Fatal("poll stats missing")
+}
@@ -1,14 +1,18 @@
 package poller
 
 import (
+	"context"
 	"sync"
+	"time"
 )
 
 type TestCollector struct {
 	sync.RWMutex
-	Logger Logger
-	inputs []*InputPlugin
-	poller *Poller
+	Logger  Logger
+	inputs  []*InputPlugin
+	poller  *Poller
+	relabel []*relabeler
+	sched   *scheduler
 }
 
 func NewTestCollector(l testLogger) *TestCollector {
@@ -25,24 +29,91 @@ func (t *TestCollector) AddInput(input *InputPlugin) {
 }
 
 func (t *TestCollector) Metrics(filter *Filter) (*Metrics, error) {
+	return t.MetricsContext(context.Background(), filter)
+}
+
+func (t *TestCollector) MetricsContext(ctx context.Context, filter *Filter) (*Metrics, error) {
 	t.RLock()
 	defer t.RUnlock()
 
-	return collectMetrics(filter, t.inputs)
+	metrics, err := collectMetrics(ctx, filter, t.inputs)
+	relabelMetrics(t.relabel, metrics)
+
+	return metrics, err
 }
 
 func (t *TestCollector) Events(filter *Filter) (*Events, error) {
+	return t.EventsContext(context.Background(), filter)
+}
+
+func (t *TestCollector) EventsContext(ctx context.Context, filter *Filter) (*Events, error) {
 	t.RLock()
 	defer t.RUnlock()
 
-	return collectEvents(filter, t.inputs)
+	events, err := collectEvents(ctx, filter, t.inputs)
+	relabelEvents(t.relabel, events)
+
+	if filter == nil || !filter.ack {
+		if err := trackEvents(events, 0); err != nil {
+			t.LogErrorf("Saving event cursors: %v", err)
+		}
+	}
+
+	return events, err
+}
+
+// Subscribe starts a poll scheduler on the first call, using the Interval
+// from SetPoller as the base tick if one was set.
+func (t *TestCollector) Subscribe(sub *Subscription) <-chan *Snapshot {
+	t.Lock()
+	if t.sched == nil {
+		var base time.Duration
+		if t.poller != nil {
+			base = t.poller.Interval.Duration
+		}
+
+		t.sched = newScheduler(t, base)
+		go t.sched.run(context.Background())
+	}
+	t.Unlock()
+
+	return t.sched.subscribe(sub)
+}
+
+// PushEvents hands events to the scheduler like an EventStreamer input does.
+// Call Subscribe first.
+func (t *TestCollector) PushEvents(events *Events) {
+	t.Lock()
+	sched, rules := t.sched, t.relabel
+	t.Unlock()
+
+	relabelEvents(rules, events)
+	sched.push("", events)
+}
+
+// Reschedule changes the interval of a subscription made with Subscribe.
+func (t *TestCollector) Reschedule(sub *Subscription, interval time.Duration) {
+	t.Lock()
+	sched := t.sched
+	t.Unlock()
+
+	if sched != nil {
+		sched.reschedule(sub, interval)
+	}
 }
 
+// SetPoller sets the poller config, and compiles its relabel rules. It panics
+// on rules that do not compile, as that is a mistake in the test.
 func (t *TestCollector) SetPoller(poller *Poller) {
 	t.Lock()
 	defer t.Unlock()
 
-	t.poller = poller
+	rules, err := compileRelabel(poller.Relabel)
+	if err != nil {
+		panic(err)
+	}
+
+	t.poller, t.relabel = poller, rules
 }
 
 func (t *TestCollector) Poller() Poller {
@@ -0,0 +1,271 @@
+package poller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"golift.io/cnfg"
+)
+
+// ConfigChecker is an optional interface for inputs and outputs. CheckConfig is called
+// by --validate-config after the configs are parsed. It returns one error for each
+// value that parses, but will not work as written: out of range, malformed, or in
+// conflict with another value. It must not connect to anything.
+type ConfigChecker interface {
+	CheckConfig() []error
+}
+
+// ConfigPrinter is an optional interface for inputs and outputs. EffectiveConfig is
+// called by --print-config after the configs are parsed. It returns the plugin's config
+// with defaults applied and secrets redacted, the way it is shown on the web interface.
+type ConfigPrinter interface {
+	EffectiveConfig() any
+}
+
+var errConfigProblems = fmt.Errorf("config file has problems")
+
+// ValidateConfig parses the config file for the poller and every input and output,
+// then prints unknown keys, deprecated keys, and values the plugins report as invalid.
+// Returns an error if there is anything to fix; deprecated keys are only warnings.
+// Used by --validate-config.
+func (u *UnifiPoller) ValidateConfig() error {
+	fmt.Printf("Validating %s\n", u.Flags.ConfigFile)
+
+	warnings, problems, err := u.checkKeys()
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if err := u.ParseConfigs(); err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		problems = append(problems, u.checkValues()...)
+	}
+
+	for _, msg := range warnings {
+		fmt.Println("WARNING:", msg)
+	}
+
+	for _, msg := range problems {
+		fmt.Println("ERROR:", msg)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %d error(s), %d warning(s)", errConfigProblems, len(problems), len(warnings))
+	}
+
+	fmt.Printf("Config is valid, %d warning(s)\n", len(warnings))
+
+	return nil
+}
+
+// checkKeys compares the keys in the config file to the keys the config structs accept.
+func (u *UnifiPoller) checkKeys() (warnings, problems []string, err error) {
+	file := u.Flags.ConfigFile
+
+	data, err := readConfigFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	format := configFormat(file)
+
+	keys, err := fileKeys(format, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	tree := u.configKeys(format)
+	unknown := make(map[string]bool)
+
+KEYS:
+	for _, key := range keys {
+		// Only report the outermost key of an unknown section.
+		for i := range key.path {
+			if unknown[strings.Join(key.path[:i], ".")] {
+				continue KEYS
+			}
+		}
+
+		node, ok := tree.find(key.path)
+
+		switch {
+		case !ok:
+			unknown[key.String()] = true
+			problems = append(problems, fmt.Sprintf("%s: unknown key %s", position(file, key.line), key))
+		case node.deprecated != "":
+			warnings = append(warnings, fmt.Sprintf("%s: deprecated key %s: %s",
+				position(file, key.line), key, node.deprecated))
+		}
+	}
+
+	return warnings, problems, nil
+}
+
+// configKeys returns the keys accepted by the poller config and every plugin config.
+func (u *UnifiPoller) configKeys(format string) *keyTree {
+	inputSync.RLock()
+	defer inputSync.RUnlock()
+
+	outputSync.RLock()
+	defer outputSync.RUnlock()
+
+	tree := &keyTree{}
+	tree.add(reflect.TypeOf(u.Config), format, 0)
+
+	for _, i := range inputs {
+		tree.add(reflect.TypeOf(i.Config), format, 0)
+	}
+
+	for _, o := range outputs {
+		tree.add(reflect.TypeOf(o.Config), format, 0)
+	}
+
+	return tree
+}
+
+func position(file string, line int) string {
+	if line == 0 {
+		return file
+	}
+
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// checkValues runs the core's checks and every plugin's ConfigChecker.
+func (u *UnifiPoller) checkValues() []string {
+	var problems []string
+
+	if u.Interval.Duration < 0 {
+		problems = append(problems, fmt.Sprintf("poller: interval must not be negative: %v", u.Interval))
+	}
+
+	if u.ShutdownTimeout.Duration < 0 {
+		problems = append(problems, fmt.Sprintf("poller: shutdown_timeout must not be negative: %v", u.ShutdownTimeout))
+	}
+
+	switch strings.ToLower(u.LogFormat) {
+	case "", LogFormatText, LogFormatLogfmt, LogFormatJSON:
+	default:
+		problems = append(problems, fmt.Sprintf("poller: %v, not %q", ErrLogFormat, u.LogFormat))
+	}
+
+	if _, err := parseLogLevels(u.LogLevels); err != nil {
+		problems = append(problems, "poller: "+err.Error())
+	}
+
+	if _, err := compileRelabel(u.Relabel); err != nil {
+		problems = append(problems, "poller: "+err.Error())
+	}
+
+	inputSync.RLock()
+	defer inputSync.RUnlock()
+
+	outputSync.RLock()
+	defer outputSync.RUnlock()
+
+	for _, plugin := range slices.Sorted(maps.Keys(u.LogLevels)) {
+		if !slices.ContainsFunc(inputs, func(i *InputPlugin) bool { return strings.EqualFold(i.Name, plugin) }) &&
+			!slices.ContainsFunc(outputs, func(o *Output) bool { return strings.EqualFold(o.Name, plugin) }) {
+			problems = append(problems, fmt.Sprintf("poller: log_levels.%s: no plugin has that name", plugin))
+		}
+	}
+
+	for _, i := range inputs {
+		if c, ok := i.Input.(ConfigChecker); ok {
+			for _, err := range c.CheckConfig() {
+				problems = append(problems, fmt.Sprintf("%s: %v", i.Name, err))
+			}
+		}
+	}
+
+	for _, o := range outputs {
+		if c, ok := o.OutputPlugin.(ConfigChecker); ok {
+			for _, err := range c.CheckConfig() {
+				problems = append(problems, fmt.Sprintf("%s: %v", o.Name, err))
+			}
+		}
+	}
+
+	return problems
+}
+
+// PrintConfig prints the poller config and every input and output config as json, with
+// defaults applied and secrets redacted. A plugin that does not implement ConfigPrinter
+// is listed without its config, because there is no telling which values are secrets.
+// Used by --print-config.
+func (u *UnifiPoller) PrintConfig() error {
+	// Keep log lines, ie. about unreadable password files, out of the json.
+	log.SetOutput(os.Stderr)
+
+	if err := u.ParseConfigs(); err != nil {
+		return err
+	}
+
+	b, err := u.effectiveConfig()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(b))
+
+	return nil
+}
+
+// effectiveConfig returns the parsed config as json, with defaults applied and secrets
+// redacted, for --print-config and --support-bundle.
+func (u *UnifiPoller) effectiveConfig() ([]byte, error) {
+	core := *u.Config.Poller
+	if core.Interval.Duration <= 0 {
+		core.Interval = cnfg.Duration{Duration: DefaultPollInterval}
+	}
+
+	core.ShutdownTimeout = cnfg.Duration{Duration: core.shutdownTimeout()}
+
+	config := map[string]any{"poller": core, "inputs": u.inputConfigs(), "outputs": u.outputConfigs()}
+
+	b, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding config: %w", err)
+	}
+
+	return b, nil
+}
+
+const configNotShown = "not shown; this plugin cannot redact its secrets"
+
+func (u *UnifiPoller) inputConfigs() map[string]any {
+	inputSync.RLock()
+	defer inputSync.RUnlock()
+
+	configs := make(map[string]any)
+
+	for _, i := range inputs {
+		configs[i.Name] = configNotShown
+		if p, ok := i.Input.(ConfigPrinter); ok {
+			configs[i.Name] = p.EffectiveConfig()
+		}
+	}
+
+	return configs
+}
+
+func (u *UnifiPoller) outputConfigs() map[string]any {
+	outputSync.RLock()
+	defer outputSync.RUnlock()
+
+	configs := make(map[string]any)
+
+	for _, o := range outputs {
+		configs[o.Name] = configNotShown
+		if p, ok := o.OutputPlugin.(ConfigPrinter); ok {
+			configs[o.Name] = p.EffectiveConfig()
+		}
+	}
+
+	return configs
+}
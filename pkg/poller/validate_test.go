@@ -0,0 +1,113 @@
+package poller_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validate runs ValidateConfig against a config file and returns what it printed.
+func validate(t *testing.T, name, content string) (string, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	writeConfig(t, path, content)
+
+	u := poller.New()
+	u.Flags.ConfigFile = path
+	u.Quiet = true
+
+	read, write, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = write
+
+	defer func() { os.Stdout = stdout }()
+
+	err = u.ValidateConfig()
+
+	require.NoError(t, write.Close())
+
+	out, readErr := io.ReadAll(read)
+	require.NoError(t, readErr)
+
+	return string(out), err
+}
+
+// Not parallel: swaps os.Stdout.
+func TestValidateConfigReportsUnknownKeys(t *testing.T) {
+	tests := map[string]string{
+		"up.conf":      "[poller]\ndebug = true\n\n# a comment\nintervall = \"5s\"\n",
+		"up.json":      "{\n  \"poller\": {\n    \"debug\": true,\n    \"intervall\": \"5s\"\n  }\n}\n",
+		"up.yaml":      "poller:\n  debug: true\n  # a comment\n  intervall: 5s\n",
+		"up.xml":       "<config>\n  <poller debug=\"true\">\n    <intervall>5s</intervall>\n  </poller>\n</config>\n",
+		"nested.conf":  "[poller]\ndebug = true\n[poller.intervall]\nvalue = 1\nother = 2\n",
+		"section.yaml": "poller:\n  debug: true\nnot_a_plugin:\n  url: x\n  user: y\n",
+	}
+	lines := map[string]string{
+		"up.conf":      ":5: unknown key poller.intervall",
+		"up.json":      ":4: unknown key poller.intervall",
+		"up.yaml":      ":4: unknown key poller.intervall",
+		"up.xml":       ":3: unknown key poller.intervall",
+		"nested.conf":  ":3: unknown key poller.intervall\n",
+		"section.yaml": ":3: unknown key not_a_plugin\n",
+	}
+
+	for name, content := range tests {
+		out, err := validate(t, name, content)
+
+		require.Error(t, err, name)
+		assert.Contains(t, out, name+lines[name], name)
+		assert.NotContains(t, out, "poller.debug", name)
+		assert.NotContains(t, out, ".value", "keys below an unknown key are not reported")
+		assert.NotContains(t, out, ".url", "keys below an unknown key are not reported")
+	}
+}
+
+// Not parallel: swaps os.Stdout.
+func TestValidateConfigAcceptsKnownKeys(t *testing.T) {
+	out, err := validate(t, "up.conf", "[poller]\ndebug = true\ninterval = \"10s\"\n")
+
+	require.NoError(t, err)
+	assert.Contains(t, out, "Config is valid")
+}
+
+// Not parallel: swaps os.Stdout.
+func TestValidateConfigChecksValues(t *testing.T) {
+	out, err := validate(t, "up.conf", "[poller]\nshutdown_timeout = \"-1s\"\n")
+
+	require.Error(t, err)
+	assert.Contains(t, out, "shutdown_timeout must not be negative")
+}
+
+// Not parallel: swaps os.Stdout.
+func TestValidateConfigWarnsOfDeprecatedKeys(t *testing.T) {
+	tests := map[string]string{
+		"up.conf": "[poller]\ndebug = true\nplugins = []\n",
+		"up.json": "{\n  \"poller\": {\n    \"debug\": true,\n    \"plugins\": []\n  }\n}\n",
+		"up.yaml": "poller:\n  debug: true\n  plugins: []\n",
+		"up.xml":  "<config>\n  <poller debug=\"true\">\n    <plugin></plugin>\n  </poller>\n</config>\n",
+	}
+	lines := map[string]string{
+		"up.conf": ":3: deprecated key poller.plugins: use the [exec] plugin",
+		"up.json": ":4: deprecated key poller.plugins: use the [exec] plugin",
+		"up.yaml": ":3: deprecated key poller.plugins: use the [exec] plugin",
+		"up.xml":  ":3: deprecated key poller.plugin: use the [exec] plugin",
+	}
+
+	for name, content := range tests {
+		out, err := validate(t, name, content)
+
+		require.NoError(t, err, "deprecated keys are only warnings: %s", name)
+		assert.Contains(t, out, "WARNING: ", name)
+		assert.Contains(t, out, name+lines[name], name)
+		assert.Contains(t, out, "Config is valid, 1 warning(s)", name)
+	}
+}
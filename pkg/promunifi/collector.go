@@ -2,6 +2,8 @@
 package promunifi
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -86,11 +88,15 @@ type promUnifi struct {
 	DPICategory         *dpiCategory
 	PendingDevice       *pendingDevice
 	Country             *country
+	ClientInventory     *clientInventory
 	// controllerUp tracks per-controller poll success (1) or failure (0).
 	// Reflects the most recent background poll — when /metrics is served from
 	// a stale cache, controllerUp lags real-time health; pair with
 	// unpoller_prometheus_cache_age_seconds for staleness signals.
 	controllerUp *prometheus.GaugeVec
+	// controllerThrottled is 1 when the controller asked us to back off, and some or
+	// all of its collections were skipped on the most recent background poll.
+	controllerThrottled *prometheus.GaugeVec
 	// refreshFailures counts background refresh failures since process start
 	// so operators can alert on failure rate independently of cache staleness.
 	refreshFailures prometheus.Counter
@@ -102,6 +108,23 @@ type promUnifi struct {
 	// scrapeFlight coalesces concurrent /scrape requests targeting the same
 	// controller URL so a noisy scraper can't multiply upstream load.
 	scrapeFlight singleflight.Group
+	// exporters routes each collected type to its export method. It is built once, by
+	// handlers; the methods read the descriptions at export time, so reloads keep it.
+	exporters     *poller.Handlers[report]
+	exportersOnce sync.Once
+	// telemetry and cacheAge are registered with the gauges above, and
+	// registered again when a reload changes the namespace.
+	telemetry *telemetry
+	cacheAge  prometheus.Collector
+	// registered is true once this collector is registered with Prometheus.
+	registered bool
+	// mu locks the config and the metric descriptions, which a reload replaces
+	// while scrapes read them.
+	mu sync.RWMutex
+	// reload carries configs from Reload to the background poll, which applies them.
+	reload chan *Config
+	// relisten tells the web server to start again with a reloaded listen address.
+	relisten chan struct{}
 	// This interface is passed to the Collect() method. The Collect method uses
 	// this interface to retrieve the latest UniFi measurements and export them.
 	Collector poller.Collect
@@ -141,7 +164,12 @@ func (c *metricsCache) set(m *poller.Metrics, err error) {
 	}
 }
 
-var _ poller.OutputPlugin = &promUnifi{}
+var (
+	_ poller.OutputPlugin        = &promUnifi{}
+	_ poller.ContextOutputPlugin = &promUnifi{}
+	_ poller.KindExporter        = &promUnifi{}
+	_ poller.Reloader            = &promUnifi{}
+)
 
 // Config is the input (config file) data used to initialize this output plugin.
 type Config struct {
@@ -212,7 +240,7 @@ type target struct {
 // init is how this modular code is initialized by the main app.
 // This module adds itself as an output module to the poller core.
 func init() { // nolint: gochecknoinits
-	u := &promUnifi{Config: &Config{}}
+	u := &promUnifi{Config: &Config{}, reload: make(chan *Config, 1)}
 
 	poller.NewOutput(&poller.Output{
 		Name:         PluginName,
@@ -274,6 +302,12 @@ func (u *promUnifi) Enabled() bool {
 // Run creates the collectors and starts the web server up.
 // Should be run in a Go routine. Returns nil if not configured.
 func (u *promUnifi) Run(c poller.Collect) error {
+	return u.RunContext(context.Background(), c)
+}
+
+// RunContext is Run, but it stops the background poll and shuts the web server
+// down once the context is canceled. In-flight scrapes get the shutdown timeout to finish.
+func (u *promUnifi) RunContext(ctx context.Context, c poller.Collect) error {
 	u.Collector = c
 	if u.Config == nil || !u.Enabled() {
 		u.LogDebugf("Prometheus config missing (or disabled), Prometheus HTTP listener disabled!")
@@ -282,22 +316,106 @@ func (u *promUnifi) Run(c poller.Collect) error {
 	}
 
 	u.Logf("Prometheus is enabled")
+	u.setConfigDefaults()
+	u.describe()
 
-	u.Namespace = strings.Trim(strings.ReplaceAll(u.Namespace, "-", "_"), "_")
-	if u.Namespace == "" {
-		u.Namespace = strings.ReplaceAll(poller.AppName, "-", "")
-	}
+	promver.Version = version.Version
+	promver.Revision = version.Revision
+	promver.Branch = version.Branch
 
-	if u.HTTPListen == "" {
-		u.HTTPListen = defaultHTTPListen
+	webserver.UpdateOutput(&webserver.Output{Name: PluginName, Config: u.Config})
+	prometheus.MustRegister(collectors.NewBuildInfoCollector())
+
+	u.cache = &metricsCache{}
+	u.register()
+	// safeRefresh (not refreshCache) because a panic in the initial upstream
+	// fetch must not kill Run() before the HTTP listener starts.
+	u.safeRefresh()
+
+	u.relisten = make(chan struct{}, 1)
+	polling := make(chan struct{})
+
+	go func() {
+		defer close(polling)
+		u.backgroundPoll(ctx)
+	}()
+
+	u.Logf("Prometheus scrape cache enabled, refresh interval: %v", u.Interval.Duration)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(prometheus.DefaultGatherer,
+		promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError},
+	))
+	mux.HandleFunc("/scrape", u.ScrapeHandler)
+	mux.HandleFunc("/", u.DefaultHandler)
+
+	return u.serve(ctx, mux, polling)
+}
+
+// serve runs the web server until the context is canceled, or the background poll
+// stops because a reload disabled the output. When a reload changes the listen
+// address or certificates, the server is shut down and started again with them.
+func (u *promUnifi) serve(ctx context.Context, mux http.Handler, polling <-chan struct{}) error {
+	for {
+		u.mu.RLock()
+		config := u.Config
+		u.mu.RUnlock()
+
+		server := &http.Server{Addr: config.HTTPListen, Handler: mux} // nolint: gosec
+		served := make(chan error, 1)
+
+		go func() { served <- u.listen(server, config) }()
+
+		select {
+		case err := <-served:
+			return err
+		case <-u.relisten:
+		case <-polling:
+		case <-ctx.Done():
+		}
+
+		u.shutdown(server)
+
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+
+		select {
+		case <-polling:
+			return nil
+		case <-ctx.Done():
+			return nil
+		default: // listen again with the reloaded config.
+		}
 	}
+}
 
-	if u.Buffer == 0 {
-		u.Buffer = defaultBuffer
+// listen serves HTTP, or HTTPS when a certificate is configured, until the server is shut down.
+func (u *promUnifi) listen(server *http.Server, config *Config) error {
+	if config.SSLKeyPath == "" && config.SSLCrtPath == "" {
+		u.Logf("Prometheus exported at http://%s/ - namespace: %s", config.HTTPListen, config.Namespace)
+
+		return server.ListenAndServe()
 	}
 
-	u.normalizeInterval()
+	u.Logf("Prometheus exported at https://%s/ - namespace: %s", config.HTTPListen, config.Namespace)
+
+	return server.ListenAndServeTLS(config.SSLCrtPath, config.SSLKeyPath)
+}
+
+// shutdown stops the web server, giving in-flight scrapes the shutdown timeout to finish.
+func (u *promUnifi) shutdown(server *http.Server) {
+	flushCtx, cancel := poller.FlushContext(u.Collector)
+	defer cancel()
 
+	if err := server.Shutdown(flushCtx); err != nil {
+		u.LogErrorf("Shutting down Prometheus web server: %v", err)
+	}
+}
+
+// describe creates the metric descriptions and the poller's own metrics in the
+// configured namespace.
+func (u *promUnifi) describe() {
 	u.Client = descClient(u.Namespace + "_client_")
 	u.Device = descDevice(u.Namespace + "_device_") // stats for all device types.
 	u.UAP = descUAP(u.Namespace + "_device_")
@@ -337,54 +455,64 @@ func (u *promUnifi) Run(c poller.Collect) error {
 	u.DPICategory = descDPICategory(u.Namespace + "_")
 	u.PendingDevice = descPendingDevice(u.Namespace + "_")
 	u.Country = descCountry(u.Namespace + "_")
+	u.ClientInventory = descClientInventory(u.Namespace + "_")
 	u.controllerUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: u.Namespace + "_controller_up",
 		Help: "Whether the most recent background poll of the UniFi controller succeeded (1) or failed (0). " +
 			"Reflects the last poll attempt, not real-time health; pair with " +
 			u.Namespace + "_prometheus_cache_age_seconds for liveness signals when scrapes are served from a stale cache.",
 	}, []string{"source"})
+	u.controllerThrottled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: u.Namespace + "_controller_throttled",
+		Help: "Whether the UniFi controller was rate limited (1) on the most recent background poll, " +
+			"so some or all of its data was skipped, or not (0).",
+	}, []string{"source"})
 	u.refreshFailures = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: u.Namespace + "_prometheus_refresh_failures_total",
 		Help: "Total background metrics refresh failures since process start.",
 	})
+	u.telemetry = descTelemetry(u.Namespace + "_poller_")
+	u.cacheAge = u.cacheAgeGauge()
+}
 
-	mux := http.NewServeMux()
-	promver.Version = version.Version
-	promver.Revision = version.Revision
-	promver.Branch = version.Branch
-
-	webserver.UpdateOutput(&webserver.Output{Name: PluginName, Config: u.Config})
-	prometheus.MustRegister(collectors.NewBuildInfoCollector())
-	prometheus.MustRegister(u.controllerUp)
-	prometheus.MustRegister(u.refreshFailures)
-	prometheus.MustRegister(u)
-
-	u.cache = &metricsCache{}
-	prometheus.MustRegister(u.cacheAgeGauge())
-	// safeRefresh (not refreshCache) because a panic in the initial upstream
-	// fetch must not kill Run() before the HTTP listener starts.
-	u.safeRefresh()
-
-	go u.backgroundPoll()
+// register registers the poller's own metrics, and this collector, with Prometheus.
+// The collector is registered once; its descriptions follow the namespace.
+func (u *promUnifi) register() {
+	prometheus.MustRegister(u.controllerUp, u.controllerThrottled, u.refreshFailures, u.telemetry, u.cacheAge)
 
-	u.Logf("Prometheus scrape cache enabled, refresh interval: %v", u.Interval.Duration)
+	if !u.registered {
+		prometheus.MustRegister(u)
+		u.registered = true
+	}
+}
 
-	mux.Handle("/metrics", promhttp.HandlerFor(prometheus.DefaultGatherer,
-		promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError},
-	))
-	mux.HandleFunc("/scrape", u.ScrapeHandler)
-	mux.HandleFunc("/", u.DefaultHandler)
+// unregister removes the poller's own metrics from Prometheus, before a reload
+// registers them again in a new namespace.
+func (u *promUnifi) unregister() {
+	for _, c := range []prometheus.Collector{
+		u.controllerUp, u.controllerThrottled, u.refreshFailures, u.telemetry, u.cacheAge,
+	} {
+		prometheus.Unregister(c)
+	}
+}
 
-	switch u.SSLKeyPath == "" && u.SSLCrtPath == "" {
-	case true:
-		u.Logf("Prometheus exported at http://%s/ - namespace: %s", u.HTTPListen, u.Namespace)
+// setConfigDefaults cleans up the namespace and fills in the listen address,
+// buffer and interval when they are not configured.
+func (u *promUnifi) setConfigDefaults() {
+	u.Namespace = strings.Trim(strings.ReplaceAll(u.Namespace, "-", "_"), "_")
+	if u.Namespace == "" {
+		u.Namespace = strings.ReplaceAll(poller.AppName, "-", "")
+	}
 
-		return http.ListenAndServe(u.HTTPListen, mux)
-	default:
-		u.Logf("Prometheus exported at https://%s/ - namespace: %s", u.HTTPListen, u.Namespace)
+	if u.HTTPListen == "" {
+		u.HTTPListen = defaultHTTPListen
+	}
 
-		return http.ListenAndServeTLS(u.HTTPListen, u.SSLCrtPath, u.SSLKeyPath, mux)
+	if u.Buffer == 0 {
+		u.Buffer = defaultBuffer
 	}
+
+	u.normalizeInterval()
 }
 
 // normalizeInterval applies defaults and the minimum-interval floor to the
@@ -404,20 +532,137 @@ func (u *promUnifi) normalizeInterval() {
 	}
 }
 
-// backgroundPoll runs forever, refreshing the metrics cache on the configured
-// interval. Returns immediately if the cache is not configured. A panic in
-// upstream collection is logged and the loop continues so one bad payload
-// doesn't silently stop refreshes (operator would only see cache_age climb).
-func (u *promUnifi) backgroundPoll() {
+// Reload hands a new config to the background poll, which applies it between
+// refreshes. Satisfies poller.Reloader.
+func (u *promUnifi) Reload(config any) error {
+	n, ok := config.(*promUnifi)
+	if !ok {
+		return fmt.Errorf("%w: %T", poller.ErrReloadConfigType, config)
+	}
+
+	if n.Config == nil {
+		n.Config = &Config{Disable: true}
+	}
+
+	// Replace a config the background poll has not picked up yet.
+	select {
+	case <-u.reload:
+	default:
+	}
+
+	u.reload <- n.Config
+
+	return nil
+}
+
+// applyConfig swaps in a reloaded config. A new namespace renames every metric, and
+// a new listen address or certificate restarts the web server. It runs on the
+// background poll, and waits for scrapes in progress to finish.
+// Returns false if the reloaded config disables the output.
+func (u *promUnifi) applyConfig(config *Config) bool {
+	if config.Disable {
+		u.Logf("Prometheus output disabled by a config reload")
+
+		return false
+	}
+
+	(&promUnifi{Config: config, Collector: u.Collector}).setConfigDefaults()
+
+	if config.HTTPListen != u.HTTPListen {
+		ln, err := net.Listen("tcp", config.HTTPListen)
+		if err != nil {
+			u.LogErrorf("Reloading Prometheus config, keeping the current one: %v", err)
+
+			return true
+		}
+
+		_ = ln.Close()
+	}
+
+	relisten := config.HTTPListen != u.HTTPListen ||
+		config.SSLCrtPath != u.SSLCrtPath || config.SSLKeyPath != u.SSLKeyPath
+
+	u.mu.Lock()
+
+	if config.Namespace != u.Namespace {
+		u.unregister()
+		u.Config = config
+		u.describe()
+		u.register()
+	}
+
+	u.Config = config
+	u.mu.Unlock()
+
+	if relisten {
+		select {
+		case u.relisten <- struct{}{}:
+		default:
+		}
+	}
+
+	webserver.UpdateOutput(&webserver.Output{Name: PluginName, Config: u.Config})
+	u.Logf("Prometheus config reloaded, interval: %v, namespace: %s, listen: %s",
+		u.Interval.Duration, u.Namespace, u.HTTPListen)
+
+	return true
+}
+
+// backgroundPoll refreshes the metrics cache on the configured interval until
+// the context is canceled, or a reload disables the output. Reloaded configs are
+// applied between refreshes. Returns immediately if the cache is not configured.
+// When the core provides a shared poll scheduler the cache is fed from its
+// snapshots, so Prometheus does not poll the controller separately from the
+// other outputs. Otherwise a panic in upstream collection is logged and the
+// loop continues so one bad payload doesn't silently stop refreshes (operator
+// would only see cache_age climb).
+func (u *promUnifi) backgroundPoll(ctx context.Context) {
 	if u.cache == nil {
 		return
 	}
 
+	if s, ok := u.Collector.(poller.Scheduler); ok {
+		sub := &poller.Subscription{Name: PluginName, Interval: u.Interval.Duration, Metrics: true}
+		snaps := s.Subscribe(sub)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case config := <-u.reload:
+				if !u.applyConfig(config) {
+					return
+				}
+
+				if r, ok := s.(poller.Rescheduler); ok {
+					r.Reschedule(sub, u.Interval.Duration)
+				}
+			case snap, ok := <-snaps:
+				if !ok {
+					return
+				}
+
+				u.storeCache(snap.Metrics, snap.MetricsErr)
+			}
+		}
+	}
+
 	ticker := time.NewTicker(u.Interval.Duration)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		u.safeRefresh()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case config := <-u.reload:
+			if !u.applyConfig(config) {
+				return
+			}
+
+			ticker.Reset(u.Interval.Duration)
+		case <-ticker.C:
+			u.safeRefresh()
+		}
 	}
 }
 
@@ -463,7 +708,12 @@ func (u *promUnifi) refreshCache() {
 		return
 	}
 
-	m, err := u.Collector.Metrics(nil)
+	u.storeCache(u.Collector.Metrics(nil))
+}
+
+// storeCache updates the cache with one upstream poll result and records
+// a failure if there was one.
+func (u *promUnifi) storeCache(m *poller.Metrics, err error) {
 	u.cache.set(m, err)
 
 	if err != nil {
@@ -602,6 +852,9 @@ func (t *target) Describe(ch chan<- *prometheus.Desc) {
 // Describe satisfies the prometheus Collector. This returns all of the
 // metric descriptions that this packages produces.
 func (u *promUnifi) Describe(ch chan<- *prometheus.Desc) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
 	for _, f := range []any{
 		u.Client, u.Device, u.UAP, u.USG, u.USW, u.PDU, u.Site, u.SpeedTest,
 		u.DHCPLease, u.WAN, u.FirewallPolicy, u.Topology, u.PortAnomaly, u.VPNMesh,
@@ -610,7 +863,7 @@ func (u *promUnifi) Describe(ch chan<- *prometheus.Desc) {
 		u.FirewallZone, u.ACLRule, u.VPNServer, u.SiteToSiteTunnel,
 		u.LAG, u.MCLAGDomain, u.SwitchStack, u.DNSPolicy, u.RADIUSProfile,
 		u.TrafficMatchingList, u.HotspotVoucher,
-		u.DPIApplication, u.DPICategory, u.PendingDevice, u.Country,
+		u.DPIApplication, u.DPICategory, u.PendingDevice, u.Country, u.ClientInventory,
 		u.UNASDevice,
 	} {
 		v := reflect.Indirect(reflect.ValueOf(f))
@@ -639,6 +892,9 @@ func (u *promUnifi) Collect(ch chan<- prometheus.Metric) {
 func (u *promUnifi) collect(ch chan<- prometheus.Metric, filter *poller.Filter) {
 	var err error
 
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
 	r := &Report{
 		Config: u.Config,
 		ch:     make(chan []*metric, u.Buffer),
@@ -652,6 +908,7 @@ func (u *promUnifi) collect(ch chan<- prometheus.Metric, filter *poller.Filter)
 	if err != nil {
 		r.error(ch, prometheus.NewInvalidDesc(err), ErrMetricFetchFailed)
 		u.LogErrorf("metric fetch failed: %v", err)
+		poller.RecordWrite(PluginName, time.Since(r.Start), 0, err)
 
 		return
 	}
@@ -665,6 +922,15 @@ func (u *promUnifi) collect(ch chan<- prometheus.Metric, filter *poller.Filter)
 			}
 
 			u.controllerUp.WithLabelValues(cs.Source).Set(val)
+
+			if u.controllerThrottled != nil {
+				throttled := 0.0
+				if cs.Throttled {
+					throttled = 1.0
+				}
+
+				u.controllerThrottled.WithLabelValues(cs.Source).Set(throttled)
+			}
 		}
 	}
 
@@ -712,30 +978,10 @@ func (u *promUnifi) exportMetrics(r report, ch chan<- prometheus.Metric, ourChan
 func (u *promUnifi) loopExports(r report) {
 	m := r.metrics()
 
-	for _, s := range m.RogueAPs {
-		u.switchExport(r, s)
-	}
-
-	for _, s := range m.Sites {
-		u.switchExport(r, s)
-	}
-
 	for _, s := range m.SitesDPI {
 		u.exportSiteDPI(r, s)
 	}
 
-	for _, c := range m.Clients {
-		u.switchExport(r, c)
-	}
-
-	for _, d := range m.Devices {
-		u.switchExport(r, d)
-	}
-
-	for _, st := range m.SpeedTests {
-		u.switchExport(r, st)
-	}
-
 	appTotal := make(totalsDPImap)
 	catTotal := make(totalsDPImap)
 
@@ -743,253 +989,79 @@ func (u *promUnifi) loopExports(r report) {
 		u.exportClientDPI(r, c, appTotal, catTotal)
 	}
 
-	for _, ct := range m.CountryTraffic {
-		u.exportCountryTraffic(r, ct)
-	}
-
-	// Export network-level pool metrics first (once per network)
-	dhcpLeases := make([]*unifi.DHCPLease, 0, len(m.DHCPLeases))
-	for _, lease := range m.DHCPLeases {
-		if l, ok := lease.(*unifi.DHCPLease); ok {
-			dhcpLeases = append(dhcpLeases, l)
-		}
-	}
-
-	if len(dhcpLeases) > 0 {
-		u.exportDHCPNetworkPool(r, dhcpLeases)
-	}
-
-	// Export per-lease metrics
-	for _, lease := range m.DHCPLeases {
-		if l, ok := lease.(*unifi.DHCPLease); ok {
-			u.exportDHCPLease(r, l)
-		}
-	}
-
-	// Export WAN metrics
-	for _, wanConfig := range m.WANConfigs {
-		if w, ok := wanConfig.(*unifi.WANEnrichedConfiguration); ok {
-			u.exportWAN(r, w)
-		}
-	}
-
-	// Export controller sysinfo metrics
-	for _, s := range m.Sysinfos {
-		if sysinfo, ok := s.(*unifi.Sysinfo); ok {
-			u.exportSysinfo(r, sysinfo)
-		}
-	}
-
-	// Export firewall policy metrics
-	firewallPolicies := make([]*unifi.FirewallPolicy, 0, len(m.FirewallPolicies))
-	for _, p := range m.FirewallPolicies {
-		if policy, ok := p.(*unifi.FirewallPolicy); ok {
-			firewallPolicies = append(firewallPolicies, policy)
-		}
-	}
-
-	u.exportFirewallPolicies(r, firewallPolicies)
-
-	for _, t := range m.Topologies {
-		if topo, ok := t.(*unifi.Topology); ok {
-			u.exportTopology(r, topo)
+	for _, v := range u.handlers().Export(r, m.Collections()...) {
+		if u.Collector.Poller().LogUnknownTypes {
+			u.LogDebugf("unknown type: %T", v)
 		}
 	}
 
-	portAnomalies := make([]*unifi.PortAnomaly, 0, len(m.PortAnomalies))
-	for _, a := range m.PortAnomalies {
-		if anomaly, ok := a.(*unifi.PortAnomaly); ok {
-			portAnomalies = append(portAnomalies, anomaly)
-		}
-	}
+	u.exportClientDPItotals(r, appTotal, catTotal)
+	u.exportStaticLabels(r, m.Labels)
+}
 
-	u.exportPortAnomalies(r, portAnomalies)
+// handlers returns the export method for each type this output exports, built on the
+// first call. DPI tables are exported in loopExports, as sites and clients are exported
+// differently. Devices are counted for the report as they are exported.
+func (u *promUnifi) handlers() *poller.Handlers[report] {
+	u.exportersOnce.Do(func() { u.exporters = u.newHandlers() })
 
-	for _, v := range m.VPNMeshes {
-		if mesh, ok := v.(*unifi.MagicSiteToSiteVPN); ok {
-			u.exportVPNMesh(r, mesh)
-		}
-	}
+	return u.exporters
+}
 
+func (u *promUnifi) newHandlers() *poller.Handlers[report] {
+	h := &poller.Handlers[report]{}
+	// Devices.
+	poller.Handle(h, func(r report, d *unifi.UAP) { r.addUAP(); u.exportUAP(r, d) })
+	poller.Handle(h, func(r report, d *unifi.USW) { r.addUSW(); u.exportUSW(r, d) })
+	poller.Handle(h, func(r report, d *unifi.PDU) { r.addPDU(); u.exportPDU(r, d) })
+	poller.Handle(h, func(r report, d *unifi.USG) { r.addUSG(); u.exportUSG(r, d) })
+	poller.Handle(h, func(r report, d *unifi.UXG) { r.addUXG(); u.exportUXG(r, d) })
+	poller.Handle(h, func(r report, d *unifi.UBB) { r.addUBB(); u.exportUBB(r, d) })
+	poller.Handle(h, func(r report, d *unifi.UCI) { r.addUCI(); u.exportUCI(r, d) })
+	poller.Handle(h, func(r report, d *unifi.UDB) { r.addUDB(); u.exportUDB(r, d) })
+	poller.Handle(h, func(r report, d *unifi.UDM) { r.addUDM(); u.exportUDM(r, d) })
+	poller.Handle(h, u.exportUNASDevice)
+	// Metrics.
+	poller.Handle(h, u.exportRogueAP)
+	poller.Handle(h, u.exportSite)
+	poller.Handle(h, u.exportClient)
+	poller.Handle(h, u.exportSpeedTest)
+	poller.Handle(h, func(r report, c *unifi.UsageByCountry) { u.exportCountryTraffic(r, c) })
+	poller.HandleAll(h, u.exportDHCPLeases)
+	poller.Handle(h, u.exportWAN)
+	poller.Handle(h, u.exportSysinfo)
+	poller.HandleAll(h, u.exportFirewallPolicies)
+	poller.Handle(h, u.exportTopology)
+	poller.HandleAll(h, u.exportPortAnomalies)
+	poller.Handle(h, u.exportVPNMesh)
 	// v5.26.0 additions.
-	for _, ds := range m.IntegrationDevStats {
-		if d, ok := ds.(*unifi.IntegrationDeviceStats); ok {
-			u.exportIntegrationDeviceStats(r, d)
-		}
-	}
-
-	for _, ws := range m.WANStatuses {
-		if w, ok := ws.(*unifi.WANStatus); ok {
-			u.exportWANStatus(r, w)
-		}
-	}
-
-	for _, pf := range m.PortForwards {
-		if v, ok := pf.(*unifi.PortForward); ok {
-			u.exportPortForward(r, v)
-		}
-	}
-
-	for _, sc := range m.SSLCertificates {
-		if v, ok := sc.(*unifi.SSLCertificate); ok {
-			u.exportSSLCertificate(r, v)
-		}
-	}
-
-	for _, ud := range m.UPSDevices {
-		if v, ok := ud.(*unifi.UPSDeviceSelector); ok {
-			u.exportUPSDevice(r, v)
-		}
-	}
-
-	for _, nd := range m.UNASDevices {
-		if v, ok := nd.(*unifi.UNASDevice); ok {
-			u.exportUNASDevice(r, v)
-		}
-	}
-
-	for _, wb := range m.WifiBroadcasts {
-		if v, ok := wb.(*unifi.WifiBroadcast); ok {
-			u.exportWifiBroadcast(r, v)
-		}
-	}
-
-	for _, fz := range m.FirewallZones {
-		if v, ok := fz.(*unifi.FirewallZone); ok {
-			u.exportFirewallZone(r, v)
-		}
-	}
-
-	for _, ar := range m.ACLRules {
-		if v, ok := ar.(*unifi.ACLRule); ok {
-			u.exportACLRule(r, v)
-		}
-	}
-
-	for _, vs := range m.VPNServers {
-		if v, ok := vs.(*unifi.VPNServer); ok {
-			u.exportVPNServer(r, v)
-		}
-	}
-
-	for _, st := range m.SiteToSiteTunnels {
-		if v, ok := st.(*unifi.SiteToSiteTunnel); ok {
-			u.exportSiteToSiteTunnel(r, v)
-		}
-	}
-
-	for _, l := range m.LAGs {
-		if v, ok := l.(*unifi.LAG); ok {
-			u.exportLAG(r, v)
-		}
-	}
-
-	for _, md := range m.MCLAGDomains {
-		if v, ok := md.(*unifi.MCLAGDomain); ok {
-			u.exportMCLAGDomain(r, v)
-		}
-	}
-
-	for _, ss := range m.SwitchStacks {
-		if v, ok := ss.(*unifi.SwitchStack); ok {
-			u.exportSwitchStack(r, v)
-		}
-	}
-
-	for _, dp := range m.DNSPolicies {
-		if v, ok := dp.(*unifi.DNSPolicy); ok {
-			u.exportDNSPolicy(r, v)
-		}
-	}
-
-	for _, rp := range m.RADIUSProfiles {
-		if v, ok := rp.(*unifi.RADIUSProfile); ok {
-			u.exportRADIUSProfile(r, v)
-		}
-	}
-
-	for _, tml := range m.TrafficMatchingLists {
-		if v, ok := tml.(*unifi.TrafficMatchingList); ok {
-			u.exportTrafficMatchingList(r, v)
-		}
-	}
-
-	for _, hv := range m.HotspotVouchers {
-		if v, ok := hv.(*unifi.HotspotVoucher); ok {
-			u.exportHotspotVoucher(r, v)
-		}
-	}
-
-	for _, app := range m.DPIApplications {
-		if v, ok := app.(*unifi.DPIApplication); ok {
-			u.exportDPIApplication(r, v)
-		}
-	}
-
-	for _, cat := range m.DPICategories {
-		if v, ok := cat.(*unifi.DPICategory); ok {
-			u.exportDPICategory(r, v)
-		}
-	}
-
-	for _, pd := range m.PendingDevices {
-		if v, ok := pd.(*unifi.PendingDevice); ok {
-			u.exportPendingDevice(r, v)
-		}
-	}
-
-	for _, c := range m.Countries {
-		if v, ok := c.(*unifi.Country); ok {
-			u.exportCountry(r, v)
-		}
-	}
-
-	u.exportClientDPItotals(r, appTotal, catTotal)
+	poller.Handle(h, u.exportIntegrationDeviceStats)
+	poller.Handle(h, u.exportWANStatus)
+	poller.Handle(h, u.exportPortForward)
+	poller.Handle(h, u.exportSSLCertificate)
+	poller.Handle(h, u.exportUPSDevice)
+	poller.Handle(h, u.exportWifiBroadcast)
+	poller.Handle(h, u.exportFirewallZone)
+	poller.Handle(h, u.exportACLRule)
+	poller.Handle(h, u.exportVPNServer)
+	poller.Handle(h, u.exportSiteToSiteTunnel)
+	poller.Handle(h, u.exportLAG)
+	poller.Handle(h, u.exportMCLAGDomain)
+	poller.Handle(h, u.exportSwitchStack)
+	poller.Handle(h, u.exportDNSPolicy)
+	poller.Handle(h, u.exportRADIUSProfile)
+	poller.Handle(h, u.exportTrafficMatchingList)
+	poller.Handle(h, u.exportHotspotVoucher)
+	poller.Handle(h, u.exportDPIApplication)
+	poller.Handle(h, u.exportDPICategory)
+	poller.Handle(h, u.exportPendingDevice)
+	poller.Handle(h, u.exportCountry)
+	poller.Handle(h, u.exportClientInventory)
+
+	return h
 }
 
-func (u *promUnifi) switchExport(r report, v any) {
-	switch v := v.(type) {
-	case *unifi.RogueAP:
-		// r.addRogueAP()
-		u.exportRogueAP(r, v)
-	case *unifi.UAP:
-		r.addUAP()
-		u.exportUAP(r, v)
-	case *unifi.USW:
-		r.addUSW()
-		u.exportUSW(r, v)
-	case *unifi.PDU:
-		r.addPDU()
-		u.exportPDU(r, v)
-	case *unifi.USG:
-		r.addUSG()
-		u.exportUSG(r, v)
-	case *unifi.UXG:
-		r.addUXG()
-		u.exportUXG(r, v)
-	case *unifi.UBB:
-		r.addUBB()
-		u.exportUBB(r, v)
-	case *unifi.UCI:
-		r.addUCI()
-		u.exportUCI(r, v)
-	case *unifi.UDB:
-		r.addUDB()
-		u.exportUDB(r, v)
-	case *unifi.UDM:
-		r.addUDM()
-		u.exportUDM(r, v)
-	case *unifi.Site:
-		u.exportSite(r, v)
-	case *unifi.Client:
-		u.exportClient(r, v)
-	case *unifi.SpeedTestResult:
-		u.exportSpeedTest(r, v)
-	case *unifi.UsageByCountry:
-		u.exportCountryTraffic(r, v)
-	default:
-		if u.Collector.Poller().LogUnknownTypes {
-			u.LogDebugf("unknown type: %T", v)
-		}
-	}
+// ExportedKinds satisfies poller.KindExporter. Prometheus does not export events.
+func (u *promUnifi) ExportedKinds() []poller.MetricKind {
+	return append(u.handlers().Kinds(), poller.KindOf(&unifi.DPITable{}))
 }
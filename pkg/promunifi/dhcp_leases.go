@@ -92,6 +92,15 @@ func (u *promUnifi) exportDHCPLease(r report, l *unifi.DHCPLease) {
 	r.send(metrics)
 }
 
+// exportDHCPLeases exports the pool metrics for each network, then each lease.
+func (u *promUnifi) exportDHCPLeases(r report, leases []*unifi.DHCPLease) {
+	u.exportDHCPNetworkPool(r, leases)
+
+	for _, l := range leases {
+		u.exportDHCPLease(r, l)
+	}
+}
+
 // exportDHCPNetworkPool exports network-level DHCP pool metrics (once per network).
 func (u *promUnifi) exportDHCPNetworkPool(r report, leases []*unifi.DHCPLease) {
 	// Group leases by network_id to export pool metrics once per network
@@ -0,0 +1,26 @@
+package promunifi
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+type clientInventory struct {
+	Clients *prometheus.Desc
+}
+
+func descClientInventory(ns string) *clientInventory {
+	labels := []string{"state", "site_name", "source"}
+
+	return &clientInventory{
+		Clients: prometheus.NewDesc(ns+"client_inventory",
+			"Connected Clients by Inventory State: known, or new (first seen in the last day)", labels, nil),
+	}
+}
+
+func (u *promUnifi) exportClientInventory(r report, s *poller.ClientInventory) {
+	r.send([]*metric{
+		{u.ClientInventory.Clients, gauge, s.Known, []string{"known", s.SiteName, s.SourceName}},
+		{u.ClientInventory.Clients, gauge, s.New, []string{"new", s.SiteName, s.SourceName}},
+	})
+}
@@ -0,0 +1,68 @@
+package promunifi
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// invalidLabelName matches what may not be in a Prometheus label name.
+var invalidLabelName = regexp.MustCompile(`[^a-zA-Z0-9_]`) // nolint: gochecknoglobals
+
+// exportStaticLabels exports the static labels from relabel rules and site overrides
+// as one series per controller and site, valued 1. Prometheus needs every series of a
+// metric to have the same label names, so unlike the other outputs, these are not
+// added to every metric. Join them in queries instead, ie:
+//
+//	unpoller_client_uptime_seconds * on (source, site_name) group_left(zone) unpoller_site_labels
+func (u *promUnifi) exportStaticLabels(r report, labels poller.Labels) {
+	if len(labels) == 0 {
+		return
+	}
+
+	names := []string{}
+
+	for _, l := range labels {
+		for name := range l {
+			if name = labelName(name); name != "source" && name != "site_name" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	desc := prometheus.NewDesc(u.Namespace+"_site_labels",
+		"Static labels of a controller and site, from relabel rules and site overrides (always 1)",
+		append([]string{"source", "site_name"}, names...), nil)
+	metrics := make([]*metric, 0, len(labels))
+
+	for scope, l := range labels {
+		byName := make(map[string]string, len(l))
+		for name, value := range l {
+			byName[labelName(name)] = value
+		}
+
+		values := []string{scope.Source, scope.Site}
+		for _, name := range names {
+			values = append(values, byName[name])
+		}
+
+		metrics = append(metrics, &metric{desc, gauge, 1, values})
+	}
+
+	r.send(metrics)
+}
+
+// labelName makes a static label name a valid Prometheus label name.
+func labelName(name string) string {
+	name = invalidLabelName.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+
+	return name
+}
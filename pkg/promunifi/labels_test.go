@@ -0,0 +1,41 @@
+//nolint:testpackage // white-box: exercises the unexported export path.
+package promunifi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+func TestExportStaticLabels(t *testing.T) {
+	t.Parallel()
+
+	u := &promUnifi{Config: &Config{Namespace: "unpoller"}}
+	r := &fakeReport{}
+	u.exportStaticLabels(r, poller.Labels{
+		{Source: "https://hq", Site: "default"}:  {"env": "prod", "rack-id": "7"},
+		{Source: "https://lab", Site: "default"}: {"env": "lab", "site_name": "ignored"},
+	})
+
+	require.Len(t, r.sent, 2, "one series per controller and site")
+
+	for _, m := range r.sent {
+		assert.Contains(t, m.Desc.String(), `fqName: "unpoller_site_labels"`)
+		assert.Contains(t, m.Desc.String(), `variableLabels: {source,site_name,env,rack_id}`,
+			"every series has every label name, made valid for Prometheus")
+		assert.EqualValues(t, 1, m.Value)
+
+		switch m.Labels[0] {
+		case "https://hq":
+			assert.Equal(t, []string{"https://hq", "default", "prod", "7"}, m.Labels)
+		default:
+			assert.Equal(t, []string{"https://lab", "default", "lab", ""}, m.Labels)
+		}
+	}
+
+	empty := &fakeReport{}
+	u.exportStaticLabels(empty, nil)
+	assert.Empty(t, empty.sent)
+}
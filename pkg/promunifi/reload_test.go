@@ -0,0 +1,51 @@
+//nolint:testpackage // white-box: exercises the unexported reload of the config and descriptions.
+package promunifi
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		running bool
+		renamed bool
+	}{
+		{name: "disabled", config: Config{Namespace: "before", Disable: true}},
+		{name: "settings changed", config: Config{Namespace: "before", ReportErrors: true}, running: true},
+		{name: "namespace changed", config: Config{Namespace: "after"}, running: true, renamed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u := &promUnifi{Config: &Config{Namespace: "before", HTTPListen: defaultHTTPListen}, registered: true}
+			u.setConfigDefaults()
+			u.describe()
+			u.register()
+
+			defer func() { u.unregister() }()
+
+			up, client := u.controllerUp, u.Client
+			config := test.config
+			config.HTTPListen = defaultHTTPListen // the same address is not bound again.
+
+			require.Equal(t, test.running, u.applyConfig(&config))
+
+			if !test.running {
+				assert.Equal(t, "before", u.Namespace, "a disabled output keeps its config")
+
+				return
+			}
+
+			assert.Same(t, &config, u.Config)
+			assert.Equal(t, test.renamed, u.Client != client, "descriptions are made again in a new namespace")
+			assert.Equal(t, test.renamed, !prometheus.Unregister(up), "the old metrics are unregistered")
+			assert.Contains(t, u.controllerUp.WithLabelValues("x").Desc().String(), config.Namespace+"_controller_up")
+		})
+	}
+}
@@ -50,6 +50,8 @@ func (r *Report) metrics() *poller.Metrics {
 func (r *Report) report(c poller.Logger, descs map[*prometheus.Desc]bool) {
 	m := r.Metrics
 
+	poller.RecordWrite(PluginName, r.Elapsed, r.Total, nil)
+
 	c.Logf("UniFi Measurements Exported. Site: %d, Client: %d, "+
 		"UAP: %d, USG/UDM: %d, USW: %d, DPI Site/Client: %d/%d, Countries: %d, Desc: %d, "+
 		"Metric: %d, Bytes: %d, Err: %d, 0s: %d, Req/Total: %v / %v",
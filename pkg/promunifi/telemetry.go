@@ -0,0 +1,91 @@
+package promunifi
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+// telemetry exports the poller's own telemetry: input polls, requests to controllers,
+// and output writes. It reads poller.GetTelemetry on every scrape.
+type telemetry struct {
+	PollCount      *prometheus.Desc
+	PollErrors     *prometheus.Desc
+	PollSeconds    *prometheus.Desc
+	PollLast       *prometheus.Desc
+	RequestCount   *prometheus.Desc
+	RequestErrors  *prometheus.Desc
+	RequestSeconds *prometheus.Desc
+	RequestLast    *prometheus.Desc
+	RequestMax     *prometheus.Desc
+	WriteCount     *prometheus.Desc
+	WriteErrors    *prometheus.Desc
+	WriteSeconds   *prometheus.Desc
+	WriteLast      *prometheus.Desc
+	WritePoints    *prometheus.Desc
+}
+
+var _ prometheus.Collector = &telemetry{}
+
+func descTelemetry(ns string) *telemetry {
+	poll := []string{"input", "kind"}
+	request := []string{"input", "controller", "endpoint"}
+	write := []string{"output"}
+	nd := prometheus.NewDesc
+
+	return &telemetry{
+		PollCount:      nd(ns+"poll_total", "Input Collections", poll, nil),
+		PollErrors:     nd(ns+"poll_errors_total", "Input Collections That Failed", poll, nil),
+		PollSeconds:    nd(ns+"poll_seconds_total", "Input Collection Time", poll, nil),
+		PollLast:       nd(ns+"poll_last_seconds", "Last Input Collection Duration", poll, nil),
+		RequestCount:   nd(ns+"request_total", "Controller API Requests", request, nil),
+		RequestErrors:  nd(ns+"request_errors_total", "Controller API Requests That Failed", request, nil),
+		RequestSeconds: nd(ns+"request_seconds_total", "Controller API Request Time", request, nil),
+		RequestLast:    nd(ns+"request_last_seconds", "Last Controller API Request Duration", request, nil),
+		RequestMax:     nd(ns+"request_max_seconds", "Longest Controller API Request Duration", request, nil),
+		WriteCount:     nd(ns+"write_total", "Output Writes", write, nil),
+		WriteErrors:    nd(ns+"write_errors_total", "Output Writes That Failed", write, nil),
+		WriteSeconds:   nd(ns+"write_seconds_total", "Output Write Time", write, nil),
+		WriteLast:      nd(ns+"write_last_seconds", "Last Output Write Duration", write, nil),
+		WritePoints:    nd(ns+"write_points_total", "Points Written by Outputs", write, nil),
+	}
+}
+
+func (t *telemetry) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		t.PollCount, t.PollErrors, t.PollSeconds, t.PollLast,
+		t.RequestCount, t.RequestErrors, t.RequestSeconds, t.RequestLast, t.RequestMax,
+		t.WriteCount, t.WriteErrors, t.WriteSeconds, t.WriteLast, t.WritePoints,
+	} {
+		ch <- d
+	}
+}
+
+func (t *telemetry) Collect(ch chan<- prometheus.Metric) {
+	counter, gauge := prometheus.CounterValue, prometheus.GaugeValue
+	data := poller.GetTelemetry()
+
+	for _, p := range data.Polls {
+		labels := []string{p.Input, p.Kind}
+		ch <- prometheus.MustNewConstMetric(t.PollCount, counter, float64(p.Count), labels...)
+		ch <- prometheus.MustNewConstMetric(t.PollErrors, counter, float64(p.Errors), labels...)
+		ch <- prometheus.MustNewConstMetric(t.PollSeconds, counter, p.Total.Seconds(), labels...)
+		ch <- prometheus.MustNewConstMetric(t.PollLast, gauge, p.Last.Seconds(), labels...)
+	}
+
+	for _, r := range data.Requests {
+		labels := []string{r.Input, r.Controller, r.Endpoint}
+		ch <- prometheus.MustNewConstMetric(t.RequestCount, counter, float64(r.Count), labels...)
+		ch <- prometheus.MustNewConstMetric(t.RequestErrors, counter, float64(r.Errors), labels...)
+		ch <- prometheus.MustNewConstMetric(t.RequestSeconds, counter, r.Total.Seconds(), labels...)
+		ch <- prometheus.MustNewConstMetric(t.RequestLast, gauge, r.Last.Seconds(), labels...)
+		ch <- prometheus.MustNewConstMetric(t.RequestMax, gauge, r.Max.Seconds(), labels...)
+	}
+
+	for _, w := range data.Writes {
+		ch <- prometheus.MustNewConstMetric(t.WriteCount, counter, float64(w.Count), w.Output)
+		ch <- prometheus.MustNewConstMetric(t.WriteErrors, counter, float64(w.Errors), w.Output)
+		ch <- prometheus.MustNewConstMetric(t.WriteSeconds, counter, w.Total.Seconds(), w.Output)
+		ch <- prometheus.MustNewConstMetric(t.WriteLast, gauge, w.Last.Seconds(), w.Output)
+		ch <- prometheus.MustNewConstMetric(t.WritePoints, counter, float64(w.Points), w.Output)
+	}
+}
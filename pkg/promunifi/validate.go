@@ -0,0 +1,62 @@
+package promunifi
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+)
+
+var (
+	ErrSSLPair        = errors.New("ssl_cert_path and ssl_key_path must both be set to listen with SSL")
+	ErrNegativeBuffer = errors.New("buffer must not be negative")
+	ErrIntervalLow    = fmt.Errorf("interval is below the minimum of %v, and is raised to it", minimumInterval)
+)
+
+var (
+	_ poller.ConfigChecker = &promUnifi{}
+	_ poller.ConfigPrinter = &promUnifi{}
+)
+
+// CheckConfig returns a problem for each configured value that cannot work, or that
+// setConfigDefaults would quietly change. Satisfies poller.ConfigChecker.
+func (u *promUnifi) CheckConfig() []error {
+	if u.Config == nil || !u.Enabled() {
+		return nil
+	}
+
+	var errs []error
+
+	if u.HTTPListen != "" {
+		if _, _, err := net.SplitHostPort(u.HTTPListen); err != nil {
+			errs = append(errs, fmt.Errorf("http_listen: %w", err))
+		}
+	}
+
+	if (u.SSLCrtPath == "") != (u.SSLKeyPath == "") {
+		errs = append(errs, ErrSSLPair)
+	}
+
+	if u.Buffer < 0 {
+		errs = append(errs, fmt.Errorf("%w: %d", ErrNegativeBuffer, u.Buffer))
+	}
+
+	if u.Interval.Duration > 0 && u.Interval.Duration < minimumInterval {
+		errs = append(errs, fmt.Errorf("%w: %v", ErrIntervalLow, u.Interval))
+	}
+
+	return errs
+}
+
+// EffectiveConfig returns the config with defaults applied. There are no secrets in it.
+// Satisfies poller.ConfigPrinter.
+func (u *promUnifi) EffectiveConfig() any {
+	if u.Config == nil {
+		return Config{Disable: true}
+	}
+
+	u.setConfigDefaults()
+
+	return *u.Config
+}
@@ -0,0 +1,118 @@
+package unittest
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// EventStream is a fake controller event websocket. Point a controller's URL at
+// Server; requests for anything but an event stream are proxied to the API server it
+// was made with, so the controller logs in and lists sites as usual.
+type EventStream struct {
+	Server    *httptest.Server
+	connected chan string
+	mu        sync.Mutex
+	conns     map[*websocket.Conn]string
+}
+
+// NewEventStream starts a fake event websocket in front of the API server at api.
+func NewEventStream(api string) *EventStream {
+	target, err := url.Parse(api)
+	if err != nil {
+		panic(err)
+	}
+
+	s := &EventStream{connected: make(chan string, 100), conns: make(map[*websocket.Conn]string)}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}} // nolint: gosec
+	stream := websocket.Handler(s.serve)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/wss/s/") {
+			stream.ServeHTTP(w, r)
+		} else {
+			proxy.ServeHTTP(w, r)
+		}
+	}))
+
+	return s
+}
+
+// serve holds a stream open until the client or Drop closes it.
+func (s *EventStream) serve(conn *websocket.Conn) {
+	// The path is /proxy/network/wss/s/<site>/events or /wss/s/<site>/events.
+	path := conn.Request().URL.Path
+	site := strings.TrimSuffix(path[strings.Index(path, "/wss/s/")+len("/wss/s/"):], "/events")
+
+	s.mu.Lock()
+	s.conns[conn] = site
+	s.mu.Unlock()
+
+	s.connected <- site
+
+	_, _ = io.Copy(io.Discard, conn)
+
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+}
+
+// Connected returns the site name of the next stream that connects,
+// or an empty string if none connects within the timeout.
+func (s *EventStream) Connected(timeout time.Duration) string {
+	select {
+	case site := <-s.connected:
+		return site
+	case <-time.After(timeout):
+		return ""
+	}
+}
+
+// Streams returns how many streams are connected.
+func (s *EventStream) Streams() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+// Send sends a message, like "events" or "alarm", with data to every connected stream.
+func (s *EventStream) Send(message string, data any) error {
+	msg := map[string]any{"meta": map[string]string{"rc": "ok", "message": message}, "data": data}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		if err := websocket.JSON.Send(conn, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Drop closes every connected stream, like a controller restart does.
+func (s *EventStream) Drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close drops every stream and stops the server.
+func (s *EventStream) Close() {
+	s.Drop()
+	s.Server.Close()
+}
@@ -0,0 +1,116 @@
+package unittest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/inputunifi"
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/unittest"
+)
+
+func TestInputStreamsEvents(t *testing.T) {
+	testRig := unittest.NewTestSetup(t)
+	defer testRig.Close()
+
+	stream := unittest.NewEventStream(testRig.MockServer.Server.URL)
+	defer stream.Close()
+
+	testRig.Controller.URL = stream.Server.URL
+	testRig.Controller.EventStream = unittest.PBool(true)
+	testRig.Initialize()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pushed := make(chan *poller.Events, 10)
+	go testRig.InputUnifi.StreamEvents(ctx, func(e *poller.Events) { pushed <- e })
+
+	site := stream.Connected(5 * time.Second)
+	require.NotEmpty(t, site, "the input must open an event stream")
+
+	require.NoError(t, stream.Send("events", []map[string]any{
+		{"key": "EVT_IPS_IpsAlert", "msg": "intrusion", "datetime": time.Now().UTC()},
+	}))
+
+	select {
+	case events := <-pushed:
+		require.Len(t, events.Logs, 1)
+
+		event, ok := events.Logs[0].(*unifi.Event)
+		require.True(t, ok, "streamed events are *unifi.Event")
+		assert.Equal(t, "intrusion", event.Msg)
+		assert.Equal(t, stream.Server.URL, event.SourceName)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for a pushed event")
+	}
+
+	stream.Drop()
+	assert.Equal(t, site, stream.Connected(10*time.Second), "the input must reconnect after the stream drops")
+}
+
+func TestInputStreamFollowsReload(t *testing.T) {
+	testRig := unittest.NewTestSetup(t)
+	defer testRig.Close()
+
+	stream := unittest.NewEventStream(testRig.MockServer.Server.URL)
+	defer stream.Close()
+
+	testRig.Controller.URL = stream.Server.URL
+	testRig.Controller.EventStream = unittest.PBool(true)
+	testRig.Initialize()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pushed := make(chan *poller.Events, 10)
+	go testRig.InputUnifi.StreamEvents(ctx, func(e *poller.Events) { pushed <- e })
+
+	require.NotEmpty(t, stream.Connected(5*time.Second), "the input must open an event stream")
+
+	reload := func(change func(c *inputunifi.Controller)) {
+		t.Helper()
+
+		next := *testRig.Controller
+		next.Unifi = nil
+		change(&next)
+
+		require.NoError(t, testRig.InputUnifi.Reload(&inputunifi.InputUnifi{
+			Config: &inputunifi.Config{Controllers: []*inputunifi.Controller{&next}},
+		}))
+	}
+
+	// The reloaded controller replaces the one the stream was opened with.
+	reload(func(c *inputunifi.Controller) {
+		c.HashPII = unittest.PBool(true)
+		c.PIIKey = "not-a-secret"
+	})
+
+	const mac = "00:11:22:33:44:55"
+
+	require.Eventually(t, func() bool {
+		_ = stream.Send("alarm", []map[string]any{
+			{"key": "EVT_GW_WANTransition", "msg": "Client " + mac + " lost its lease", "datetime": time.Now().UTC()},
+		})
+
+		select {
+		case events := <-pushed:
+			alarm, ok := events.Logs[0].(*unifi.Alarm)
+			return ok && !strings.Contains(alarm.Msg, mac)
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond, "streamed alarms are redacted with the reloaded settings")
+
+	reload(func(c *inputunifi.Controller) { c.EventStream = unittest.PBool(false) })
+	assert.Eventually(t, func() bool { return stream.Streams() == 0 },
+		5*time.Second, 10*time.Millisecond, "a reload that turns off event_stream closes the stream")
+
+	reload(func(c *inputunifi.Controller) { c.EventStream = unittest.PBool(true) })
+	assert.NotEmpty(t, stream.Connected(5*time.Second), "a reload that turns on event_stream opens it again")
+}
@@ -0,0 +1,45 @@
+package unittest_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/unittest"
+)
+
+func TestInputClientInventory(t *testing.T) {
+	dir := t.TempDir()
+	p := poller.New()
+	p.StateDir = dir
+	require.NoError(t, p.LoadState())
+	t.Cleanup(func() { _ = poller.New().LoadState() })
+
+	testRig := unittest.NewTestSetup(t)
+	defer testRig.Close()
+
+	testRig.Controller.ClientInventory = unittest.PBool(true)
+	testRig.Initialize() // there is no inventory file, so the first poll fills it in.
+
+	m, err := testRig.InputUnifi.Metrics(nil)
+	require.NoError(t, err)
+
+	counted := 0
+
+	for _, v := range m.Extra[poller.KindOf(&poller.ClientInventory{})] {
+		site, ok := v.(*poller.ClientInventory)
+		require.True(t, ok)
+		assert.Zero(t, site.Known, "every client was first seen today")
+
+		counted += site.New
+	}
+
+	assert.Positive(t, counted)
+	assert.LessOrEqual(t, counted, len(m.Clients))
+
+	require.NoError(t, testRig.InputUnifi.Close(context.Background()))
+	assert.FileExists(t, filepath.Join(dir, "unpoller-inventory.json"), "the inventory is saved on shutdown")
+}
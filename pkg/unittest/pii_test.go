@@ -0,0 +1,75 @@
+package unittest_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/inputunifi"
+	"github.com/unpoller/unpoller/pkg/unittest"
+)
+
+func TestInputHashPIIWithKey(t *testing.T) {
+	testRig := unittest.NewTestSetup(t)
+	defer testRig.Close()
+
+	testRig.Controller.HashPII = unittest.PBool(true)
+	testRig.Controller.PIIKey = "not-a-secret"
+	testRig.Initialize()
+
+	m, err := testRig.InputUnifi.Metrics(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, m.Clients)
+
+	for _, v := range m.Clients {
+		client, ok := v.(*unifi.Client)
+		require.True(t, ok)
+
+		if client.Mac == "" {
+			continue
+		}
+
+		mac, err := net.ParseMAC(client.Mac)
+		require.NoError(t, err, "pseudonyms of MACs are MACs")
+		assert.Equal(t, byte(0x02), mac[0]&0x03, "pseudonyms are locally administered unicast MACs")
+
+		if client.Hostname != "" {
+			assert.Len(t, client.Hostname, 24)
+		}
+	}
+}
+
+func TestInputPIIKeyMustBeRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		piiHash string
+		piiKey  string
+		err     error
+	}{
+		{name: "unreadable key", piiKey: "file:///nonexistent/unpoller/pii_key", err: inputunifi.ErrPIIKeyRead},
+		{name: "hmac without key", piiHash: inputunifi.PIIHashHMAC, err: inputunifi.ErrPIIKeyMissing},
+		{name: "md5 ignores key", piiHash: inputunifi.PIIHashMD5, piiKey: "file:///nonexistent/unpoller/pii_key"},
+		{name: "no key is md5", piiKey: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testRig := unittest.NewTestSetup(t)
+			defer testRig.Close()
+
+			testRig.Controller.HashPII = unittest.PBool(true)
+			testRig.Controller.PIIHash = test.piiHash
+			testRig.Controller.PIIKey = test.piiKey
+
+			err := testRig.InputUnifi.Initialize(testRig.Collector.Logger)
+			if test.err == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, test.err, "hashing without the selected key is a startup error")
+		})
+	}
+}
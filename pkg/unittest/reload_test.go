@@ -0,0 +1,85 @@
+package unittest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unifi/v5/mocks"
+	"github.com/unpoller/unpoller/pkg/inputunifi"
+	"github.com/unpoller/unpoller/pkg/unittest"
+)
+
+func TestInputReloadKeepsUnchangedControllers(t *testing.T) {
+	other := mocks.NewMockHTTPTestServer()
+	defer other.Server.Close()
+
+	tests := []struct {
+		name string
+		// next returns the controllers to reload, made from the running one.
+		next  func(running inputunifi.Controller) []*inputunifi.Controller
+		added []string // URLs of the controllers after the first one.
+		kept  bool     // the running *Controller is still polled.
+	}{
+		{
+			name: "unchanged",
+			next: func(c inputunifi.Controller) []*inputunifi.Controller { return []*inputunifi.Controller{&c} },
+			kept: true,
+		},
+		{
+			name: "sites changed",
+			next: func(c inputunifi.Controller) []*inputunifi.Controller {
+				c.Sites = []string{"default"}
+				return []*inputunifi.Controller{&c}
+			},
+		},
+		{
+			name: "controller added",
+			next: func(c inputunifi.Controller) []*inputunifi.Controller {
+				added := c
+				added.URL = other.Server.URL
+
+				return []*inputunifi.Controller{&c, &added}
+			},
+			added: []string{other.Server.URL},
+			kept:  true,
+		},
+		{
+			name: "controller removed",
+			next: func(c inputunifi.Controller) []*inputunifi.Controller {
+				c.URL = other.Server.URL
+				return []*inputunifi.Controller{&c}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testRig := unittest.NewTestSetup(t)
+			defer testRig.Close()
+
+			testRig.Initialize()
+
+			running := testRig.InputUnifi.Controllers[0]
+			next := *running
+			next.Unifi = nil
+
+			controllers := test.next(next)
+			require.NoError(t, testRig.InputUnifi.Reload(&inputunifi.InputUnifi{
+				Config: &inputunifi.Config{Controllers: controllers},
+			}))
+
+			reloaded := testRig.InputUnifi.Controllers
+			require.Len(t, reloaded, len(controllers))
+
+			var added []string
+			for _, c := range reloaded[1:] {
+				added = append(added, c.URL)
+			}
+
+			assert.Equal(t, test.kept, reloaded[0] == running, "only an unchanged controller is kept")
+			assert.Equal(t, controllers[0].URL, reloaded[0].URL)
+			assert.Equal(t, test.added, added)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package unittest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unpoller/pkg/poller"
+	"github.com/unpoller/unpoller/pkg/unittest"
+	"golift.io/cnfg"
+)
+
+// requests returns how many times an endpoint was requested from a controller.
+func requests(controller, endpoint string) uint64 {
+	for _, r := range poller.GetTelemetry().Requests {
+		if r.Controller == controller && r.Endpoint == endpoint {
+			return r.Count
+		}
+	}
+
+	return 0
+}
+
+func TestInputCollectionIntervals(t *testing.T) {
+	testRig := unittest.NewTestSetup(t)
+	defer testRig.Close()
+
+	testRig.Controller.Intervals = map[string]cnfg.Duration{"devices": {Duration: time.Hour}}
+	testRig.Initialize()
+
+	first, err := testRig.InputUnifi.Metrics(nil)
+	require.NoError(t, err)
+
+	second, err := testRig.InputUnifi.Metrics(nil)
+	require.NoError(t, err)
+
+	url := testRig.Controller.URL
+	assert.Equal(t, uint64(1), requests(url, "GetDevices"), "devices are reused for an hour")
+	assert.Greater(t, requests(url, "GetClients"), uint64(1), "clients have no interval")
+
+	require.NotEmpty(t, second.Devices, "reused devices are still returned")
+	require.Len(t, second.Devices, len(first.Devices))
+	assert.NotSame(t, first.Devices[0], second.Devices[0], "each poll gets its own copy")
+	assert.Equal(t, first.Devices[0], second.Devices[0])
+}
@@ -0,0 +1,53 @@
+package unittest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unpoller/unifi/v5"
+	"github.com/unpoller/unpoller/pkg/inputunifi"
+	"github.com/unpoller/unpoller/pkg/unittest"
+)
+
+func TestInputSiteOverrides(t *testing.T) {
+	testRig := unittest.NewTestSetup(t)
+	defer testRig.Close()
+
+	testRig.Initialize()
+
+	first, err := testRig.InputUnifi.Metrics(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, first.Sites)
+	require.NotEmpty(t, first.Clients)
+
+	c := testRig.InputUnifi.Controllers[0]
+	c.SiteOverrides = map[string]*inputunifi.SiteOverride{}
+
+	for _, v := range first.Sites {
+		site, ok := v.(*unifi.Site)
+		require.True(t, ok)
+
+		c.SiteOverrides[site.Name] = &inputunifi.SiteOverride{
+			DropPII: unittest.PBool(true),
+			SaveDPI: unittest.PBool(false),
+			Labels:  map[string]string{"zone": "guest"},
+		}
+	}
+
+	m, err := testRig.InputUnifi.Metrics(nil)
+	require.NoError(t, err)
+	assert.Empty(t, m.ClientsDPI, "every site turned DPI off")
+
+	for _, v := range m.Clients {
+		client, ok := v.(*unifi.Client)
+		require.True(t, ok)
+		assert.Empty(t, client.Mac, "every site drops PII")
+	}
+
+	for _, v := range m.Sites {
+		site, ok := v.(*unifi.Site)
+		require.True(t, ok)
+		assert.Equal(t, "guest", m.Labels.For(c.URL, site.SiteName)["zone"])
+	}
+}
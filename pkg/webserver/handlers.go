@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/unpoller/unpoller/pkg/poller"
 )
 
 /* This file has the methods that pass out actual content. */
@@ -57,6 +58,24 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Returns the poller's own poll, request and write statistics: /api/v1/telemetry.
+func (s *Server) handleTelemetry(w http.ResponseWriter, r *http.Request) {
+	t := poller.GetTelemetry()
+
+	switch mux.Vars(r)["sub"] {
+	case "":
+		s.handleJSON(w, t)
+	case "polls":
+		s.handleJSON(w, t.Polls)
+	case "requests":
+		s.handleJSON(w, t.Requests)
+	case "writes":
+		s.handleJSON(w, t.Writes)
+	default:
+		s.handleMissing(w, r)
+	}
+}
+
 // Returns an output plugin's data: /api/v1/output/{output}.
 func (s *Server) handleOutput(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -128,6 +147,8 @@ func (s *Server) handleInput(w http.ResponseWriter, r *http.Request) { //nolint:
 		s.handleJSON(w, c.Devices.Filter(val))
 	case "clients":
 		s.handleJSON(w, c.Clients.Filter(val))
+	case "inventory":
+		s.handleJSON(w, c.Inventory.Filter(val))
 	case "counters":
 		if val != "" {
 			s.handleJSON(w, map[string]int64{val: c.Counter[val]})
@@ -126,6 +126,10 @@ func (w *webPlugins) updateInput(config *Input) {
 		input.Devices = config.Devices
 	}
 
+	if config.Inventory != nil {
+		input.Inventory = config.Inventory
+	}
+
 	if config.Config != nil {
 		input.Config = config.Config
 	}
@@ -1,6 +1,7 @@
 package webserver
 
 import (
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +16,7 @@ type Input struct {
 	Events       Events
 	Devices      Devices
 	Clients      Clients
+	Inventory    Inventory
 	Config       any
 	Counter      map[string]int64
 	sync.RWMutex // Locks this data structure.
@@ -147,3 +149,30 @@ func (c Clients) Filter(siteid string) (clients []*Client) {
 
 	return clients
 }
+
+// Inventory is every client an input has ever seen.
+type Inventory []*InventoryClient
+
+// InventoryClient is a client in an input's inventory, and where it has been seen.
+type InventoryClient struct {
+	MAC       string    `json:"mac"`
+	OUI       string    `json:"oui"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Sources   []string  `json:"sources"`
+	Sites     []string  `json:"sites"`
+	Networks  []string  `json:"networks"`
+	APs       []string  `json:"aps"`
+	Hostnames []string  `json:"hostnames"`
+}
+
+// Filter returns the clients seen at a site, or the client with a MAC.
+func (c Inventory) Filter(val string) (clients []*InventoryClient) {
+	for _, n := range c {
+		if val == "" || n.MAC == val || slices.Contains(n.Sites, val) {
+			clients = append(clients, n)
+		}
+	}
+
+	return clients
+}
@@ -2,6 +2,7 @@
 package webserver
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -37,6 +38,7 @@ type Config struct {
 }
 
 // accounts stores a map of usernames and password hashes.
+// A hash may be a secret reference, like file:///etc/unpoller/admin.hash.
 type accounts map[string]string
 
 // Server is the main library struct/data.
@@ -46,9 +48,13 @@ type Server struct {
 	plugins *webPlugins
 	Collect poller.Collect
 	start   time.Time
+	hashes  accounts // Accounts with secret references resolved.
 }
 
-var _ poller.OutputPlugin = &Server{}
+var (
+	_ poller.OutputPlugin        = &Server{}
+	_ poller.ContextOutputPlugin = &Server{}
+)
 
 // init is how this modular code is initialized by the main app.
 // This module adds itself as an output module to the poller core.
@@ -81,6 +87,11 @@ func (s *Server) Enabled() bool {
 
 // Run starts the server and gets things going.
 func (s *Server) Run(c poller.Collect) error {
+	return s.RunContext(context.Background(), c)
+}
+
+// RunContext is Run, but it shuts the server down once the context is canceled.
+func (s *Server) RunContext(ctx context.Context, c poller.Collect) error {
 	s.Collect = c
 	if s.Config == nil || s.Port == 0 || s.HTMLPath == "" || !s.Enabled() {
 		s.LogDebugf("Internal web server disabled!")
@@ -94,9 +105,27 @@ func (s *Server) Run(c poller.Collect) error {
 		return fmt.Errorf("problem with HTML path: %w", err)
 	}
 
+	var err error
+	if s.hashes, err = s.Accounts.resolve(); err != nil {
+		return err
+	}
+
 	UpdateOutput(&Output{Name: PluginName, Config: s.Config})
 
-	return s.Start()
+	s.server = s.newServer()
+
+	go func() {
+		<-ctx.Done()
+
+		flushCtx, cancel := poller.FlushContext(c)
+		defer cancel()
+
+		if err := s.server.Shutdown(flushCtx); err != nil {
+			s.LogErrorf("Shutting down web server: %v", err)
+		}
+	}()
+
+	return s.serve()
 }
 
 func (s *Server) DebugOutput() (bool, error) {
@@ -136,14 +165,27 @@ func (s *Server) DebugOutput() (bool, error) {
 
 // Start gets the web server going.
 func (s *Server) Start() (err error) {
-	s.server = &http.Server{
+	if s.hashes, err = s.Accounts.resolve(); err != nil {
+		return err
+	}
+
+	s.server = s.newServer()
+
+	return s.serve()
+}
+
+func (s *Server) newServer() *http.Server {
+	return &http.Server{
 		Addr:         "0.0.0.0:" + strconv.Itoa(int(s.Port)),
 		WriteTimeout: time.Minute,
 		ReadTimeout:  time.Minute,
 		IdleTimeout:  time.Minute,
 		Handler:      s.newRouter(), // *mux.Router
 	}
+}
 
+// serve listens on the server Start or RunContext created, until it is shut down.
+func (s *Server) serve() (err error) {
 	if s.SSLCrtPath == "" || s.SSLKeyPath == "" {
 		s.Logf("Web server starting without SSL. Listening on HTTP port %d", s.Port)
 		err = s.server.ListenAndServe()
@@ -177,11 +219,28 @@ func (s *Server) newRouter() *mux.Router {
 	router.HandleFunc("/api/v1/output/{output}", s.basicAuth(s.handleOutput)).Methods("GET")
 	router.HandleFunc("/api/v1/output/{output}/{sub}", s.basicAuth(s.handleOutput)).Methods("GET")
 	router.HandleFunc("/api/v1/output/{output}/{sub}/{value}", s.basicAuth(s.handleOutput)).Methods("GET", "POST")
+	router.HandleFunc("/api/v1/telemetry", s.basicAuth(s.handleTelemetry)).Methods("GET")
+	router.HandleFunc("/api/v1/telemetry/{sub}", s.basicAuth(s.handleTelemetry)).Methods("GET")
 	router.PathPrefix("/").Handler(s.basicAuth(s.handleMissing)).Methods("GET", "POST", "PUT") // 404 everything.
 
 	return router
 }
 
+// resolve returns the accounts with their secret references replaced by the hashes
+// they point to.
+func (a accounts) resolve() (accounts, error) {
+	hashes := make(accounts, len(a))
+
+	for user, hash := range a {
+		var err error
+		if hashes[user], err = poller.ResolveSecret(hash); err != nil {
+			return nil, fmt.Errorf("web server account %s: %w", user, err)
+		}
+	}
+
+	return hashes, nil
+}
+
 // PasswordIsCorrect returns true if the provided password matches a user's account.
 func (a accounts) PasswordIsCorrect(user, pass string, ok bool) bool {
 	if len(a) == 0 {
@@ -22,7 +22,7 @@ const (
 // Called on nearly every request.
 func (s *Server) basicAuth(handler http.HandlerFunc) http.HandlerFunc {
 	return s.handleLog(func(w http.ResponseWriter, r *http.Request) {
-		if s.Accounts.PasswordIsCorrect(r.BasicAuth()) {
+		if s.hashes.PasswordIsCorrect(r.BasicAuth()) {
 			handler(w, r)
 
 			return
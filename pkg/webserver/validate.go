@@ -0,0 +1,76 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/unpoller/unpoller/pkg/poller"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrSSLPair     = errors.New("ssl_cert_path and ssl_key_path must both be set to serve with SSL")
+	ErrPortRange   = errors.New("port must be between 1 and 65535")
+	ErrAccountHash = errors.New("account password is not a bcrypt hash")
+)
+
+var (
+	_ poller.ConfigChecker = &Server{}
+	_ poller.ConfigPrinter = &Server{}
+)
+
+// CheckConfig returns a problem for each configured value that cannot work.
+// Satisfies poller.ConfigChecker.
+func (s *Server) CheckConfig() []error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	var errs []error
+
+	if s.Port == 0 || s.Port > math.MaxUint16 {
+		errs = append(errs, fmt.Errorf("%w: %d", ErrPortRange, s.Port))
+	}
+
+	if (s.SSLCrtPath == "") != (s.SSLKeyPath == "") {
+		errs = append(errs, ErrSSLPair)
+	}
+
+	for _, user := range slices.Sorted(maps.Keys(s.Accounts)) {
+		hash := s.Accounts[user]
+		if poller.IsSecretRef(hash) {
+			if err := poller.CheckSecret(hash); err != nil {
+				errs = append(errs, fmt.Errorf("account %s: %w", user, err))
+			}
+
+			continue // The hash it points to is not read here, so it cannot be checked.
+		}
+
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrAccountHash, user))
+		}
+	}
+
+	return errs
+}
+
+// EffectiveConfig returns the config with the account password hashes redacted.
+// Satisfies poller.ConfigPrinter.
+func (s *Server) EffectiveConfig() any {
+	if s.Config == nil {
+		return Config{}
+	}
+
+	fake := *s.Config
+	fake.Accounts = make(accounts, len(s.Accounts))
+
+	for user, hash := range s.Accounts {
+		fake.Accounts[user] = strconv.FormatBool(hash != "")
+	}
+
+	return fake
+}